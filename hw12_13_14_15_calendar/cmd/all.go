@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/changes"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+// allCmd runs the servers, the scheduler and the sender in one process.
+var allCmd = &cobra.Command{
+	Use:   "all",
+	Short: "Start http and grpc servers, scheduler and sender in one process",
+	Run: func(cmd *cobra.Command, args []string) {
+		// the failure is logged by runAll, it returns to clean up before the exit
+		if err := runAll(cmd.Flag("config").Value.String()); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+func runAll(configPath string) error {
+	config := requireConfig(configPath)
+	logg, cleanupLogger := requireLogger(config.Logger)
+	defer cleanupLogger()
+
+	cleanupTracing := requireTracing(config.Tracing, "calendar")
+	defer cleanupTracing()
+
+	// the metrics server answers the probes from the start to the end of the process
+	checker := health.New()
+	c := newComponents(logg)
+	stopMetrics := c.start(component{"metrics server", func(ctx context.Context) error {
+		return serveMetrics(ctx, config.Metrics, logg, checker)
+	}})
+	defer stopMetrics()
+
+	requireMigrations(config.Storage, checker)
+
+	eventRepo, cleanupEventRepo := requireEventStorage(config.Storage, checker)
+	defer cleanupEventRepo()
+
+	userRepo, cleanupUserRepo := requireUserStorage(config.Storage)
+	defer cleanupUserRepo()
+
+	idempotencyRepo, cleanupIdempotencyRepo := requireIdempotencyStorage(config.Storage)
+	defer cleanupIdempotencyRepo()
+
+	keys, err := newSenderKeys(config.Queue, idempotencyRepo, logg)
+	if err != nil {
+		logg.Error("all create keys: " + err.Error())
+		return err
+	}
+
+	n, cleanupNotifier := requireNotifier(config.Notifier)
+	defer cleanupNotifier()
+
+	q := requireQueue(config.Queue)
+	checker.AddReadiness("queue", q.Ping)
+	defer func() {
+		if err := q.Close(); err != nil {
+			logg.Error("all close queue: " + err.Error())
+		}
+	}()
+
+	// the consumer binds the queue before the scheduler publishes, the in-process queue drops unbound messages
+	consumer, err := createSenderConsumer(q, config.Queue)
+	if err != nil {
+		logg.Error("all create consumer: " + err.Error())
+		return err
+	}
+
+	producer, err := q.CreateProducer(config.Queue.Exchange)
+	if err != nil {
+		logg.Error("all create producer: " + err.Error())
+		return err
+	}
+
+	events := app.NewEventUseCase(eventRepo, userRepo, changes.New(changes.DefaultBuffer))
+	ical := app.NewICalendarUseCase(events, eventRepo)
+	users := app.NewUserUseCase(userRepo)
+
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	// the stages are started from the sender and stopped from the servers,
+	// so the messages published by the scheduler and the requests in handling are not lost
+	stopSender := c.start(component{"sender", func(ctx context.Context) error {
+		return runSender(ctx, logg, n, consumer, keys)
+	}})
+	stopScheduler := c.start(component{"scheduler", func(ctx context.Context) error {
+		return runScheduler(ctx, config.Scheduler, logg, checker, eventRepo, producer)
+	}})
+	stopServers := c.start(component{"http server", func(ctx context.Context) error {
+		return serveHTTP(ctx, config.HTTP, logg, checker, events, ical, users)
+	}}, component{"grpc server", func(ctx context.Context) error {
+		return serveGRPC(ctx, config.GRPC, logg, checker, events, ical, users)
+	}})
+	checker.SetState(health.StateServing)
+
+	select {
+	case <-ctx.Done():
+	case err = <-c.failed:
+	}
+
+	logg.Info("stopping calendar...")
+	checker.SetState(health.StateStopping)
+	stopServers()
+	stopScheduler()
+	stopSender()
+
+	return err
+}
+
+type component struct {
+	name string
+	// run runs the component until the ctx is done
+	run func(ctx context.Context) error
+}
+
+// components runs the components of the process and reports their state.
+type components struct {
+	logg   logger.Logger
+	failed chan error
+}
+
+func newComponents(logg logger.Logger) *components {
+	return &components{
+		logg: logg,
+		// the channel fits a report of every component, so the reports never block
+		failed: make(chan error, 8),
+	}
+}
+
+// start runs the stage of the components and returns the function that stops it and waits for the components.
+func (c *components) start(stage ...component) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(len(stage))
+
+	for _, comp := range stage {
+		comp := comp
+
+		go func() {
+			defer wg.Done()
+
+			c.logg.Info(comp.name+" is up", "Component", comp.name)
+			if err := comp.run(ctx); err != nil {
+				c.logg.Error(comp.name+" is down: "+err.Error(), "Component", comp.name)
+				c.failed <- fmt.Errorf("%s: %w", comp.name, err)
+
+				return
+			}
+			c.logg.Info(comp.name+" is stopped", "Component", comp.name)
+		}()
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(allCmd)
+}
@@ -6,10 +6,17 @@ import (
 	"os"
 	"time"
 
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/notifier"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	filequeue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue/file"
+	memoryqueue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue/memory"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 	memorystorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 )
 
 type CleanUpFunc = func()
@@ -38,9 +45,61 @@ func requireLogger(config LoggerConf) (logger.Logger, CleanUpFunc) {
 	}
 }
 
-func requireEventStorage(config StorageConf) (storage.EventStorage, CleanUpFunc) {
+// requireTracing installs the tracer provider of the service, the cleanup flushes the recorded spans.
+func requireTracing(config TracingConf, service string) CleanUpFunc {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shutdown, err := tracing.Setup(ctx, tracing.Options{
+		ServiceName: service,
+		Exporter:    config.Exporter,
+		Endpoint:    config.Endpoint,
+		Insecure:    config.Insecure,
+		SampleRatio: config.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalln("cannot set up tracing:", err)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = shutdown(ctx)
+	}
+}
+
+// requireMigrations runs the migrations when the config asks for it, the readiness fails while they are running.
+func requireMigrations(config StorageConf, checker *health.Checker) {
+	if config.Driver != "db" || !config.Migrate {
+		return
+	}
+
+	state := checker.State()
+	checker.SetState(health.StateMigrating)
+	defer checker.SetState(state)
+
+	// the overlapping events are resolved only by the migrate command on request
+	if err := runMigrations(config, false); err != nil {
+		log.Fatalln("cannot run migrations:", err)
+	}
+}
+
+// serveUntilDone marks the command serving, the readiness fails as soon as the ctx is done,
+// so the command is taken out of the rotation while it finishes the work in progress.
+func serveUntilDone(ctx context.Context, checker *health.Checker) {
+	checker.SetState(health.StateServing)
+
+	go func() {
+		<-ctx.Done()
+		checker.SetState(health.StateStopping)
+	}()
+}
+
+// requireEventStorage creates the event storage, the DB connectivity is added to the readiness of the checker.
+func requireEventStorage(config StorageConf, checker *health.Checker) (storage.EventStorage, CleanUpFunc) {
 	if config.Driver == "memory" {
-		return memorystorage.New(), func() {}
+		return tracing.NewEventStorage(metrics.NewEventStorage(memorystorage.New())), func() {}
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -51,7 +110,113 @@ func requireEventStorage(config StorageConf) (storage.EventStorage, CleanUpFunc)
 	}
 	defer cancel()
 
+	checker.AddReadiness("storage", sqlStorage.Ping)
+
+	return tracing.NewEventStorage(metrics.NewEventStorage(sqlStorage)), func() {
+		_ = sqlStorage.Close()
+	}
+}
+
+func requireUserStorage(config StorageConf) (storage.UserStorage, CleanUpFunc) {
+	if config.Driver == "memory" {
+		return memorystorage.NewUserStorage(), func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	sqlStorage := sqlstorage.NewUserStorage()
+	if err := sqlStorage.Connect(ctx, config.dbConnectionString()); err != nil {
+		log.Fatalln("cannot create user repository:", err)
+	}
+	defer cancel()
+
 	return sqlStorage, func() {
 		_ = sqlStorage.Close()
 	}
 }
+
+// requireIdempotencyStorage creates the storage of the handled message keys, the memory one
+// remembers them until the process exits.
+func requireIdempotencyStorage(config StorageConf) (storage.IdempotencyStorage, CleanUpFunc) {
+	if config.Driver == "memory" {
+		return memorystorage.NewIdempotencyStorage(), func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	sqlStorage := sqlstorage.NewIdempotencyStorage()
+	if err := sqlStorage.Connect(ctx, config.dbConnectionString()); err != nil {
+		log.Fatalln("cannot create idempotency repository:", err)
+	}
+	defer cancel()
+
+	return sqlStorage, func() {
+		_ = sqlStorage.Close()
+	}
+}
+
+func requireNotifier(config NotifierConf) (notifier.Notifier, CleanUpFunc) {
+	logNotifier, closeLog, err := notifier.NewFile(config.Log.Target)
+	if err != nil {
+		log.Fatalln("cannot create log notifier:", err)
+	}
+
+	notifiers := map[string]notifier.Notifier{
+		string(storage.ChannelLog): logNotifier,
+	}
+
+	if config.Email.Host != "" {
+		timeout, err := time.ParseDuration(config.Email.Timeout)
+		if err != nil {
+			log.Fatalln("cannot parse email timeout:", err)
+		}
+
+		notifiers[string(storage.ChannelEmail)] = notifier.NewEmail(notifier.EmailConfig{
+			Host:     config.Email.Host,
+			Port:     config.Email.Port,
+			User:     config.Email.User,
+			Password: config.Email.Password,
+			From:     config.Email.From,
+			To:       config.Email.To,
+			Timeout:  timeout,
+		})
+	}
+
+	if config.Webhook.URL != "" {
+		timeout, err := time.ParseDuration(config.Webhook.Timeout)
+		if err != nil {
+			log.Fatalln("cannot parse webhook timeout:", err)
+		}
+
+		notifiers[string(storage.ChannelWebhook)] = notifier.NewWebhook(
+			config.Webhook.URL, config.Webhook.Secret, timeout)
+	}
+
+	return notifier.NewRouter(notifiers, logNotifier), func() {
+		_ = closeLog()
+	}
+}
+
+func requireQueue(config QueueConf) queue.Queue {
+	var q queue.Queue
+
+	switch config.Driver {
+	case "memory":
+		q = memoryqueue.New()
+	case "file":
+		q = filequeue.New(config.Path)
+	default:
+		reconnect, err := config.Reconnect.Policy()
+		if err != nil {
+			log.Fatalln("cannot parse queue reconnect policy:", err)
+		}
+
+		q = queue.New(config.URI(), reconnect)
+	}
+
+	if err := q.Connect(); err != nil {
+		log.Fatalln("cannot connect to queue:", err)
+	}
+
+	return q
+}
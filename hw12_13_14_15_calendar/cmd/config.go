@@ -4,18 +4,40 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"time"
 
 	validator "github.com/go-playground/validator/v10"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	HTTP      HTTPConf
 	GRPC      GRPCConf
+	Metrics   MetricsConf
+	Tracing   TracingConf
 	Logger    LoggerConf
 	Storage   StorageConf
 	Queue     QueueConf
 	Scheduler SchedulerConf
+	Notifier  NotifierConf
+}
+
+// MetricsConf is the address of the metrics endpoint every long-running command serves.
+type MetricsConf struct {
+	Host string `validate:"required"`
+	Port string `validate:"required"`
+}
+
+// TracingConf configures the export of the spans, the none exporter only propagates the trace context.
+type TracingConf struct {
+	Exporter string `validate:"required,oneof=none stdout otlp"`
+	// Endpoint is the host:port of the OTLP gRPC receiver
+	Endpoint    string `validate:"required_if=Exporter otlp"`
+	Insecure    bool
+	SampleRatio float64 `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
 }
 
 type LoggerConf struct {
@@ -41,19 +63,83 @@ type StorageConf struct {
 	DBUser     string `mapstructure:"db_user" validate:"required_if=Driver db"`
 	DBPassword string `mapstructure:"db_password" validate:"required_if=Driver db"`
 	DBName     string `mapstructure:"db_name" validate:"required_if=Driver db"`
+	// Migrate runs the migrations on the start of the command, the readiness fails until they are done
+	Migrate bool
 }
 
 type QueueConf struct {
-	User     string `validate:"required"`
-	Password string `validate:"required"`
-	Host     string `validate:"required"`
-	Port     string `validate:"required"`
+	// Driver memory keeps the messages in the process, file keeps them in the log at Path
+	Driver   string `validate:"required,oneof=amqp memory file"`
+	Path     string `validate:"required_if=Driver file"`
+	User     string `validate:"required_if=Driver amqp"`
+	Password string `validate:"required_if=Driver amqp"`
+	Host     string `validate:"required_if=Driver amqp"`
+	Port     string `validate:"required_if=Driver amqp"`
 	Exchange string `validate:"required"`
+	// Prefetch and Workers configure the consumer of the sender
+	Prefetch int `validate:"gte=0"`
+	Workers  int `validate:"gte=1"`
+	// DedupTTL is how long the sender remembers the idempotency keys of the handled messages in the storage,
+	// the memory storage forgets them on the restart of the sender
+	DedupTTL string `mapstructure:"dedup_ttl" validate:"required"`
+	Retry    QueueRetryConf
+	// Reconnect with zero retries reconnects until the service stops
+	Reconnect QueueRetryConf
+}
+
+// QueueRetryConf configures the retries with the exponential backoff, they are the retries
+// of the failed messages of the sender and the reconnections to the queue.
+type QueueRetryConf struct {
+	Retries  int    `validate:"gte=0"`
+	MinDelay string `mapstructure:"min_delay" validate:"required"`
+	MaxDelay string `mapstructure:"max_delay" validate:"required"`
 }
 
 type SchedulerConf struct {
 	SendNotification string `mapstructure:"send_notification" validate:"required"`
+	SendInvitations  string `mapstructure:"send_invitations" validate:"required"`
+	RelayOutbox      string `mapstructure:"relay_outbox" validate:"required"`
 	DeleteOld        string `mapstructure:"delete_old" validate:"required"`
+	PurgeTrash       string `mapstructure:"purge_trash" validate:"required"`
+	Retention        RetentionConf
+}
+
+// RetentionConf is how long the events are kept, the durations are in the time.ParseDuration format.
+type RetentionConf struct {
+	// Events is the time the ended events are kept for, Users overrides it by the user id.
+	Events string `validate:"required"`
+	Users  map[string]string
+	// Trash is the time the deleted events are kept in the trash for.
+	Trash string `validate:"required"`
+}
+
+// NotifierConf configures the delivery of event notifications by the sender,
+// notifications of the channels that are not configured are written to the log target.
+type NotifierConf struct {
+	Log     LogNotifierConf
+	Email   EmailNotifierConf
+	Webhook WebhookNotifierConf
+}
+
+type LogNotifierConf struct {
+	Target string `validate:"required"`
+}
+
+type EmailNotifierConf struct {
+	Host     string
+	Port     string `validate:"required_with=Host"`
+	User     string
+	Password string
+	From     string `validate:"required_with=Host"`
+	// To is a fmt pattern of the recipient address, it receives the user id.
+	To      string `validate:"required_with=Host"`
+	Timeout string `validate:"required"`
+}
+
+type WebhookNotifierConf struct {
+	URL     string `validate:"omitempty,url"`
+	Secret  string `validate:"required_with=URL"`
+	Timeout string `validate:"required"`
 }
 
 func NewConfig(r io.Reader) (*Config, error) {
@@ -86,8 +172,16 @@ func bindEnv() {
 	_ = viper.BindEnv("storage.db_password", "DB_PASSWORD")
 	_ = viper.BindEnv("storage.db_name", "DB_NAME")
 
+	_ = viper.BindEnv("metrics.port", "METRICS_PORT")
+
+	_ = viper.BindEnv("tracing.endpoint", "TRACING_ENDPOINT")
+
 	_ = viper.BindEnv("queue.user", "QUEUE_USER")
 	_ = viper.BindEnv("queue.password", "QUEUE_PASSWORD")
+
+	_ = viper.BindEnv("notifier.email.user", "SMTP_USER")
+	_ = viper.BindEnv("notifier.email.password", "SMTP_PASSWORD")
+	_ = viper.BindEnv("notifier.webhook.secret", "WEBHOOK_SECRET")
 }
 
 func setDefaults() {
@@ -97,17 +191,44 @@ func setDefaults() {
 	viper.SetDefault("grpc.host", "0.0.0.0")
 	viper.SetDefault("grpc.port", "50051")
 
+	viper.SetDefault("metrics.host", "0.0.0.0")
+	viper.SetDefault("metrics.port", "9100")
+
+	viper.SetDefault("tracing.exporter", "none")
+	viper.SetDefault("tracing.endpoint", "localhost:4317")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+
 	viper.SetDefault("logger.target", "stderr")
 	viper.SetDefault("logger.encoding", "console")
 
 	viper.SetDefault("storage.driver", "memory")
 
+	viper.SetDefault("queue.driver", "amqp")
 	viper.SetDefault("queue.host", "localhost")
 	viper.SetDefault("queue.port", "5672")
 	viper.SetDefault("queue.exchange", "calendar")
+	viper.SetDefault("queue.prefetch", 20)
+	viper.SetDefault("queue.workers", 4)
+	viper.SetDefault("queue.dedup_ttl", "24h")
+	viper.SetDefault("queue.retry.retries", 5)
+	viper.SetDefault("queue.retry.min_delay", "10s")
+	viper.SetDefault("queue.retry.max_delay", "10m")
+	viper.SetDefault("queue.reconnect.retries", 0)
+	viper.SetDefault("queue.reconnect.min_delay", "1s")
+	viper.SetDefault("queue.reconnect.max_delay", "30s")
 
 	viper.SetDefault("scheduler.send_notification", "1m")
+	viper.SetDefault("scheduler.send_invitations", "1m")
+	viper.SetDefault("scheduler.relay_outbox", "10s")
 	viper.SetDefault("scheduler.delete_old", "0 0 */1 * *")
+	viper.SetDefault("scheduler.purge_trash", "30 0 */1 * *")
+	viper.SetDefault("scheduler.retention.events", "8760h")
+	viper.SetDefault("scheduler.retention.trash", "720h")
+
+	viper.SetDefault("notifier.log.target", "stdout")
+	viper.SetDefault("notifier.email.port", "25")
+	viper.SetDefault("notifier.email.timeout", "10s")
+	viper.SetDefault("notifier.webhook.timeout", "5s")
 }
 
 func (c *HTTPConf) Addr() string {
@@ -118,6 +239,10 @@ func (c *GRPCConf) Addr() string {
 	return net.JoinHostPort(c.Host, c.Port)
 }
 
+func (c *MetricsConf) Addr() string {
+	return net.JoinHostPort(c.Host, c.Port)
+}
+
 func (c *StorageConf) dbConnectionString() string {
 	return fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
@@ -132,3 +257,53 @@ func (c *StorageConf) dbConnectionString() string {
 func (c *QueueConf) URI() string {
 	return fmt.Sprintf("amqp://%s:%s@%s:%s/", c.User, c.Password, c.Host, c.Port)
 }
+
+func (c *QueueRetryConf) Policy() (queue.RetryPolicy, error) {
+	minDelay, err := time.ParseDuration(c.MinDelay)
+	if err != nil {
+		return queue.RetryPolicy{}, fmt.Errorf("parse min delay: %w", err)
+	}
+
+	maxDelay, err := time.ParseDuration(c.MaxDelay)
+	if err != nil {
+		return queue.RetryPolicy{}, fmt.Errorf("parse max delay: %w", err)
+	}
+
+	return queue.RetryPolicy{
+		Retries:  c.Retries,
+		MinDelay: minDelay,
+		MaxDelay: maxDelay,
+	}, nil
+}
+
+func (c *RetentionConf) Policy() (scheduler.RetentionPolicy, error) {
+	d, err := time.ParseDuration(c.Events)
+	if err != nil {
+		return scheduler.RetentionPolicy{}, fmt.Errorf("parse events retention: %w", err)
+	}
+
+	policy := scheduler.RetentionPolicy{Default: d, Users: make(map[int64]time.Duration, len(c.Users))}
+	for user, retention := range c.Users {
+		userID, err := strconv.ParseInt(user, 10, 64)
+		if err != nil {
+			return scheduler.RetentionPolicy{}, fmt.Errorf("parse retention user id %q: %w", user, err)
+		}
+
+		d, err := time.ParseDuration(retention)
+		if err != nil {
+			return scheduler.RetentionPolicy{}, fmt.Errorf("parse retention of user %d: %w", userID, err)
+		}
+		policy.Users[userID] = d
+	}
+
+	return policy, nil
+}
+
+func (c *RetentionConf) TrashRetention() (time.Duration, error) {
+	d, err := time.ParseDuration(c.Trash)
+	if err != nil {
+		return 0, fmt.Errorf("parse trash retention: %w", err)
+	}
+
+	return d, nil
+}
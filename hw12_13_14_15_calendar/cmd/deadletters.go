@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+var deadLettersCmd = &cobra.Command{
+	Use:   "dead-letters",
+	Short: "Inspect and replay messages the sender failed to handle",
+}
+
+var deadLettersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print dead letters without removing them",
+	Run: func(cmd *cobra.Command, args []string) {
+		logg, dlq, cleanup := requireDeadLetterQueue(cmd)
+		defer cleanup()
+
+		letters, err := dlq.List(requireLimit(cmd, logg))
+		if err != nil {
+			logg.Error("dead letters list: " + err.Error())
+			os.Exit(1)
+		}
+
+		out := cmd.OutOrStdout()
+		for _, l := range letters {
+			fmt.Fprintf(out, "%s\t%s\tattempts=%d\terror=%q\n%s\n\n",
+				l.DiedAt.Format(time.RFC3339), l.Key, l.Attempt, l.Error, l.Payload)
+		}
+		fmt.Fprintf(out, "%d dead letters\n", len(letters))
+	},
+}
+
+var deadLettersReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Move dead letters back to the sender queue",
+	Run: func(cmd *cobra.Command, args []string) {
+		logg, dlq, cleanup := requireDeadLetterQueue(cmd)
+		defer cleanup()
+
+		replayed, err := dlq.Replay(requireLimit(cmd, logg))
+		if err != nil {
+			logg.Error("dead letters replay: "+err.Error(), "Replayed", replayed)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d dead letters replayed\n", replayed)
+	},
+}
+
+func requireDeadLetterQueue(cmd *cobra.Command) (logger.Logger, queue.DeadLetterQueue, CleanUpFunc) {
+	config := requireConfig(cmd.Flag("config").Value.String())
+	logg, cleanupLogger := requireLogger(config.Logger)
+
+	q := requireQueue(config.Queue)
+
+	dlq, err := q.CreateDeadLetterQueue(config.Queue.Exchange, eventsQueueName)
+	if err != nil {
+		logg.Error("dead letters create queue: " + err.Error())
+		os.Exit(1)
+	}
+
+	return logg, dlq, func() {
+		_ = q.Close()
+		cleanupLogger()
+	}
+}
+
+func requireLimit(cmd *cobra.Command, logg logger.Logger) int {
+	limit, err := strconv.Atoi(cmd.Flag("limit").Value.String())
+	if err != nil || limit <= 0 {
+		logg.Error("dead letters: limit must be a positive number")
+		os.Exit(1)
+	}
+
+	return limit
+}
+
+func init() {
+	deadLettersCmd.PersistentFlags().Int("limit", 100, "Maximum number of dead letters")
+	deadLettersCmd.AddCommand(deadLettersListCmd, deadLettersReplayCmd)
+	senderCmd.AddCommand(deadLettersCmd)
+}
@@ -5,8 +5,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/changes"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
 	grpcserver "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
 	"github.com/spf13/cobra"
 )
@@ -23,26 +27,31 @@ var grpcCmd = &cobra.Command{
 		logg, cleanupLogger := requireLogger(config.Logger)
 		defer cleanupLogger()
 
-		eventRepo, cleanupEventRepo := requireEventStorage(config.Storage)
+		cleanupTracing := requireTracing(config.Tracing, "calendar-grpc")
+		defer cleanupTracing()
+
+		checker := health.New()
+		stopMetrics := startMetrics(config.Metrics, logg, checker)
+		defer stopMetrics()
+
+		requireMigrations(config.Storage, checker)
+
+		eventRepo, cleanupEventRepo := requireEventStorage(config.Storage, checker)
 		defer cleanupEventRepo()
 
-		events := app.NewEventUseCase(eventRepo)
+		userRepo, cleanupUserRepo := requireUserStorage(config.Storage)
+		defer cleanupUserRepo()
 
-		server := grpcserver.New(logg, events, config.GRPC.Addr())
+		events := app.NewEventUseCase(eventRepo, userRepo, changes.New(changes.DefaultBuffer))
+		ical := app.NewICalendarUseCase(events, eventRepo)
+		users := app.NewUserUseCase(userRepo)
 
 		ctx, cancel := signal.NotifyContext(context.Background(),
 			syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 		defer cancel()
 
-		go func() {
-			<-ctx.Done()
-
-			server.Stop()
-		}()
-
-		logg.Info("calendar is running over grpc...")
-
-		if err := server.Start(); err != nil {
+		serveUntilDone(ctx, checker)
+		if err := serveGRPC(ctx, config.GRPC, logg, checker, events, ical, users); err != nil {
 			logg.Error("failed to start grpc server: " + err.Error())
 			cancel()
 			os.Exit(1)
@@ -50,6 +59,34 @@ var grpcCmd = &cobra.Command{
 	},
 }
 
+// serveGRPC serves the api over grpc until the ctx is done.
+func serveGRPC(
+	ctx context.Context,
+	config GRPCConf,
+	logg logger.Logger,
+	checker *health.Checker,
+	events app.EventsUseCase,
+	ical app.ICalendarUseCase,
+	users app.UsersUseCase,
+) error {
+	server := grpcserver.New(logg, events, ical, users, checker, config.Addr())
+
+	go func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		if err := server.Stop(ctx); err != nil {
+			logg.Error("failed to stop grpc server: " + err.Error())
+		}
+	}()
+
+	logg.Info("calendar is running over grpc...")
+
+	return server.Start()
+}
+
 func init() {
 	rootCmd.AddCommand(grpcCmd)
 }
@@ -8,6 +8,9 @@ import (
 	"time"
 
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/changes"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
 	httpserver "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/server/http"
 	"github.com/spf13/cobra"
 )
@@ -22,31 +25,31 @@ var httpCmd = &cobra.Command{
 		logg, cleanupLogger := requireLogger(config.Logger)
 		defer cleanupLogger()
 
-		eventRepo, cleanupEventRepo := requireEventStorage(config.Storage)
+		cleanupTracing := requireTracing(config.Tracing, "calendar-http")
+		defer cleanupTracing()
+
+		checker := health.New()
+		stopMetrics := startMetrics(config.Metrics, logg, checker)
+		defer stopMetrics()
+
+		requireMigrations(config.Storage, checker)
+
+		eventRepo, cleanupEventRepo := requireEventStorage(config.Storage, checker)
 		defer cleanupEventRepo()
 
-		events := app.NewEventUseCase(eventRepo)
+		userRepo, cleanupUserRepo := requireUserStorage(config.Storage)
+		defer cleanupUserRepo()
 
-		server := httpserver.New(logg, events, config.HTTP.Addr())
+		events := app.NewEventUseCase(eventRepo, userRepo, changes.New(changes.DefaultBuffer))
+		ical := app.NewICalendarUseCase(events, eventRepo)
+		users := app.NewUserUseCase(userRepo)
 
 		ctx, cancel := signal.NotifyContext(context.Background(),
 			syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 		defer cancel()
 
-		go func() {
-			<-ctx.Done()
-
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-			defer cancel()
-
-			if err := server.Stop(ctx); err != nil {
-				logg.Error("failed to stop http server: " + err.Error())
-			}
-		}()
-
-		logg.Info("calendar is running over http...")
-
-		if err := server.Start(); err != nil {
+		serveUntilDone(ctx, checker)
+		if err := serveHTTP(ctx, config.HTTP, logg, checker, events, ical, users); err != nil {
 			logg.Error("failed to start http server: " + err.Error())
 			cancel()
 			os.Exit(1)
@@ -54,6 +57,34 @@ var httpCmd = &cobra.Command{
 	},
 }
 
+// serveHTTP serves the api over http until the ctx is done.
+func serveHTTP(
+	ctx context.Context,
+	config HTTPConf,
+	logg logger.Logger,
+	checker *health.Checker,
+	events app.EventsUseCase,
+	ical app.ICalendarUseCase,
+	users app.UsersUseCase,
+) error {
+	server := httpserver.New(logg, events, ical, users, checker, config.Addr())
+
+	go func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		if err := server.Stop(ctx); err != nil {
+			logg.Error("failed to stop http server: " + err.Error())
+		}
+	}()
+
+	logg.Info("calendar is running over http...")
+
+	return server.Start()
+}
+
 func init() {
 	rootCmd.AddCommand(httpCmd)
 }
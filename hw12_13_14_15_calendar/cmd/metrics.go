@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+)
+
+// serveMetrics serves the metrics and the health endpoints until the ctx is done.
+func serveMetrics(ctx context.Context, config MetricsConf, logg logger.Logger, checker *health.Checker) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", checker.LivenessHandler())
+	mux.Handle("/readyz", checker.ReadinessHandler())
+
+	server := &http.Server{
+		Addr:              config.Addr(),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		_ = server.Shutdown(ctx)
+	}()
+
+	logg.Info("serving metrics on " + server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server: %w", err)
+	}
+
+	return nil
+}
+
+// startMetrics serves the metrics and the health endpoints in the background until the returned function is called,
+// the command keeps working without them. It is started before the dependencies,
+// so the probes are answered during the startup and the shutdown of the command.
+func startMetrics(config MetricsConf, logg logger.Logger, checker *health.Checker) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		if err := serveMetrics(ctx, config, logg, checker); err != nil {
+			logg.Error(err.Error())
+		}
+	}()
+
+	return cancel
+}
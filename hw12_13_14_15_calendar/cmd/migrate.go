@@ -27,21 +27,9 @@ var migrateCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
-		goose.SetBaseFS(MigrationsFS)
-
-		db, err := sql.Open("pgx", config.Storage.dbConnectionString())
-		if err != nil {
-			logg.Error(fmt.Sprintf("cannot connect to DB: %v", err))
-			os.Exit(1)
-		}
-
-		if err := goose.SetDialect("postgres"); err != nil {
-			logg.Error(fmt.Sprintf("migration prepare failed: %v", err))
-			os.Exit(1)
-		}
-
-		if err := goose.Up(db, "migrations"); err != nil {
-			logg.Error(fmt.Sprintf("migration up failed: %v", err))
+		resolveOverlaps, _ := cmd.Flags().GetBool("resolve-overlaps")
+		if err := runMigrations(config.Storage, resolveOverlaps); err != nil {
+			logg.Error(err.Error())
 			os.Exit(1)
 		}
 
@@ -49,6 +37,35 @@ var migrateCmd = &cobra.Command{
 	},
 }
 
+// runMigrations applies the embedded migrations to the DB of the config,
+// resolveOverlaps lets the migrations mark the overlapping events non-blocking instead of failing on them.
+func runMigrations(config StorageConf, resolveOverlaps bool) error {
+	goose.SetBaseFS(MigrationsFS)
+
+	dsn := config.dbConnectionString()
+	if resolveOverlaps {
+		dsn += " calendar.resolve_overlaps=on"
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return fmt.Errorf("cannot connect to DB: %w", err)
+	}
+	defer db.Close()
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("migration prepare failed: %w", err)
+	}
+
+	if err := goose.Up(db, "migrations"); err != nil {
+		return fmt.Errorf("migration up failed: %w", err)
+	}
+
+	return nil
+}
+
 func init() {
+	migrateCmd.Flags().Bool("resolve-overlaps", false,
+		"Mark the events overlapping the earlier events of their users non-blocking instead of failing")
 	rootCmd.AddCommand(migrateCmd)
 }
@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	"github.com/spf13/cobra"
 )
 
@@ -24,14 +29,20 @@ var schedulerCmd = &cobra.Command{
 		logg, cleanupLogger := requireLogger(config.Logger)
 		defer cleanupLogger()
 
-		storage, cleanupStorage := requireEventStorage(config.Storage)
+		cleanupTracing := requireTracing(config.Tracing, "calendar-scheduler")
+		defer cleanupTracing()
+
+		checker := health.New()
+		stopMetrics := startMetrics(config.Metrics, logg, checker)
+		defer stopMetrics()
+
+		requireMigrations(config.Storage, checker)
+
+		storage, cleanupStorage := requireEventStorage(config.Storage, checker)
 		defer cleanupStorage()
 
-		q := queue.New(config.Queue.URI())
-		if err := q.Connect(); err != nil {
-			logg.Error("scheduler connect: " + err.Error())
-			os.Exit(1)
-		}
+		q := requireQueue(config.Queue)
+		checker.AddReadiness("queue", q.Ping)
 
 		producer, err := q.CreateProducer(config.Queue.Exchange)
 		if err != nil {
@@ -43,26 +54,40 @@ var schedulerCmd = &cobra.Command{
 			syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 		defer cancel()
 
-		s := scheduler.New(ctx)
-		taskFactory := scheduler.NewTaskFactory(storage, producer)
-		if err := defineTasks(config.Scheduler, taskFactory, s, logg); err != nil {
-			logg.Error("scheduler define tasks: " + err.Error())
+		serveUntilDone(ctx, checker)
+		if err := runScheduler(ctx, config.Scheduler, logg, checker, storage, producer); err != nil {
+			logg.Error(err.Error())
 			os.Exit(1)
 		}
 
-		go func() {
-			<-ctx.Done()
-			s.Stop()
+		if err := q.Close(); err != nil {
+			logg.Error("scheduler: " + err.Error())
+			os.Exit(1)
+		}
+	},
+}
 
-			if err := q.Close(); err != nil {
-				logg.Error("scheduler: " + err.Error())
-				os.Exit(1)
-			}
-		}()
+// runScheduler runs the tasks until the ctx is done.
+func runScheduler(
+	ctx context.Context,
+	config SchedulerConf,
+	logg logger.Logger,
+	checker *health.Checker,
+	storage storage.EventStorage,
+	producer queue.Producer,
+) error {
+	s := scheduler.New(ctx)
+	taskFactory := scheduler.NewTaskFactory(storage, tracing.NewProducer(metrics.NewProducer(producer)))
+	if err := defineTasks(config, taskFactory, s, logg); err != nil {
+		return fmt.Errorf("scheduler define tasks: %w", err)
+	}
 
-		logg.Info("starting scheduler...")
-		s.Start()
-	},
+	checker.AddLiveness("scheduler", s.Check)
+
+	logg.Info("starting scheduler...")
+	s.Start()
+
+	return nil
 }
 
 func wrapTaskWithLog(name string, t scheduler.Task, logg logger.Logger) scheduler.Task {
@@ -78,21 +103,57 @@ func wrapTaskWithLog(name string, t scheduler.Task, logg logger.Logger) schedule
 	}
 }
 
+// instrumentTask logs the runs of the task, observes their metrics and records their spans.
+func instrumentTask(name string, t scheduler.Task, logg logger.Logger) scheduler.Task {
+	return tracing.Task(name, metrics.Task(name, wrapTaskWithLog(strings.ReplaceAll(name, "_", " "), t, logg)))
+}
+
 func defineTasks(cfg SchedulerConf, f *scheduler.TaskFactory, s *scheduler.Scheduler, logg logger.Logger) error {
 	if err := s.AddTask(
 		cfg.SendNotification,
-		wrapTaskWithLog("send notification", f.CreateSendNotificationTask(time.Minute), logg),
+		instrumentTask("send_notification", f.CreateSendNotificationTask(time.Minute), logg),
 	); err != nil {
 		return fmt.Errorf("definition notify task: %w", err)
 	}
 
+	if err := s.AddTask(
+		cfg.SendInvitations,
+		instrumentTask("send_invitations", f.CreateSendInvitationsTask(time.Minute), logg),
+	); err != nil {
+		return fmt.Errorf("definition invitations task: %w", err)
+	}
+
+	if err := s.AddTask(
+		cfg.RelayOutbox,
+		instrumentTask("relay_outbox", f.CreateRelayOutboxTask(time.Minute), logg),
+	); err != nil {
+		return fmt.Errorf("definition relay outbox task: %w", err)
+	}
+
+	retention, err := cfg.Retention.Policy()
+	if err != nil {
+		return fmt.Errorf("definition delete old task: %w", err)
+	}
+
 	if err := s.AddTask(
 		cfg.DeleteOld,
-		wrapTaskWithLog("delete old", f.CreateDeleteOldEventsTask(time.Minute), logg),
+		instrumentTask("delete_old", f.CreateDeleteOldEventsTask(time.Minute, retention), logg),
 	); err != nil {
 		return fmt.Errorf("definition delete old task: %w", err)
 	}
 
+	trashRetention, err := cfg.Retention.TrashRetention()
+	if err != nil {
+		return fmt.Errorf("definition purge trash task: %w", err)
+	}
+
+	if err := s.AddTask(
+		cfg.PurgeTrash,
+		instrumentTask("purge_trash", f.CreatePurgeTrashTask(time.Minute, trashRetention), logg),
+	); err != nil {
+		return fmt.Errorf("definition purge trash task: %w", err)
+	}
+
 	return nil
 }
 
@@ -2,18 +2,29 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/notifier"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	"github.com/spf13/cobra"
 )
 
-const eventsQueueName = "events"
+const (
+	eventsQueueName = "events"
+	// dedupSize is the number of the latest idempotency keys remembered by the sender in front of the storage
+	dedupSize = 10000
+)
 
 var senderCmd = &cobra.Command{
 	Use:   "sender",
@@ -25,15 +36,31 @@ var senderCmd = &cobra.Command{
 		logg, cleanupLogger := requireLogger(config.Logger)
 		defer cleanupLogger()
 
-		q := queue.New(config.Queue.URI())
-		if err := q.Connect(); err != nil {
-			logg.Error("sender connect: " + err.Error())
+		cleanupTracing := requireTracing(config.Tracing, "calendar-sender")
+		defer cleanupTracing()
+
+		checker := health.New()
+		stopMetrics := startMetrics(config.Metrics, logg, checker)
+		defer stopMetrics()
+
+		n, cleanupNotifier := requireNotifier(config.Notifier)
+		defer cleanupNotifier()
+
+		q := requireQueue(config.Queue)
+		checker.AddReadiness("queue", q.Ping)
+
+		consumer, err := createSenderConsumer(q, config.Queue)
+		if err != nil {
+			logg.Error("sender create consumer: " + err.Error())
 			os.Exit(1)
 		}
 
-		consumer, err := q.CreateConsumer(config.Queue.Exchange, eventsQueueName, scheduler.EventNotificationKey)
+		idempotencyRepo, cleanupIdempotencyRepo := requireIdempotencyStorage(config.Storage)
+		defer cleanupIdempotencyRepo()
+
+		keys, err := newSenderKeys(config.Queue, idempotencyRepo, logg)
 		if err != nil {
-			logg.Error("sender create consumer: " + err.Error())
+			logg.Error("sender create keys: " + err.Error())
 			os.Exit(1)
 		}
 
@@ -41,48 +68,180 @@ var senderCmd = &cobra.Command{
 			syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 		defer cancel()
 
-		go func() {
-			<-ctx.Done()
-
-			logg.Info("stopping sender")
-			if err := q.Close(); err != nil {
-				logg.Error("sender close queue: " + err.Error())
-				os.Exit(1)
-			}
-		}()
-
-		logg.Info("sender started...")
-		if err := consumer.Consume(func(m *queue.Message) {
-			logg.Info("incoming message with key=" + m.Key)
-			switch m.Key {
-			case scheduler.EventNotificationKey:
-				handleEventNotification(logg, m)
-			default:
-				logg.Warn("unknown message")
-			}
-
-			logg.Info(string(m.Payload))
-		}); err != nil {
-			logg.Error("sender consume: " + err.Error())
+		serveUntilDone(ctx, checker)
+		if err := runSender(ctx, logg, n, consumer, keys); err != nil {
+			logg.Error(err.Error())
+			os.Exit(1)
+		}
+
+		logg.Info("stopping sender")
+		if err := q.Close(); err != nil {
+			logg.Error("sender close queue: " + err.Error())
 			os.Exit(1)
 		}
 	},
 }
 
-func handleEventNotification(logg logger.Logger, m *queue.Message) {
+func createSenderConsumer(q queue.Queue, config QueueConf) (queue.Consumer, error) {
+	retry, err := config.Retry.Policy()
+	if err != nil {
+		return nil, fmt.Errorf("retry policy: %w", err)
+	}
+
+	opts := queue.ConsumerOptions{
+		Retry:    retry,
+		Prefetch: config.Prefetch,
+		Workers:  config.Workers,
+	}
+
+	return q.CreateConsumer(config.Exchange, eventsQueueName, opts,
+		scheduler.EventNotificationKey,
+		scheduler.AttendeeInvitationKey,
+		scheduler.AttendeeRSVPChangedKey,
+	)
+}
+
+// senderKeys remembers the idempotency keys of the handled messages in the storage for the ttl.
+type senderKeys struct {
+	storage storage.IdempotencyStorage
+	ttl     time.Duration
+	logg    logger.Logger
+}
+
+func newSenderKeys(config QueueConf, s storage.IdempotencyStorage, logg logger.Logger) (*senderKeys, error) {
+	ttl, err := time.ParseDuration(config.DedupTTL)
+	if err != nil {
+		return nil, fmt.Errorf("dedup ttl: %w", err)
+	}
+
+	return &senderKeys{storage: s, ttl: ttl, logg: logg}, nil
+}
+
+func (k *senderKeys) Has(ctx context.Context, key string) (bool, error) {
+	return k.storage.HasKey(ctx, key)
+}
+
+func (k *senderKeys) Add(ctx context.Context, key string) error {
+	err := k.storage.AddKey(ctx, key, time.Now().Add(k.ttl))
+	if err != nil {
+		// the message is handled already, so it is not retried, but its duplicate may be sent
+		k.logg.Error("sender remember key: "+err.Error(), "Key", key)
+	}
+
+	return err
+}
+
+// runSender consumes the messages until the ctx is done, the messages in handling are finished before it returns.
+func runSender(
+	ctx context.Context,
+	logg logger.Logger,
+	n notifier.Notifier,
+	consumer queue.Consumer,
+	keys queue.KeyStore,
+) error {
+	logg.Info("sender started...")
+	// the relay publishes a message again when it fails to delete it from the outbox
+	handle := queue.Deduplicate(func(ctx context.Context, m *queue.Message) error {
+		logg.Info(string(m.Payload))
+
+		switch m.Key {
+		case scheduler.EventNotificationKey:
+			return handleEventNotification(ctx, logg, n, m)
+		case scheduler.AttendeeInvitationKey:
+			return handleAttendeeInvitation(logg, m)
+		case scheduler.AttendeeRSVPChangedKey:
+			return handleAttendeeRSVPChanged(logg, m)
+		default:
+			logg.Warn("unknown message")
+		}
+
+		return nil
+	}, func(m *queue.Message) string {
+		return scheduler.IdempotencyKey(m.Payload)
+	}, dedupSize, keys)
+
+	if err := consumer.Consume(ctx, tracing.MessageHandler(metrics.MessageHandler(func(
+		ctx context.Context,
+		m *queue.Message,
+	) error {
+		logg.Info("incoming message with key=" + m.Key)
+
+		err := handle(ctx, m)
+		if err != nil {
+			logg.Error("sender handle: "+err.Error(), "Key", m.Key, "Attempt", m.Attempt)
+		}
+
+		return err
+	}))); err != nil {
+		return fmt.Errorf("sender consume: %w", err)
+	}
+
+	return nil
+}
+
+func handleEventNotification(ctx context.Context, logg logger.Logger, nn notifier.Notifier, m *queue.Message) error {
 	json := jsoniter.ConfigCompatibleWithStandardLibrary
 
 	n := &scheduler.EventNotification{}
 	if err := json.Unmarshal(m.Payload, n); err != nil {
-		logg.Error("sender event notification unmarshal: " + err.Error())
+		return fmt.Errorf("sender event notification unmarshal: %s: %w", err, queue.ErrPermanent)
 	}
 
 	logg.Info("event notification received",
 		"EventId", n.EventID,
+		"ReminderID", n.ReminderID,
 		"UserID", n.UserID,
+		"Channel", n.Channel,
 		"Title", n.Title,
 		"TimeStart", n.TimeStart,
 	)
+
+	if err := nn.Notify(ctx, n); err != nil {
+		return fmt.Errorf("sender event notification deliver: %w", err)
+	}
+
+	return nil
+}
+
+func handleAttendeeInvitation(logg logger.Logger, m *queue.Message) error {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+
+	n := &scheduler.AttendeeInvitation{}
+	if err := json.Unmarshal(m.Payload, n); err != nil {
+		return fmt.Errorf("sender attendee invitation unmarshal: %s: %w", err, queue.ErrPermanent)
+	}
+
+	logg.Info("attendee invitation received",
+		"EventId", n.EventID,
+		"OrganizerID", n.OrganizerID,
+		"UserID", n.UserID,
+		"Email", n.Email,
+		"Role", n.Role,
+		"Title", n.Title,
+		"TimeStart", n.TimeStart,
+	)
+
+	return nil
+}
+
+func handleAttendeeRSVPChanged(logg logger.Logger, m *queue.Message) error {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+
+	n := &scheduler.AttendeeRSVPChanged{}
+	if err := json.Unmarshal(m.Payload, n); err != nil {
+		return fmt.Errorf("sender attendee rsvp unmarshal: %s: %w", err, queue.ErrPermanent)
+	}
+
+	logg.Info("attendee rsvp changed",
+		"EventId", n.EventID,
+		"OrganizerID", n.OrganizerID,
+		"UserID", n.UserID,
+		"Email", n.Email,
+		"Status", n.Status,
+		"Title", n.Title,
+	)
+
+	return nil
 }
 
 func init() {
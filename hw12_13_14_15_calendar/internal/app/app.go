@@ -2,22 +2,71 @@ package app
 
 import (
 	"context"
+	"io"
+	"time"
 
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/changes"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 )
 
 type EventsUseCase interface {
-	GetByID(ctx context.Context, id int64) (*storage.Event, error)
+	GetByID(ctx context.Context, userID, id int64) (*storage.Event, error)
 	Create(ctx context.Context, dto CreateDTO) (int64, error)
-	Update(ctx context.Context, id int64, dto UpdateDTO) error
-	Delete(ctx context.Context, id int64) error
-	FindForDay(ctx context.Context, dto FindByDateDTO) ([]*storage.Event, error)
-	FindForWeek(ctx context.Context, dto FindByDateDTO) ([]*storage.Event, error)
-	FindForMonth(ctx context.Context, dto FindByDateDTO) ([]*storage.Event, error)
+	// Update returns the new version of the event.
+	Update(ctx context.Context, id int64, dto UpdateDTO) (int64, error)
+	// Delete moves the event to the trash, Restore moves it back and Purge deletes it permanently.
+	Delete(ctx context.Context, userID, id int64) error
+	FindDeleted(ctx context.Context, dto TrashDTO) (*EventPage, error)
+	Restore(ctx context.Context, userID, id int64) error
+	Purge(ctx context.Context, userID, id int64) error
+	// FindHistory returns the changes made by Create, Update, Delete and Restore of the event and its occurrences.
+	FindHistory(ctx context.Context, userID, id int64) ([]*storage.HistoryEntry, error)
+	Watch(ctx context.Context, userID, afterID int64) (<-chan *storage.HistoryEntry, error)
+	// Batch creates, updates and deletes the events checking the overlaps of the whole batch at once.
+	Batch(ctx context.Context, dto BatchDTO) (*BatchResult, error)
+	UpdateOccurrence(ctx context.Context, id int64, occurrence time.Time, dto UpdateDTO) (int64, error)
+	DeleteOccurrence(ctx context.Context, userID, id int64, occurrence time.Time) error
+	FindForDay(ctx context.Context, dto FindByDateDTO) (*EventPage, error)
+	FindForWeek(ctx context.Context, dto FindByDateDTO) (*EventPage, error)
+	FindForMonth(ctx context.Context, dto FindByDateDTO) (*EventPage, error)
+	Search(ctx context.Context, dto SearchDTO) (*EventPage, error)
+	Invite(ctx context.Context, dto InviteDTO) (int64, error)
+	Respond(ctx context.Context, dto RespondDTO) error
+	FindInvitations(ctx context.Context, userID int64) ([]*storage.Invitation, error)
+	FindFreeSlots(ctx context.Context, dto FreeSlotsDTO) ([]TimeSlot, error)
 }
 
-func NewEventUseCase(storage storage.EventStorage) EventsUseCase {
+type ICalendarUseCase interface {
+	Export(ctx context.Context, dto ExportDTO, w io.Writer) error
+	Import(ctx context.Context, userID int64, r io.Reader) (*ImportResult, error)
+}
+
+type UsersUseCase interface {
+	Register(ctx context.Context, dto CredentialsDTO) (*AuthResult, error)
+	Login(ctx context.Context, dto CredentialsDTO) (*AuthResult, error)
+	AuthenticatePassword(ctx context.Context, dto CredentialsDTO) (int64, error)
+	AuthenticateToken(ctx context.Context, token string) (int64, error)
+	GetSettings(ctx context.Context, userID int64) (*SettingsDTO, error)
+	UpdateSettings(ctx context.Context, dto SettingsDTO) (*SettingsDTO, error)
+}
+
+func NewEventUseCase(storage storage.EventStorage, users storage.UserStorage, changes *changes.Bus) EventsUseCase {
 	return &Events{
 		storage: storage,
+		users:   users,
+		changes: changes,
+	}
+}
+
+func NewICalendarUseCase(events EventsUseCase, storage storage.EventStorage) ICalendarUseCase {
+	return &ICalendar{
+		events:  events,
+		storage: storage,
+	}
+}
+
+func NewUserUseCase(storage storage.UserStorage) UsersUseCase {
+	return &Users{
+		storage: storage,
 	}
 }
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// Invite adds the attendee to the event of the organizer, the scheduler sends the invitation later.
+func (c *Events) Invite(ctx context.Context, dto InviteDTO) (int64, error) {
+	e, err := c.getOwned(ctx, dto.UserID, dto.EventID)
+	if err != nil {
+		return 0, fmt.Errorf("event use case invite: %w", err)
+	}
+
+	a := &storage.Attendee{
+		EventID: e.ID,
+		Email:   strings.ToLower(strings.TrimSpace(dto.Email)),
+		Role:    dto.Role,
+		Status:  storage.RSVPNeedsAction,
+		// there is no response to notify the organizer about yet
+		ResponseSent: true,
+	}
+	if dto.AttendeeUserID != 0 {
+		a.UserID = storage.AttendeeUserID{Int64: dto.AttendeeUserID, Valid: true}
+	}
+	if a.Role == "" {
+		a.Role = storage.RoleRequired
+	}
+
+	if err := c.validateAttendee(ctx, e, a); err != nil {
+		return 0, err
+	}
+
+	id, err := c.storage.AddAttendee(ctx, a)
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return 0, ErrAttendeeAlreadyInvited
+		}
+
+		return 0, fmt.Errorf("event use case invite: %w", err)
+	}
+
+	return id, nil
+}
+
+// Respond sets the RSVP status of the user invited to the event, the scheduler notifies the organizer later.
+func (c *Events) Respond(ctx context.Context, dto RespondDTO) error {
+	switch dto.Status {
+	case storage.RSVPAccepted, storage.RSVPDeclined, storage.RSVPTentative:
+	default:
+		return &ValidationErrors{errors: []error{
+			fmt.Errorf("status %q: %w", dto.Status, ErrInvalidRSVPStatus),
+		}}
+	}
+
+	attendees, err := c.storage.FindAttendees(ctx, dto.EventID)
+	if err != nil {
+		return fmt.Errorf("event use case respond: %w", err)
+	}
+
+	var a *storage.Attendee
+	for _, attendee := range attendees {
+		if attendee.UserID.Valid && attendee.UserID.Int64 == dto.UserID {
+			a = attendee
+			break
+		}
+	}
+
+	if a == nil {
+		return ErrInvitationIsNotExists
+	}
+
+	if a.Status == dto.Status {
+		return nil
+	}
+
+	a.Status = dto.Status
+	a.ResponseSent = false
+	if err := c.storage.UpdateAttendee(ctx, a); err != nil {
+		return fmt.Errorf("event use case respond: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Events) FindInvitations(ctx context.Context, userID int64) ([]*storage.Invitation, error) {
+	invitations, err := c.storage.FindInvitations(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+func (c *Events) validateAttendee(ctx context.Context, e *storage.Event, a *storage.Attendee) error {
+	errs := make([]error, 0)
+
+	switch {
+	case a.UserID.Valid == (a.Email != ""):
+		errs = append(errs, ErrAttendeeIsRequired)
+	case a.UserID.Valid && a.UserID.Int64 == e.UserID:
+		errs = append(errs, ErrOrganizerCanNotBeAttendee)
+	case a.UserID.Valid:
+		if _, err := c.users.GetByID(ctx, a.UserID.Int64); err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				return fmt.Errorf("validate attendee repository error: %w", err)
+			}
+
+			errs = append(errs, fmt.Errorf("user %d: %w", a.UserID.Int64, ErrAttendeeUserIsNotExists))
+		}
+	default:
+		if addr, err := mail.ParseAddress(a.Email); err != nil || addr.Address != a.Email {
+			errs = append(errs, fmt.Errorf("email %q: %w", a.Email, ErrInvalidEmail))
+		}
+	}
+
+	switch a.Role {
+	case storage.RoleChair, storage.RoleRequired, storage.RoleOptional, storage.RoleNonParticipant:
+	default:
+		errs = append(errs, fmt.Errorf("role %q: %w", a.Role, ErrInvalidAttendeeRole))
+	}
+
+	if len(errs) > 0 {
+		return &ValidationErrors{errors: errs}
+	}
+
+	return nil
+}
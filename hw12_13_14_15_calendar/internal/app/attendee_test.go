@@ -0,0 +1,200 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_Invite(t *testing.T) {
+	t.Run("success case", func(t *testing.T) {
+		e := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, e.ID).Once().Return(&e, nil)
+		storageMock.
+			On("AddAttendee", ctx, mock.MatchedBy(func(a *storage.Attendee) bool {
+				return a.EventID == e.ID &&
+					a.UserID.Int64 == 2 &&
+					a.Role == storage.RoleRequired &&
+					a.Status == storage.RSVPNeedsAction &&
+					!a.InvitationSent
+			})).
+			Once().
+			Return(int64(7), nil)
+
+		usersMock := mockstorage.UserStorage{}
+		usersMock.On("GetByID", ctx, int64(2)).Once().Return(&storage.User{ID: 2}, nil)
+
+		uc := Events{storage: &storageMock, users: &usersMock}
+		id, err := uc.Invite(ctx, InviteDTO{UserID: e.UserID, EventID: e.ID, AttendeeUserID: 2})
+		require.NoError(t, err)
+		require.Equal(t, int64(7), id)
+	})
+
+	t.Run("email is normalized", func(t *testing.T) {
+		e := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, e.ID).Once().Return(&e, nil)
+		storageMock.
+			On("AddAttendee", ctx, mock.MatchedBy(func(a *storage.Attendee) bool {
+				return a.Email == "guest@example.com" && !a.UserID.Valid && a.Role == storage.RoleOptional
+			})).
+			Once().
+			Return(int64(8), nil)
+
+		uc := Events{storage: &storageMock}
+		_, err := uc.Invite(ctx, InviteDTO{
+			UserID:  e.UserID,
+			EventID: e.ID,
+			Email:   " Guest@Example.com ",
+			Role:    storage.RoleOptional,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		e := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, e.ID).Return(&e, nil)
+
+		usersMock := mockstorage.UserStorage{}
+		usersMock.On("GetByID", ctx, int64(3)).Once().Return(nil, storage.ErrNotFound)
+
+		uc := Events{storage: &storageMock, users: &usersMock}
+
+		cases := []struct {
+			dto      InviteDTO
+			expected []error
+		}{
+			{InviteDTO{}, []error{ErrAttendeeIsRequired}},
+			{InviteDTO{AttendeeUserID: 2, Email: "a@b.c"}, []error{ErrAttendeeIsRequired}},
+			{InviteDTO{AttendeeUserID: e.UserID}, []error{ErrOrganizerCanNotBeAttendee}},
+			{InviteDTO{AttendeeUserID: 3}, []error{ErrAttendeeUserIsNotExists}},
+			{InviteDTO{Email: "not an email", Role: "boss"}, []error{ErrInvalidEmail, ErrInvalidAttendeeRole}},
+		}
+
+		for _, c := range cases {
+			c.dto.UserID, c.dto.EventID = e.UserID, e.ID
+
+			_, err := uc.Invite(ctx, c.dto)
+
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+			require.Len(t, v.Errors(), len(c.expected))
+			for i, expected := range c.expected {
+				require.ErrorIs(t, v.Errors()[i], expected)
+			}
+		}
+	})
+
+	t.Run("already invited", func(t *testing.T) {
+		e := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, e.ID).Once().Return(&e, nil)
+		storageMock.On("AddAttendee", ctx, mock.Anything).Once().Return(int64(0), storage.ErrAlreadyExists)
+
+		uc := Events{storage: &storageMock}
+		_, err := uc.Invite(ctx, InviteDTO{UserID: e.UserID, EventID: e.ID, Email: "a@b.c"})
+		require.ErrorIs(t, err, ErrAttendeeAlreadyInvited)
+	})
+
+	t.Run("event of other user", func(t *testing.T) {
+		e := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, e.ID).Once().Return(&e, nil)
+
+		uc := Events{storage: &storageMock}
+		_, err := uc.Invite(ctx, InviteDTO{UserID: e.UserID + 1, EventID: e.ID, Email: "a@b.c"})
+		require.ErrorIs(t, err, ErrEventIsNotExists)
+	})
+}
+
+func TestEvents_Respond(t *testing.T) {
+	attendees := func() []*storage.Attendee {
+		return []*storage.Attendee{
+			{ID: 1, EventID: 1, Email: "a@b.c", Status: storage.RSVPNeedsAction, ResponseSent: true},
+			{
+				ID:           2,
+				EventID:      1,
+				UserID:       storage.AttendeeUserID{Int64: 2, Valid: true},
+				Status:       storage.RSVPNeedsAction,
+				ResponseSent: true,
+			},
+		}
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindAttendees", ctx, int64(1)).Once().Return(attendees(), nil)
+		storageMock.
+			On("UpdateAttendee", ctx, mock.MatchedBy(func(a *storage.Attendee) bool {
+				return a.ID == 2 && a.Status == storage.RSVPAccepted && !a.ResponseSent
+			})).
+			Once().
+			Return(nil)
+
+		uc := Events{storage: &storageMock}
+		require.NoError(t, uc.Respond(ctx, RespondDTO{UserID: 2, EventID: 1, Status: storage.RSVPAccepted}))
+		storageMock.AssertExpectations(t)
+	})
+
+	t.Run("same status", func(t *testing.T) {
+		a := attendees()
+		a[1].Status = storage.RSVPDeclined
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindAttendees", ctx, int64(1)).Once().Return(a, nil)
+
+		uc := Events{storage: &storageMock}
+		require.NoError(t, uc.Respond(ctx, RespondDTO{UserID: 2, EventID: 1, Status: storage.RSVPDeclined}))
+		storageMock.AssertNotCalled(t, "UpdateAttendee", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		uc := Events{storage: &mockstorage.EventStorage{}}
+
+		for _, status := range []storage.RSVPStatus{"", storage.RSVPNeedsAction, "maybe"} {
+			err := uc.Respond(ctx, RespondDTO{UserID: 2, EventID: 1, Status: status})
+
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+			require.ErrorIs(t, v.Errors()[0], ErrInvalidRSVPStatus)
+		}
+	})
+
+	t.Run("not invited", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindAttendees", ctx, int64(1)).Once().Return(attendees(), nil)
+
+		uc := Events{storage: &storageMock}
+		err := uc.Respond(ctx, RespondDTO{UserID: 3, EventID: 1, Status: storage.RSVPTentative})
+		require.ErrorIs(t, err, ErrInvitationIsNotExists)
+	})
+}
+
+func TestEvents_FindInvitations(t *testing.T) {
+	e := eventStub(t)
+	expected := []*storage.Invitation{{Event: &e, Attendee: &storage.Attendee{ID: 1, EventID: e.ID}}}
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.On("FindInvitations", ctx, int64(2)).Once().Return(expected, nil)
+
+	uc := Events{storage: &storageMock}
+	actual, err := uc.FindInvitations(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	testErr := errors.New("test error")
+	storageMock.On("FindInvitations", ctx, int64(3)).Once().Return(nil, testErr)
+	_, err = uc.FindInvitations(ctx, 3)
+	require.ErrorIs(t, err, testErr)
+}
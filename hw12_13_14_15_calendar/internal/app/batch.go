@@ -0,0 +1,394 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxBatchOperations limits the number of operations of a single batch.
+const MaxBatchOperations = 1000
+
+// batchItem is an operation of the batch, event is the new state of the created or updated event.
+type batchItem struct {
+	operation BatchOperation
+	id        int64
+	old       *storage.Event
+	event     *storage.Event
+	errs      []error
+}
+
+func (c *Events) Batch(ctx context.Context, dto BatchDTO) (*BatchResult, error) {
+	if len(dto.Operations) == 0 {
+		return nil, &ValidationErrors{errors: []error{ErrBatchIsEmpty}}
+	}
+
+	if len(dto.Operations) > MaxBatchOperations {
+		return nil, &ValidationErrors{errors: []error{
+			fmt.Errorf("operations count is %d/%d: %w", len(dto.Operations), MaxBatchOperations, ErrTooManyOperations),
+		}}
+	}
+
+	items, err := c.prepareBatch(ctx, dto)
+	if err != nil {
+		return nil, fmt.Errorf("event use case batch: %w", err)
+	}
+
+	if err := c.checkBatchOverlaps(ctx, dto.UserID, items); err != nil {
+		return nil, fmt.Errorf("event use case batch: %w", err)
+	}
+
+	valid := make([]*batchItem, 0, len(items))
+	for _, item := range items {
+		if len(item.errs) == 0 {
+			valid = append(valid, item)
+		}
+	}
+
+	result := &BatchResult{Items: make([]BatchItemResult, len(items))}
+	switch {
+	case dto.Atomic && len(valid) < len(items):
+		fillBatchResult(result, items)
+		return result, nil
+	case dto.Atomic:
+		if err := c.applyBatch(ctx, dto.UserID, valid); err != nil {
+			if !isBatchRejected(err) {
+				return nil, fmt.Errorf("event use case batch: %w", err)
+			}
+
+			rejectBatch(valid, err)
+			fillBatchResult(result, items)
+			return result, nil
+		}
+	default:
+		if err := c.applyBestEffort(ctx, dto.UserID, valid); err != nil {
+			return nil, fmt.Errorf("event use case batch: %w", err)
+		}
+	}
+
+	result.Applied = true
+	fillBatchResult(result, items)
+
+	return result, nil
+}
+
+// prepareBatch reads the updated and deleted events and checks the fields of the created and updated ones.
+func (c *Events) prepareBatch(ctx context.Context, dto BatchDTO) ([]*batchItem, error) {
+	items := make([]*batchItem, 0, len(dto.Operations))
+	changed := make(map[int64]bool)
+	olds := make([]*storage.Event, 0)
+
+	for _, op := range dto.Operations {
+		item := &batchItem{operation: op.Operation, id: op.ID}
+		items = append(items, item)
+
+		switch op.Operation {
+		case BatchCreate:
+			item.event = &storage.Event{UserID: dto.UserID}
+			applyUpdate(item.event, op.Event)
+			// the invalid event has errors, so its overlaps are not checked
+			item.errs, _ = checkEvent(item.event)
+			continue
+		case BatchUpdate, BatchDelete:
+		default:
+			item.errs = []error{fmt.Errorf("%q: %w", op.Operation, ErrInvalidBatchOperation)}
+			continue
+		}
+
+		if changed[op.ID] {
+			item.errs = []error{fmt.Errorf("event %d: %w", op.ID, ErrDuplicateBatchEvent)}
+			continue
+		}
+		changed[op.ID] = true
+
+		e, err := c.getOwned(ctx, dto.UserID, op.ID)
+		if errors.Is(err, ErrEventIsNotExists) {
+			item.errs = []error{err}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Operation == BatchDelete {
+			continue
+		}
+
+		if op.Event.Version != 0 && op.Event.Version != e.Version {
+			item.errs = []error{fmt.Errorf("version %d: %w", op.Event.Version, ErrEventIsModified)}
+			continue
+		}
+
+		old := *e
+		item.old = &old
+		olds = append(olds, item.old)
+
+		applyUpdate(e, op.Event)
+		item.event = e
+		item.errs, _ = checkEvent(e)
+	}
+
+	// the reminders are replaced, so the old ones are read for the history
+	if err := attachReminders(ctx, c.storage, olds); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// checkBatchOverlaps reports the blocking events of the batch which overlap other blocking events of the user
+// or the events of the batch before them. The events of the whole batch are read by a single query
+// instead of a query per event, the events updated and deleted by the batch are not conflicts.
+func (c *Events) checkBatchOverlaps(ctx context.Context, userID int64, items []*batchItem) error {
+	var from, to time.Time
+	checked := make([]*storage.Event, 0, len(items))
+	itemOf := make(map[*storage.Event]*batchItem)
+	occurrencesOf := make(map[*storage.Event][]*storage.Event)
+	replaced := make(map[int64]bool)
+	deleted := make(map[int64]bool)
+
+	for _, item := range items {
+		if len(item.errs) > 0 {
+			continue
+		}
+
+		switch item.operation {
+		case BatchDelete:
+			deleted[item.id] = true
+			replaced[item.id] = true
+			continue
+		case BatchUpdate:
+			replaced[item.id] = true
+		}
+
+		e := item.event
+		if e.NonBlocking {
+			continue
+		}
+
+		occurrences, err := busyOccurrences(e)
+		if err != nil {
+			return err
+		}
+		if len(occurrences) == 0 {
+			continue
+		}
+
+		start, end := occurrences[0].TimeStart, occurrences[len(occurrences)-1].TimeEnd
+		if len(checked) == 0 || start.Before(from) {
+			from = start
+		}
+		if len(checked) == 0 || end.After(to) {
+			to = end
+		}
+		checked = append(checked, e)
+		itemOf[e] = item
+		occurrencesOf[e] = occurrences
+	}
+
+	if len(checked) == 0 {
+		return nil
+	}
+
+	existed, err := c.storage.FindOverlapping(ctx, userID, from, to)
+	if err != nil {
+		return err
+	}
+
+	series, err := c.storage.FindRecurring(ctx, userID, from, to)
+	if err != nil {
+		return err
+	}
+
+	// the exceptions are deleted together with the series
+	isReplaced := func(e *storage.Event) bool {
+		return replaced[e.ID] || (e.SeriesID.Valid && deleted[e.SeriesID.Int64])
+	}
+	existed = filterEvents(existed, isReplaced)
+	series = filterEvents(series, isReplaced)
+
+	for _, e := range checked {
+		occurrences := occurrencesOf[e]
+		overlaps := overlapsExisted(e, occurrences, existed)
+		if !overlaps {
+			if overlaps, err = overlapsOccurrence(e, occurrences, series); err != nil {
+				return err
+			}
+		}
+
+		if overlaps {
+			itemOf[e].errs = append(itemOf[e].errs, ErrTimeIsBusy)
+			continue
+		}
+
+		// the next events of the batch must not overlap the checked one
+		if e.IsRecurring() {
+			series = append(series, e)
+		} else {
+			existed = append(existed, e)
+		}
+	}
+
+	return nil
+}
+
+// overlapsExisted reports whether an occurrence of the event overlaps another blocking one-off event,
+// the one-off events are read for the range of the whole batch, so they are compared by time.
+func overlapsExisted(e *storage.Event, occurrences, existed []*storage.Event) bool {
+	for _, ex := range existed {
+		if conflicts(e, ex) && overlapsAny(occurrences, ex) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overlapsAny reports whether the event overlaps a blocking event of the list like storage.FindOverlapping does,
+// the events created by the batch have no ids yet, so they are not compared by ids.
+func overlapsAny(events []*storage.Event, e *storage.Event) bool {
+	for _, ex := range events {
+		if ex != e && !ex.NonBlocking && ex.TimeStart.Before(e.TimeEnd) && ex.TimeEnd.After(e.TimeStart) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func filterEvents(events []*storage.Event, skip func(e *storage.Event) bool) []*storage.Event {
+	result := make([]*storage.Event, 0, len(events))
+	for _, e := range events {
+		if !skip(e) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// applyBatch saves the items of the user together with their history in a single transaction.
+func (c *Events) applyBatch(ctx context.Context, userID int64, items []*batchItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	batch := &storage.Batch{}
+	for _, item := range items {
+		switch item.operation {
+		case BatchCreate:
+			batch.Create = append(batch.Create, item.event)
+			batch.CreateHistory = append(batch.CreateHistory,
+				newHistoryEntry(userID, 0, storage.HistoryCreated, nil, item.event))
+		case BatchUpdate:
+			batch.Update = append(batch.Update, item.event)
+			batch.UpdateHistory = append(batch.UpdateHistory,
+				newHistoryEntry(userID, item.id, storage.HistoryUpdated, item.old, item.event))
+		case BatchDelete:
+			batch.Delete = append(batch.Delete, item.id)
+			batch.DeleteHistory = append(batch.DeleteHistory,
+				newHistoryEntry(userID, item.id, storage.HistoryDeleted, nil, nil))
+		}
+	}
+
+	if err := c.storage.ApplyBatch(ctx, batch); err != nil {
+		return err
+	}
+
+	for _, entries := range [][]*storage.HistoryEntry{batch.DeleteHistory, batch.UpdateHistory, batch.CreateHistory} {
+		for _, entry := range entries {
+			c.publish(entry)
+		}
+	}
+
+	return nil
+}
+
+// applyBestEffort saves the items together and falls back to saving them one by one when the storage
+// rejects them, so only the items changed or overlapped after the checks fail.
+func (c *Events) applyBestEffort(ctx context.Context, userID int64, items []*batchItem) error {
+	err := c.applyBatch(ctx, userID, items)
+	if err == nil || !isBatchRejected(err) {
+		return err
+	}
+
+	for _, item := range items {
+		err := c.applyBatch(ctx, userID, []*batchItem{item})
+		if err == nil {
+			continue
+		}
+
+		if !isBatchRejected(err) {
+			return err
+		}
+		item.errs = batchItemErrors(err)
+	}
+
+	return nil
+}
+
+// rejectBatch reports the atomic batch rejected by the storage on the items which may cause the rejection,
+// the storage does not tell the rejected change: the updated events may be changed in the meantime
+// and the blocking one-off events may overlap the events saved after the checks.
+func rejectBatch(items []*batchItem, err error) {
+	overlap := errors.Is(err, storage.ErrOverlap)
+	for _, item := range items {
+		switch {
+		case item.operation == BatchDelete:
+			continue
+		case overlap && (item.event.NonBlocking || item.event.IsRecurring()):
+			continue
+		case !overlap && item.operation != BatchUpdate:
+			continue
+		}
+
+		item.errs = batchItemErrors(err)
+	}
+}
+
+func isBatchRejected(err error) bool {
+	return errors.Is(err, storage.ErrConflict) || errors.Is(err, storage.ErrOverlap)
+}
+
+// batchError reports the batch rejected by the storage like the single changes are reported.
+func batchError(err error) error {
+	if errors.Is(err, storage.ErrConflict) {
+		return ErrEventIsModified
+	}
+
+	return busyError(err)
+}
+
+// batchItemErrors returns the errors of the item rejected by the storage.
+func batchItemErrors(err error) []error {
+	err = batchError(err)
+
+	var v *ValidationErrors
+	if errors.As(err, &v) {
+		return v.errors
+	}
+
+	return []error{err}
+}
+
+func fillBatchResult(result *BatchResult, items []*batchItem) {
+	for i, item := range items {
+		if len(item.errs) > 0 {
+			result.Items[i].Err = &ValidationErrors{errors: item.errs}
+			continue
+		}
+
+		if !result.Applied {
+			continue
+		}
+
+		result.Items[i].ID = item.id
+		if item.event != nil {
+			result.Items[i].ID = item.event.ID
+			result.Items[i].Version = item.event.Version
+		}
+	}
+}
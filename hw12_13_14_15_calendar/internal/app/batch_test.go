@@ -0,0 +1,300 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func batchEvent(timeStart time.Time) UpdateDTO {
+	return UpdateDTO{Title: "t", TimeStart: timeStart, TimeEnd: timeStart.Add(time.Hour)}
+}
+
+// applyBatchStub fills the ids and versions like the storage does.
+func applyBatchStub(args mock.Arguments) {
+	b := args.Get(1).(*storage.Batch)
+	for i, e := range b.Create {
+		e.ID = int64(100 + i)
+		e.Version = 1
+		if i < len(b.CreateHistory) {
+			b.CreateHistory[i].EventID = e.ID
+		}
+	}
+	for _, e := range b.Update {
+		e.Version++
+	}
+}
+
+func TestEvents_Batch(t *testing.T) {
+	base := time.Date(2022, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("invalid batch", func(t *testing.T) {
+		uc := Events{storage: &mockstorage.EventStorage{}}
+
+		_, err := uc.Batch(ctx, BatchDTO{UserID: 1})
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		require.ErrorIs(t, v.Errors()[0], ErrBatchIsEmpty)
+
+		_, err = uc.Batch(ctx, BatchDTO{UserID: 1, Operations: make([]BatchOperationDTO, MaxBatchOperations+1)})
+		require.ErrorAs(t, err, &v)
+		require.ErrorIs(t, v.Errors()[0], ErrTooManyOperations)
+	})
+
+	t.Run("atomic success case", func(t *testing.T) {
+		existed := eventStub(t)
+		existed.ID, existed.Version = 1, 3
+		deleted := eventStub(t)
+		deleted.ID, deleted.TimeStart, deleted.TimeEnd = 2, base, base.Add(time.Hour)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, int64(1)).Once().Return(&existed, nil)
+		storageMock.On("GetByID", ctx, int64(2)).Once().Return(&deleted, nil)
+		storageMock.On("FindReminders", ctx, []int64{1}).Once().Return([]*storage.Reminder{}, nil)
+		// the time of the deleted event is free for the created one
+		storageMock.
+			On("FindOverlapping", ctx, int64(1), base, base.Add(4*time.Hour)).
+			Once().
+			Return([]*storage.Event{&deleted}, nil)
+		storageMock.
+			On("FindRecurring", ctx, int64(1), base, base.Add(4*time.Hour)).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("ApplyBatch", ctx, mock.MatchedBy(func(b *storage.Batch) bool {
+				return len(b.Create) == 2 && len(b.Update) == 1 && b.Update[0].ID == 1 &&
+					b.Update[0].Title == "updated" && len(b.Delete) == 1 && b.Delete[0] == 2 &&
+					// the history is saved in the transaction of the batch
+					len(b.CreateHistory) == 2 && len(b.UpdateHistory) == 1 && len(b.DeleteHistory) == 1 &&
+					b.UpdateHistory[0].EventID == 1 && b.DeleteHistory[0].EventID == 2
+			})).
+			Once().
+			Run(applyBatchStub).
+			Return(nil)
+		defer storageMock.AssertExpectations(t)
+
+		updated := batchEvent(base.Add(3 * time.Hour))
+		updated.Title = "updated"
+		updated.Version = 3
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.Batch(ctx, BatchDTO{
+			UserID: 1,
+			Atomic: true,
+			Operations: []BatchOperationDTO{
+				{Operation: BatchCreate, Event: batchEvent(base)},
+				{Operation: BatchUpdate, ID: 1, Event: updated},
+				{Operation: BatchDelete, ID: 2},
+				{Operation: BatchCreate, Event: batchEvent(base.Add(time.Hour))},
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, result.Applied)
+		require.Equal(t, []BatchItemResult{
+			{ID: 100, Version: 1},
+			{ID: 1, Version: 4},
+			{ID: 2},
+			{ID: 101, Version: 1},
+		}, result.Items)
+	})
+
+	t.Run("atomic batch with failed operations", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, int64(5)).Once().Return(nil, storage.ErrNotFound)
+		storageMock.
+			On("FindOverlapping", ctx, int64(1), base, base.Add(90*time.Minute)).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindRecurring", ctx, int64(1), base, base.Add(90*time.Minute)).
+			Once().
+			Return([]*storage.Event{}, nil)
+		defer storageMock.AssertExpectations(t)
+
+		invalid := batchEvent(base)
+		invalid.Title = longTitle
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.Batch(ctx, BatchDTO{
+			UserID: 1,
+			Atomic: true,
+			Operations: []BatchOperationDTO{
+				{Operation: BatchCreate, Event: batchEvent(base)},
+				// the events of the batch overlap each other
+				{Operation: BatchCreate, Event: batchEvent(base.Add(30 * time.Minute))},
+				{Operation: BatchCreate, Event: invalid},
+				{Operation: BatchUpdate, ID: 5, Event: batchEvent(base)},
+				{Operation: "move", ID: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, result.Applied)
+		require.Len(t, result.Items, 5)
+		require.Nil(t, result.Items[0].Err)
+		require.Zero(t, result.Items[0].ID)
+		require.ErrorIs(t, result.Items[1].Err.Errors()[0], ErrTimeIsBusy)
+		require.ErrorIs(t, result.Items[2].Err.Errors()[0], ErrTitleTooLong)
+		require.ErrorIs(t, result.Items[3].Err.Errors()[0], ErrEventIsNotExists)
+		require.ErrorIs(t, result.Items[4].Err.Errors()[0], ErrInvalidBatchOperation)
+		storageMock.AssertNotCalled(t, "ApplyBatch", ctx, mock.Anything)
+	})
+
+	t.Run("atomic batch rejected by the storage", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindOverlapping", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("ApplyBatch", ctx, mock.Anything).Once().Return(storage.ErrOverlap)
+		defer storageMock.AssertExpectations(t)
+
+		nonBlocking := batchEvent(base.Add(time.Hour))
+		nonBlocking.NonBlocking = true
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.Batch(ctx, BatchDTO{
+			UserID: 1,
+			Atomic: true,
+			Operations: []BatchOperationDTO{
+				{Operation: BatchCreate, Event: batchEvent(base)},
+				{Operation: BatchCreate, Event: nonBlocking},
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, result.Applied)
+		require.ErrorIs(t, result.Items[0].Err.Errors()[0], ErrTimeIsBusy)
+		require.Zero(t, result.Items[0].ID)
+		// the non-blocking event can not overlap
+		require.Nil(t, result.Items[1].Err)
+		require.Zero(t, result.Items[1].ID)
+	})
+
+	t.Run("atomic batch with a stale update rejected by the storage", func(t *testing.T) {
+		existed := eventStub(t)
+		existed.ID, existed.Version = 1, 3
+		deleted := eventStub(t)
+		deleted.ID = 2
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, int64(1)).Once().Return(&existed, nil)
+		storageMock.On("GetByID", ctx, int64(2)).Once().Return(&deleted, nil)
+		storageMock.On("FindReminders", ctx, []int64{1}).Once().Return([]*storage.Reminder{}, nil)
+		storageMock.On("FindOverlapping", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("ApplyBatch", ctx, mock.Anything).Once().Return(storage.ErrConflict)
+		defer storageMock.AssertExpectations(t)
+
+		updated := batchEvent(base)
+		updated.Version = 3
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.Batch(ctx, BatchDTO{
+			UserID: 1,
+			Atomic: true,
+			Operations: []BatchOperationDTO{
+				{Operation: BatchUpdate, ID: 1, Event: updated},
+				{Operation: BatchDelete, ID: 2},
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, result.Applied)
+		require.ErrorIs(t, result.Items[0].Err.Errors()[0], ErrEventIsModified)
+		require.Nil(t, result.Items[1].Err)
+	})
+
+	t.Run("atomic batch failed in the storage", func(t *testing.T) {
+		errTest := errors.New("some storage error")
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindOverlapping", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("ApplyBatch", ctx, mock.Anything).Once().Return(errTest)
+
+		uc := Events{storage: &storageMock}
+		_, err := uc.Batch(ctx, BatchDTO{
+			UserID:     1,
+			Atomic:     true,
+			Operations: []BatchOperationDTO{{Operation: BatchCreate, Event: batchEvent(base)}},
+		})
+		require.ErrorIs(t, err, errTest)
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, int64(5)).Once().Return(nil, storage.ErrNotFound)
+		storageMock.
+			On("FindOverlapping", ctx, int64(1), base, base.Add(3*time.Hour)).
+			Once().
+			Return([]*storage.Event{{ID: 3, UserID: 1, TimeStart: base, TimeEnd: base.Add(time.Hour)}}, nil)
+		storageMock.
+			On("FindRecurring", ctx, int64(1), base, base.Add(3*time.Hour)).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("ApplyBatch", ctx, mock.MatchedBy(func(b *storage.Batch) bool {
+				return len(b.Create) == 2 && b.Create[0].TimeStart.Equal(base.Add(time.Hour))
+			})).
+			Once().
+			Run(applyBatchStub).
+			Return(nil)
+		defer storageMock.AssertExpectations(t)
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.Batch(ctx, BatchDTO{
+			UserID: 1,
+			Operations: []BatchOperationDTO{
+				{Operation: BatchCreate, Event: batchEvent(base)},
+				{Operation: BatchCreate, Event: batchEvent(base.Add(time.Hour))},
+				{Operation: BatchDelete, ID: 5},
+				{Operation: BatchCreate, Event: batchEvent(base.Add(2 * time.Hour))},
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, result.Applied)
+		require.ErrorIs(t, result.Items[0].Err.Errors()[0], ErrTimeIsBusy)
+		require.Equal(t, BatchItemResult{ID: 100, Version: 1}, result.Items[1])
+		require.ErrorIs(t, result.Items[2].Err.Errors()[0], ErrEventIsNotExists)
+		require.Equal(t, BatchItemResult{ID: 101, Version: 1}, result.Items[3])
+	})
+
+	t.Run("best effort batch rejected by the storage", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindOverlapping", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), mock.Anything, mock.Anything).Return([]*storage.Event{}, nil)
+		storageMock.
+			On("ApplyBatch", ctx, mock.MatchedBy(func(b *storage.Batch) bool { return len(b.Create) == 2 })).
+			Once().
+			Return(storage.ErrOverlap)
+		// the events are saved one by one after the batch is rejected
+		storageMock.
+			On("ApplyBatch", ctx, mock.MatchedBy(func(b *storage.Batch) bool {
+				return len(b.Create) == 1 && b.Create[0].TimeStart.Equal(base)
+			})).
+			Once().
+			Run(applyBatchStub).
+			Return(nil)
+		storageMock.
+			On("ApplyBatch", ctx, mock.MatchedBy(func(b *storage.Batch) bool {
+				return len(b.Create) == 1 && b.Create[0].TimeStart.Equal(base.Add(time.Hour))
+			})).
+			Once().
+			Return(storage.ErrOverlap)
+		defer storageMock.AssertExpectations(t)
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.Batch(ctx, BatchDTO{
+			UserID: 1,
+			Operations: []BatchOperationDTO{
+				{Operation: BatchCreate, Event: batchEvent(base)},
+				{Operation: BatchCreate, Event: batchEvent(base.Add(time.Hour))},
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, result.Applied)
+		require.Equal(t, BatchItemResult{ID: 100, Version: 1}, result.Items[0])
+		require.ErrorIs(t, result.Items[1].Err.Errors()[0], ErrTimeIsBusy)
+	})
+}
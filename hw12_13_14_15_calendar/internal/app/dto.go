@@ -2,6 +2,8 @@ package app
 
 import (
 	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 )
 
 type CreateDTO struct {
@@ -10,20 +12,179 @@ type CreateDTO struct {
 	Description string
 	TimeStart   time.Time
 	TimeEnd     time.Time
-	Notify      time.Duration
+	Reminders   []ReminderDTO
+	RRule       string
+	ExDates     []time.Time
+	// TimeZone is an IANA time zone name, DefaultTimeZone is used when it is empty.
+	TimeZone string
+	// NonBlocking events, e.g. tentative or all-day ones, may overlap other events and do not make the time busy.
+	NonBlocking bool
 }
 
 type UpdateDTO struct {
+	UserID      int64
 	Title       string
 	Description string
 	TimeStart   time.Time
 	TimeEnd     time.Time
-	Notify      time.Duration
+	Reminders   []ReminderDTO
+	RRule       string
+	ExDates     []time.Time
+	// TimeZone is an IANA time zone name, the current zone of the event is kept when it is empty.
+	TimeZone string
+	// Version is the version of the event the update is based on, the update fails with ErrEventIsModified
+	// when the event has another version. The version is not checked when it is zero.
+	Version int64
+	// NonBlocking events, e.g. tentative or all-day ones, may overlap other events and do not make the time busy.
+	NonBlocking bool
+}
+
+type ReminderDTO struct {
+	// Channel is DefaultReminderChannel when empty.
+	Channel storage.ReminderChannel
+	Before  time.Duration
 }
 
 type FindByDateDTO struct {
 	UserID int64
 	Date   time.Time
-	Limit  uint8
-	Offset uint8
+	// Limit is the page size, DefaultPageSize is used when it is zero.
+	Limit int
+	// PageToken is NextPageToken of the previous page, it is empty for the first one.
+	PageToken string
+}
+
+type SearchDTO struct {
+	UserID int64
+	From   time.Time
+	To     time.Time
+	// Query is the full-text query, every word of it must be present in the title or the description.
+	Query        string
+	Notification storage.NotificationState
+	// CreatedSince and UpdatedSince are ignored when zero.
+	CreatedSince time.Time
+	UpdatedSince time.Time
+	// Sort is storage.SortTimeStart when empty.
+	Sort      storage.SearchSort
+	Limit     int
+	PageToken string
+}
+
+// TrashDTO requests the deleted events of the user, the recently deleted ones go first.
+type TrashDTO struct {
+	UserID    int64
+	Limit     int
+	PageToken string
+}
+
+type EventPage struct {
+	Events []*storage.Event
+	// NextPageToken is empty on the last page.
+	NextPageToken string
+}
+
+type InviteDTO struct {
+	// UserID is the organizer, only they can invite attendees.
+	UserID  int64
+	EventID int64
+	// Either AttendeeUserID of the calendar user or Email of the external attendee is required.
+	AttendeeUserID int64
+	Email          string
+	Role           storage.AttendeeRole
+}
+
+type RespondDTO struct {
+	UserID  int64
+	EventID int64
+	Status  storage.RSVPStatus
+}
+
+type FreeSlotsDTO struct {
+	UserIDs  []int64
+	From     time.Time
+	To       time.Time
+	Duration time.Duration
+	// WorkDayStart and WorkDayEnd are offsets from the midnight, the whole day is used when both are zero.
+	WorkDayStart time.Duration
+	WorkDayEnd   time.Duration
+	// TimeZone is the IANA time zone of the working hours, DefaultTimeZone is used when it is empty.
+	TimeZone string
+}
+
+type TimeSlot struct {
+	Start time.Time
+	End   time.Time
+}
+
+type ExportDTO struct {
+	UserID int64
+	From   time.Time
+	To     time.Time
+}
+
+type ImportFailure struct {
+	// Index is the position of the VEVENT in the imported file.
+	Index int
+	UID   string
+	Err   *ValidationErrors
+}
+
+type ImportResult struct {
+	Created []int64
+	Failed  []ImportFailure
+}
+
+type BatchOperation string
+
+const (
+	BatchCreate BatchOperation = "create"
+	BatchUpdate BatchOperation = "update"
+	BatchDelete BatchOperation = "delete"
+)
+
+// BatchDTO changes many events of the user at once. In the atomic mode nothing is changed when any
+// operation fails, otherwise the valid operations are applied and the failed ones are reported.
+type BatchDTO struct {
+	UserID     int64
+	Atomic     bool
+	Operations []BatchOperationDTO
+}
+
+// BatchOperationDTO creates an event from Event, updates the event ID with Event or deletes the event ID.
+// UserID of Event is ignored, the events of BatchDTO.UserID are changed.
+type BatchOperationDTO struct {
+	Operation BatchOperation
+	ID        int64
+	Event     UpdateDTO
+}
+
+// BatchItemResult is the result of the operation with the same index. ID is the id of the changed event
+// and Version is its new version, Err is set for the failed operation.
+type BatchItemResult struct {
+	ID      int64
+	Version int64
+	Err     *ValidationErrors
+}
+
+type BatchResult struct {
+	// Applied is false when the atomic batch is rejected, then none of the operations is applied.
+	Applied bool
+	Items   []BatchItemResult
+}
+
+type CredentialsDTO struct {
+	Login    string
+	Password string
+}
+
+type SettingsDTO struct {
+	UserID int64
+	// TimeZone is an IANA time zone name, days, weeks and months are computed in it.
+	TimeZone  string
+	WeekStart time.Weekday
+}
+
+type AuthResult struct {
+	UserID int64
+	Token  string
 }
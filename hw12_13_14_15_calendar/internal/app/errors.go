@@ -10,6 +10,49 @@ var (
 	ErrTimeEndMustBeGreaterThanStart = errors.New("time end must be greater than time start")
 	ErrTimeIsBusy                    = errors.New("time is busy")
 	ErrEventIsNotExists              = errors.New("event is not exists")
+	ErrEventIsModified               = errors.New("event is modified")
+	ErrSeriesIsDeleted               = errors.New("series of the exception is deleted")
+	ErrInvalidRecurrenceRule         = errors.New("invalid recurrence rule")
+	ErrRecurringException            = errors.New("exception of the series can not be recurring")
+	ErrEventIsNotRecurring           = errors.New("event is not recurring")
+	ErrOccurrenceIsNotExists         = errors.New("occurrence is not exists")
+	ErrInvalidCalendar               = errors.New("invalid calendar")
+	ErrImportSeriesIsNotFound        = errors.New("series of the occurrence is not found in the calendar")
+	ErrInvalidLogin                  = errors.New("invalid login")
+	ErrPasswordTooShort              = errors.New("password is too short")
+	ErrLoginIsTaken                  = errors.New("login is taken")
+	ErrInvalidCredentials            = errors.New("invalid credentials")
+	ErrAttendeeIsRequired            = errors.New("either user id or email of the attendee is required")
+	ErrInvalidEmail                  = errors.New("invalid email")
+	ErrInvalidAttendeeRole           = errors.New("invalid attendee role")
+	ErrAttendeeUserIsNotExists       = errors.New("attendee user is not exists")
+	ErrOrganizerCanNotBeAttendee     = errors.New("organizer can not be an attendee")
+	ErrAttendeeAlreadyInvited        = errors.New("attendee is already invited")
+	ErrInvalidRSVPStatus             = errors.New("invalid rsvp status")
+	ErrInvitationIsNotExists         = errors.New("invitation is not exists")
+	ErrUsersAreRequired              = errors.New("users are required")
+	ErrTooManyUsers                  = errors.New("too many users")
+	ErrUserIsNotExists               = errors.New("user is not exists")
+	ErrIntervalTooLong               = errors.New("interval is too long")
+	ErrInvalidSlotDuration           = errors.New("slot duration must be positive")
+	ErrInvalidWorkingHours           = errors.New("invalid working hours")
+	ErrInvalidPageSize               = errors.New("invalid page size")
+	ErrInvalidPageToken              = errors.New("invalid page token")
+	ErrIntervalIsRequired            = errors.New("from and to are required")
+	ErrSearchQueryTooLong            = errors.New("search query is too long")
+	ErrInvalidNotificationState      = errors.New("invalid notification state")
+	ErrInvalidSort                   = errors.New("invalid sort")
+	ErrInvalidTimeZone               = errors.New("invalid time zone")
+	ErrInvalidWeekStart              = errors.New("invalid week start")
+	ErrTooManyReminders              = errors.New("too many reminders")
+	ErrInvalidReminderChannel        = errors.New("invalid reminder channel")
+	ErrInvalidReminderTime           = errors.New("reminder time must be a non-negative number of seconds")
+	ErrDuplicateReminder             = errors.New("duplicate reminder")
+	ErrTooManyChanges                = errors.New("too many changes since the last seen one")
+	ErrBatchIsEmpty                  = errors.New("batch is empty")
+	ErrTooManyOperations             = errors.New("too many operations")
+	ErrInvalidBatchOperation         = errors.New("invalid batch operation")
+	ErrDuplicateBatchEvent           = errors.New("event is changed by several operations of the batch")
 )
 
 type ValidationErrors struct {
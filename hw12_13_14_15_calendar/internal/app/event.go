@@ -4,26 +4,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jinzhu/now"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/changes"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 )
 
 const MaxEventTitleLength = 100
 
+// BusyCheckHorizon limits the occurrences of the series which are checked for overlaps.
+const BusyCheckHorizon = 366 * 24 * time.Hour
+
 var _ EventsUseCase = (*Events)(nil)
 
 type Events struct {
 	storage storage.EventStorage
+	users   storage.UserStorage
+	changes *changes.Bus
 }
 
-func (c *Events) GetByID(ctx context.Context, id int64) (*storage.Event, error) {
-	e, err := c.storage.GetByID(ctx, id)
+func (c *Events) GetByID(ctx context.Context, userID, id int64) (*storage.Event, error) {
+	e, err := c.getOwned(ctx, userID, id)
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			return nil, ErrEventIsNotExists
-		}
+		return nil, fmt.Errorf("event use case get: %w", err)
+	}
 
+	if e.Attendees, err = c.storage.FindAttendees(ctx, e.ID); err != nil {
+		return nil, fmt.Errorf("event use case get: %w", err)
+	}
+
+	if err := attachReminders(ctx, c.storage, []*storage.Event{e}); err != nil {
 		return nil, fmt.Errorf("event use case get: %w", err)
 	}
 
@@ -37,99 +48,359 @@ func (c *Events) Create(ctx context.Context, dto CreateDTO) (int64, error) {
 		Description: dto.Description,
 		TimeStart:   dto.TimeStart,
 		TimeEnd:     dto.TimeEnd,
-		NotifyAt:    storage.CreateNotificationTime(dto.TimeStart, dto.Notify),
+		Reminders:   newReminders(dto.TimeStart, dto.Reminders),
+		RRule:       normalizeRecurrenceRule(dto.RRule),
+		ExDates:     dto.ExDates,
+		TimeZone:    dto.TimeZone,
+		NonBlocking: dto.NonBlocking,
 	}
 
 	if err := c.validate(ctx, e); err != nil {
 		return 0, err
 	}
 
-	id, err := c.storage.Create(ctx, e)
+	entry := newHistoryEntry(dto.UserID, 0, storage.HistoryCreated, nil, e)
+	id, err := c.storage.Create(ctx, e, entry)
 	if err != nil {
-		return 0, fmt.Errorf("event use case create: %w", err)
+		return 0, fmt.Errorf("event use case create: %w", busyError(err))
 	}
+	c.publish(entry)
 
 	return id, nil
 }
 
-func (c *Events) Update(ctx context.Context, id int64, dto UpdateDTO) error {
-	e, err := c.storage.GetByID(ctx, id)
+func (c *Events) Update(ctx context.Context, id int64, dto UpdateDTO) (int64, error) {
+	e, err := c.getOwned(ctx, dto.UserID, id)
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			return ErrEventIsNotExists
-		}
+		return 0, fmt.Errorf("event use case update: %w", err)
+	}
 
-		return fmt.Errorf("event use case update: %w", err)
+	if dto.Version != 0 && dto.Version != e.Version {
+		return 0, fmt.Errorf("event use case update: version %d: %w", dto.Version, ErrEventIsModified)
 	}
 
-	e.Title = dto.Title
-	e.Description = dto.Description
-	e.TimeStart = dto.TimeStart
-	e.TimeEnd = dto.TimeEnd
-	e.NotifyAt = storage.CreateNotificationTime(dto.TimeStart, dto.Notify)
+	// the reminders are replaced, so the old ones are read for the history
+	old := *e
+	if err := attachReminders(ctx, c.storage, []*storage.Event{&old}); err != nil {
+		return 0, fmt.Errorf("event use case update: %w", err)
+	}
+
+	applyUpdate(e, dto)
 
 	if err := c.validate(ctx, e); err != nil {
-		return err
+		return 0, err
 	}
 
-	if err := c.storage.Update(ctx, e); err != nil {
-		return fmt.Errorf("event use case update: %w", err)
+	entry := newHistoryEntry(dto.UserID, id, storage.HistoryUpdated, &old, e)
+	if err := c.update(ctx, e, entry); err != nil {
+		return 0, fmt.Errorf("event use case update: %w", err)
 	}
 
-	return nil
+	return e.Version, nil
 }
 
-func (c *Events) Delete(ctx context.Context, id int64) error {
-	if err := c.storage.Delete(ctx, id); err != nil {
+func (c *Events) Delete(ctx context.Context, userID, id int64) error {
+	if _, err := c.getOwned(ctx, userID, id); err != nil {
+		return fmt.Errorf("event use case delete: %w", err)
+	}
+
+	entry := newHistoryEntry(userID, id, storage.HistoryDeleted, nil, nil)
+	if err := c.storage.Delete(ctx, id, entry); err != nil {
 		return fmt.Errorf("event use case delete: %w", err)
 	}
+	c.publish(entry)
 
 	return nil
 }
 
-func (c *Events) FindForDay(ctx context.Context, dto FindByDateDTO) ([]*storage.Event, error) {
-	noww := now.With(dto.Date)
+// UpdateOccurrence creates an exception which replaces a single occurrence of the series.
+// Recurrence fields of the dto are ignored, an exception can not be recurring itself.
+func (c *Events) UpdateOccurrence(ctx context.Context, id int64, occurrence time.Time, dto UpdateDTO) (int64, error) {
+	series, err := c.getOccurrence(ctx, dto.UserID, id, occurrence)
+	if err != nil {
+		return 0, fmt.Errorf("event use case update occurrence: %w", err)
+	}
+
+	e := &storage.Event{
+		UserID:       series.UserID,
+		Title:        dto.Title,
+		Description:  dto.Description,
+		TimeStart:    dto.TimeStart,
+		TimeEnd:      dto.TimeEnd,
+		Reminders:    newReminders(dto.TimeStart, dto.Reminders),
+		SeriesID:     storage.SeriesID{Int64: series.ID, Valid: true},
+		RecurrenceID: storage.RecurrenceTime{Time: occurrence, Valid: true},
+		TimeZone:     dto.TimeZone,
+		NonBlocking:  dto.NonBlocking,
+	}
+	if e.TimeZone == "" {
+		e.TimeZone = series.TimeZone
+	}
+
+	if err := c.validate(ctx, e); err != nil {
+		return 0, err
+	}
+
+	created := newHistoryEntry(dto.UserID, 0, storage.HistoryCreated, nil, e)
+	exceptionID, err := c.storage.Create(ctx, e, created)
+	if err != nil {
+		return 0, fmt.Errorf("event use case update occurrence: %w", busyError(err))
+	}
+	c.publish(created)
+
+	old := *series
+	series.ExDates = append(series.ExDates, occurrence)
+	updated := newHistoryEntry(dto.UserID, series.ID, storage.HistoryUpdated, &old, series)
+	if err := c.update(ctx, series, updated); err != nil {
+		return 0, fmt.Errorf("event use case update occurrence: %w", err)
+	}
+
+	return exceptionID, nil
+}
+
+func (c *Events) DeleteOccurrence(ctx context.Context, userID, id int64, occurrence time.Time) error {
+	series, err := c.getOccurrence(ctx, userID, id, occurrence)
+	if err != nil {
+		return fmt.Errorf("event use case delete occurrence: %w", err)
+	}
+
+	old := *series
+	series.ExDates = append(series.ExDates, occurrence)
+	entry := newHistoryEntry(userID, series.ID, storage.HistoryUpdated, &old, series)
+	if err := c.update(ctx, series, entry); err != nil {
+		return fmt.Errorf("event use case delete occurrence: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Events) FindForDay(ctx context.Context, dto FindByDateDTO) (*EventPage, error) {
+	noww, err := c.userCalendar(ctx, dto)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find for day: %w", err)
+	}
 
 	from := noww.BeginningOfDay()
 	to := noww.EndOfDay()
 
-	events, err := c.storage.FindForInterval(ctx, dto.UserID, from, to, dto.Limit, dto.Offset)
+	page, err := c.findForInterval(ctx, dto, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("event use case find for day: %w", err)
 	}
 
-	return events, nil
+	return page, nil
 }
 
-func (c *Events) FindForWeek(ctx context.Context, dto FindByDateDTO) ([]*storage.Event, error) {
-	noww := now.With(dto.Date)
+func (c *Events) FindForWeek(ctx context.Context, dto FindByDateDTO) (*EventPage, error) {
+	noww, err := c.userCalendar(ctx, dto)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find for week: %w", err)
+	}
 
 	from := noww.BeginningOfWeek()
 	to := noww.EndOfWeek()
 
-	events, err := c.storage.FindForInterval(ctx, dto.UserID, from, to, dto.Limit, dto.Offset)
+	page, err := c.findForInterval(ctx, dto, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("event use case find for week: %w", err)
 	}
 
-	return events, nil
+	return page, nil
 }
 
-func (c *Events) FindForMonth(ctx context.Context, dto FindByDateDTO) ([]*storage.Event, error) {
-	noww := now.With(dto.Date)
+func (c *Events) FindForMonth(ctx context.Context, dto FindByDateDTO) (*EventPage, error) {
+	noww, err := c.userCalendar(ctx, dto)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find for month: %w", err)
+	}
 
 	from := noww.BeginningOfMonth()
 	to := noww.EndOfMonth()
 
-	events, err := c.storage.FindForInterval(ctx, dto.UserID, from, to, dto.Limit, dto.Offset)
+	page, err := c.findForInterval(ctx, dto, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("event use case find for month: %w", err)
 	}
 
-	return events, nil
+	return page, nil
+}
+
+// userCalendar returns the requested date in the time zone and with the week start of the user.
+func (c *Events) userCalendar(ctx context.Context, dto FindByDateDTO) (*now.Now, error) {
+	u, err := c.users.GetByID(ctx, dto.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return userCalendar(u, dto.Date)
+}
+
+// findForInterval returns the page of one-off events and exceptions merged with occurrences of the series.
+// Occurrences share the id of the series, but their time start differs, so (time_start, id) is still unique.
+func (c *Events) findForInterval(
+	ctx context.Context,
+	dto FindByDateDTO,
+	from, to time.Time,
+) (*EventPage, error) {
+	limit, after, err := pageParams(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more event is fetched to know whether the next page exists
+	events, err := c.storage.FindForInterval(ctx, dto.UserID, from, to, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := c.storage.FindRecurring(ctx, dto.UserID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	expandFrom := from
+	if after != nil && after.TimeStart.After(from) {
+		expandFrom = after.TimeStart
+	}
+	for _, s := range series {
+		occurrences, err := expand(s, expandFrom, to)
+		if err != nil {
+			return nil, fmt.Errorf("expand event %d: %w", s.ID, err)
+		}
+
+		for _, o := range occurrences {
+			if after == nil || isAfter(o, after) {
+				events = append(events, o)
+			}
+		}
+	}
+	sortByTimeStart(events)
+
+	page := &EventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.NextPageToken = encodePageToken(last.TimeStart, last.ID)
+	}
+
+	if err := attachReminders(ctx, c.storage, page.Events); err != nil {
+		return nil, err
+	}
+
+	return page, nil
 }
 
+// applyUpdate sets the fields of the event from the dto, the time zone of the event is kept when it is empty.
+func applyUpdate(e *storage.Event, dto UpdateDTO) {
+	e.Title = dto.Title
+	e.Description = dto.Description
+	e.TimeStart = dto.TimeStart
+	e.TimeEnd = dto.TimeEnd
+	e.Reminders = newReminders(dto.TimeStart, dto.Reminders)
+	e.RRule = normalizeRecurrenceRule(dto.RRule)
+	e.ExDates = dto.ExDates
+	e.RecurrenceUntil = storage.RecurrenceTime{}
+	if dto.TimeZone != "" {
+		e.TimeZone = dto.TimeZone
+	}
+	e.NonBlocking = dto.NonBlocking
+}
+
+// getOwned returns the event of the user, events of other users are reported as not existing.
+func (c *Events) getOwned(ctx context.Context, userID, id int64) (*storage.Event, error) {
+	e, err := c.storage.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrEventIsNotExists
+		}
+
+		return nil, err
+	}
+
+	if e.UserID != userID {
+		return nil, ErrEventIsNotExists
+	}
+
+	return e, nil
+}
+
+// update saves the event read before together with the history entry of the change,
+// it fails with ErrEventIsModified when the event is changed in the meantime.
+func (c *Events) update(ctx context.Context, e *storage.Event, entry *storage.HistoryEntry) error {
+	if err := c.storage.Update(ctx, e, entry); err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return ErrEventIsModified
+		}
+
+		return busyError(err)
+	}
+	c.publish(entry)
+
+	return nil
+}
+
+// busyError reports the overlap found by the storage the same way as the one found by validate,
+// the storage finds it when another event takes the time after the validation.
+func busyError(err error) error {
+	if errors.Is(err, storage.ErrOverlap) {
+		return &ValidationErrors{errors: []error{ErrTimeIsBusy}}
+	}
+
+	return err
+}
+
+func (c *Events) getOccurrence(
+	ctx context.Context,
+	userID, id int64,
+	occurrence time.Time,
+) (*storage.Event, error) {
+	series, err := c.getOwned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !series.IsRecurring() {
+		return nil, ErrEventIsNotRecurring
+	}
+
+	ok, err := isOccurrence(series, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrOccurrenceIsNotExists
+	}
+
+	return series, nil
+}
+
+// validate checks the event, fills RecurrenceUntil of the series and the default time zone.
 func (c *Events) validate(ctx context.Context, e *storage.Event) error {
+	errs, ok := checkEvent(e)
+	if !ok {
+		return &ValidationErrors{errors: errs}
+	}
+
+	busy, err := c.isBusy(ctx, e)
+	if err != nil {
+		return fmt.Errorf("validate event repository error: %w", err)
+	}
+
+	if busy {
+		errs = append(errs, ErrTimeIsBusy)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationErrors{errors: errs}
+	}
+
+	return nil
+}
+
+// checkEvent checks the fields of the event, fills RecurrenceUntil of the series and the default time zone.
+// It is not ok when the occurrences of the event can not be computed, so the overlaps can not be checked.
+func checkEvent(e *storage.Event) ([]error, bool) {
 	errs := make([]error, 0)
 
 	if len(e.Title) > MaxEventTitleLength {
@@ -140,23 +411,141 @@ func (c *Events) validate(ctx context.Context, e *storage.Event) error {
 		errs = append(errs, ErrTimeEndMustBeGreaterThanStart)
 	}
 
-	existed, err := c.storage.FindForInterval(ctx, e.UserID, e.TimeStart, e.TimeEnd, 2, 0)
+	errs = append(errs, validateReminders(e.Reminders)...)
+
+	if e.TimeZone == "" {
+		e.TimeZone = DefaultTimeZone
+	}
+	if _, err := loadLocation(e.TimeZone); err != nil {
+		// the series can not be expanded without the zone
+		return append(errs, err), false
+	}
+
+	if e.IsRecurring() {
+		if e.SeriesID.Valid {
+			errs = append(errs, ErrRecurringException)
+		}
+
+		until, err := recurrenceUntil(e)
+		if err != nil {
+			// the series can not be expanded without the rule
+			return append(errs, err), false
+		}
+		e.RecurrenceUntil = until
+	}
+
+	return errs, true
+}
+
+// isBusy reports whether the blocking event overlaps another blocking event or occurrence of the user.
+// The storage rejects the overlapping one-off events atomically, the check reports the conflict
+// with the series and the conflict before the update of the series. The check of the series is not
+// atomic with the change, a concurrent change of the user may take the time between them.
+func (c *Events) isBusy(ctx context.Context, e *storage.Event) (bool, error) {
+	if e.NonBlocking {
+		return false, nil
+	}
+
+	occurrences, err := busyOccurrences(e)
 	if err != nil {
-		return fmt.Errorf("validate event repository error: %w", err)
+		return false, err
 	}
 
-	if len(existed) > 0 {
-		for _, ex := range existed {
-			if e.ID != ex.ID {
-				errs = append(errs, ErrTimeIsBusy)
-				break
-			}
+	if len(occurrences) == 0 {
+		return false, nil
+	}
+	from, to := occurrences[0].TimeStart, occurrences[len(occurrences)-1].TimeEnd
+
+	existed, err := c.storage.FindOverlapping(ctx, e.UserID, from, to)
+	if err != nil {
+		return false, err
+	}
+
+	if overlapsEvent(e, occurrences, existed) {
+		return true, nil
+	}
+
+	series, err := c.storage.FindRecurring(ctx, e.UserID, from, to)
+	if err != nil {
+		return false, err
+	}
+
+	return overlapsOccurrence(e, occurrences, series)
+}
+
+// busyOccurrences returns the one-off event itself or the occurrences of the series
+// which start within BusyCheckHorizon, an endless series can not be checked entirely.
+func busyOccurrences(e *storage.Event) ([]*storage.Event, error) {
+	if !e.IsRecurring() {
+		return []*storage.Event{e}, nil
+	}
+
+	to := e.TimeStart.Add(BusyCheckHorizon)
+	if e.RecurrenceUntil.Valid && e.RecurrenceUntil.Time.Before(to) {
+		to = e.RecurrenceUntil.Time
+	}
+
+	occurrences, err := expand(e, e.TimeStart, to)
+	if err != nil {
+		return nil, fmt.Errorf("expand event %d: %w", e.ID, err)
+	}
+
+	return occurrences, nil
+}
+
+// conflicts reports whether ex is another blocking event, the exceptions of the series
+// replace its occurrences, so they are not conflicts of the series.
+func conflicts(e, ex *storage.Event) bool {
+	// the events created by the batch have no ids yet
+	if ex == e || (e.ID != 0 && ex.ID == e.ID) || ex.NonBlocking {
+		return false
+	}
+
+	return !(e.IsRecurring() && e.ID != 0 && ex.SeriesID.Valid && ex.SeriesID.Int64 == e.ID)
+}
+
+// overlapsEvent reports whether another blocking event is found among the overlapping events.
+// The storage finds the events overlapping the one-off event, the events found for the series
+// are compared with its occurrences.
+func overlapsEvent(e *storage.Event, occurrences, existed []*storage.Event) bool {
+	for _, ex := range existed {
+		if conflicts(e, ex) && (!e.IsRecurring() || overlapsAny(occurrences, ex)) {
+			return true
 		}
 	}
 
-	if len(errs) > 0 {
-		return &ValidationErrors{errors: errs}
+	return false
+}
+
+// overlapsOccurrence reports whether an occurrence of the event overlaps an occurrence of another blocking series.
+func overlapsOccurrence(e *storage.Event, occurrences, series []*storage.Event) (bool, error) {
+	if len(occurrences) == 0 {
+		return false, nil
 	}
+	from, to := occurrences[0].TimeStart, occurrences[len(occurrences)-1].TimeEnd
 
-	return nil
+	for _, s := range series {
+		if !conflicts(e, s) {
+			continue
+		}
+
+		// the occurrences started before the event still overlap it
+		existed, err := expand(s, from.Add(-s.TimeEnd.Sub(s.TimeStart)), to)
+		if err != nil {
+			return false, fmt.Errorf("expand event %d: %w", s.ID, err)
+		}
+
+		for _, o := range existed {
+			// the occurrence which is replaced by the exception is not a conflict
+			if e.SeriesID.Int64 == s.ID && e.RecurrenceID.Time.Equal(o.RecurrenceID.Time) {
+				continue
+			}
+
+			if overlapsAny(occurrences, o) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
 }
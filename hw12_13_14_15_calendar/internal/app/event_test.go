@@ -2,8 +2,10 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,6 +25,9 @@ var (
 	anyEvent = mock.MatchedBy(func(e *storage.Event) bool {
 		return true
 	})
+	anyHistory = mock.MatchedBy(func(h *storage.HistoryEntry) bool {
+		return true
+	})
 )
 
 func eventStub(t *testing.T) storage.Event {
@@ -36,7 +41,6 @@ func eventStub(t *testing.T) storage.Event {
 		Description: "d",
 		TimeStart:   timeStart,
 		TimeEnd:     timeStart.Add(time.Hour),
-		NotifyAt:    storage.CreateNotificationTime(timeStart, time.Minute),
 	}
 }
 
@@ -49,7 +53,7 @@ func createDtoToEvent(t *testing.T, dto CreateDTO) storage.Event {
 		Description: dto.Description,
 		TimeStart:   dto.TimeStart,
 		TimeEnd:     dto.TimeEnd,
-		NotifyAt:    storage.CreateNotificationTime(dto.TimeStart, dto.Notify),
+		Reminders:   newReminders(dto.TimeStart, dto.Reminders),
 	}
 }
 
@@ -63,13 +67,42 @@ func TestEvents_Get(t *testing.T) {
 			On("GetByID", ctx, id).
 			Once().
 			Return(&expected, nil)
+		storageMock.
+			On("FindAttendees", ctx, expected.ID).
+			Once().
+			Return([]*storage.Attendee{}, nil)
+		storageMock.
+			On("FindReminders", ctx, []int64{expected.ID}).
+			Once().
+			Return([]*storage.Reminder{{ID: 3, EventID: expected.ID, Channel: storage.ChannelEmail, Before: time.Hour}}, nil)
 
 		uc := Events{
 			storage: &storageMock,
 		}
-		actual, err := uc.GetByID(ctx, id)
+		actual, err := uc.GetByID(ctx, expected.UserID, id)
 		require.NoError(t, err)
 		require.Equal(t, &expected, actual)
+		require.Empty(t, actual.Attendees)
+		require.Len(t, actual.Reminders, 1)
+		require.Equal(t, expected.TimeStart.Add(-time.Hour), actual.Reminders[0].NotifyAt)
+	})
+
+	t.Run("other user case", func(t *testing.T) {
+		id := int64(32)
+
+		expected := eventStub(t)
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, id).
+			Once().
+			Return(&expected, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+		actual, err := uc.GetByID(ctx, expected.UserID+1, id)
+		require.Nil(t, actual)
+		require.ErrorIs(t, err, ErrEventIsNotExists)
 	})
 
 	t.Run("not found case", func(t *testing.T) {
@@ -84,7 +117,7 @@ func TestEvents_Get(t *testing.T) {
 		uc := Events{
 			storage: &storageMock,
 		}
-		actual, err := uc.GetByID(ctx, id)
+		actual, err := uc.GetByID(ctx, 1, id)
 		require.Nil(t, actual)
 		require.ErrorIs(t, err, ErrEventIsNotExists)
 	})
@@ -102,7 +135,7 @@ func TestEvents_Get(t *testing.T) {
 		uc := Events{
 			storage: &storageMock,
 		}
-		actual, err := uc.GetByID(ctx, id)
+		actual, err := uc.GetByID(ctx, 1, id)
 		require.Nil(t, actual)
 		require.ErrorIs(t, err, errTest)
 	})
@@ -113,9 +146,12 @@ func TestEventUseCase_Create(t *testing.T) {
 
 	t.Run("success case", func(t *testing.T) {
 		testData := []CreateDTO{
-			{1, "title", "", noww, noww.Add(time.Hour), 0},
-			{1, "title", "descr", noww, noww.Add(time.Hour), 0},
-			{1, "title", "descr", noww, noww.Add(time.Hour), time.Minute * 10},
+			{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", false},
+			{1, "title", "descr", noww, noww.Add(time.Hour), nil, "", nil, "", false},
+			{
+				1, "title", "descr", noww, noww.Add(time.Hour),
+				[]ReminderDTO{{storage.ChannelEmail, time.Minute * 10}}, "", nil, "", false,
+			},
 		}
 
 		for i, dto := range testData {
@@ -123,11 +159,15 @@ func TestEventUseCase_Create(t *testing.T) {
 			t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
 				storageMock := mockstorage.EventStorage{}
 				storageMock.
-					On("FindForInterval", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+					On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+					Once().
+					Return([]*storage.Event{}, nil)
+				storageMock.
+					On("FindRecurring", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
 					Once().
 					Return([]*storage.Event{}, nil)
 				storageMock.
-					On("Create", ctx, anyEvent).
+					On("Create", ctx, anyEvent, anyHistory).
 					Once().
 					Return(int64(32), nil)
 
@@ -148,19 +188,19 @@ func TestEventUseCase_Create(t *testing.T) {
 			err []error
 		}{
 			{
-				dto: CreateDTO{1, longTitle, "", noww, noww.Add(time.Hour), 0},
+				dto: CreateDTO{1, longTitle, "", noww, noww.Add(time.Hour), nil, "", nil, "", false},
 				err: []error{ErrTitleTooLong},
 			},
 			{
-				dto: CreateDTO{1, "title", "", noww, noww.Add(-time.Hour), 0},
+				dto: CreateDTO{1, "title", "", noww, noww.Add(-time.Hour), nil, "", nil, "", false},
 				err: []error{ErrTimeEndMustBeGreaterThanStart},
 			},
 			{
-				dto: CreateDTO{2, "title", "", noww, noww.Add(time.Hour), 0},
+				dto: CreateDTO{2, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", false},
 				err: []error{ErrTimeIsBusy},
 			},
 			{
-				dto: CreateDTO{2, longTitle, "", noww, noww.Add(-time.Hour), 0},
+				dto: CreateDTO{2, longTitle, "", noww, noww.Add(-time.Hour), nil, "", nil, "", false},
 				err: []error{ErrTitleTooLong, ErrTimeEndMustBeGreaterThanStart, ErrTimeIsBusy},
 			},
 		}
@@ -174,10 +214,13 @@ func TestEventUseCase_Create(t *testing.T) {
 				existed.ID = 99
 
 				storageMock.
-					On("FindForInterval", ctx, int64(1), dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+					On("FindOverlapping", ctx, int64(1), dto.TimeStart, dto.TimeEnd).
 					Return([]*storage.Event{}, nil)
 				storageMock.
-					On("FindForInterval", ctx, int64(2), dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+					On("FindRecurring", ctx, int64(1), dto.TimeStart, dto.TimeEnd).
+					Return([]*storage.Event{}, nil)
+				storageMock.
+					On("FindOverlapping", ctx, int64(2), dto.TimeStart, dto.TimeEnd).
 					Return([]*storage.Event{&existed}, nil)
 
 				uc := Events{
@@ -204,14 +247,14 @@ func TestEventUseCase_Create(t *testing.T) {
 	})
 
 	t.Run("storage error", func(t *testing.T) {
-		dto := CreateDTO{1, "title", "", noww, noww.Add(time.Hour), 0}
+		dto := CreateDTO{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", false}
 
-		t.Run("find for interval", func(t *testing.T) {
+		t.Run("find overlapping", func(t *testing.T) {
 			storageMock := mockstorage.EventStorage{}
 
 			errTest := errors.New("some error")
 			storageMock.
-				On("FindForInterval", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
 				Once().
 				Return([]*storage.Event{}, errTest)
 
@@ -229,11 +272,15 @@ func TestEventUseCase_Create(t *testing.T) {
 
 			errTest := errors.New("some error")
 			storageMock.
-				On("FindForInterval", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
 				Once().
 				Return([]*storage.Event{}, nil)
 			storageMock.
-				On("Create", ctx, anyEvent).
+				On("FindRecurring", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("Create", ctx, anyEvent, anyHistory).
 				Once().
 				Return(int64(0), errTest)
 
@@ -246,6 +293,195 @@ func TestEventUseCase_Create(t *testing.T) {
 			require.ErrorIs(t, err, errTest)
 		})
 	})
+
+	t.Run("overlap", func(t *testing.T) {
+		t.Run("time is taken concurrently", func(t *testing.T) {
+			dto := CreateDTO{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", false}
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("FindRecurring", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("Create", ctx, anyEvent, anyHistory).
+				Once().
+				Return(int64(0), storage.ErrOverlap)
+			defer storageMock.AssertExpectations(t)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			_, err := uc.Create(ctx, dto)
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+			require.ErrorIs(t, v.Errors()[0], ErrTimeIsBusy)
+		})
+
+		t.Run("occurrence started before the event", func(t *testing.T) {
+			daily := &storage.Event{
+				ID:        2,
+				UserID:    1,
+				TimeStart: time.Date(2022, 5, 2, 9, 0, 0, 0, time.UTC),
+				TimeEnd:   time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC),
+				RRule:     "FREQ=DAILY",
+				TimeZone:  DefaultTimeZone,
+			}
+			timeStart := time.Date(2022, 5, 10, 9, 30, 0, 0, time.UTC)
+			dto := CreateDTO{1, "title", "", timeStart, timeStart.Add(time.Hour), nil, "", nil, "", false}
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("FindRecurring", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{daily}, nil)
+			defer storageMock.AssertExpectations(t)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			_, err := uc.Create(ctx, dto)
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+			require.ErrorIs(t, v.Errors()[0], ErrTimeIsBusy)
+			storageMock.AssertNotCalled(t, "Create", ctx, anyEvent)
+		})
+
+		t.Run("series over a later event", func(t *testing.T) {
+			timeStart := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+			dto := CreateDTO{1, "title", "", timeStart, timeStart.Add(time.Hour), nil, "FREQ=WEEKLY;COUNT=4", nil, "", false}
+			// the event of the next week overlaps the second occurrence only
+			later := &storage.Event{
+				ID:        2,
+				UserID:    1,
+				TimeStart: timeStart.AddDate(0, 0, 7).Add(30 * time.Minute),
+				TimeEnd:   timeStart.AddDate(0, 0, 7).Add(90 * time.Minute),
+			}
+			// the event between the occurrences is not a conflict
+			between := &storage.Event{
+				ID:        3,
+				UserID:    1,
+				TimeStart: timeStart.AddDate(0, 0, 1),
+				TimeEnd:   timeStart.AddDate(0, 0, 1).Add(time.Hour),
+			}
+			checkedTo := timeStart.AddDate(0, 0, 21).Add(time.Hour)
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, checkedTo).
+				Once().
+				Return([]*storage.Event{between, later}, nil)
+			defer storageMock.AssertExpectations(t)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			_, err := uc.Create(ctx, dto)
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+			require.ErrorIs(t, v.Errors()[0], ErrTimeIsBusy)
+		})
+
+		t.Run("series between other events", func(t *testing.T) {
+			timeStart := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+			dto := CreateDTO{1, "title", "", timeStart, timeStart.Add(time.Hour), nil, "FREQ=WEEKLY;COUNT=4", nil, "", false}
+			between := &storage.Event{
+				ID:        3,
+				UserID:    1,
+				TimeStart: timeStart.AddDate(0, 0, 1),
+				TimeEnd:   timeStart.AddDate(0, 0, 1).Add(time.Hour),
+			}
+			daily := &storage.Event{
+				ID:        4,
+				UserID:    1,
+				TimeStart: timeStart.AddDate(0, 0, -1).Add(time.Hour),
+				TimeEnd:   timeStart.AddDate(0, 0, -1).Add(2 * time.Hour),
+				RRule:     "FREQ=DAILY",
+				TimeZone:  DefaultTimeZone,
+			}
+			checkedTo := timeStart.AddDate(0, 0, 21).Add(time.Hour)
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, checkedTo).
+				Once().
+				Return([]*storage.Event{between}, nil)
+			storageMock.
+				On("FindRecurring", ctx, dto.UserID, dto.TimeStart, checkedTo).
+				Once().
+				Return([]*storage.Event{daily}, nil)
+			storageMock.
+				On("Create", ctx, anyEvent, anyHistory).
+				Once().
+				Return(int64(5), nil)
+			defer storageMock.AssertExpectations(t)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			id, err := uc.Create(ctx, dto)
+			require.NoError(t, err)
+			require.Equal(t, int64(5), id)
+		})
+
+		t.Run("non-blocking events are ignored", func(t *testing.T) {
+			dto := CreateDTO{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", false}
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{{ID: 2, UserID: 1, NonBlocking: true}}, nil)
+			storageMock.
+				On("FindRecurring", ctx, dto.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{{ID: 3, UserID: 1, RRule: "FREQ=DAILY", NonBlocking: true}}, nil)
+			storageMock.
+				On("Create", ctx, anyEvent, anyHistory).
+				Once().
+				Return(int64(4), nil)
+			defer storageMock.AssertExpectations(t)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			id, err := uc.Create(ctx, dto)
+			require.NoError(t, err)
+			require.Equal(t, int64(4), id)
+		})
+
+		t.Run("non-blocking event is not checked", func(t *testing.T) {
+			dto := CreateDTO{1, "tentative", "", noww, noww.Add(time.Hour), nil, "", nil, "", true}
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("Create", ctx, mock.MatchedBy(func(e *storage.Event) bool { return e.NonBlocking }), anyHistory).
+				Once().
+				Return(int64(5), nil)
+			defer storageMock.AssertExpectations(t)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			id, err := uc.Create(ctx, dto)
+			require.NoError(t, err)
+			require.Equal(t, int64(5), id)
+		})
+	})
 }
 
 func TestEventUseCase_Update(t *testing.T) {
@@ -254,10 +490,10 @@ func TestEventUseCase_Update(t *testing.T) {
 
 	t.Run("success case", func(t *testing.T) {
 		testData := []UpdateDTO{
-			{"title", "", noww, noww.Add(time.Hour), 0},
-			{"title", "description", noww, noww.Add(time.Hour), 0},
-			{"title", "", noww, noww.Add(time.Hour), time.Minute},
-			{"title", "description", noww, noww.Add(time.Hour), time.Minute},
+			{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", 0, false},
+			{1, "title", "description", noww, noww.Add(time.Hour), nil, "", nil, "", 0, false},
+			{1, "title", "", noww, noww.Add(time.Hour), []ReminderDTO{{Before: time.Minute}}, "", nil, "", 0, false},
+			{1, "title", "description", noww, noww.Add(time.Hour), []ReminderDTO{{Before: time.Minute}}, "", nil, "", 0, false},
 		}
 
 		userID := int64(1)
@@ -268,7 +504,11 @@ func TestEventUseCase_Update(t *testing.T) {
 				sampleEvent := sampleEvent
 
 				storageMock.
-					On("FindForInterval", ctx, userID, dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+					On("FindOverlapping", ctx, userID, dto.TimeStart, dto.TimeEnd).
+					Once().
+					Return([]*storage.Event{}, nil)
+				storageMock.
+					On("FindRecurring", ctx, userID, dto.TimeStart, dto.TimeEnd).
 					Once().
 					Return([]*storage.Event{}, nil)
 				storageMock.
@@ -276,21 +516,24 @@ func TestEventUseCase_Update(t *testing.T) {
 					Once().
 					Return(&sampleEvent, nil)
 				storageMock.
-					On("Update", ctx, anyEvent).
+					On("Update", ctx, anyEvent, anyHistory).
 					Once().
 					Return(nil)
+				storageMock.
+					On("FindReminders", ctx, mock.Anything).
+					Return([]*storage.Reminder{}, nil)
 
 				uc := Events{
 					storage: &storageMock,
 				}
 
-				err := uc.Update(ctx, 1, dto)
+				_, err := uc.Update(ctx, 1, dto)
 				require.NoError(t, err)
 				require.Equal(t, sampleEvent.Title, dto.Title)
 				require.Equal(t, sampleEvent.Description, dto.Description)
 				require.Equal(t, sampleEvent.TimeStart, dto.TimeStart)
 				require.Equal(t, sampleEvent.TimeEnd, dto.TimeEnd)
-				require.Equal(t, sampleEvent.NotifyAt, storage.CreateNotificationTime(dto.TimeStart, dto.Notify))
+				require.Equal(t, newReminders(dto.TimeStart, dto.Reminders), sampleEvent.Reminders)
 			})
 		}
 	})
@@ -306,10 +549,80 @@ func TestEventUseCase_Update(t *testing.T) {
 			storage: &storageMock,
 		}
 
-		err := uc.Update(ctx, 1, UpdateDTO{})
+		_, err := uc.Update(ctx, 1, UpdateDTO{})
 		require.ErrorIs(t, err, ErrEventIsNotExists)
 	})
 
+	t.Run("other user error", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, int64(1)).
+			Once().
+			Return(&sampleEvent, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+
+		_, err := uc.Update(ctx, 1, UpdateDTO{UserID: sampleEvent.UserID + 1})
+		require.ErrorIs(t, err, ErrEventIsNotExists)
+		storageMock.AssertNotCalled(t, "Update", ctx, anyEvent)
+	})
+
+	t.Run("version mismatch error", func(t *testing.T) {
+		versioned := sampleEvent
+		versioned.Version = 3
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, int64(1)).
+			Once().
+			Return(&versioned, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+
+		_, err := uc.Update(ctx, 1, UpdateDTO{UserID: versioned.UserID, Version: 2})
+		require.ErrorIs(t, err, ErrEventIsModified)
+		storageMock.AssertNotCalled(t, "Update", ctx, anyEvent)
+	})
+
+	t.Run("concurrent update error", func(t *testing.T) {
+		dto := UpdateDTO{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", 3, false}
+		versioned := sampleEvent
+		versioned.Version = 3
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, int64(1)).
+			Once().
+			Return(&versioned, nil)
+		storageMock.
+			On("FindOverlapping", ctx, versioned.UserID, dto.TimeStart, dto.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindRecurring", ctx, versioned.UserID, dto.TimeStart, dto.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		// the event is changed by another client after it is read
+		storageMock.
+			On("Update", ctx, mock.MatchedBy(func(e *storage.Event) bool { return e.Version == 3 }), anyHistory).
+			Once().
+			Return(storage.ErrConflict)
+		storageMock.
+			On("FindReminders", ctx, mock.Anything).
+			Return([]*storage.Reminder{}, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+
+		_, err := uc.Update(ctx, 1, dto)
+		require.ErrorIs(t, err, ErrEventIsModified)
+	})
+
 	t.Run("storage error", func(t *testing.T) {
 		errTest := errors.New("some storage error")
 
@@ -324,12 +637,12 @@ func TestEventUseCase_Update(t *testing.T) {
 				storage: &storageMock,
 			}
 
-			err := uc.Update(ctx, 1, UpdateDTO{})
+			_, err := uc.Update(ctx, 1, UpdateDTO{UserID: 1})
 			require.ErrorIs(t, err, errTest)
 		})
 
 		t.Run("update", func(t *testing.T) {
-			dto := UpdateDTO{"title", "", noww, noww.Add(time.Hour), 0}
+			dto := UpdateDTO{1, "title", "", noww, noww.Add(time.Hour), nil, "", nil, "", 0, false}
 
 			storageMock := mockstorage.EventStorage{}
 			storageMock.
@@ -337,19 +650,26 @@ func TestEventUseCase_Update(t *testing.T) {
 				Once().
 				Return(&sampleEvent, nil)
 			storageMock.
-				On("FindForInterval", ctx, sampleEvent.UserID, dto.TimeStart, dto.TimeEnd, uint8(2), uint8(0)).
+				On("FindOverlapping", ctx, sampleEvent.UserID, dto.TimeStart, dto.TimeEnd).
 				Once().
 				Return([]*storage.Event{}, nil)
 			storageMock.
-				On("Update", ctx, anyEvent).
+				On("FindRecurring", ctx, sampleEvent.UserID, dto.TimeStart, dto.TimeEnd).
+				Once().
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("Update", ctx, anyEvent, anyHistory).
 				Once().
 				Return(errTest)
+			storageMock.
+				On("FindReminders", ctx, mock.Anything).
+				Return([]*storage.Reminder{}, nil)
 
 			uc := Events{
 				storage: &storageMock,
 			}
 
-			err := uc.Update(ctx, 1, dto)
+			_, err := uc.Update(ctx, 1, dto)
 			require.ErrorIs(t, err, errTest)
 		})
 	})
@@ -357,43 +677,67 @@ func TestEventUseCase_Update(t *testing.T) {
 
 func TestEventUseCase_Delete(t *testing.T) {
 	t.Run("success case", func(t *testing.T) {
-		id := int64(56)
+		event := eventStub(t)
 
 		storageMock := mockstorage.EventStorage{}
 		storageMock.
-			On("Delete", ctx, id).
+			On("GetByID", ctx, event.ID).
+			Once().
+			Return(&event, nil)
+		storageMock.
+			On("Delete", ctx, event.ID, anyHistory).
 			Once().
 			Return(nil)
 
 		uc := Events{
 			storage: &storageMock,
 		}
-		require.NoError(t, uc.Delete(ctx, id))
+		require.NoError(t, uc.Delete(ctx, event.UserID, event.ID))
+	})
+
+	t.Run("other user case", func(t *testing.T) {
+		event := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, event.ID).
+			Once().
+			Return(&event, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+		require.ErrorIs(t, uc.Delete(ctx, event.UserID+1, event.ID), ErrEventIsNotExists)
+		storageMock.AssertNotCalled(t, "Delete", ctx, event.ID)
 	})
 
 	t.Run("error case", func(t *testing.T) {
-		id := int64(56)
+		event := eventStub(t)
 		err := errors.New("some storage error")
 
 		storageMock := mockstorage.EventStorage{}
 		storageMock.
-			On("Delete", ctx, id).
+			On("GetByID", ctx, event.ID).
+			Once().
+			Return(&event, nil)
+		storageMock.
+			On("Delete", ctx, event.ID, anyHistory).
 			Once().
 			Return(err)
 
 		uc := Events{
 			storage: &storageMock,
 		}
-		require.ErrorIs(t, uc.Delete(ctx, id), err)
+		require.ErrorIs(t, uc.Delete(ctx, event.UserID, event.ID), err)
 	})
 }
 
 func TestEvents_FindForInterval(t *testing.T) {
 	t.Run("test success", func(t *testing.T) {
 		testData := []FindByDateDTO{
-			{1, time.Now(), 10, 0},
-			{2, time.Now().AddDate(0, 0, 32), 50, 10},
-			{3, time.Now().AddDate(1, 1, 0), 100, 100},
+			{1, time.Now(), 10, ""},
+			{2, time.Now().AddDate(0, 0, 32), 50, encodePageToken(time.Now(), 10)},
+			{3, time.Now().AddDate(1, 1, 0), 0, ""},
 		}
 
 		s1, s2 := eventStub(t), eventStub(t)
@@ -404,86 +748,539 @@ func TestEvents_FindForInterval(t *testing.T) {
 		for i, dto := range testData {
 			dto := dto
 			t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
-				beginningOfDay := now.With(dto.Date).BeginningOfDay()
-				endOfDay := now.With(dto.Date).EndOfDay()
+				limit, after, err := pageParams(dto)
+				require.NoError(t, err)
+
+				beginningOfDay := now.With(dto.Date.UTC()).BeginningOfDay()
+				endOfDay := now.With(dto.Date.UTC()).EndOfDay()
 
-				beginningOfWeek := now.With(dto.Date).BeginningOfWeek()
-				endOfWeek := now.With(dto.Date).EndOfWeek()
+				beginningOfWeek := now.With(dto.Date.UTC()).BeginningOfWeek()
+				endOfWeek := now.With(dto.Date.UTC()).EndOfWeek()
 
-				beginningOfMonth := now.With(dto.Date).BeginningOfMonth()
-				endOfMonth := now.With(dto.Date).EndOfMonth()
+				beginningOfMonth := now.With(dto.Date.UTC()).BeginningOfMonth()
+				endOfMonth := now.With(dto.Date.UTC()).EndOfMonth()
 
 				storageMock := mockstorage.EventStorage{}
 				storageMock.
-					On("FindForInterval", ctx, dto.UserID, beginningOfDay, endOfDay, dto.Limit, dto.Offset).
+					On("FindRecurring", ctx, dto.UserID, beginningOfDay, endOfDay).
+					Once().
+					Return([]*storage.Event{}, nil)
+				storageMock.
+					On("FindForInterval", ctx, dto.UserID, beginningOfDay, endOfDay, after, limit+1).
 					Once().
 					Return(expected, nil)
 				storageMock.
-					On("FindForInterval", ctx, dto.UserID, beginningOfWeek, endOfWeek, dto.Limit, dto.Offset).
+					On("FindRecurring", ctx, dto.UserID, beginningOfWeek, endOfWeek).
+					Once().
+					Return([]*storage.Event{}, nil)
+				storageMock.
+					On("FindForInterval", ctx, dto.UserID, beginningOfWeek, endOfWeek, after, limit+1).
 					Once().
 					Return(expected, nil)
 				storageMock.
-					On("FindForInterval", ctx, dto.UserID, beginningOfMonth, endOfMonth, dto.Limit, dto.Offset).
+					On("FindRecurring", ctx, dto.UserID, beginningOfMonth, endOfMonth).
+					Once().
+					Return([]*storage.Event{}, nil)
+				storageMock.
+					On("FindForInterval", ctx, dto.UserID, beginningOfMonth, endOfMonth, after, limit+1).
 					Once().
 					Return(expected, nil)
+				storageMock.
+					On("FindReminders", ctx, []int64{s1.ID, s2.ID}).
+					Times(3).
+					Return([]*storage.Reminder{}, nil)
 
 				uc := Events{
 					storage: &storageMock,
+					users:   userStub(&storage.User{}),
 				}
 				actual, err := uc.FindForDay(ctx, dto)
 				require.NoError(t, err)
-				require.EqualValues(t, expected, actual)
+				require.EqualValues(t, &EventPage{Events: expected}, actual)
 
 				actual, err = uc.FindForWeek(ctx, dto)
 				require.NoError(t, err)
-				require.EqualValues(t, expected, actual)
+				require.EqualValues(t, &EventPage{Events: expected}, actual)
 
 				actual, err = uc.FindForMonth(ctx, dto)
 				require.NoError(t, err)
-				require.EqualValues(t, expected, actual)
+				require.EqualValues(t, &EventPage{Events: expected}, actual)
 			})
 		}
 	})
 
 	t.Run("test storage error", func(t *testing.T) {
 		errTest := errors.New("storage error")
-		dto := FindByDateDTO{1, time.Now(), 10, 0}
-		beginningOfDay := now.With(dto.Date).BeginningOfDay()
-		endOfDay := now.With(dto.Date).EndOfDay()
+		dto := FindByDateDTO{1, time.Now(), 10, ""}
+		beginningOfDay := now.With(dto.Date.UTC()).BeginningOfDay()
+		endOfDay := now.With(dto.Date.UTC()).EndOfDay()
 
-		beginningOfWeek := now.With(dto.Date).BeginningOfWeek()
-		endOfWeek := now.With(dto.Date).EndOfWeek()
+		beginningOfWeek := now.With(dto.Date.UTC()).BeginningOfWeek()
+		endOfWeek := now.With(dto.Date.UTC()).EndOfWeek()
 
-		beginningOfMonth := now.With(dto.Date).BeginningOfMonth()
-		endOfMonth := now.With(dto.Date).EndOfMonth()
+		beginningOfMonth := now.With(dto.Date.UTC()).BeginningOfMonth()
+		endOfMonth := now.With(dto.Date.UTC()).EndOfMonth()
 
 		storageMock := mockstorage.EventStorage{}
 		storageMock.
-			On("FindForInterval", ctx, dto.UserID, beginningOfDay, endOfDay, dto.Limit, dto.Offset).
+			On("FindRecurring", ctx, dto.UserID, beginningOfDay, endOfDay).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindForInterval", ctx, dto.UserID, beginningOfDay, endOfDay, (*storage.Cursor)(nil), 11).
 			Once().
 			Return(nil, errTest)
 		storageMock.
-			On("FindForInterval", ctx, dto.UserID, beginningOfWeek, endOfWeek, dto.Limit, dto.Offset).
+			On("FindRecurring", ctx, dto.UserID, beginningOfWeek, endOfWeek).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindForInterval", ctx, dto.UserID, beginningOfWeek, endOfWeek, (*storage.Cursor)(nil), 11).
 			Once().
 			Return(nil, errTest)
 		storageMock.
-			On("FindForInterval", ctx, dto.UserID, beginningOfMonth, endOfMonth, dto.Limit, dto.Offset).
+			On("FindRecurring", ctx, dto.UserID, beginningOfMonth, endOfMonth).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindForInterval", ctx, dto.UserID, beginningOfMonth, endOfMonth, (*storage.Cursor)(nil), 11).
 			Once().
 			Return(nil, errTest)
 
 		uc := Events{
 			storage: &storageMock,
+			users:   userStub(&storage.User{}),
 		}
-		events, err := uc.FindForDay(ctx, dto)
-		require.Nil(t, events)
+		page, err := uc.FindForDay(ctx, dto)
+		require.Nil(t, page)
 		require.ErrorIs(t, err, errTest)
 
-		events, err = uc.FindForWeek(ctx, dto)
-		require.Nil(t, events)
+		page, err = uc.FindForWeek(ctx, dto)
+		require.Nil(t, page)
 		require.ErrorIs(t, err, errTest)
 
-		events, err = uc.FindForMonth(ctx, dto)
-		require.Nil(t, events)
+		page, err = uc.FindForMonth(ctx, dto)
+		require.Nil(t, page)
 		require.ErrorIs(t, err, errTest)
 	})
+
+	t.Run("test next page", func(t *testing.T) {
+		dto := FindByDateDTO{1, time.Now(), 2, ""}
+		beginningOfDay := now.With(dto.Date.UTC()).BeginningOfDay()
+		endOfDay := now.With(dto.Date.UTC()).EndOfDay()
+
+		s1, s2, s3 := eventStub(t), eventStub(t), eventStub(t)
+		s2.ID, s3.ID = 2, 3
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("FindRecurring", ctx, dto.UserID, beginningOfDay, endOfDay).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindForInterval", ctx, dto.UserID, beginningOfDay, endOfDay, (*storage.Cursor)(nil), 3).
+			Once().
+			Return([]*storage.Event{&s1, &s2, &s3}, nil)
+		storageMock.
+			On("FindReminders", ctx, []int64{s1.ID, s2.ID}).
+			Once().
+			Return([]*storage.Reminder{}, nil)
+
+		uc := Events{
+			storage: &storageMock,
+			users:   userStub(&storage.User{}),
+		}
+		page, err := uc.FindForDay(ctx, dto)
+		require.NoError(t, err)
+		require.Equal(t, []*storage.Event{&s1, &s2}, page.Events)
+
+		key, id, err := decodePageToken(page.NextPageToken)
+		require.NoError(t, err)
+		require.Equal(t, s2.ID, id)
+		require.True(t, s2.TimeStart.Equal(key))
+	})
+
+	t.Run("test validation error", func(t *testing.T) {
+		uc := Events{
+			storage: &mockstorage.EventStorage{},
+			users:   userStub(&storage.User{}),
+		}
+
+		for _, dto := range []FindByDateDTO{
+			{1, time.Now(), -1, ""},
+			{1, time.Now(), MaxPageSize + 1, ""},
+			{1, time.Now(), 10, "not a token"},
+			{1, time.Now(), 10, base64.RawURLEncoding.EncodeToString([]byte("1:2:3"))},
+		} {
+			_, err := uc.FindForDay(ctx, dto)
+
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+		}
+	})
+}
+
+func TestEvents_FindForIntervalRecurring(t *testing.T) {
+	date := time.Date(2022, 5, 18, 12, 0, 0, 0, time.UTC)
+	from := now.With(date).BeginningOfWeek()
+	to := now.With(date).EndOfWeek()
+
+	standUp := eventStub(t)
+	standUp.ID = 10
+	standUp.TimeStart = time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+	standUp.TimeEnd = standUp.TimeStart.Add(15 * time.Minute)
+	standUp.RRule = "FREQ=WEEKLY;BYDAY=MO,WE,FR"
+	standUp.ExDates = []time.Time{time.Date(2022, 5, 18, 10, 0, 0, 0, time.UTC)}
+
+	oneOff := eventStub(t)
+	oneOff.ID = 20
+	oneOff.TimeStart = time.Date(2022, 5, 19, 10, 0, 0, 0, time.UTC)
+	oneOff.TimeEnd = oneOff.TimeStart.Add(time.Hour)
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.
+		On("FindRecurring", ctx, int64(1), from, to).
+		Once().
+		Return([]*storage.Event{&standUp}, nil)
+	storageMock.
+		On("FindForInterval", ctx, int64(1), from, to, (*storage.Cursor)(nil), 3).
+		Once().
+		Return([]*storage.Event{&oneOff}, nil)
+	storageMock.
+		On("FindReminders", ctx, mock.Anything).
+		Return([]*storage.Reminder{{
+			ID:       1,
+			EventID:  standUp.ID,
+			Channel:  storage.ChannelLog,
+			Before:   time.Minute,
+			NotifyAt: standUp.TimeStart.Add(-time.Minute),
+			Sent:     true,
+		}}, nil)
+
+	uc := Events{
+		storage: &storageMock,
+		users:   userStub(&storage.User{}),
+	}
+
+	page, err := uc.FindForWeek(ctx, FindByDateDTO{1, date, 2, ""})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+	require.Equal(t, standUp.ID, page.Events[0].ID)
+	require.Equal(t, time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC), page.Events[0].TimeStart)
+	require.Equal(t, oneOff.ID, page.Events[1].ID)
+	require.NotEmpty(t, page.NextPageToken)
+
+	after := &storage.Cursor{TimeStart: oneOff.TimeStart, ID: oneOff.ID}
+	storageMock.
+		On("FindRecurring", ctx, int64(1), from, to).
+		Once().
+		Return([]*storage.Event{&standUp}, nil)
+	storageMock.
+		On("FindForInterval", ctx, int64(1), from, to, after, 3).
+		Once().
+		Return([]*storage.Event{}, nil)
+
+	page, err = uc.FindForWeek(ctx, FindByDateDTO{1, date, 2, page.NextPageToken})
+	require.NoError(t, err)
+	require.Empty(t, page.NextPageToken)
+	require.Len(t, page.Events, 1)
+
+	friday := page.Events[0]
+	require.Equal(t, standUp.ID, friday.ID)
+	require.Equal(t, time.Date(2022, 5, 20, 10, 0, 0, 0, time.UTC), friday.TimeStart)
+	require.Equal(t, time.Date(2022, 5, 20, 10, 15, 0, 0, time.UTC), friday.TimeEnd)
+	require.Len(t, friday.Reminders, 1)
+	require.Equal(t, time.Date(2022, 5, 20, 9, 59, 0, 0, time.UTC), friday.Reminders[0].NotifyAt)
+	require.False(t, friday.Reminders[0].Sent)
+	require.Equal(t, friday.TimeStart, friday.RecurrenceID.Time)
+}
+
+func TestEvents_FindForIntervalTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// it is still Tuesday, May 17 in New York
+	date := time.Date(2022, 5, 18, 2, 0, 0, 0, time.UTC)
+	user := &storage.User{ID: 1, TimeZone: "America/New_York", WeekStart: time.Monday}
+
+	testData := []struct {
+		find     func(uc *Events, ctx context.Context, dto FindByDateDTO) (*EventPage, error)
+		from, to time.Time
+	}{
+		{
+			find: (*Events).FindForDay,
+			from: time.Date(2022, 5, 17, 0, 0, 0, 0, newYork),
+			to:   time.Date(2022, 5, 17, 23, 59, 59, 999999999, newYork),
+		},
+		{
+			find: (*Events).FindForWeek,
+			from: time.Date(2022, 5, 16, 0, 0, 0, 0, newYork),
+			to:   time.Date(2022, 5, 22, 23, 59, 59, 999999999, newYork),
+		},
+		{
+			find: (*Events).FindForMonth,
+			from: time.Date(2022, 5, 1, 0, 0, 0, 0, newYork),
+			to:   time.Date(2022, 5, 31, 23, 59, 59, 999999999, newYork),
+		},
+	}
+
+	for i, td := range testData {
+		td := td
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindForInterval", ctx, user.ID, td.from, td.to, (*storage.Cursor)(nil), DefaultPageSize+1).
+				Once().
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("FindRecurring", ctx, user.ID, td.from, td.to).
+				Once().
+				Return([]*storage.Event{}, nil)
+
+			uc := &Events{storage: &storageMock, users: userStub(user)}
+			_, err := td.find(uc, ctx, FindByDateDTO{UserID: user.ID, Date: date})
+			require.NoError(t, err)
+			storageMock.AssertExpectations(t)
+		})
+	}
+
+	t.Run("invalid time zone", func(t *testing.T) {
+		uc := &Events{
+			storage: &mockstorage.EventStorage{},
+			users:   userStub(&storage.User{ID: 1, TimeZone: "Mars/Olympus"}),
+		}
+
+		_, err := uc.FindForDay(ctx, FindByDateDTO{UserID: 1, Date: date})
+		require.ErrorIs(t, err, ErrInvalidTimeZone)
+	})
+}
+
+func TestEvents_ExpandTimeZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// the daily meeting at 10:00 in Berlin, the clocks are moved forward on March 27
+	meeting := eventStub(t)
+	meeting.TimeStart = time.Date(2022, 3, 25, 9, 0, 0, 0, time.UTC)
+	meeting.TimeEnd = meeting.TimeStart.Add(time.Hour)
+	meeting.RRule = "FREQ=DAILY"
+	meeting.TimeZone = "Europe/Berlin"
+
+	occurrences, err := expand(&meeting, meeting.TimeStart, time.Date(2022, 3, 29, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, occurrences, 4)
+
+	for _, o := range occurrences {
+		local := o.TimeStart.In(berlin)
+		require.Equal(t, 10, local.Hour(), local)
+		require.Equal(t, time.Hour, o.TimeEnd.Sub(o.TimeStart))
+	}
+	require.True(t, time.Date(2022, 3, 28, 8, 0, 0, 0, time.UTC).Equal(occurrences[3].TimeStart))
+}
+
+func TestEvents_CreateInvalidTimeZone(t *testing.T) {
+	uc := Events{storage: &mockstorage.EventStorage{}}
+
+	noww := time.Now()
+	for _, tz := range []string{"Mars/Olympus", "Local"} {
+		_, err := uc.Create(ctx, CreateDTO{
+			UserID:    1,
+			Title:     "title",
+			TimeStart: noww,
+			TimeEnd:   noww.Add(time.Hour),
+			TimeZone:  tz,
+		})
+
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		require.ErrorIs(t, v.Errors()[0], ErrInvalidTimeZone)
+	}
+}
+
+func TestEvents_CreateRecurring(t *testing.T) {
+	timeStart := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+
+	testData := []struct {
+		rrule string
+		until storage.RecurrenceTime
+		// the end of the last occurrence checked for overlaps
+		checkedTo time.Time
+		err       error
+	}{
+		{rrule: "RRULE:FREQ=DAILY", checkedTo: time.Date(2023, 5, 3, 11, 0, 0, 0, time.UTC)},
+		{
+			rrule:     "FREQ=WEEKLY;BYDAY=MO,TU;COUNT=3",
+			until:     storage.RecurrenceTime{Time: time.Date(2022, 5, 9, 11, 0, 0, 0, time.UTC), Valid: true},
+			checkedTo: time.Date(2022, 5, 9, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			rrule:     "FREQ=MONTHLY;UNTIL=20221231T100000Z",
+			until:     storage.RecurrenceTime{Time: time.Date(2022, 12, 31, 11, 0, 0, 0, time.UTC), Valid: true},
+			checkedTo: time.Date(2022, 12, 2, 11, 0, 0, 0, time.UTC),
+		},
+		{rrule: "FREQ=HOURLY", err: ErrInvalidRecurrenceRule},
+		{rrule: "FREQ=DAILY;FOO=BAR", err: ErrInvalidRecurrenceRule},
+	}
+
+	for i, td := range testData {
+		td := td
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			dto := CreateDTO{1, "title", "", timeStart, timeStart.Add(time.Hour), nil, td.rrule, nil, "", false}
+
+			storageMock := mockstorage.EventStorage{}
+			storageMock.
+				On("FindOverlapping", ctx, dto.UserID, dto.TimeStart, td.checkedTo).
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("FindRecurring", ctx, dto.UserID, dto.TimeStart, td.checkedTo).
+				Return([]*storage.Event{}, nil)
+			storageMock.
+				On("Create", ctx, mock.MatchedBy(func(e *storage.Event) bool {
+					return e.RecurrenceUntil == td.until && !strings.HasPrefix(e.RRule, "RRULE:")
+				}), anyHistory).
+				Return(int64(1), nil)
+
+			uc := Events{
+				storage: &storageMock,
+			}
+
+			_, err := uc.Create(ctx, dto)
+			if td.err != nil {
+				var v *ValidationErrors
+				require.ErrorAs(t, err, &v)
+				require.ErrorIs(t, v.Errors()[0], td.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEvents_UpdateOccurrence(t *testing.T) {
+	timeStart := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+	occurrence := timeStart.AddDate(0, 0, 7)
+	dto := UpdateDTO{
+		1, "moved", "", occurrence.Add(time.Hour), occurrence.Add(2 * time.Hour), nil, "FREQ=DAILY", nil, "", 0, false,
+	}
+
+	seriesStub := func() storage.Event {
+		series := eventStub(t)
+		series.TimeStart = timeStart
+		series.TimeEnd = timeStart.Add(time.Hour)
+		series.RRule = "FREQ=WEEKLY"
+		return series
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		series := seriesStub()
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, series.ID).
+			Once().
+			Return(&series, nil)
+		storageMock.
+			On("FindOverlapping", ctx, series.UserID, dto.TimeStart, dto.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindRecurring", ctx, series.UserID, dto.TimeStart, dto.TimeEnd).
+			Once().
+			Return([]*storage.Event{&series}, nil)
+		storageMock.
+			On("Create", ctx, mock.MatchedBy(func(e *storage.Event) bool {
+				return e.SeriesID.Int64 == series.ID &&
+					e.RecurrenceID.Time.Equal(occurrence) &&
+					e.RRule == "" &&
+					e.Title == dto.Title
+			}), anyHistory).
+			Once().
+			Return(int64(32), nil)
+		storageMock.
+			On("Update", ctx, mock.MatchedBy(func(e *storage.Event) bool {
+				return e.ID == series.ID && len(e.ExDates) == 1 && e.ExDates[0].Equal(occurrence)
+			}), anyHistory).
+			Once().
+			Return(nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+
+		id, err := uc.UpdateOccurrence(ctx, series.ID, occurrence, dto)
+		require.NoError(t, err)
+		require.Equal(t, int64(32), id)
+	})
+
+	t.Run("not recurring event", func(t *testing.T) {
+		event := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, event.ID).
+			Once().
+			Return(&event, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+
+		_, err := uc.UpdateOccurrence(ctx, event.ID, occurrence, dto)
+		require.ErrorIs(t, err, ErrEventIsNotRecurring)
+	})
+
+	t.Run("not existed occurrence", func(t *testing.T) {
+		series := seriesStub()
+		series.ExDates = []time.Time{occurrence}
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("GetByID", ctx, series.ID).
+			Twice().
+			Return(&series, nil)
+
+		uc := Events{
+			storage: &storageMock,
+		}
+
+		_, err := uc.UpdateOccurrence(ctx, series.ID, occurrence, dto)
+		require.ErrorIs(t, err, ErrOccurrenceIsNotExists)
+
+		_, err = uc.UpdateOccurrence(ctx, series.ID, occurrence.Add(time.Minute), dto)
+		require.ErrorIs(t, err, ErrOccurrenceIsNotExists)
+	})
+}
+
+func TestEvents_DeleteOccurrence(t *testing.T) {
+	series := eventStub(t)
+	series.TimeStart = time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+	series.TimeEnd = series.TimeStart.Add(time.Hour)
+	series.RRule = "FREQ=DAILY"
+	occurrence := series.TimeStart.AddDate(0, 0, 3)
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.
+		On("GetByID", ctx, series.ID).
+		Once().
+		Return(&series, nil)
+	storageMock.
+		On("Update", ctx, mock.MatchedBy(func(e *storage.Event) bool {
+			return len(e.ExDates) == 1 && e.ExDates[0].Equal(occurrence)
+		}), anyHistory).
+		Once().
+		Return(nil)
+
+	uc := Events{
+		storage: &storageMock,
+	}
+
+	require.NoError(t, uc.DeleteOccurrence(ctx, series.UserID, series.ID, occurrence))
+}
+
+// userStub returns the user storage which finds u by any id.
+func userStub(u *storage.User) *mockstorage.UserStorage {
+	m := &mockstorage.UserStorage{}
+	m.On("GetByID", mock.Anything, mock.Anything).Return(u, nil)
+
+	return m
 }
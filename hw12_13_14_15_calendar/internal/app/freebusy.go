@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	MaxFreeSlotsUsers    = 20
+	MaxFreeSlotsInterval = 31 * 24 * time.Hour
+)
+
+// FindFreeSlots returns intervals of at least dto.Duration within the working hours
+// when none of the users has an event or an accepted invitation.
+func (c *Events) FindFreeSlots(ctx context.Context, dto FreeSlotsDTO) ([]TimeSlot, error) {
+	if err := c.validateFreeSlots(ctx, dto); err != nil {
+		return nil, err
+	}
+
+	busy := make([]TimeSlot, 0)
+	for _, userID := range dto.UserIDs {
+		b, err := c.findBusy(ctx, userID, dto.From, dto.To)
+		if err != nil {
+			return nil, fmt.Errorf("event use case find free slots: %w", err)
+		}
+
+		busy = append(busy, b...)
+	}
+	busy = mergeSlots(busy)
+
+	loc, err := loadLocation(dto.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find free slots: %w", err)
+	}
+
+	result := make([]TimeSlot, 0)
+	for _, window := range workingWindows(dto, loc) {
+		for _, free := range subtractSlots(window, busy) {
+			if free.End.Sub(free.Start) >= dto.Duration {
+				result = append(result, free)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// findBusy returns intervals of the user events and accepted invitations which overlap [from, to).
+func (c *Events) findBusy(ctx context.Context, userID int64, from, to time.Time) ([]TimeSlot, error) {
+	events, err := c.storage.FindOverlapping(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := c.storage.FindRecurring(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := c.storage.FindInvitations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range invitations {
+		if i.Attendee.Status != storage.RSVPAccepted {
+			continue
+		}
+
+		if i.Event.IsRecurring() {
+			series = append(series, i.Event)
+		} else {
+			events = append(events, i.Event)
+		}
+	}
+
+	for _, s := range series {
+		// occurrences started before from may still overlap the interval
+		occurrences, err := expand(s, from.Add(-s.TimeEnd.Sub(s.TimeStart)), to)
+		if err != nil {
+			return nil, fmt.Errorf("expand event %d: %w", s.ID, err)
+		}
+
+		events = append(events, occurrences...)
+	}
+
+	result := make([]TimeSlot, 0, len(events))
+	for _, e := range events {
+		if e.NonBlocking {
+			continue
+		}
+
+		if e.TimeStart.Before(to) && e.TimeEnd.After(from) {
+			result = append(result, TimeSlot{Start: e.TimeStart, End: e.TimeEnd})
+		}
+	}
+
+	return result, nil
+}
+
+func (c *Events) validateFreeSlots(ctx context.Context, dto FreeSlotsDTO) error {
+	errs := make([]error, 0)
+
+	switch {
+	case len(dto.UserIDs) == 0:
+		errs = append(errs, ErrUsersAreRequired)
+	case len(dto.UserIDs) > MaxFreeSlotsUsers:
+		errs = append(errs, fmt.Errorf("users count is %d/%d: %w", len(dto.UserIDs), MaxFreeSlotsUsers, ErrTooManyUsers))
+	default:
+		for _, userID := range dto.UserIDs {
+			if _, err := c.users.GetByID(ctx, userID); err != nil {
+				if !errors.Is(err, storage.ErrNotFound) {
+					return fmt.Errorf("validate free slots repository error: %w", err)
+				}
+
+				errs = append(errs, fmt.Errorf("user %d: %w", userID, ErrUserIsNotExists))
+			}
+		}
+	}
+
+	if !dto.From.Before(dto.To) {
+		errs = append(errs, ErrTimeEndMustBeGreaterThanStart)
+	} else if interval := dto.To.Sub(dto.From); interval > MaxFreeSlotsInterval {
+		errs = append(errs, fmt.Errorf("interval is %s/%s: %w", interval, MaxFreeSlotsInterval, ErrIntervalTooLong))
+	}
+
+	if dto.Duration <= 0 {
+		errs = append(errs, ErrInvalidSlotDuration)
+	}
+
+	if dto.WorkDayStart != 0 || dto.WorkDayEnd != 0 {
+		if dto.WorkDayStart < 0 || dto.WorkDayEnd > 24*time.Hour || dto.WorkDayStart >= dto.WorkDayEnd {
+			errs = append(errs, ErrInvalidWorkingHours)
+		}
+	}
+
+	if _, err := loadLocation(dto.TimeZone); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationErrors{errors: errs}
+	}
+
+	return nil
+}
+
+// workingWindows splits [From, To) into working hours of every day in loc,
+// the whole interval is used without them.
+func workingWindows(dto FreeSlotsDTO, loc *time.Location) []TimeSlot {
+	if dto.WorkDayStart == 0 && dto.WorkDayEnd == 0 {
+		return []TimeSlot{{Start: dto.From, End: dto.To}}
+	}
+
+	result := make([]TimeSlot, 0)
+	from := dto.From.In(loc)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	for day.Before(dto.To) {
+		// offsets are added to the wall clock, so the working hours do not move on DST transitions
+		window := TimeSlot{Start: wallClock(day, dto.WorkDayStart), End: wallClock(day, dto.WorkDayEnd)}
+		if window.Start.Before(dto.From) {
+			window.Start = dto.From
+		}
+		if window.End.After(dto.To) {
+			window.End = dto.To
+		}
+
+		if window.Start.Before(window.End) {
+			result = append(result, window)
+		}
+
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return result
+}
+
+// wallClock returns the time of the day which is offset from the midnight by the wall clock.
+func wallClock(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, int(offset), day.Location())
+}
+
+// mergeSlots sorts the slots and joins the overlapping and adjacent ones.
+func mergeSlots(slots []TimeSlot) []TimeSlot {
+	if len(slots) == 0 {
+		return slots
+	}
+
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+
+	result := []TimeSlot{slots[0]}
+	for _, s := range slots[1:] {
+		last := &result[len(result)-1]
+		if s.Start.After(last.End) {
+			result = append(result, s)
+			continue
+		}
+
+		if s.End.After(last.End) {
+			last.End = s.End
+		}
+	}
+
+	return result
+}
+
+// subtractSlots returns parts of the window which are not covered by the sorted and merged busy slots.
+func subtractSlots(window TimeSlot, busy []TimeSlot) []TimeSlot {
+	result := make([]TimeSlot, 0)
+
+	start := window.Start
+	for _, b := range busy {
+		if !b.End.After(start) {
+			continue
+		}
+		if !b.Start.Before(window.End) {
+			break
+		}
+
+		if b.Start.After(start) {
+			result = append(result, TimeSlot{Start: start, End: b.Start})
+		}
+		start = b.End
+	}
+
+	if start.Before(window.End) {
+		result = append(result, TimeSlot{Start: start, End: window.End})
+	}
+
+	return result
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_FindFreeSlots(t *testing.T) {
+	day := time.Date(2022, 10, 17, 0, 0, 0, 0, time.UTC)
+	at := func(d, h, m int) time.Time {
+		return day.AddDate(0, 0, d).Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		dto := FreeSlotsDTO{
+			UserIDs:      []int64{1, 2},
+			From:         day,
+			To:           day.AddDate(0, 0, 2),
+			Duration:     time.Hour,
+			WorkDayStart: 9 * time.Hour,
+			WorkDayEnd:   18 * time.Hour,
+		}
+
+		usersMock := mockstorage.UserStorage{}
+		usersMock.On("GetByID", ctx, mock.Anything).Return(&storage.User{}, nil)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindOverlapping", ctx, int64(1), dto.From, dto.To).Once().Return([]*storage.Event{
+			{ID: 1, UserID: 1, TimeStart: at(0, 10, 0), TimeEnd: at(0, 11, 0)},
+			{ID: 2, UserID: 1, TimeStart: at(0, 16, 30), TimeEnd: at(0, 19, 0)},
+		}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), dto.From, dto.To).Once().Return([]*storage.Event{}, nil)
+		storageMock.On("FindInvitations", ctx, int64(1)).Once().Return([]*storage.Invitation{}, nil)
+
+		storageMock.On("FindOverlapping", ctx, int64(2), dto.From, dto.To).Once().Return([]*storage.Event{
+			{ID: 3, UserID: 2, TimeStart: at(0, 10, 30), TimeEnd: at(0, 12, 0)},
+		}, nil)
+		// the daily standup of the second user
+		storageMock.On("FindRecurring", ctx, int64(2), dto.From, dto.To).Once().Return([]*storage.Event{
+			{ID: 4, UserID: 2, TimeStart: at(-7, 9, 0), TimeEnd: at(-7, 9, 30), RRule: "FREQ=DAILY"},
+		}, nil)
+		storageMock.On("FindInvitations", ctx, int64(2)).Once().Return([]*storage.Invitation{
+			{
+				Event:    &storage.Event{ID: 5, UserID: 3, TimeStart: at(1, 13, 0), TimeEnd: at(1, 14, 0)},
+				Attendee: &storage.Attendee{Status: storage.RSVPAccepted},
+			},
+			{
+				Event:    &storage.Event{ID: 6, UserID: 3, TimeStart: at(1, 15, 0), TimeEnd: at(1, 16, 0)},
+				Attendee: &storage.Attendee{Status: storage.RSVPDeclined},
+			},
+		}, nil)
+
+		uc := Events{storage: &storageMock, users: &usersMock}
+		actual, err := uc.FindFreeSlots(ctx, dto)
+		require.NoError(t, err)
+		require.Equal(t, []TimeSlot{
+			{Start: at(0, 12, 0), End: at(0, 16, 30)},
+			{Start: at(1, 9, 30), End: at(1, 13, 0)},
+			{Start: at(1, 14, 0), End: at(1, 18, 0)},
+		}, actual)
+	})
+
+	t.Run("whole interval", func(t *testing.T) {
+		dto := FreeSlotsDTO{UserIDs: []int64{1}, From: at(0, 9, 0), To: at(0, 12, 0), Duration: 30 * time.Minute}
+
+		usersMock := mockstorage.UserStorage{}
+		usersMock.On("GetByID", ctx, int64(1)).Return(&storage.User{}, nil)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindOverlapping", ctx, int64(1), dto.From, dto.To).Once().Return([]*storage.Event{
+			{ID: 1, UserID: 1, TimeStart: at(0, 8, 0), TimeEnd: at(0, 9, 15)},
+			{ID: 2, UserID: 1, TimeStart: at(0, 10, 0), TimeEnd: at(0, 11, 45)},
+		}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), dto.From, dto.To).Once().Return([]*storage.Event{}, nil)
+		storageMock.On("FindInvitations", ctx, int64(1)).Once().Return([]*storage.Invitation{}, nil)
+
+		uc := Events{storage: &storageMock, users: &usersMock}
+		actual, err := uc.FindFreeSlots(ctx, dto)
+		require.NoError(t, err)
+		require.Equal(t, []TimeSlot{{Start: at(0, 9, 15), End: at(0, 10, 0)}}, actual)
+	})
+
+	t.Run("working hours in the time zone", func(t *testing.T) {
+		// 9:00-18:00 in Tokyo is 0:00-9:00 UTC
+		dto := FreeSlotsDTO{
+			UserIDs:      []int64{1},
+			From:         day,
+			To:           day.AddDate(0, 0, 1),
+			Duration:     time.Hour,
+			WorkDayStart: 9 * time.Hour,
+			WorkDayEnd:   18 * time.Hour,
+			TimeZone:     "Asia/Tokyo",
+		}
+
+		usersMock := mockstorage.UserStorage{}
+		usersMock.On("GetByID", ctx, int64(1)).Return(&storage.User{}, nil)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindOverlapping", ctx, int64(1), dto.From, dto.To).Once().Return([]*storage.Event{
+			{ID: 1, UserID: 1, TimeStart: at(0, 3, 0), TimeEnd: at(0, 4, 0)},
+			// the non-blocking event does not take the time
+			{ID: 2, UserID: 1, TimeStart: at(0, 5, 0), TimeEnd: at(0, 6, 0), NonBlocking: true},
+		}, nil)
+		storageMock.On("FindRecurring", ctx, int64(1), dto.From, dto.To).Once().Return([]*storage.Event{}, nil)
+		storageMock.On("FindInvitations", ctx, int64(1)).Once().Return([]*storage.Invitation{}, nil)
+
+		uc := Events{storage: &storageMock, users: &usersMock}
+		actual, err := uc.FindFreeSlots(ctx, dto)
+		require.NoError(t, err)
+		require.Len(t, actual, 2)
+		require.True(t, actual[0].Start.Equal(at(0, 0, 0)))
+		require.True(t, actual[0].End.Equal(at(0, 3, 0)))
+		require.True(t, actual[1].Start.Equal(at(0, 4, 0)))
+		require.True(t, actual[1].End.Equal(at(0, 9, 0)))
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		usersMock := mockstorage.UserStorage{}
+		usersMock.On("GetByID", ctx, int64(1)).Return(&storage.User{}, nil)
+		usersMock.On("GetByID", ctx, int64(2)).Return(nil, storage.ErrNotFound)
+
+		uc := Events{storage: &mockstorage.EventStorage{}, users: &usersMock}
+
+		cases := []struct {
+			dto      FreeSlotsDTO
+			expected []error
+		}{
+			{
+				FreeSlotsDTO{From: day, To: day.Add(time.Hour), Duration: time.Hour},
+				[]error{ErrUsersAreRequired},
+			},
+			{
+				FreeSlotsDTO{UserIDs: make([]int64, MaxFreeSlotsUsers+1), From: day, To: day.Add(time.Hour), Duration: time.Hour},
+				[]error{ErrTooManyUsers},
+			},
+			{
+				FreeSlotsDTO{UserIDs: []int64{1, 2}, From: day, To: day, Duration: 0},
+				[]error{ErrUserIsNotExists, ErrTimeEndMustBeGreaterThanStart, ErrInvalidSlotDuration},
+			},
+			{
+				FreeSlotsDTO{UserIDs: []int64{1}, From: day, To: day.AddDate(0, 2, 0), Duration: time.Hour},
+				[]error{ErrIntervalTooLong},
+			},
+			{
+				FreeSlotsDTO{
+					UserIDs:      []int64{1},
+					From:         day,
+					To:           day.AddDate(0, 0, 1),
+					Duration:     time.Hour,
+					WorkDayStart: 18 * time.Hour,
+					WorkDayEnd:   9 * time.Hour,
+				},
+				[]error{ErrInvalidWorkingHours},
+			},
+			{
+				FreeSlotsDTO{UserIDs: []int64{1}, From: day, To: day.Add(time.Hour), Duration: time.Hour, TimeZone: "UTC+3"},
+				[]error{ErrInvalidTimeZone},
+			},
+		}
+
+		for _, c := range cases {
+			_, err := uc.FindFreeSlots(ctx, c.dto)
+
+			var v *ValidationErrors
+			require.ErrorAs(t, err, &v)
+			require.Len(t, v.Errors(), len(c.expected))
+			for i, expected := range c.expected {
+				require.ErrorIs(t, v.Errors()[i], expected)
+			}
+		}
+	})
+}
+
+func TestMergeSlots(t *testing.T) {
+	base := time.Date(2022, 10, 17, 0, 0, 0, 0, time.UTC)
+	slot := func(from, to int) TimeSlot {
+		return TimeSlot{Start: base.Add(time.Duration(from) * time.Hour), End: base.Add(time.Duration(to) * time.Hour)}
+	}
+
+	require.Empty(t, mergeSlots(nil))
+	require.Equal(t,
+		[]TimeSlot{slot(1, 4), slot(5, 8)},
+		mergeSlots([]TimeSlot{slot(5, 6), slot(2, 4), slot(1, 3), slot(6, 8), slot(2, 3)}),
+	)
+}
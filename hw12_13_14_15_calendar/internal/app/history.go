@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// FindHistory returns the changes of the event ordered by time, the history of the event in the trash is available too.
+func (c *Events) FindHistory(ctx context.Context, userID, id int64) ([]*storage.HistoryEntry, error) {
+	if _, err := c.getOwned(ctx, userID, id); err != nil {
+		if !errors.Is(err, ErrEventIsNotExists) {
+			return nil, fmt.Errorf("event use case find history: %w", err)
+		}
+
+		if _, err := c.getDeleted(ctx, userID, id); err != nil {
+			return nil, fmt.Errorf("event use case find history: %w", err)
+		}
+	}
+
+	history, err := c.storage.FindHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find history: %w", err)
+	}
+
+	return history, nil
+}
+
+// newHistoryEntry returns the entry of the change of the event made by the user, the storage saves it
+// together with the change. Old is nil for the created event and both events are nil when only the action
+// is recorded, id is set by the storage for the created event.
+func newHistoryEntry(
+	userID, id int64,
+	action storage.HistoryAction,
+	old, updated *storage.Event,
+) *storage.HistoryEntry {
+	entry := &storage.HistoryEntry{
+		EventID: id,
+		UserID:  userID,
+		Action:  action,
+		Changes: make([]*storage.FieldChange, 0),
+	}
+	if updated != nil {
+		if old == nil {
+			old = &storage.Event{}
+		}
+		entry.Changes = diffEvents(old, updated)
+	}
+
+	return entry
+}
+
+// publish sends the saved entry to the watchers of the user,
+// the changes are made by the owner of the event, so the entry is published to their watchers.
+func (c *Events) publish(entry *storage.HistoryEntry) {
+	if c.changes != nil {
+		c.changes.Publish(entry.UserID, entry)
+	}
+}
+
+// diffEvents returns the changed fields of the event named as in the API.
+func diffEvents(old, updated *storage.Event) []*storage.FieldChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"title", old.Title, updated.Title},
+		{"description", old.Description, updated.Description},
+		{"timeStart", formatHistoryTime(old.TimeStart), formatHistoryTime(updated.TimeStart)},
+		{"timeEnd", formatHistoryTime(old.TimeEnd), formatHistoryTime(updated.TimeEnd)},
+		{"timeZone", old.TimeZone, updated.TimeZone},
+		{"rrule", old.RRule, updated.RRule},
+		{"exDates", formatHistoryTimes(old.ExDates), formatHistoryTimes(updated.ExDates)},
+		{"nonBlocking", strconv.FormatBool(old.NonBlocking), strconv.FormatBool(updated.NonBlocking)},
+		{"reminders", formatHistoryReminders(old.Reminders), formatHistoryReminders(updated.Reminders)},
+	}
+
+	changes := make([]*storage.FieldChange, 0)
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, &storage.FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+
+	return changes
+}
+
+func formatHistoryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatHistoryTimes(times []time.Time) string {
+	result := make([]string, 0, len(times))
+	for _, t := range times {
+		result = append(result, formatHistoryTime(t))
+	}
+	sort.Strings(result)
+
+	return strings.Join(result, ",")
+}
+
+// formatHistoryReminders formats the reminders as the sorted list of "channel before" pairs.
+func formatHistoryReminders(reminders []*storage.Reminder) string {
+	result := make([]string, 0, len(reminders))
+	for _, r := range reminders {
+		result = append(result, string(r.Channel)+" "+r.Before.String())
+	}
+	sort.Strings(result)
+
+	return strings.Join(result, ",")
+}
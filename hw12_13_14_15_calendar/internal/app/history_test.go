@@ -0,0 +1,86 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_History(t *testing.T) {
+	t.Run("update is recorded with the diff", func(t *testing.T) {
+		event := eventStub(t)
+		event.TimeZone = DefaultTimeZone
+		dto := UpdateDTO{
+			1, "moved", event.Description, event.TimeStart.Add(time.Hour), event.TimeEnd.Add(time.Hour),
+			[]ReminderDTO{{storage.ChannelLog, time.Minute}}, "", nil, "", 0, false,
+		}
+
+		var recorded *storage.HistoryEntry
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, event.ID).Once().Return(&event, nil)
+		storageMock.
+			On("FindReminders", ctx, []int64{event.ID}).
+			Once().
+			Return([]*storage.Reminder{{EventID: event.ID, Channel: storage.ChannelEmail, Before: time.Hour}}, nil)
+		storageMock.On("FindOverlapping", ctx, event.UserID, dto.TimeStart, dto.TimeEnd).Return([]*storage.Event{}, nil)
+		storageMock.On("FindRecurring", ctx, event.UserID, dto.TimeStart, dto.TimeEnd).Return([]*storage.Event{}, nil)
+		// the history is saved in the transaction of the change
+		storageMock.
+			On("Update", ctx, anyEvent, mock.MatchedBy(func(h *storage.HistoryEntry) bool {
+				recorded = h
+				return true
+			})).
+			Once().
+			Return(nil)
+		defer storageMock.AssertExpectations(t)
+
+		uc := Events{storage: &storageMock}
+		_, err := uc.Update(ctx, event.ID, dto)
+		require.NoError(t, err)
+
+		require.Equal(t, event.ID, recorded.EventID)
+		require.Equal(t, dto.UserID, recorded.UserID)
+		require.Equal(t, storage.HistoryUpdated, recorded.Action)
+
+		fields := make([]string, 0, len(recorded.Changes))
+		for _, c := range recorded.Changes {
+			fields = append(fields, c.Field)
+		}
+		require.Equal(t, []string{"title", "timeStart", "timeEnd", "reminders"}, fields)
+		require.Equal(t, &storage.FieldChange{Field: "title", Old: "t", New: "moved"}, recorded.Changes[0])
+		require.Equal(t, &storage.FieldChange{Field: "reminders", Old: "email 1h0m0s", New: "log 1m0s"}, recorded.Changes[3])
+	})
+
+	t.Run("created event is diffed with the empty one", func(t *testing.T) {
+		event := eventStub(t)
+		event.TimeZone = DefaultTimeZone
+
+		changes := diffEvents(&storage.Event{}, &event)
+		require.Len(t, changes, 5)
+		require.Equal(t, "", changes[0].Old)
+		require.Equal(t, event.TimeStart.UTC().Format(time.RFC3339), changes[2].New)
+	})
+
+	t.Run("history of the deleted event", func(t *testing.T) {
+		deleted := deletedStub(t, 1, time.Now())
+		history := []*storage.HistoryEntry{{ID: 1, EventID: 1, UserID: 1, Action: storage.HistoryDeleted}}
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, int64(1)).Return(nil, storage.ErrNotFound)
+		storageMock.On("GetDeleted", ctx, int64(1)).Return(&deleted, nil)
+		storageMock.On("FindHistory", ctx, int64(1)).Once().Return(history, nil)
+		defer storageMock.AssertExpectations(t)
+
+		uc := Events{storage: &storageMock}
+		result, err := uc.FindHistory(ctx, deleted.UserID, 1)
+		require.NoError(t, err)
+		require.Equal(t, history, result)
+
+		_, err = uc.FindHistory(ctx, deleted.UserID+1, 1)
+		require.ErrorIs(t, err, ErrEventIsNotExists)
+	})
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/ical"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const uidSuffix = "@calendar"
+
+var _ ICalendarUseCase = (*ICalendar)(nil)
+
+type ICalendar struct {
+	events  EventsUseCase
+	storage storage.EventStorage
+}
+
+// Export writes one-off events, exceptions and series of the user started within the interval.
+// Series are exported with RRULE, exceptions are exported as standalone events
+// because the replaced occurrences are already excluded with EXDATE.
+func (c *ICalendar) Export(ctx context.Context, dto ExportDTO, w io.Writer) error {
+	events := make([]*storage.Event, 0)
+	var after *storage.Cursor
+	for {
+		page, err := c.storage.FindForInterval(ctx, dto.UserID, dto.From, dto.To, after, MaxPageSize)
+		if err != nil {
+			return fmt.Errorf("icalendar use case export: %w", err)
+		}
+
+		events = append(events, page...)
+		if len(page) < MaxPageSize {
+			break
+		}
+
+		last := page[len(page)-1]
+		after = &storage.Cursor{TimeStart: last.TimeStart, ID: last.ID}
+	}
+
+	series, err := c.storage.FindRecurring(ctx, dto.UserID, dto.From, dto.To)
+	if err != nil {
+		return fmt.Errorf("icalendar use case export: %w", err)
+	}
+
+	events = append(events, series...)
+	sortByTimeStart(events)
+
+	if err := attachReminders(ctx, c.storage, events); err != nil {
+		return fmt.Errorf("icalendar use case export: %w", err)
+	}
+
+	result := make([]*ical.Event, 0, len(events))
+	for _, e := range events {
+		result = append(result, eventToICal(e))
+	}
+
+	if err := ical.Encode(w, result); err != nil {
+		return fmt.Errorf("icalendar use case export: %w", err)
+	}
+
+	return nil
+}
+
+// Import creates events of the user from the VCALENDAR feed.
+// Invalid VEVENTs are reported in the result and do not abort the import.
+func (c *ICalendar) Import(ctx context.Context, userID int64, r io.Reader) (*ImportResult, error) {
+	events, err := ical.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCalendar, err.Error())
+	}
+
+	result := &ImportResult{
+		Created: make([]int64, 0, len(events)),
+		Failed:  make([]ImportFailure, 0),
+	}
+	fail := func(i int, e *ical.Event, err error) {
+		var v *ValidationErrors
+		if !errors.As(err, &v) {
+			v = &ValidationErrors{errors: []error{err}}
+		}
+
+		result.Failed = append(result.Failed, ImportFailure{Index: i, UID: e.UID, Err: v})
+	}
+
+	// series are created first, so overrides of their occurrences can refer to them by UID
+	series := make(map[string]int64)
+	for i, e := range events {
+		if e.Err != nil {
+			fail(i, e, e.Err)
+			continue
+		}
+
+		if !e.RecurrenceID.IsZero() {
+			continue
+		}
+
+		id, err := c.events.Create(ctx, CreateDTO{
+			UserID:      userID,
+			Title:       e.Summary,
+			Description: e.Description,
+			TimeStart:   e.Start,
+			TimeEnd:     e.End,
+			Reminders:   alarmsToReminders(e.Alarms),
+			RRule:       e.RRule,
+			ExDates:     e.ExDates,
+			TimeZone:    e.Start.Location().String(),
+		})
+		if err != nil {
+			if isImportFailure(err) {
+				fail(i, e, err)
+				continue
+			}
+
+			return nil, fmt.Errorf("icalendar use case import: %w", err)
+		}
+
+		result.Created = append(result.Created, id)
+		if e.RRule != "" && e.UID != "" {
+			series[e.UID] = id
+		}
+	}
+
+	for i, e := range events {
+		if e.Err != nil || e.RecurrenceID.IsZero() {
+			continue
+		}
+
+		seriesID, ok := series[e.UID]
+		if !ok {
+			fail(i, e, ErrImportSeriesIsNotFound)
+			continue
+		}
+
+		id, err := c.events.UpdateOccurrence(ctx, seriesID, e.RecurrenceID, UpdateDTO{
+			UserID:      userID,
+			Title:       e.Summary,
+			Description: e.Description,
+			TimeStart:   e.Start,
+			TimeEnd:     e.End,
+			Reminders:   alarmsToReminders(e.Alarms),
+			TimeZone:    e.Start.Location().String(),
+		})
+		if err != nil {
+			if isImportFailure(err) {
+				fail(i, e, err)
+				continue
+			}
+
+			return nil, fmt.Errorf("icalendar use case import: %w", err)
+		}
+
+		result.Created = append(result.Created, id)
+	}
+
+	sort.SliceStable(result.Failed, func(i, j int) bool {
+		return result.Failed[i].Index < result.Failed[j].Index
+	})
+
+	return result, nil
+}
+
+func isImportFailure(err error) bool {
+	var v *ValidationErrors
+
+	return errors.As(err, &v) ||
+		errors.Is(err, ErrEventIsNotRecurring) ||
+		errors.Is(err, ErrOccurrenceIsNotExists)
+}
+
+// alarmsToReminders imports VALARMs as reminders of the default channel, the same durations are imported once.
+func alarmsToReminders(alarms []time.Duration) []ReminderDTO {
+	result := make([]ReminderDTO, 0, len(alarms))
+	seen := make(map[time.Duration]struct{}, len(alarms))
+	for _, alarm := range alarms {
+		// reminders are stored with the precision of seconds
+		alarm = alarm.Truncate(time.Second)
+		if _, ok := seen[alarm]; ok {
+			continue
+		}
+		seen[alarm] = struct{}{}
+
+		result = append(result, ReminderDTO{Before: alarm})
+	}
+
+	return result
+}
+
+// eventToICal exports every reminder as a VALARM, the reminders of the same duration share one.
+func eventToICal(e *storage.Event) *ical.Event {
+	alarms := make([]time.Duration, 0, len(e.Reminders))
+	seen := make(map[time.Duration]struct{}, len(e.Reminders))
+	for _, r := range e.Reminders {
+		if _, ok := seen[r.Before]; ok || r.Before <= 0 {
+			continue
+		}
+		seen[r.Before] = struct{}{}
+
+		alarms = append(alarms, r.Before)
+	}
+
+	return &ical.Event{
+		UID:         strconv.FormatInt(e.ID, 10) + uidSuffix,
+		Summary:     e.Title,
+		Description: e.Description,
+		Start:       e.TimeStart,
+		End:         e.TimeEnd,
+		RRule:       e.RRule,
+		ExDates:     e.ExDates,
+		Alarms:      alarms,
+		Created:     e.CreatedAt,
+		Updated:     e.UpdatedAt,
+	}
+}
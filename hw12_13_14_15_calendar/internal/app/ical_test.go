@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICalendar_Import(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:ok\r\n" +
+		"SUMMARY:ok\r\n" +
+		"DTSTART:20220502T100000Z\r\n" +
+		"DTEND:20220502T110000Z\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"TRIGGER:-PT15M\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:too-long\r\n" +
+		"SUMMARY:" + strings.Repeat("a", MaxEventTitleLength+1) + "\r\n" +
+		"DTSTART:20220503T100000Z\r\n" +
+		"DTEND:20220503T110000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:malformed\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:orphan\r\n" +
+		"RECURRENCE-ID:20220504T100000Z\r\n" +
+		"DTSTART:20220504T120000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.
+		On("FindOverlapping", ctx, int64(7), mock.Anything, mock.Anything).
+		Return([]*storage.Event{}, nil)
+	storageMock.
+		On("FindRecurring", ctx, int64(7), mock.Anything, mock.Anything).
+		Return([]*storage.Event{}, nil)
+	storageMock.
+		On("Create", ctx, mock.MatchedBy(func(e *storage.Event) bool {
+			return e.UserID == 7 && e.Title == "ok" &&
+				len(e.Reminders) == 1 &&
+				e.Reminders[0].Channel == DefaultReminderChannel &&
+				e.Reminders[0].Before == 15*time.Minute
+		}), anyHistory).
+		Once().
+		Return(int64(1), nil)
+
+	events := &Events{storage: &storageMock}
+	uc := NewICalendarUseCase(events, &storageMock)
+
+	result, err := uc.Import(ctx, 7, strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, result.Created)
+	require.Len(t, result.Failed, 3)
+
+	require.Equal(t, 1, result.Failed[0].Index)
+	require.Equal(t, "too-long", result.Failed[0].UID)
+	require.ErrorIs(t, result.Failed[0].Err.Errors()[0], ErrTitleTooLong)
+
+	require.Equal(t, 2, result.Failed[1].Index)
+	require.Equal(t, "malformed", result.Failed[1].UID)
+
+	require.Equal(t, 3, result.Failed[2].Index)
+	require.ErrorIs(t, result.Failed[2].Err.Errors()[0], ErrImportSeriesIsNotFound)
+
+	t.Run("invalid calendar", func(t *testing.T) {
+		_, err := uc.Import(ctx, 7, strings.NewReader("BEGIN:VEVENT\r\nEND:VEVENT\r\n"))
+		require.ErrorIs(t, err, ErrInvalidCalendar)
+	})
+
+	t.Run("storage error aborts import", func(t *testing.T) {
+		errTest := errors.New("some storage error")
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("FindOverlapping", ctx, int64(7), mock.Anything, mock.Anything).
+			Return(nil, errTest)
+
+		uc := NewICalendarUseCase(&Events{storage: &storageMock}, &storageMock)
+		_, err := uc.Import(ctx, 7, strings.NewReader(data))
+		require.ErrorIs(t, err, errTest)
+	})
+}
+
+func TestICalendar_Export(t *testing.T) {
+	from := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	oneOff := eventStub(t)
+	oneOff.TimeStart = from.AddDate(0, 0, 10)
+	oneOff.TimeEnd = oneOff.TimeStart.Add(time.Hour)
+
+	series := eventStub(t)
+	series.ID = 2
+	series.Title = "series"
+	series.RRule = "FREQ=DAILY"
+	series.TimeStart = from.AddDate(0, 0, 1)
+	series.TimeEnd = series.TimeStart.Add(time.Hour)
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.
+		On("FindForInterval", ctx, int64(1), from, to, (*storage.Cursor)(nil), MaxPageSize).
+		Once().
+		Return([]*storage.Event{&oneOff}, nil)
+	storageMock.
+		On("FindRecurring", ctx, int64(1), from, to).
+		Once().
+		Return([]*storage.Event{&series}, nil)
+	storageMock.
+		On("FindReminders", ctx, []int64{2, 1}).
+		Once().
+		Return([]*storage.Reminder{
+			{EventID: 1, Channel: storage.ChannelLog, Before: time.Minute},
+			{EventID: 1, Channel: storage.ChannelEmail, Before: time.Hour},
+			{EventID: 2, Channel: storage.ChannelLog, Before: time.Minute},
+			{EventID: 2, Channel: storage.ChannelEmail, Before: time.Minute},
+		}, nil)
+
+	uc := NewICalendarUseCase(&Events{storage: &storageMock}, &storageMock)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, uc.Export(ctx, ExportDTO{UserID: 1, From: from, To: to}, buf))
+
+	ics := buf.String()
+	require.Contains(t, ics, "UID:1@calendar\r\n")
+	require.Contains(t, ics, "UID:2@calendar\r\n")
+	require.Contains(t, ics, "RRULE:FREQ=DAILY\r\n")
+	require.Equal(t, 2, strings.Count(ics, "TRIGGER:-PT1M\r\n"))
+	require.Equal(t, 1, strings.Count(ics, "TRIGGER:-PT1H\r\n"))
+	require.Less(t, strings.Index(ics, "SUMMARY:series"), strings.Index(ics, "SUMMARY:t\r\n"))
+}
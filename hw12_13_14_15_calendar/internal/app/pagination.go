@@ -0,0 +1,90 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// encodePageToken makes an opaque token of the sort key and the id of the last event on the page,
+// clients must pass it back as is.
+func encodePageToken(key time.Time, id int64) string {
+	raw := strconv.FormatInt(key.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePageToken(token string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidPageToken
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return time.Time{}, 0, ErrInvalidPageToken
+	}
+
+	nsec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidPageToken
+	}
+
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidPageToken
+	}
+
+	return time.Unix(0, nsec).UTC(), id, nil
+}
+
+// pageLimit returns DefaultPageSize for zero.
+func pageLimit(limit int) (int, error) {
+	switch {
+	case limit == 0:
+		return DefaultPageSize, nil
+	case limit < 0 || limit > MaxPageSize:
+		return 0, fmt.Errorf("limit is %d/%d: %w", limit, MaxPageSize, ErrInvalidPageSize)
+	}
+
+	return limit, nil
+}
+
+// isAfter reports whether the event follows the cursor in (time_start, id) order.
+func isAfter(e *storage.Event, c *storage.Cursor) bool {
+	return e.TimeStart.After(c.TimeStart) || (e.TimeStart.Equal(c.TimeStart) && e.ID > c.ID)
+}
+
+// pageParams validates the page size and the token of FindByDateDTO, the cursor is nil for the first page.
+func pageParams(dto FindByDateDTO) (int, *storage.Cursor, error) {
+	errs := make([]error, 0)
+
+	limit, err := pageLimit(dto.Limit)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	var after *storage.Cursor
+	if dto.PageToken != "" {
+		key, id, err := decodePageToken(dto.PageToken)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		after = &storage.Cursor{TimeStart: key, ID: id}
+	}
+
+	if len(errs) > 0 {
+		return 0, nil, &ValidationErrors{errors: errs}
+	}
+
+	return limit, after, nil
+}
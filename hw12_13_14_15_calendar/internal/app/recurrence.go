@@ -0,0 +1,154 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/teambition/rrule-go"
+)
+
+const rrulePrefix = "RRULE:"
+
+// normalizeRecurrenceRule strips the optional property name, so "RRULE:FREQ=DAILY" is stored as "FREQ=DAILY".
+func normalizeRecurrenceRule(rule string) string {
+	rule = strings.TrimSpace(rule)
+	if strings.HasPrefix(strings.ToUpper(rule), rrulePrefix) {
+		rule = rule[len(rrulePrefix):]
+	}
+
+	return strings.ToUpper(rule)
+}
+
+func parseRecurrenceRule(rule string) (*rrule.ROption, error) {
+	opt, err := rrule.StrToROption(normalizeRecurrenceRule(rule))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRecurrenceRule, err.Error())
+	}
+
+	switch opt.Freq {
+	case rrule.DAILY, rrule.WEEKLY, rrule.MONTHLY, rrule.YEARLY:
+	default:
+		return nil, fmt.Errorf("frequency %s is not supported: %w", opt.Freq, ErrInvalidRecurrenceRule)
+	}
+
+	if !opt.Dtstart.IsZero() {
+		return nil, fmt.Errorf("DTSTART is not allowed, time start is used instead: %w", ErrInvalidRecurrenceRule)
+	}
+
+	return opt, nil
+}
+
+// recurrenceSet builds the set of occurrence start times of the series.
+func recurrenceSet(e *storage.Event) (*rrule.Set, error) {
+	opt, err := parseRecurrenceRule(e.RRule)
+	if err != nil {
+		return nil, err
+	}
+	// occurrences keep the wall clock time of the event zone across DST transitions
+	loc, err := loadLocation(e.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	opt.Dtstart = e.TimeStart.In(loc)
+
+	r, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRecurrenceRule, err.Error())
+	}
+
+	set := &rrule.Set{}
+	set.RRule(r)
+	set.SetExDates(e.ExDates)
+
+	return set, nil
+}
+
+// recurrenceUntil returns the end of the last occurrence, the result is not valid for endless series.
+func recurrenceUntil(e *storage.Event) (storage.RecurrenceTime, error) {
+	var until storage.RecurrenceTime
+
+	opt, err := parseRecurrenceRule(e.RRule)
+	if err != nil {
+		return until, err
+	}
+
+	duration := e.TimeEnd.Sub(e.TimeStart)
+	switch {
+	case opt.Count > 0:
+		set, err := recurrenceSet(e)
+		if err != nil {
+			return until, err
+		}
+
+		if all := set.GetRRule().All(); len(all) > 0 {
+			until.Valid = true
+			until.Time = all[len(all)-1].Add(duration)
+		}
+	case !opt.Until.IsZero():
+		until.Valid = true
+		until.Time = opt.Until.Add(duration)
+	}
+
+	return until, nil
+}
+
+// isOccurrence reports whether the series has a not excluded occurrence started at t.
+func isOccurrence(e *storage.Event, t time.Time) (bool, error) {
+	set, err := recurrenceSet(e)
+	if err != nil {
+		return false, err
+	}
+
+	return len(set.Between(t, t, true)) > 0, nil
+}
+
+// NextOccurrence returns the start of the first not excluded occurrence of the series after t,
+// or at t when inc is set. It is not ok when the series has no more occurrences.
+func NextOccurrence(e *storage.Event, t time.Time, inc bool) (time.Time, bool, error) {
+	set, err := recurrenceSet(e)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	next := set.After(t, inc)
+
+	return next, !next.IsZero(), nil
+}
+
+// expand returns occurrences of the series which start within [from, to].
+func expand(e *storage.Event, from, to time.Time) ([]*storage.Event, error) {
+	set, err := recurrenceSet(e)
+	if err != nil {
+		return nil, err
+	}
+
+	starts := set.Between(from, to, true)
+	result := make([]*storage.Event, 0, len(starts))
+	for _, start := range starts {
+		shift := start.Sub(e.TimeStart)
+
+		o := *e
+		o.TimeStart = start
+		o.TimeEnd = e.TimeEnd.Add(shift)
+		o.Reminders = nil
+		o.RecurrenceID = storage.RecurrenceTime{Time: start, Valid: true}
+
+		result = append(result, &o)
+	}
+
+	return result, nil
+}
+
+// sortByTimeStart orders events by (time_start, id) as the storage pages them.
+func sortByTimeStart(events []*storage.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].TimeStart.Equal(events[j].TimeStart) {
+			return events[i].ID < events[j].ID
+		}
+
+		return events[i].TimeStart.Before(events[j].TimeStart)
+	})
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	MaxReminders           = 5
+	DefaultReminderChannel = storage.ChannelLog
+)
+
+// newReminders builds reminders of the event started at timeStart, the channel defaults to DefaultReminderChannel.
+func newReminders(timeStart time.Time, dtos []ReminderDTO) []*storage.Reminder {
+	result := make([]*storage.Reminder, 0, len(dtos))
+	for _, dto := range dtos {
+		r := &storage.Reminder{
+			Channel:  dto.Channel,
+			Before:   dto.Before,
+			NotifyAt: timeStart.Add(-dto.Before),
+		}
+		if r.Channel == "" {
+			r.Channel = DefaultReminderChannel
+		}
+
+		result = append(result, r)
+	}
+
+	return result
+}
+
+func validateReminders(reminders []*storage.Reminder) []error {
+	errs := make([]error, 0)
+
+	if len(reminders) > MaxReminders {
+		errs = append(errs, fmt.Errorf("reminders count is %d/%d: %w", len(reminders), MaxReminders, ErrTooManyReminders))
+	}
+
+	type key struct {
+		channel storage.ReminderChannel
+		before  time.Duration
+	}
+	seen := make(map[key]struct{}, len(reminders))
+
+	for _, r := range reminders {
+		switch r.Channel {
+		case storage.ChannelEmail, storage.ChannelWebhook, storage.ChannelLog:
+		default:
+			errs = append(errs, fmt.Errorf("channel %q: %w", r.Channel, ErrInvalidReminderChannel))
+		}
+
+		// reminders are stored with the precision of seconds
+		if r.Before < 0 || r.Before%time.Second != 0 {
+			errs = append(errs, fmt.Errorf("reminder %s before: %w", r.Before, ErrInvalidReminderTime))
+		}
+
+		k := key{r.Channel, r.Before}
+		if _, ok := seen[k]; ok {
+			errs = append(errs, fmt.Errorf("reminder %s %s before: %w", r.Channel, r.Before, ErrDuplicateReminder))
+		}
+		seen[k] = struct{}{}
+	}
+
+	return errs
+}
+
+// attachReminders fills reminders of the events. Occurrences get the reminders of their series
+// moved to the occurrence start, the stored reminder of the series is armed for its next occurrence,
+// so the reminders of the occurrences before it are sent.
+func attachReminders(ctx context.Context, s storage.EventStorage, events []*storage.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+
+	reminders, err := s.FindReminders(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byEvent := make(map[int64][]*storage.Reminder)
+	for _, r := range reminders {
+		byEvent[r.EventID] = append(byEvent[r.EventID], r)
+	}
+
+	for _, e := range events {
+		e.Reminders = make([]*storage.Reminder, 0, len(byEvent[e.ID]))
+		for _, stored := range byEvent[e.ID] {
+			r := *stored
+			r.NotifyAt = e.TimeStart.Add(-r.Before)
+			r.Sent = (r.Sent && r.NotifyAt.Equal(stored.NotifyAt)) ||
+				(e.RecurrenceID.Valid && r.NotifyAt.Before(stored.NotifyAt))
+			e.Reminders = append(e.Reminders, &r)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const MaxSearchQueryLength = 200
+
+// Search returns the page of stored events of the user matched by the filters,
+// series are returned as they are stored and are not expanded into occurrences.
+func (c *Events) Search(ctx context.Context, dto SearchDTO) (*EventPage, error) {
+	filter, err := searchFilter(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	// one more event is fetched to know whether the next page exists
+	filter.Limit++
+
+	events, err := c.storage.Search(ctx, *filter)
+	if err != nil {
+		return nil, fmt.Errorf("event use case search: %w", err)
+	}
+
+	page := &EventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.NextPageToken = encodePageToken(searchKey(filter.Sort, last), last.ID)
+	}
+
+	if err := attachReminders(ctx, c.storage, page.Events); err != nil {
+		return nil, fmt.Errorf("event use case search: %w", err)
+	}
+
+	return page, nil
+}
+
+// searchFilter validates the dto and converts it to the storage filter.
+func searchFilter(dto SearchDTO) (*storage.SearchFilter, error) {
+	errs := make([]error, 0)
+
+	switch {
+	case dto.From.IsZero() || dto.To.IsZero():
+		errs = append(errs, ErrIntervalIsRequired)
+	case dto.From.After(dto.To):
+		errs = append(errs, ErrTimeEndMustBeGreaterThanStart)
+	}
+
+	if l := utf8.RuneCountInString(dto.Query); l > MaxSearchQueryLength {
+		errs = append(errs, fmt.Errorf("query length is %d/%d: %w", l, MaxSearchQueryLength, ErrSearchQueryTooLong))
+	}
+
+	switch dto.Notification {
+	case storage.NotificationAny, storage.NotificationNone, storage.NotificationPending, storage.NotificationSent:
+	default:
+		errs = append(errs, fmt.Errorf("notification %q: %w", dto.Notification, ErrInvalidNotificationState))
+	}
+
+	sort := dto.Sort
+	switch sort {
+	case "":
+		sort = storage.SortTimeStart
+	case storage.SortTimeStart, storage.SortTimeStartDesc,
+		storage.SortCreatedAt, storage.SortCreatedAtDesc,
+		storage.SortUpdatedAt, storage.SortUpdatedAtDesc:
+	default:
+		errs = append(errs, fmt.Errorf("sort %q: %w", dto.Sort, ErrInvalidSort))
+	}
+
+	limit, err := pageLimit(dto.Limit)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	var after *storage.SearchCursor
+	if dto.PageToken != "" {
+		key, id, err := decodePageToken(dto.PageToken)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		after = &storage.SearchCursor{Key: key, ID: id}
+	}
+
+	if len(errs) > 0 {
+		return nil, &ValidationErrors{errors: errs}
+	}
+
+	return &storage.SearchFilter{
+		UserID:       dto.UserID,
+		From:         dto.From,
+		To:           dto.To,
+		Query:        dto.Query,
+		Notification: dto.Notification,
+		CreatedSince: dto.CreatedSince,
+		UpdatedSince: dto.UpdatedSince,
+		Sort:         sort,
+		After:        after,
+		Limit:        limit,
+	}, nil
+}
+
+func searchKey(sort storage.SearchSort, e *storage.Event) time.Time {
+	switch sort {
+	case storage.SortCreatedAt, storage.SortCreatedAtDesc:
+		return e.CreatedAt
+	case storage.SortUpdatedAt, storage.SortUpdatedAtDesc:
+		return e.UpdatedAt
+	default:
+		return e.TimeStart
+	}
+}
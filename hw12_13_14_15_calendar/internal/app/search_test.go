@@ -0,0 +1,140 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_Search(t *testing.T) {
+	from := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	t.Run("success case", func(t *testing.T) {
+		dto := SearchDTO{
+			UserID:       1,
+			From:         from,
+			To:           to,
+			Query:        "standup",
+			Notification: storage.NotificationPending,
+			UpdatedSince: from,
+			Sort:         storage.SortUpdatedAtDesc,
+			Limit:        2,
+		}
+
+		e1, e2, e3 := eventStub(t), eventStub(t), eventStub(t)
+		e2.ID, e3.ID = 2, 3
+		e2.UpdatedAt = from.Add(time.Hour)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("Search", ctx, storage.SearchFilter{
+				UserID:       1,
+				From:         from,
+				To:           to,
+				Query:        "standup",
+				Notification: storage.NotificationPending,
+				UpdatedSince: from,
+				Sort:         storage.SortUpdatedAtDesc,
+				Limit:        3,
+			}).
+			Once().
+			Return([]*storage.Event{&e1, &e2, &e3}, nil)
+		storageMock.
+			On("FindReminders", ctx, []int64{e1.ID, e2.ID}).
+			Once().
+			Return([]*storage.Reminder{}, nil)
+
+		uc := Events{storage: &storageMock}
+		page, err := uc.Search(ctx, dto)
+		require.NoError(t, err)
+		require.Equal(t, []*storage.Event{&e1, &e2}, page.Events)
+
+		key, id, err := decodePageToken(page.NextPageToken)
+		require.NoError(t, err)
+		require.Equal(t, e2.ID, id)
+		require.True(t, e2.UpdatedAt.Equal(key))
+
+		dto.PageToken = page.NextPageToken
+		storageMock.
+			On("Search", ctx, mock.MatchedBy(func(f storage.SearchFilter) bool {
+				return f.After != nil && f.After.ID == e2.ID && f.After.Key.Equal(e2.UpdatedAt)
+			})).
+			Once().
+			Return([]*storage.Event{&e3}, nil)
+		storageMock.
+			On("FindReminders", ctx, []int64{e3.ID}).
+			Once().
+			Return([]*storage.Reminder{}, nil)
+
+		page, err = uc.Search(ctx, dto)
+		require.NoError(t, err)
+		require.Equal(t, []*storage.Event{&e3}, page.Events)
+		require.Empty(t, page.NextPageToken)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("Search", ctx, mock.MatchedBy(func(f storage.SearchFilter) bool {
+				return f.Sort == storage.SortTimeStart && f.Limit == DefaultPageSize+1 && f.After == nil
+			})).
+			Once().
+			Return([]*storage.Event{}, nil)
+
+		uc := Events{storage: &storageMock}
+		page, err := uc.Search(ctx, SearchDTO{UserID: 1, From: from, To: to})
+		require.NoError(t, err)
+		require.Empty(t, page.Events)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		uc := Events{storage: &mockstorage.EventStorage{}}
+
+		_, err := uc.Search(ctx, SearchDTO{
+			UserID:       1,
+			From:         to,
+			To:           from,
+			Query:        longTitle + longTitle,
+			Notification: "unknown",
+			Sort:         "title",
+			Limit:        -1,
+			PageToken:    "???",
+		})
+
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		expected := []error{
+			ErrTimeEndMustBeGreaterThanStart,
+			ErrSearchQueryTooLong,
+			ErrInvalidNotificationState,
+			ErrInvalidSort,
+			ErrInvalidPageSize,
+			ErrInvalidPageToken,
+		}
+		require.Len(t, v.Errors(), len(expected))
+		for i, e := range expected {
+			require.ErrorIs(t, v.Errors()[i], e)
+		}
+
+		_, err = uc.Search(ctx, SearchDTO{UserID: 1})
+		require.ErrorAs(t, err, &v)
+		require.ErrorIs(t, v.Errors()[0], ErrIntervalIsRequired)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		testErr := errors.New("test error")
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("Search", ctx, mock.Anything).Once().Return(nil, testErr)
+
+		uc := Events{storage: &storageMock}
+		_, err := uc.Search(ctx, SearchDTO{UserID: 1, From: from, To: to})
+		require.ErrorIs(t, err, testErr)
+	})
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const DefaultTimeZone = "UTC"
+
+// loadLocation returns the IANA time zone, an empty name is DefaultTimeZone.
+// "Local" is rejected because it depends on the server.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimeZone
+	}
+
+	if name == "Local" {
+		return nil, fmt.Errorf("time zone %q: %w", name, ErrInvalidTimeZone)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("time zone %q: %w", name, ErrInvalidTimeZone)
+	}
+
+	return loc, nil
+}
+
+// userCalendar returns the date in the time zone of the user with the week start of the user.
+func userCalendar(u *storage.User, date time.Time) (*now.Now, error) {
+	loc, err := loadLocation(u.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &now.Config{WeekStartDay: u.WeekStart, TimeLocation: loc}
+
+	return cfg.With(date.In(loc)), nil
+}
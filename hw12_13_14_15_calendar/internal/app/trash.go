@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (c *Events) FindDeleted(ctx context.Context, dto TrashDTO) (*EventPage, error) {
+	errs := make([]error, 0)
+
+	limit, err := pageLimit(dto.Limit)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	var after *storage.SearchCursor
+	if dto.PageToken != "" {
+		key, id, err := decodePageToken(dto.PageToken)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		after = &storage.SearchCursor{Key: key, ID: id}
+	}
+
+	if len(errs) > 0 {
+		return nil, &ValidationErrors{errors: errs}
+	}
+
+	// one more event is fetched to know whether the next page exists
+	events, err := c.storage.FindDeleted(ctx, dto.UserID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("event use case find deleted: %w", err)
+	}
+
+	page := &EventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.NextPageToken = encodePageToken(last.DeletedAt.Time, last.ID)
+	}
+
+	if err := attachReminders(ctx, c.storage, page.Events); err != nil {
+		return nil, fmt.Errorf("event use case find deleted: %w", err)
+	}
+
+	return page, nil
+}
+
+// Restore moves the event back from the trash, it fails with ErrTimeIsBusy like Create
+// when the time of the event is taken since it was deleted.
+func (c *Events) Restore(ctx context.Context, userID, id int64) error {
+	e, err := c.getDeleted(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("event use case restore: %w", err)
+	}
+
+	// the exception deleted together with the series is restored with it
+	if e.SeriesID.Valid {
+		if _, err := c.storage.GetByID(ctx, e.SeriesID.Int64); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return fmt.Errorf("event use case restore: %w", ErrSeriesIsDeleted)
+			}
+
+			return fmt.Errorf("event use case restore: %w", err)
+		}
+	}
+
+	busy, err := c.isBusy(ctx, e)
+	if err != nil {
+		return fmt.Errorf("event use case restore: %w", err)
+	}
+
+	if busy {
+		return &ValidationErrors{errors: []error{ErrTimeIsBusy}}
+	}
+
+	entry := newHistoryEntry(userID, id, storage.HistoryRestored, nil, nil)
+	if err := c.storage.Restore(ctx, id, entry); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("event use case restore: %w", ErrEventIsNotExists)
+		}
+
+		return fmt.Errorf("event use case restore: %w", busyError(err))
+	}
+	c.publish(entry)
+
+	return nil
+}
+
+func (c *Events) Purge(ctx context.Context, userID, id int64) error {
+	if _, err := c.getDeleted(ctx, userID, id); err != nil {
+		return fmt.Errorf("event use case purge: %w", err)
+	}
+
+	if err := c.storage.Purge(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("event use case purge: %w", ErrEventIsNotExists)
+		}
+
+		return fmt.Errorf("event use case purge: %w", err)
+	}
+
+	return nil
+}
+
+// getDeleted returns the deleted event of the user, events of other users are reported as not existing.
+func (c *Events) getDeleted(ctx context.Context, userID, id int64) (*storage.Event, error) {
+	e, err := c.storage.GetDeleted(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrEventIsNotExists
+		}
+
+		return nil, err
+	}
+
+	if e.UserID != userID {
+		return nil, ErrEventIsNotExists
+	}
+
+	return e, nil
+}
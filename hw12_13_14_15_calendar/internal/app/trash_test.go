@@ -0,0 +1,157 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func deletedStub(t *testing.T, id int64, deletedAt time.Time) storage.Event {
+	t.Helper()
+
+	e := eventStub(t)
+	e.ID = id
+	e.DeletedAt = storage.DeletedTime{Time: deletedAt, Valid: true}
+
+	return e
+}
+
+func TestEvents_FindDeleted(t *testing.T) {
+	deletedAt := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	e1 := deletedStub(t, 1, deletedAt)
+	e2 := deletedStub(t, 2, deletedAt.Add(-time.Hour))
+	e3 := deletedStub(t, 3, deletedAt.Add(-2*time.Hour))
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.
+		On("FindDeleted", ctx, int64(1), (*storage.SearchCursor)(nil), 3).
+		Once().
+		Return([]*storage.Event{&e1, &e2, &e3}, nil)
+	storageMock.
+		On("FindDeleted", ctx, int64(1), &storage.SearchCursor{Key: e2.DeletedAt.Time, ID: e2.ID}, 3).
+		Once().
+		Return([]*storage.Event{&e3}, nil)
+	storageMock.On("FindReminders", ctx, mock.Anything).Return([]*storage.Reminder{}, nil)
+	defer storageMock.AssertExpectations(t)
+
+	uc := Events{storage: &storageMock}
+
+	page, err := uc.FindDeleted(ctx, TrashDTO{UserID: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Event{&e1, &e2}, page.Events)
+	require.NotEmpty(t, page.NextPageToken)
+
+	page, err = uc.FindDeleted(ctx, TrashDTO{UserID: 1, Limit: 2, PageToken: page.NextPageToken})
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Event{&e3}, page.Events)
+	require.Empty(t, page.NextPageToken)
+
+	_, err = uc.FindDeleted(ctx, TrashDTO{UserID: 1, PageToken: "invalid"})
+	var v *ValidationErrors
+	require.ErrorAs(t, err, &v)
+	require.ErrorIs(t, v.Errors()[0], ErrInvalidPageToken)
+}
+
+func TestEvents_Restore(t *testing.T) {
+	deleted := deletedStub(t, 1, time.Now())
+
+	t.Run("success case", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetDeleted", ctx, int64(1)).Once().Return(&deleted, nil)
+		storageMock.
+			On("FindOverlapping", ctx, deleted.UserID, deleted.TimeStart, deleted.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindRecurring", ctx, deleted.UserID, deleted.TimeStart, deleted.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("Restore", ctx, int64(1), mock.MatchedBy(func(h *storage.HistoryEntry) bool {
+				return h.EventID == 1 && h.Action == storage.HistoryRestored
+			})).
+			Once().
+			Return(nil)
+		defer storageMock.AssertExpectations(t)
+
+		uc := Events{storage: &storageMock}
+		require.NoError(t, uc.Restore(ctx, deleted.UserID, 1))
+	})
+
+	t.Run("event of another user", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetDeleted", ctx, int64(1)).Once().Return(&deleted, nil)
+
+		uc := Events{storage: &storageMock}
+		require.ErrorIs(t, uc.Restore(ctx, deleted.UserID+1, 1), ErrEventIsNotExists)
+		storageMock.AssertNotCalled(t, "Restore", ctx, int64(1))
+	})
+
+	t.Run("time is busy", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetDeleted", ctx, int64(1)).Once().Return(&deleted, nil)
+		storageMock.
+			On("FindOverlapping", ctx, deleted.UserID, deleted.TimeStart, deleted.TimeEnd).
+			Once().
+			Return([]*storage.Event{{ID: 2, UserID: deleted.UserID}}, nil)
+
+		uc := Events{storage: &storageMock}
+		err := uc.Restore(ctx, deleted.UserID, 1)
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		require.ErrorIs(t, v.Errors()[0], ErrTimeIsBusy)
+		storageMock.AssertNotCalled(t, "Restore", ctx, int64(1))
+	})
+
+	t.Run("time is taken concurrently", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetDeleted", ctx, int64(1)).Once().Return(&deleted, nil)
+		storageMock.
+			On("FindOverlapping", ctx, deleted.UserID, deleted.TimeStart, deleted.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.
+			On("FindRecurring", ctx, deleted.UserID, deleted.TimeStart, deleted.TimeEnd).
+			Once().
+			Return([]*storage.Event{}, nil)
+		storageMock.On("Restore", ctx, int64(1), anyHistory).Once().Return(storage.ErrOverlap)
+		defer storageMock.AssertExpectations(t)
+
+		uc := Events{storage: &storageMock}
+		err := uc.Restore(ctx, deleted.UserID, 1)
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		require.ErrorIs(t, v.Errors()[0], ErrTimeIsBusy)
+	})
+
+	t.Run("exception of deleted series", func(t *testing.T) {
+		exception := deletedStub(t, 2, time.Now())
+		exception.SeriesID = storage.SeriesID{Int64: 1, Valid: true}
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetDeleted", ctx, int64(2)).Once().Return(&exception, nil)
+		storageMock.On("GetByID", ctx, int64(1)).Once().Return(nil, storage.ErrNotFound)
+
+		uc := Events{storage: &storageMock}
+		require.ErrorIs(t, uc.Restore(ctx, exception.UserID, 2), ErrSeriesIsDeleted)
+	})
+}
+
+func TestEvents_Purge(t *testing.T) {
+	deleted := deletedStub(t, 1, time.Now())
+
+	storageMock := mockstorage.EventStorage{}
+	storageMock.On("GetDeleted", ctx, int64(1)).Return(&deleted, nil)
+	storageMock.On("GetDeleted", ctx, int64(2)).Return(nil, storage.ErrNotFound)
+	storageMock.On("Purge", ctx, int64(1)).Once().Return(nil)
+	defer storageMock.AssertExpectations(t)
+
+	uc := Events{storage: &storageMock}
+	require.NoError(t, uc.Purge(ctx, deleted.UserID, 1))
+	require.ErrorIs(t, uc.Purge(ctx, deleted.UserID+1, 1), ErrEventIsNotExists)
+	require.ErrorIs(t, uc.Purge(ctx, deleted.UserID, 2), ErrEventIsNotExists)
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MaxLoginLength    = 100
+	MinPasswordLength = 8
+	tokenLength       = 32
+	// dummyPasswordHash is compared with the password of unknown logins,
+	// so they are rejected as slowly as wrong passwords of known ones.
+	dummyPasswordHash = "$2a$10$JH/zY5iuiDi3lqBWMMNYeeyklbHnrI8sNUdAQ/5Jcsxikl4vmiRry"
+)
+
+var _ UsersUseCase = (*Users)(nil)
+
+type Users struct {
+	storage storage.UserStorage
+}
+
+func (c *Users) Register(ctx context.Context, dto CredentialsDTO) (*AuthResult, error) {
+	if err := c.validate(dto); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(dto.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("user use case register: %w", err)
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("user use case register: %w", err)
+	}
+
+	id, err := c.storage.Create(ctx, &storage.User{
+		Login:        dto.Login,
+		PasswordHash: string(passwordHash),
+		TokenHash:    tokenHash,
+		TimeZone:     DefaultTimeZone,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return nil, ErrLoginIsTaken
+		}
+
+		return nil, fmt.Errorf("user use case register: %w", err)
+	}
+
+	return &AuthResult{UserID: id, Token: token}, nil
+}
+
+// Login checks the password and issues a new API token, the previous token of the user stops working.
+func (c *Users) Login(ctx context.Context, dto CredentialsDTO) (*AuthResult, error) {
+	u, err := c.checkPassword(ctx, dto)
+	if err != nil {
+		return nil, fmt.Errorf("user use case login: %w", err)
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("user use case login: %w", err)
+	}
+
+	u.TokenHash = tokenHash
+	if err := c.storage.Update(ctx, u); err != nil {
+		return nil, fmt.Errorf("user use case login: %w", err)
+	}
+
+	return &AuthResult{UserID: u.ID, Token: token}, nil
+}
+
+func (c *Users) AuthenticatePassword(ctx context.Context, dto CredentialsDTO) (int64, error) {
+	u, err := c.checkPassword(ctx, dto)
+	if err != nil {
+		return 0, fmt.Errorf("user use case authenticate: %w", err)
+	}
+
+	return u.ID, nil
+}
+
+func (c *Users) AuthenticateToken(ctx context.Context, token string) (int64, error) {
+	if token == "" {
+		return 0, ErrInvalidCredentials
+	}
+
+	u, err := c.storage.GetByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, ErrInvalidCredentials
+		}
+
+		return 0, fmt.Errorf("user use case authenticate: %w", err)
+	}
+
+	return u.ID, nil
+}
+
+func (c *Users) GetSettings(ctx context.Context, userID int64) (*SettingsDTO, error) {
+	u, err := c.storage.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user use case get settings: %w", err)
+	}
+
+	return userSettings(u), nil
+}
+
+// UpdateSettings changes the time zone and the week start of the user, an empty time zone is DefaultTimeZone.
+func (c *Users) UpdateSettings(ctx context.Context, dto SettingsDTO) (*SettingsDTO, error) {
+	if dto.TimeZone == "" {
+		dto.TimeZone = DefaultTimeZone
+	}
+
+	errs := make([]error, 0)
+	if _, err := loadLocation(dto.TimeZone); err != nil {
+		errs = append(errs, err)
+	}
+	if dto.WeekStart < time.Sunday || dto.WeekStart > time.Saturday {
+		errs = append(errs, fmt.Errorf("week start %d: %w", dto.WeekStart, ErrInvalidWeekStart))
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationErrors{errors: errs}
+	}
+
+	u, err := c.storage.GetByID(ctx, dto.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user use case update settings: %w", err)
+	}
+
+	u.TimeZone = dto.TimeZone
+	u.WeekStart = dto.WeekStart
+	if err := c.storage.Update(ctx, u); err != nil {
+		return nil, fmt.Errorf("user use case update settings: %w", err)
+	}
+
+	return userSettings(u), nil
+}
+
+func (c *Users) checkPassword(ctx context.Context, dto CredentialsDTO) (*storage.User, error) {
+	u, err := c.storage.GetByLogin(ctx, dto.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			_ = bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(dto.Password))
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(dto.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return u, nil
+}
+
+func (c *Users) validate(dto CredentialsDTO) error {
+	errs := make([]error, 0)
+
+	if dto.Login == "" || len(dto.Login) > MaxLoginLength {
+		errs = append(errs, fmt.Errorf("login length is %d/%d: %w", len(dto.Login), MaxLoginLength, ErrInvalidLogin))
+	}
+
+	if len(dto.Password) < MinPasswordLength {
+		errs = append(errs, fmt.Errorf("password must have at least %d characters: %w",
+			MinPasswordLength, ErrPasswordTooShort))
+	}
+
+	if len(errs) > 0 {
+		return &ValidationErrors{errors: errs}
+	}
+
+	return nil
+}
+
+// generateToken returns a random API token and its hash which is kept in the storage.
+func generateToken() (string, string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate token: %w", err)
+	}
+
+	token := hex.EncodeToString(b)
+
+	return token, hashToken(token), nil
+}
+
+func userSettings(u *storage.User) *SettingsDTO {
+	tz := u.TimeZone
+	if tz == "" {
+		tz = DefaultTimeZone
+	}
+
+	return &SettingsDTO{UserID: u.ID, TimeZone: tz, WeekStart: u.WeekStart}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUsers_Register(t *testing.T) {
+	t.Run("success case", func(t *testing.T) {
+		var created *storage.User
+
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("Create", ctx, mock.MatchedBy(func(u *storage.User) bool {
+				created = u
+				return u.Login == "user"
+			})).
+			Once().
+			Return(int64(5), nil)
+
+		uc := Users{storage: &storageMock}
+		result, err := uc.Register(ctx, CredentialsDTO{"user", "password"})
+		require.NoError(t, err)
+		require.Equal(t, int64(5), result.UserID)
+		require.Len(t, result.Token, 2*tokenLength)
+
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(created.PasswordHash), []byte("password")))
+		require.Equal(t, hashToken(result.Token), created.TokenHash)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		uc := Users{storage: &mockstorage.UserStorage{}}
+
+		_, err := uc.Register(ctx, CredentialsDTO{"", "short"})
+
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		require.Len(t, v.Errors(), 2)
+		require.ErrorIs(t, v.Errors()[0], ErrInvalidLogin)
+		require.ErrorIs(t, v.Errors()[1], ErrPasswordTooShort)
+
+		_, err = uc.Register(ctx, CredentialsDTO{strings.Repeat("a", MaxLoginLength+1), "password"})
+		require.ErrorAs(t, err, &v)
+	})
+
+	t.Run("login is taken", func(t *testing.T) {
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("Create", ctx, mock.Anything).
+			Once().
+			Return(int64(0), storage.ErrAlreadyExists)
+
+		uc := Users{storage: &storageMock}
+		_, err := uc.Register(ctx, CredentialsDTO{"user", "password"})
+		require.ErrorIs(t, err, ErrLoginIsTaken)
+	})
+}
+
+func TestUsers_Login(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	userStub := func() *storage.User {
+		return &storage.User{ID: 3, Login: "user", PasswordHash: string(hash), TokenHash: "old"}
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("GetByLogin", ctx, "user").
+			Once().
+			Return(userStub(), nil)
+
+		var tokenHash string
+		storageMock.
+			On("Update", ctx, mock.MatchedBy(func(u *storage.User) bool {
+				tokenHash = u.TokenHash
+				return u.ID == 3
+			})).
+			Once().
+			Return(nil)
+
+		uc := Users{storage: &storageMock}
+		result, err := uc.Login(ctx, CredentialsDTO{"user", "password"})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), result.UserID)
+		require.Equal(t, hashToken(result.Token), tokenHash)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("GetByLogin", ctx, "user").
+			Once().
+			Return(userStub(), nil)
+		storageMock.
+			On("GetByLogin", ctx, "unknown").
+			Once().
+			Return(nil, storage.ErrNotFound)
+
+		uc := Users{storage: &storageMock}
+
+		_, err := uc.Login(ctx, CredentialsDTO{"user", "wrong password"})
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+
+		_, err = uc.Login(ctx, CredentialsDTO{"unknown", "password"})
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
+
+func TestUsers_AuthenticateToken(t *testing.T) {
+	t.Run("success case", func(t *testing.T) {
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("GetByTokenHash", ctx, hashToken("token")).
+			Once().
+			Return(&storage.User{ID: 7}, nil)
+
+		uc := Users{storage: &storageMock}
+		id, err := uc.AuthenticateToken(ctx, "token")
+		require.NoError(t, err)
+		require.Equal(t, int64(7), id)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("GetByTokenHash", ctx, hashToken("token")).
+			Once().
+			Return(nil, storage.ErrNotFound)
+
+		uc := Users{storage: &storageMock}
+
+		_, err := uc.AuthenticateToken(ctx, "token")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+
+		_, err = uc.AuthenticateToken(ctx, "")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		errTest := errors.New("some storage error")
+
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("GetByTokenHash", ctx, hashToken("token")).
+			Once().
+			Return(nil, errTest)
+
+		uc := Users{storage: &storageMock}
+		_, err := uc.AuthenticateToken(ctx, "token")
+		require.ErrorIs(t, err, errTest)
+	})
+}
+
+func TestUsers_UpdateSettings(t *testing.T) {
+	t.Run("success case", func(t *testing.T) {
+		storageMock := mockstorage.UserStorage{}
+		storageMock.
+			On("GetByID", ctx, int64(7)).
+			Once().
+			Return(&storage.User{ID: 7, TimeZone: DefaultTimeZone}, nil)
+		storageMock.
+			On("Update", ctx, mock.MatchedBy(func(u *storage.User) bool {
+				return u.TimeZone == "Europe/Berlin" && u.WeekStart == time.Monday
+			})).
+			Once().
+			Return(nil)
+
+		uc := Users{storage: &storageMock}
+		actual, err := uc.UpdateSettings(ctx, SettingsDTO{UserID: 7, TimeZone: "Europe/Berlin", WeekStart: time.Monday})
+		require.NoError(t, err)
+		require.Equal(t, &SettingsDTO{UserID: 7, TimeZone: "Europe/Berlin", WeekStart: time.Monday}, actual)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		uc := Users{storage: &mockstorage.UserStorage{}}
+
+		_, err := uc.UpdateSettings(ctx, SettingsDTO{UserID: 7, TimeZone: "Mars/Olympus", WeekStart: 7})
+
+		var v *ValidationErrors
+		require.ErrorAs(t, err, &v)
+		require.Len(t, v.Errors(), 2)
+		require.ErrorIs(t, v.Errors()[0], ErrInvalidTimeZone)
+		require.ErrorIs(t, v.Errors()[1], ErrInvalidWeekStart)
+	})
+}
+
+func TestUsers_GetSettings(t *testing.T) {
+	storageMock := mockstorage.UserStorage{}
+	storageMock.
+		On("GetByID", ctx, int64(7)).
+		Once().
+		Return(&storage.User{ID: 7, WeekStart: time.Monday}, nil)
+
+	uc := Users{storage: &storageMock}
+	actual, err := uc.GetSettings(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, &SettingsDTO{UserID: 7, TimeZone: DefaultTimeZone, WeekStart: time.Monday}, actual)
+}
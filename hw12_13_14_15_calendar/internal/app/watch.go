@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxWatchBacklog is the number of missed changes a watcher may resume with,
+// the client refetches the events when it misses more.
+const MaxWatchBacklog = 1000
+
+// Watch streams the changes of the events of the user until ctx is done. The changes after the change afterID
+// are read from the history first, none are read when it is zero. The channel is closed when ctx is done
+// or the watcher falls behind, the client resumes from the last received change then.
+func (c *Events) Watch(ctx context.Context, userID, afterID int64) (<-chan *storage.HistoryEntry, error) {
+	// the subscription starts before the history is read, so no change is missed in between
+	sub := c.changes.Subscribe(userID)
+
+	var backlog []*storage.HistoryEntry
+	if afterID > 0 {
+		var err error
+		// one more change is read to know whether the backlog is too long
+		backlog, err = c.storage.FindUserHistory(ctx, userID, afterID, MaxWatchBacklog+1)
+		if err != nil {
+			sub.Close()
+			return nil, fmt.Errorf("event use case watch: %w", err)
+		}
+
+		if len(backlog) > MaxWatchBacklog {
+			sub.Close()
+			return nil, fmt.Errorf("event use case watch: %w", ErrTooManyChanges)
+		}
+	}
+
+	out := make(chan *storage.HistoryEntry)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		sent := make(map[int64]struct{}, len(backlog))
+		for _, h := range backlog {
+			select {
+			case out <- h:
+				sent[h.ID] = struct{}{}
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for {
+			select {
+			case h, ok := <-sub.C:
+				if !ok {
+					return
+				}
+
+				// the change is published while the backlog is read
+				if _, ok := sent[h.ID]; ok {
+					continue
+				}
+
+				select {
+				case out <- h:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/changes"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_Watch(t *testing.T) {
+	t.Run("resume from the history", func(t *testing.T) {
+		missed := []*storage.HistoryEntry{
+			{ID: 4, EventID: 1, UserID: 1, Action: storage.HistoryUpdated},
+			{ID: 5, EventID: 1, UserID: 1, Action: storage.HistoryDeleted},
+		}
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("FindUserHistory", mock.Anything, int64(1), int64(3), MaxWatchBacklog+1).Once().Return(missed, nil)
+		defer storageMock.AssertExpectations(t)
+
+		bus := changes.New(changes.DefaultBuffer)
+		uc := Events{storage: &storageMock, changes: bus}
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		c, err := uc.Watch(watchCtx, 1, 3)
+		require.NoError(t, err)
+
+		// the published change which is read from the history already is skipped
+		bus.Publish(1, missed[1])
+		bus.Publish(1, &storage.HistoryEntry{ID: 6, EventID: 2, UserID: 1, Action: storage.HistoryCreated})
+		bus.Publish(2, &storage.HistoryEntry{ID: 7, EventID: 3, UserID: 2, Action: storage.HistoryCreated})
+
+		ids := make([]int64, 0)
+		for _, h := range []*storage.HistoryEntry{<-c, <-c, <-c} {
+			ids = append(ids, h.ID)
+		}
+		require.Equal(t, []int64{4, 5, 6}, ids)
+
+		cancel()
+		for range c {
+		}
+	})
+
+	t.Run("recorded changes are published", func(t *testing.T) {
+		event := eventStub(t)
+
+		storageMock := mockstorage.EventStorage{}
+		storageMock.On("GetByID", ctx, event.ID).Once().Return(&event, nil)
+		storageMock.
+			On("Delete", ctx, event.ID, anyHistory).
+			Run(func(args mock.Arguments) {
+				args.Get(2).(*storage.HistoryEntry).ID = 1
+			}).
+			Once().
+			Return(nil)
+
+		uc := Events{storage: &storageMock, changes: changes.New(changes.DefaultBuffer)}
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		c, err := uc.Watch(watchCtx, event.UserID, 0)
+		require.NoError(t, err)
+		storageMock.AssertNotCalled(t, "FindUserHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+		require.NoError(t, uc.Delete(ctx, event.UserID, event.ID))
+
+		h := <-c
+		require.Equal(t, int64(1), h.ID)
+		require.Equal(t, storage.HistoryDeleted, h.Action)
+	})
+
+	t.Run("too many missed changes", func(t *testing.T) {
+		storageMock := mockstorage.EventStorage{}
+		storageMock.
+			On("FindUserHistory", ctx, int64(1), int64(1), MaxWatchBacklog+1).
+			Once().
+			Return(make([]*storage.HistoryEntry, MaxWatchBacklog+1), nil)
+
+		uc := Events{storage: &storageMock, changes: changes.New(changes.DefaultBuffer)}
+
+		_, err := uc.Watch(ctx, 1, 1)
+		require.ErrorIs(t, err, ErrTooManyChanges)
+	})
+}
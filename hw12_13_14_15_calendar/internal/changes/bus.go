@@ -0,0 +1,88 @@
+package changes
+
+import (
+	"sync"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// DefaultBuffer is the number of changes a subscriber may fall behind by before it is closed.
+const DefaultBuffer = 100
+
+// Bus delivers the changes of the events to the subscribers of their owners within the process,
+// a change is identified by the id of its history entry.
+type Bus struct {
+	mu          sync.Mutex
+	buffer      int
+	subscribers map[int64]map[*Subscription]struct{}
+}
+
+// Subscription receives the changes of the events of the user from C. C is closed by Close
+// and when the subscriber falls behind by more than the buffer, it resumes from the history then.
+type Subscription struct {
+	C <-chan *storage.HistoryEntry
+
+	c      chan *storage.HistoryEntry
+	userID int64
+	bus    *Bus
+}
+
+func New(buffer int) *Bus {
+	return &Bus{
+		buffer:      buffer,
+		subscribers: make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) Subscribe(userID int64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan *storage.HistoryEntry, b.buffer)
+	sub := &Subscription{C: c, c: c, userID: userID, bus: b}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// Publish sends the change of the event of the user to its subscribers without blocking.
+func (b *Bus) Publish(userID int64, entry *storage.HistoryEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[userID] {
+		select {
+		case sub.c <- entry:
+		default:
+			b.unsubscribe(sub)
+		}
+	}
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.unsubscribe(s)
+}
+
+// unsubscribe closes the subscription once, the caller must hold the lock.
+func (b *Bus) unsubscribe(sub *Subscription) {
+	subs, ok := b.subscribers[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.userID)
+	}
+	close(sub.c)
+}
@@ -0,0 +1,54 @@
+package changes
+
+import (
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("changes are delivered to the subscribers of the user", func(t *testing.T) {
+		bus := New(DefaultBuffer)
+		first := bus.Subscribe(1)
+		second := bus.Subscribe(1)
+		other := bus.Subscribe(2)
+		defer first.Close()
+		defer second.Close()
+		defer other.Close()
+
+		entry := &storage.HistoryEntry{ID: 1, EventID: 10, Action: storage.HistoryCreated}
+		bus.Publish(1, entry)
+
+		require.Equal(t, entry, <-first.C)
+		require.Equal(t, entry, <-second.C)
+		require.Empty(t, other.C)
+	})
+
+	t.Run("closed subscription", func(t *testing.T) {
+		bus := New(DefaultBuffer)
+		sub := bus.Subscribe(1)
+		sub.Close()
+		sub.Close()
+
+		bus.Publish(1, &storage.HistoryEntry{ID: 1})
+		_, ok := <-sub.C
+		require.False(t, ok)
+	})
+
+	t.Run("slow subscriber is closed", func(t *testing.T) {
+		bus := New(1)
+		sub := bus.Subscribe(1)
+
+		bus.Publish(1, &storage.HistoryEntry{ID: 1})
+		bus.Publish(1, &storage.HistoryEntry{ID: 2})
+
+		entry, ok := <-sub.C
+		require.True(t, ok)
+		require.Equal(t, int64(1), entry.ID)
+		_, ok = <-sub.C
+		require.False(t, ok)
+
+		sub.Close()
+	})
+}
@@ -0,0 +1,202 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// State is the stage of the lifecycle of the process.
+type State string
+
+const (
+	StateStarting  State = "starting"
+	StateMigrating State = "migrating"
+	StateServing   State = "serving"
+	StateStopping  State = "stopping"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// checkTimeout limits the time of a probe, so a hung dependency is reported instead of hanging the probe.
+const checkTimeout = 2 * time.Second
+
+// Check returns the error when the dependency or the component is not usable.
+type Check func(ctx context.Context) error
+
+// Report is the result of a probe, Checks holds "ok" or the error of every check by its name.
+type Report struct {
+	Status string            `json:"status"`
+	State  State             `json:"state"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+// Checker keeps the state of the process and the checks of its dependencies and components.
+type Checker struct {
+	mu        sync.RWMutex
+	state     State
+	readiness map[string]Check
+	liveness  map[string]Check
+}
+
+func New() *Checker {
+	return &Checker{
+		state:     StateStarting,
+		readiness: make(map[string]Check),
+		liveness:  make(map[string]Check),
+	}
+}
+
+func (c *Checker) SetState(s State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = s
+}
+
+func (c *Checker) State() State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.state
+}
+
+// AddReadiness adds the check of the dependency the process cannot serve without, the nil check is skipped.
+func (c *Checker) AddReadiness(name string, check Check) {
+	if check == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness[name] = check
+}
+
+// AddLiveness adds the check of the component the process must be restarted without, the nil check is skipped.
+func (c *Checker) AddLiveness(name string, check Check) {
+	if check == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness[name] = check
+}
+
+// Live reports whether the components of the process are running, the state is not taken into account.
+func (c *Checker) Live(ctx context.Context) Report {
+	c.mu.RLock()
+	state := c.state
+	checks := copyChecks(c.liveness)
+	c.mu.RUnlock()
+
+	return run(ctx, state, checks, true)
+}
+
+// Ready reports whether the process serves and its components and dependencies are usable.
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.RLock()
+	state := c.state
+	checks := copyChecks(c.liveness, c.readiness)
+	c.mu.RUnlock()
+
+	return run(ctx, state, checks, state == StateServing)
+}
+
+// LivenessHandler answers the liveness probe with the report, the status is 503 when the process is not live.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Live(r.Context()))
+	})
+}
+
+// ReadinessHandler answers the readiness probe with the report, the status is 503 when the process is not ready.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Ready(r.Context()))
+	})
+}
+
+func copyChecks(sets ...map[string]Check) map[string]Check {
+	checks := make(map[string]Check)
+	for _, set := range sets {
+		for name, check := range set {
+			checks[name] = check
+		}
+	}
+
+	return checks
+}
+
+// run runs the checks concurrently, the report is up when every check passes and up is true.
+func run(ctx context.Context, state State, checks map[string]Check, up bool) Report {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, len(names))
+	wg := sync.WaitGroup{}
+	wg.Add(len(names))
+	for i, name := range names {
+		i, check := i, checks[name]
+
+		go func() {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, State: state}
+	if len(names) > 0 {
+		report.Checks = make(map[string]string, len(names))
+	}
+
+	for i, name := range names {
+		if errs[i] != nil {
+			up = false
+			report.Checks[name] = errs[i].Error()
+
+			continue
+		}
+		report.Checks[name] = "ok"
+	}
+
+	if !up {
+		report.Status = StatusDown
+	}
+
+	return report
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Up() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(report)
+}
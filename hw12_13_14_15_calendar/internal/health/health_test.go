@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test error")
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+
+	var dbErr error
+	alive := true
+
+	c := New()
+	c.AddReadiness("db", func(context.Context) error { return dbErr })
+	c.AddReadiness("skipped", nil)
+	c.AddLiveness("scheduler", func(context.Context) error {
+		if !alive {
+			return errTest
+		}
+
+		return nil
+	})
+
+	t.Run("not ready while starting", func(t *testing.T) {
+		require.True(t, c.Live(ctx).Up())
+
+		r := c.Ready(ctx)
+		require.False(t, r.Up())
+		require.Equal(t, StateStarting, r.State)
+		require.Equal(t, map[string]string{"db": "ok", "scheduler": "ok"}, r.Checks)
+	})
+
+	t.Run("not ready while migrating", func(t *testing.T) {
+		c.SetState(StateMigrating)
+		require.False(t, c.Ready(ctx).Up())
+	})
+
+	t.Run("ready while serving", func(t *testing.T) {
+		c.SetState(StateServing)
+		require.True(t, c.Ready(ctx).Up())
+	})
+
+	t.Run("not ready without dependency", func(t *testing.T) {
+		dbErr = errTest
+		defer func() { dbErr = nil }()
+
+		r := c.Ready(ctx)
+		require.False(t, r.Up())
+		require.Equal(t, errTest.Error(), r.Checks["db"])
+		require.True(t, c.Live(ctx).Up())
+	})
+
+	t.Run("not live without component", func(t *testing.T) {
+		alive = false
+		defer func() { alive = true }()
+
+		require.False(t, c.Live(ctx).Up())
+		require.False(t, c.Ready(ctx).Up())
+	})
+
+	t.Run("not ready while stopping", func(t *testing.T) {
+		c.SetState(StateStopping)
+		require.False(t, c.Ready(ctx).Up())
+		require.True(t, c.Live(ctx).Up())
+	})
+}
+
+func TestHandlers(t *testing.T) {
+	c := New()
+
+	w := httptest.NewRecorder()
+	c.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.JSONEq(t, `{"status":"down","state":"starting"}`, w.Body.String())
+
+	c.SetState(StateServing)
+
+	w = httptest.NewRecorder()
+	c.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	c.LivenessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"up","state":"serving"}`, w.Body.String())
+}
@@ -0,0 +1,344 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxLineSize = 1024 * 1024
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+type component struct {
+	name       string
+	properties []*property
+	children   []*component
+}
+
+func (c *component) get(name string) *property {
+	for _, p := range c.properties {
+		if p.name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func (c *component) all(name string) []*property {
+	result := make([]*property, 0)
+	for _, p := range c.properties {
+		if p.name == name {
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+// Decode reads VEVENT components of a VCALENDAR feed.
+// Structural errors abort decoding, while a malformed VEVENT is returned with Err set.
+func Decode(r io.Reader) ([]*Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, fmt.Errorf("ical decode: %w", err)
+	}
+
+	var (
+		root  *component
+		stack []*component
+	)
+	for i, l := range lines {
+		p, err := parseLine(l)
+		if err != nil {
+			return nil, fmt.Errorf("ical decode line %d: %w", i+1, err)
+		}
+
+		switch p.name {
+		case "BEGIN":
+			c := &component{name: strings.ToUpper(p.value)}
+			if len(stack) == 0 {
+				if root != nil || c.name != "VCALENDAR" {
+					return nil, fmt.Errorf("ical decode line %d: VCALENDAR expected: %w", i+1, ErrMalformedCalendar)
+				}
+				root = c
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("ical decode line %d: unexpected END:%s: %w", i+1, p.value, ErrMalformedCalendar)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ical decode line %d: property outside of component: %w", i+1, ErrMalformedCalendar)
+			}
+			c := stack[len(stack)-1]
+			c.properties = append(c.properties, p)
+		}
+	}
+
+	if root == nil || len(stack) != 0 {
+		return nil, fmt.Errorf("ical decode: %w", ErrMalformedCalendar)
+	}
+
+	events := make([]*Event, 0)
+	for _, c := range root.children {
+		if c.name == "VEVENT" {
+			events = append(events, decodeEvent(c))
+		}
+	}
+
+	return events, nil
+}
+
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		l := strings.TrimRight(scanner.Text(), "\r")
+		if l == "" {
+			continue
+		}
+
+		if (l[0] == ' ' || l[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+
+		lines = append(lines, l)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseLine splits a content line: name *(";" param) ":" value.
+func parseLine(l string) (*property, error) {
+	p := &property{params: make(map[string]string)}
+
+	quoted := false
+	start, paramName := 0, ""
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '=' && p.name != "" && paramName == "":
+			paramName = strings.ToUpper(l[start:i])
+			start = i + 1
+		case c == ';' || c == ':':
+			token := l[start:i]
+			if p.name == "" {
+				p.name = strings.ToUpper(token)
+			} else if paramName != "" {
+				p.params[paramName] = strings.Trim(token, `"`)
+				paramName = ""
+			}
+			start = i + 1
+
+			if c == ':' {
+				p.value = l[start:]
+				return p, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no value in %q: %w", l, ErrMalformedCalendar)
+}
+
+func decodeEvent(c *component) *Event {
+	e := &Event{}
+	if err := fillEvent(e, c); err != nil {
+		e.Err = fmt.Errorf("%w: %s", ErrMalformedEvent, err.Error())
+	}
+
+	return e
+}
+
+func fillEvent(e *Event, c *component) error {
+	var err error
+
+	if p := c.get("UID"); p != nil {
+		e.UID = unescape(p.value)
+	}
+	if p := c.get("SUMMARY"); p != nil {
+		e.Summary = unescape(p.value)
+	}
+	if p := c.get("DESCRIPTION"); p != nil {
+		e.Description = unescape(p.value)
+	}
+	if p := c.get("RRULE"); p != nil {
+		e.RRule = p.value
+	}
+
+	start := c.get("DTSTART")
+	if start == nil {
+		return errors.New("DTSTART is required")
+	}
+	if e.Start, err = parseTime(start.value, start.params); err != nil {
+		return fmt.Errorf("DTSTART: %w", err)
+	}
+
+	switch end, duration := c.get("DTEND"), c.get("DURATION"); {
+	case end != nil:
+		if e.End, err = parseTime(end.value, end.params); err != nil {
+			return fmt.Errorf("DTEND: %w", err)
+		}
+	case duration != nil:
+		d, err := parseDuration(duration.value)
+		if err != nil {
+			return fmt.Errorf("DURATION: %w", err)
+		}
+		e.End = e.Start.Add(d)
+	case start.params["VALUE"] == "DATE":
+		e.End = e.Start.AddDate(0, 0, 1)
+	default:
+		e.End = e.Start
+	}
+
+	for _, p := range c.all("EXDATE") {
+		for _, v := range strings.Split(p.value, ",") {
+			t, err := parseTime(v, p.params)
+			if err != nil {
+				return fmt.Errorf("EXDATE: %w", err)
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+	}
+
+	if p := c.get("RECURRENCE-ID"); p != nil {
+		if e.RecurrenceID, err = parseTime(p.value, p.params); err != nil {
+			return fmt.Errorf("RECURRENCE-ID: %w", err)
+		}
+	}
+
+	if p := c.get("CREATED"); p != nil {
+		e.Created, _ = parseTime(p.value, p.params)
+	}
+	if p := c.get("LAST-MODIFIED"); p != nil {
+		e.Updated, _ = parseTime(p.value, p.params)
+	}
+
+	for _, alarm := range c.children {
+		if alarm.name != "VALARM" {
+			continue
+		}
+
+		d, err := parseAlarm(alarm, e.Start)
+		if err != nil {
+			return fmt.Errorf("VALARM: %w", err)
+		}
+
+		if d > 0 {
+			e.Alarms = append(e.Alarms, d)
+		}
+	}
+
+	return nil
+}
+
+func parseAlarm(c *component, start time.Time) (time.Duration, error) {
+	trigger := c.get("TRIGGER")
+	if trigger == nil {
+		return 0, errors.New("TRIGGER is required")
+	}
+
+	if trigger.params["VALUE"] == "DATE-TIME" {
+		t, err := parseTime(trigger.value, trigger.params)
+		if err != nil {
+			return 0, err
+		}
+
+		return start.Sub(t), nil
+	}
+
+	if trigger.params["RELATED"] == "END" {
+		return 0, nil
+	}
+
+	d, err := parseDuration(trigger.value)
+	if err != nil {
+		return 0, err
+	}
+
+	return -d, nil
+}
+
+func parseTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" {
+		return time.ParseInLocation(dateLayout, value, time.UTC)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(utcLayout, value)
+	}
+
+	loc := time.UTC
+	if tzid, ok := params["TZID"]; ok {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown TZID %s", tzid)
+		}
+	}
+
+	return time.ParseInLocation(dateTimeLayout, value, loc)
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, u := range units {
+		if m[i+2] == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		d += time.Duration(n) * u
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(s)
+}
@@ -0,0 +1,143 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const maxLineLength = 75
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// Encode writes events as a VCALENDAR feed.
+func Encode(w io.Writer, events []*Event) error {
+	enc := &encoder{w: bufio.NewWriter(w)}
+	stamp := time.Now()
+
+	enc.line("BEGIN", "VCALENDAR")
+	enc.line("VERSION", "2.0")
+	enc.line("PRODID", ProdID)
+	enc.line("CALSCALE", "GREGORIAN")
+
+	for _, e := range events {
+		enc.line("BEGIN", "VEVENT")
+		enc.line("UID", escape(e.UID))
+		enc.line("DTSTAMP", formatTime(stamp))
+		enc.line("DTSTART", formatTime(e.Start))
+		enc.line("DTEND", formatTime(e.End))
+		enc.line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			enc.line("DESCRIPTION", escape(e.Description))
+		}
+		if e.RRule != "" {
+			enc.line("RRULE", e.RRule)
+		}
+		for _, t := range e.ExDates {
+			enc.line("EXDATE", formatTime(t))
+		}
+		if !e.RecurrenceID.IsZero() {
+			enc.line("RECURRENCE-ID", formatTime(e.RecurrenceID))
+		}
+		if !e.Created.IsZero() {
+			enc.line("CREATED", formatTime(e.Created))
+		}
+		if !e.Updated.IsZero() {
+			enc.line("LAST-MODIFIED", formatTime(e.Updated))
+		}
+		for _, alarm := range e.Alarms {
+			enc.line("BEGIN", "VALARM")
+			enc.line("ACTION", "DISPLAY")
+			enc.line("DESCRIPTION", escape(e.Summary))
+			enc.line("TRIGGER", "-"+formatDuration(alarm))
+			enc.line("END", "VALARM")
+		}
+		enc.line("END", "VEVENT")
+	}
+
+	enc.line("END", "VCALENDAR")
+
+	if enc.err != nil {
+		return fmt.Errorf("ical encode: %w", enc.err)
+	}
+
+	if err := enc.w.Flush(); err != nil {
+		return fmt.Errorf("ical encode: %w", err)
+	}
+
+	return nil
+}
+
+// line writes a content line folded to 75 octets as RFC 5545 requires.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+
+	l := name + ":" + value
+	// continuation lines start with a space which is counted too
+	limit := maxLineLength
+	for len(l) > limit {
+		cut := limit
+		// do not split multibyte characters
+		for cut > 0 && l[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		if _, e.err = e.w.WriteString(l[:cut] + "\r\n "); e.err != nil {
+			return
+		}
+		l = l[cut:]
+		limit = maxLineLength - 1
+	}
+
+	_, e.err = e.w.WriteString(l + "\r\n")
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+func formatDuration(d time.Duration) string {
+	b := strings.Builder{}
+	b.WriteString("P")
+
+	if days := d / (24 * time.Hour); days > 0 {
+		b.WriteString(fmt.Sprintf("%dD", days))
+		d -= days * 24 * time.Hour
+	}
+
+	if d == 0 {
+		return b.String()
+	}
+
+	b.WriteString("T")
+	if h := d / time.Hour; h > 0 {
+		b.WriteString(fmt.Sprintf("%dH", h))
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		b.WriteString(fmt.Sprintf("%dM", m))
+		d -= m * time.Minute
+	}
+	if s := d / time.Second; s > 0 {
+		b.WriteString(fmt.Sprintf("%dS", s))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package ical
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ProdID = "-//pustato//calendar//EN"
+
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+	utcLayout      = "20060102T150405Z"
+)
+
+var (
+	ErrMalformedCalendar = errors.New("malformed calendar")
+	ErrMalformedEvent    = errors.New("malformed event")
+)
+
+// Event is a VEVENT component reduced to the properties supported by the calendar.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	RRule       string
+	ExDates     []time.Time
+	// RecurrenceID is set for overrides of a single occurrence of the series with the same UID.
+	RecurrenceID time.Time
+	// Alarms are durations before the start, one per VALARM triggered before it.
+	Alarms  []time.Duration
+	Created time.Time
+	Updated time.Time
+
+	// Err is set by Decode when the VEVENT could not be decoded.
+	Err error
+}
@@ -0,0 +1,138 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const googleExport = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Google Inc//Google Calendar 70.9054//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Moscow\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=Europe/Moscow:20220502T100000\r\n" +
+	"DTEND;TZID=Europe/Moscow:20220502T101500\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR\r\n" +
+	"EXDATE;TZID=Europe/Moscow:20220504T100000,20220506T100000\r\n" +
+	"UID:standup@google.com\r\n" +
+	"SUMMARY:Stand-up\\, daily\r\n" +
+	"DESCRIPTION:Line one\\nline two which is long enough to be folded by the e\r\n" +
+	" xporter\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT10M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20220509\r\n" +
+	"UID:holiday@google.com\r\n" +
+	"SUMMARY:Holiday\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20220510T090000Z\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"UID:review@google.com\r\n" +
+	"SUMMARY:Review\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:tomorrow\r\n" +
+	"UID:broken@google.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestDecode(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	events, err := Decode(strings.NewReader(googleExport))
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	standUp := events[0]
+	require.NoError(t, standUp.Err)
+	require.Equal(t, "standup@google.com", standUp.UID)
+	require.Equal(t, "Stand-up, daily", standUp.Summary)
+	require.Equal(t, "Line one\nline two which is long enough to be folded by the exporter", standUp.Description)
+	require.True(t, time.Date(2022, 5, 2, 10, 0, 0, 0, moscow).Equal(standUp.Start))
+	require.Equal(t, 15*time.Minute, standUp.End.Sub(standUp.Start))
+	require.Equal(t, "FREQ=WEEKLY;BYDAY=MO,WE,FR", standUp.RRule)
+	require.Len(t, standUp.ExDates, 2)
+	require.True(t, time.Date(2022, 5, 6, 10, 0, 0, 0, moscow).Equal(standUp.ExDates[1]))
+	require.Equal(t, []time.Duration{10 * time.Minute}, standUp.Alarms)
+
+	holiday := events[1]
+	require.NoError(t, holiday.Err)
+	require.Equal(t, time.Date(2022, 5, 9, 0, 0, 0, 0, time.UTC), holiday.Start)
+	require.Equal(t, time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC), holiday.End)
+
+	review := events[2]
+	require.NoError(t, review.Err)
+	require.Equal(t, 90*time.Minute, review.End.Sub(review.Start))
+
+	require.ErrorIs(t, events[3].Err, ErrMalformedEvent)
+	require.Equal(t, "broken@google.com", events[3].UID)
+}
+
+func TestDecodeMalformedCalendar(t *testing.T) {
+	testData := []string{
+		"",
+		"BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+
+	for _, data := range testData {
+		_, err := Decode(strings.NewReader(data))
+		require.ErrorIs(t, err, ErrMalformedCalendar)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	start := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+	original := []*Event{
+		{
+			UID:         "1@calendar",
+			Summary:     "Встреча; с командой, которая длится достаточно долго для переноса строки",
+			Description: "first line\nsecond line\\",
+			Start:       start,
+			End:         start.Add(time.Hour),
+			RRule:       "FREQ=DAILY;COUNT=10",
+			ExDates:     []time.Time{start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)},
+			Alarms:      []time.Duration{24 * time.Hour, 10 * time.Minute},
+		},
+		{
+			UID:   "2@calendar",
+			Start: start,
+			End:   start,
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Encode(buf, original))
+
+	for _, l := range strings.Split(buf.String(), "\r\n") {
+		require.LessOrEqual(t, len(l), maxLineLength)
+	}
+
+	decoded, err := Decode(buf)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+
+	for i, e := range decoded {
+		require.NoError(t, e.Err)
+		require.Equal(t, original[i].UID, e.UID)
+		require.Equal(t, original[i].Summary, e.Summary)
+		require.Equal(t, original[i].Description, e.Description)
+		require.Equal(t, original[i].Start, e.Start)
+		require.Equal(t, original[i].End, e.End)
+		require.Equal(t, original[i].RRule, e.RRule)
+		require.Equal(t, original[i].ExDates, e.ExDates)
+		require.Equal(t, original[i].Alarms, e.Alarms)
+	}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	mockqueue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue/mocks"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test error")
+
+func TestEventStorage(t *testing.T) {
+	ctx := context.Background()
+
+	storageMock := &mockstorage.EventStorage{}
+	storageMock.On("GetByID", ctx, int64(1)).Return(&storage.Event{ID: 1}, nil).Once()
+	storageMock.On("GetByID", ctx, int64(2)).Return(nil, storage.ErrNotFound).Once()
+	storageMock.On("Delete", ctx, int64(3), (*storage.HistoryEntry)(nil)).Return(errTest).Once()
+	defer storageMock.AssertExpectations(t)
+
+	s := NewEventStorage(storageMock)
+
+	e, err := s.GetByID(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), e.ID)
+
+	_, err = s.GetByID(ctx, 2)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.ErrorIs(t, s.Delete(ctx, 3, nil), errTest)
+
+	require.Equal(t, 0.0, testutil.ToFloat64(storageErrors.WithLabelValues("GetByID")))
+	require.Equal(t, 1.0, testutil.ToFloat64(storageErrors.WithLabelValues("Delete")))
+	require.Equal(t, 2, testutil.CollectAndCount(storageDuration))
+}
+
+func TestProducer(t *testing.T) {
+	producerMock := &mockqueue.Producer{}
+	producerMock.On("Publish", mock.Anything, mock.MatchedBy(func(m *queue.Message) bool {
+		return string(m.Payload) == "ok"
+	})).Return(nil)
+	producerMock.On("Publish", mock.Anything, mock.Anything).Return(errTest)
+
+	p := NewProducer(producerMock)
+	ctx := context.Background()
+
+	require.NoError(t, p.Publish(ctx, &queue.Message{Key: "test.publish", Payload: []byte("ok")}))
+	require.NoError(t, p.Publish(ctx, &queue.Message{Key: "test.publish", Payload: []byte("ok")}))
+	require.ErrorIs(t, p.Publish(ctx, &queue.Message{Key: "test.publish", Payload: []byte("fail")}), errTest)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(messagesPublished.WithLabelValues("test.publish")))
+	require.Equal(t, 1.0, testutil.ToFloat64(publishFailures.WithLabelValues("test.publish")))
+}
+
+func TestMessageHandler(t *testing.T) {
+	h := MessageHandler(func(_ context.Context, m *queue.Message) error {
+		if string(m.Payload) == "fail" {
+			return errTest
+		}
+
+		return nil
+	})
+
+	require.NoError(t, h(context.Background(), &queue.Message{Key: "test.consume", Payload: []byte("ok")}))
+	require.ErrorIs(t, h(context.Background(), &queue.Message{Key: "test.consume", Payload: []byte("fail")}), errTest)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(messagesConsumed.WithLabelValues("test.consume")))
+	require.Equal(t, 1.0, testutil.ToFloat64(messagesFailed.WithLabelValues("test.consume")))
+}
+
+func TestTask(t *testing.T) {
+	fail := false
+	task := Task("test_task", func(ctx context.Context) error {
+		if fail {
+			return errTest
+		}
+
+		return nil
+	})
+
+	require.NoError(t, task(context.Background()))
+	fail = true
+	require.ErrorIs(t, task(context.Background()), errTest)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(taskRuns.WithLabelValues("test_task")))
+	require.Equal(t, 1.0, testutil.ToFloat64(taskFailures.WithLabelValues("test_task")))
+}
+
+func TestHandler(t *testing.T) {
+	ObserveHTTPRequest("/event/{id:[0-9]+}", "GET", 200, 10*time.Millisecond)
+	ObserveGRPCRequest("/calendar.Calendar/GetEvent", "OK", 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	require.Contains(t, body, `calendar_http_requests_total{code="200",method="GET",route="/event/{id:[0-9]+}"} 1`)
+	require.Contains(t, body, `calendar_grpc_requests_total{code="OK",method="/calendar.Calendar/GetEvent"} 1`)
+	require.Contains(t, body, "calendar_http_request_duration_seconds_bucket")
+}
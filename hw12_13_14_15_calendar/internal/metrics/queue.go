@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+)
+
+var _ queue.Producer = (*Producer)(nil)
+
+// Producer counts the published messages of the wrapped producer.
+type Producer struct {
+	producer queue.Producer
+}
+
+func NewProducer(p queue.Producer) *Producer {
+	return &Producer{producer: p}
+}
+
+func (p *Producer) Publish(ctx context.Context, m *queue.Message) error {
+	if err := p.producer.Publish(ctx, m); err != nil {
+		publishFailures.WithLabelValues(m.Key).Inc()
+		return err
+	}
+	messagesPublished.WithLabelValues(m.Key).Inc()
+
+	return nil
+}
+
+// MessageHandler counts the consumed and the failed messages of the handler.
+func MessageHandler(h queue.MessageHandler) queue.MessageHandler {
+	return func(ctx context.Context, m *queue.Message) error {
+		messagesConsumed.WithLabelValues(m.Key).Inc()
+
+		if err := h(ctx, m); err != nil {
+			messagesFailed.WithLabelValues(m.Key).Inc()
+			return err
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+// Task observes the runs, the durations and the failures of the named task.
+func Task(name string, t scheduler.Task) scheduler.Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		err := t(ctx)
+
+		taskRuns.WithLabelValues(name).Inc()
+		taskDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			taskFailures.WithLabelValues(name).Inc()
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,289 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+var _ storage.EventStorage = (*EventStorage)(nil)
+
+// EventStorage observes the latencies and the errors of the operations of the wrapped storage.
+type EventStorage struct {
+	storage storage.EventStorage
+}
+
+func NewEventStorage(s storage.EventStorage) *EventStorage {
+	return &EventStorage{storage: s}
+}
+
+// done observes the operation started at the start, a missing entity, a version conflict and an overlap
+// are not errors of the storage.
+func done(method string, start time.Time, err error) {
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrConflict) ||
+		errors.Is(err, storage.ErrOverlap) {
+		err = nil
+	}
+	observeStorage(method, start, err)
+}
+
+func (s *EventStorage) Create(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) (int64, error) {
+	start := time.Now()
+	result, err := s.storage.Create(ctx, event, history)
+	done("Create", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) Update(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) error {
+	start := time.Now()
+	err := s.storage.Update(ctx, event, history)
+	done("Update", start, err)
+
+	return err
+}
+
+func (s *EventStorage) Delete(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	start := time.Now()
+	err := s.storage.Delete(ctx, id, history)
+	done("Delete", start, err)
+
+	return err
+}
+
+func (s *EventStorage) GetByID(ctx context.Context, id int64) (*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.GetByID(ctx, id)
+	done("GetByID", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindForInterval(
+	ctx context.Context,
+	userID int64,
+	from, to time.Time,
+	after *storage.Cursor,
+	limit int) ([]*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.FindForInterval(ctx, userID, from, to, after, limit)
+	done("FindForInterval", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindRecurring(ctx context.Context, userID int64, from, to time.Time) ([]*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.FindRecurring(ctx, userID, from, to)
+	done("FindRecurring", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindOverlapping(
+	ctx context.Context,
+	userID int64,
+	from, to time.Time) ([]*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.FindOverlapping(ctx, userID, from, to)
+	done("FindOverlapping", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) Search(ctx context.Context, filter storage.SearchFilter) ([]*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.Search(ctx, filter)
+	done("Search", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindReminders(ctx context.Context, eventIDs []int64) ([]*storage.Reminder, error) {
+	start := time.Now()
+	result, err := s.storage.FindReminders(ctx, eventIDs)
+	done("FindReminders", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*storage.DueReminder, error) {
+	start := time.Now()
+	result, err := s.storage.FindUnNotified(ctx, t)
+	done("FindUnNotified", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) MarkNotified(ctx context.Context, reminderIDs []int64, outbox []*storage.OutboxMessage) error {
+	start := time.Now()
+	err := s.storage.MarkNotified(ctx, reminderIDs, outbox)
+	done("MarkNotified", start, err)
+
+	return err
+}
+
+func (s *EventStorage) RearmReminders(
+	ctx context.Context,
+	reminders []*storage.Reminder,
+	outbox []*storage.OutboxMessage,
+) error {
+	start := time.Now()
+	err := s.storage.RearmReminders(ctx, reminders, outbox)
+	done("RearmReminders", start, err)
+
+	return err
+}
+
+func (s *EventStorage) DeleteOlderThan(ctx context.Context, filter storage.RetentionFilter) error {
+	start := time.Now()
+	err := s.storage.DeleteOlderThan(ctx, filter)
+	done("DeleteOlderThan", start, err)
+
+	return err
+}
+
+func (s *EventStorage) GetDeleted(ctx context.Context, id int64) (*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.GetDeleted(ctx, id)
+	done("GetDeleted", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindDeleted(
+	ctx context.Context,
+	userID int64,
+	after *storage.SearchCursor,
+	limit int) ([]*storage.Event, error) {
+	start := time.Now()
+	result, err := s.storage.FindDeleted(ctx, userID, after, limit)
+	done("FindDeleted", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) Restore(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	start := time.Now()
+	err := s.storage.Restore(ctx, id, history)
+	done("Restore", start, err)
+
+	return err
+}
+
+func (s *EventStorage) Purge(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := s.storage.Purge(ctx, id)
+	done("Purge", start, err)
+
+	return err
+}
+
+func (s *EventStorage) PurgeDeleted(ctx context.Context, t time.Time) error {
+	start := time.Now()
+	err := s.storage.PurgeDeleted(ctx, t)
+	done("PurgeDeleted", start, err)
+
+	return err
+}
+
+func (s *EventStorage) FindHistory(ctx context.Context, eventID int64) ([]*storage.HistoryEntry, error) {
+	start := time.Now()
+	result, err := s.storage.FindHistory(ctx, eventID)
+	done("FindHistory", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindUserHistory(
+	ctx context.Context,
+	userID, afterID int64,
+	limit int) ([]*storage.HistoryEntry, error) {
+	start := time.Now()
+	result, err := s.storage.FindUserHistory(ctx, userID, afterID, limit)
+	done("FindUserHistory", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) ApplyBatch(ctx context.Context, batch *storage.Batch) error {
+	start := time.Now()
+	err := s.storage.ApplyBatch(ctx, batch)
+	done("ApplyBatch", start, err)
+
+	return err
+}
+
+func (s *EventStorage) AddAttendee(ctx context.Context, attendee *storage.Attendee) (int64, error) {
+	start := time.Now()
+	result, err := s.storage.AddAttendee(ctx, attendee)
+	done("AddAttendee", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) UpdateAttendee(ctx context.Context, attendee *storage.Attendee) error {
+	start := time.Now()
+	err := s.storage.UpdateAttendee(ctx, attendee)
+	done("UpdateAttendee", start, err)
+
+	return err
+}
+
+func (s *EventStorage) FindAttendees(ctx context.Context, eventID int64) ([]*storage.Attendee, error) {
+	start := time.Now()
+	result, err := s.storage.FindAttendees(ctx, eventID)
+	done("FindAttendees", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindInvitations(ctx context.Context, userID int64) ([]*storage.Invitation, error) {
+	start := time.Now()
+	result, err := s.storage.FindInvitations(ctx, userID)
+	done("FindInvitations", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindUnNotifiedAttendees(ctx context.Context) ([]*storage.Invitation, error) {
+	start := time.Now()
+	result, err := s.storage.FindUnNotifiedAttendees(ctx)
+	done("FindUnNotifiedAttendees", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) MarkAttendeesNotified(ctx context.Context, ids []int64, outbox []*storage.OutboxMessage) error {
+	start := time.Now()
+	err := s.storage.MarkAttendeesNotified(ctx, ids, outbox)
+	done("MarkAttendeesNotified", start, err)
+
+	return err
+}
+
+func (s *EventStorage) FindOutbox(ctx context.Context, t time.Time, limit int) ([]*storage.OutboxMessage, error) {
+	start := time.Now()
+	result, err := s.storage.FindOutbox(ctx, t, limit)
+	done("FindOutbox", start, err)
+
+	return result, err
+}
+
+func (s *EventStorage) DeleteOutbox(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := s.storage.DeleteOutbox(ctx, id)
+	done("DeleteOutbox", start, err)
+
+	return err
+}
+
+func (s *EventStorage) RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time) error {
+	start := time.Now()
+	err := s.storage.RetryOutbox(ctx, id, nextAttemptAt)
+	done("RetryOutbox", start, err)
+
+	return err
+}
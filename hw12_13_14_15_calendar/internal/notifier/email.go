@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+var _ Notifier = (*EmailNotifier)(nil)
+
+var headerReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+var ErrAuthNotSupported = errors.New("smtp server does not support authentication")
+
+type EmailConfig struct {
+	Host string
+	Port string
+	// User and Password enable PLAIN authentication, the server must support TLS unless it is local.
+	User     string
+	Password string
+	From     string
+	// To is a fmt pattern of the recipient address, it receives the user id, e.g. user-%d@example.com.
+	To string
+	// Timeout limits the sending of the letter, a stalled server does not hold the sender.
+	Timeout time.Duration
+}
+
+// EmailNotifier sends the notification as a plain text letter over SMTP.
+type EmailNotifier struct {
+	config EmailConfig
+	auth   smtp.Auth
+}
+
+func NewEmail(config EmailConfig) *EmailNotifier {
+	var auth smtp.Auth
+	if config.User != "" {
+		auth = smtp.PlainAuth("", config.User, config.Password, config.Host)
+	}
+
+	return &EmailNotifier{
+		config: config,
+		auth:   auth,
+	}
+}
+
+func (en *EmailNotifier) Notify(ctx context.Context, n *scheduler.EventNotification) error {
+	if en.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, en.config.Timeout)
+		defer cancel()
+	}
+
+	to := fmt.Sprintf(en.config.To, n.UserID)
+	if err := en.send(ctx, to, en.message(to, n)); err != nil {
+		return fmt.Errorf("email notifier send: %w", err)
+	}
+
+	return nil
+}
+
+// send does what smtp.SendMail does over the connection bound to the ctx: the deadline of the ctx is the deadline
+// of the connection and the connection is closed when the ctx is done.
+func (en *EmailNotifier) send(ctx context.Context, to string, msg []byte) error {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(en.config.Host, en.config.Port))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	sent := make(chan struct{})
+	defer close(sent)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-sent:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, en.config.Host)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: en.config.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+
+	if en.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return ErrAuthNotSupported
+		}
+		if err := c.Auth(en.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(en.config.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (en *EmailNotifier) message(to string, n *scheduler.EventNotification) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", en.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	// the title is user input, line breaks would inject headers
+	fmt.Fprintf(&b, "Subject: Reminder: %s\r\n", headerReplacer.Replace(n.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s starts at %s.\r\n", n.Title, n.TimeStart.Format(time.RFC1123Z))
+
+	return b.Bytes()
+}
@@ -0,0 +1,197 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mail struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpStub is a local SMTP stand-in, it accepts every letter and keeps it.
+type smtpStub struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	mails []mail
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &smtpStub{ln: ln}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStub) addr() (string, string) {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return host, port
+}
+
+func (s *smtpStub) received() []mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]mail(nil), s.mails...)
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	c := textproto.NewConn(conn)
+	_ = c.PrintfLine("220 localhost ESMTP stub")
+
+	m := mail{}
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			_ = c.PrintfLine("250 localhost")
+		case "MAIL":
+			m.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			_ = c.PrintfLine("250 OK")
+		case "RCPT":
+			m.to = append(m.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			_ = c.PrintfLine("250 OK")
+		case "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			m.data = string(data)
+
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			m = mail{}
+
+			_ = c.PrintfLine("250 OK")
+		case "QUIT":
+			_ = c.PrintfLine("221 bye")
+			return
+		default:
+			_ = c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	stub := newSMTPStub(t)
+	host, port := stub.addr()
+
+	en := NewEmail(EmailConfig{
+		Host: host,
+		Port: port,
+		From: "calendar@example.com",
+		To:   "user-%d@example.com",
+	})
+
+	n := notificationStub()
+	n.Title = "standup\r\nBcc: victim@example.com"
+	require.NoError(t, en.Notify(ctx, n))
+
+	mails := stub.received()
+	require.Len(t, mails, 1)
+	require.Equal(t, "calendar@example.com", mails[0].from)
+	require.Equal(t, []string{"user-2@example.com"}, mails[0].to)
+	headers := strings.SplitN(mails[0].data, "\n\n", 2)[0]
+	require.Contains(t, headers, "Subject: Reminder: standup  Bcc: victim@example.com\n")
+	require.NotContains(t, headers, "\nBcc:")
+}
+
+func TestEmailNotifierError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, ln.Close())
+
+	en := NewEmail(EmailConfig{Host: host, Port: port, From: "calendar@example.com", To: "user-%d@example.com"})
+	require.Error(t, en.Notify(ctx, notificationStub()))
+}
+
+func TestEmailNotifierStalledServer(t *testing.T) {
+	// the server accepts the connections and never responds
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	go func() {
+		conns := make([]net.Conn, 0)
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	t.Run("timeout", func(t *testing.T) {
+		en := NewEmail(EmailConfig{
+			Host:    host,
+			Port:    port,
+			From:    "calendar@example.com",
+			To:      "user-%d@example.com",
+			Timeout: 50 * time.Millisecond,
+		})
+
+		started := time.Now()
+		require.Error(t, en.Notify(ctx, notificationStub()))
+		require.Less(t, time.Since(started), time.Second)
+	})
+
+	t.Run("ctx is done", func(t *testing.T) {
+		en := NewEmail(EmailConfig{Host: host, Port: port, From: "calendar@example.com", To: "user-%d@example.com"})
+
+		cancelCtx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		started := time.Now()
+		require.Error(t, en.Notify(cancelCtx, notificationStub()))
+		require.Less(t, time.Since(started), time.Second)
+	})
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var _ Notifier = (*WriterNotifier)(nil)
+
+// WriterNotifier writes every notification as a JSON line.
+type WriterNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+// NewFile opens the target for appending, stdout and stderr are the standard streams.
+// The returned close function must be called when the notifier is not needed anymore.
+func NewFile(target string) (*WriterNotifier, func() error, error) {
+	switch target {
+	case "stdout":
+		return NewWriter(os.Stdout), func() error { return nil }, nil
+	case "stderr":
+		return NewWriter(os.Stderr), func() error { return nil }, nil
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("file notifier open: %w", err)
+	}
+
+	return NewWriter(f), f.Close, nil
+}
+
+func (wn *WriterNotifier) Notify(_ context.Context, n *scheduler.EventNotification) error {
+	line, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("file notifier marshal: %w", err)
+	}
+	line = append(line, '\n')
+
+	wn.mu.Lock()
+	defer wn.mu.Unlock()
+
+	if _, err := wn.w.Write(line); err != nil {
+		return fmt.Errorf("file notifier write: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+// Notifier delivers the event notification to the user.
+type Notifier interface {
+	Notify(ctx context.Context, n *scheduler.EventNotification) error
+}
+
+var _ Notifier = (*Router)(nil)
+
+// Router delivers the notification through the notifier of its channel.
+type Router struct {
+	notifiers map[string]Notifier
+	fallback  Notifier
+}
+
+// NewRouter creates the router, notifications of the channels without a notifier are sent to the fallback.
+// Nil fallback makes such notifications fail with ErrUnknownChannel.
+func NewRouter(notifiers map[string]Notifier, fallback Notifier) *Router {
+	return &Router{
+		notifiers: notifiers,
+		fallback:  fallback,
+	}
+}
+
+func (r *Router) Notify(ctx context.Context, n *scheduler.EventNotification) error {
+	if nn, ok := r.notifiers[n.Channel]; ok {
+		return nn.Notify(ctx, n)
+	}
+
+	if r.fallback == nil {
+		return fmt.Errorf("channel %q: %w", n.Channel, ErrUnknownChannel)
+	}
+
+	return r.fallback.Notify(ctx, n)
+}
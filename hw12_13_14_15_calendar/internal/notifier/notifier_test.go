@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func notificationStub() *scheduler.EventNotification {
+	return &scheduler.EventNotification{
+		EventID:    1,
+		ReminderID: 10,
+		UserID:     2,
+		Channel:    "email",
+		Title:      "standup",
+		TimeStart:  time.Date(2022, 5, 18, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+type notifierFunc func(ctx context.Context, n *scheduler.EventNotification) error
+
+func (f notifierFunc) Notify(ctx context.Context, n *scheduler.EventNotification) error {
+	return f(ctx, n)
+}
+
+func TestRouter(t *testing.T) {
+	errEmail := errors.New("email")
+	email := notifierFunc(func(context.Context, *scheduler.EventNotification) error {
+		return errEmail
+	})
+
+	var log bytes.Buffer
+	r := NewRouter(map[string]Notifier{"email": email}, NewWriter(&log))
+
+	n := notificationStub()
+	require.ErrorIs(t, r.Notify(ctx, n), errEmail)
+	require.Zero(t, log.Len())
+
+	n.Channel = "webhook"
+	require.NoError(t, r.Notify(ctx, n))
+	require.Contains(t, log.String(), `"channel":"webhook"`)
+
+	r = NewRouter(map[string]Notifier{"email": email}, nil)
+	require.ErrorIs(t, r.Notify(ctx, n), ErrUnknownChannel)
+}
+
+func TestWriterNotifier(t *testing.T) {
+	var b bytes.Buffer
+	wn := NewWriter(&b)
+
+	require.NoError(t, wn.Notify(ctx, notificationStub()))
+	require.NoError(t, wn.Notify(ctx, notificationStub()))
+
+	lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	n := &scheduler.EventNotification{}
+	require.NoError(t, json.Unmarshal(lines[1], n))
+	require.Equal(t, int64(10), n.ReminderID)
+}
@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan *scheduler.EventNotification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		if r.Header.Get(SignatureHeader) != Sign([]byte("secret"), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		n := &scheduler.EventNotification{}
+		require.NoError(t, json.Unmarshal(body, n))
+		received <- n
+	}))
+	defer srv.Close()
+
+	t.Run("success case", func(t *testing.T) {
+		expected := notificationStub()
+		require.NoError(t, NewWebhook(srv.URL, "secret", time.Second).Notify(ctx, expected))
+
+		actual := <-received
+		require.Equal(t, expected.ReminderID, actual.ReminderID)
+		require.True(t, expected.TimeStart.Equal(actual.TimeStart))
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		err := NewWebhook(srv.URL, "other", time.Second).Notify(ctx, notificationStub())
+		require.ErrorIs(t, err, ErrWebhookStatus)
+	})
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t,
+		"sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13",
+		Sign([]byte("secret"), []byte("{}")),
+	)
+}
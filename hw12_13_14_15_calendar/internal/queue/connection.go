@@ -1,37 +1,155 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
 var _ Queue = (*AMQPConnection)(nil)
 
+// client is a producer, a consumer or a dead letter queue of the connection,
+// its channel is re-created on every reconnection.
+type client interface {
+	// setup opens the channel of the client and declares the exchanges and the queues it needs.
+	setup(conn *amqp.Connection) error
+}
+
+// AMQPConnection reconnects according to the Reconnect policy when the connection is lost,
+// Retries of the policy set to zero mean reconnecting until the connection is closed.
 type AMQPConnection struct {
-	URI             string
-	conn            *amqp.Connection
+	URI       string
+	Reconnect RetryPolicy
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	clients []client
+	// done is closed when the connection is closed or the reconnection gives up with err
+	done            chan struct{}
+	err             error
 	consumerCounter int
 }
 
 func (c *AMQPConnection) Connect() error {
-	var err error
-	c.conn, err = amqp.Dial(c.URI)
+	conn, closeChan, err := c.dial()
 	if err != nil {
-		return fmt.Errorf("connection dial: %w", err)
+		return err
 	}
 
+	c.mu.Lock()
+	c.conn = conn
+	c.done = make(chan struct{})
+	c.mu.Unlock()
+
+	go c.supervise(closeChan)
+
 	return nil
 }
 
-func (c *AMQPConnection) channel() (*amqp.Channel, error) {
-	ch, err := c.conn.Channel()
+func (c *AMQPConnection) dial() (*amqp.Connection, chan *amqp.Error, error) {
+	conn, err := amqp.Dial(c.URI)
 	if err != nil {
-		return nil, fmt.Errorf("connection new channel: %w", err)
+		return nil, nil, fmt.Errorf("connection dial: %w", err)
+	}
+
+	return conn, conn.NotifyClose(make(chan *amqp.Error, 1)), nil
+}
+
+// supervise reconnects every time the connection is lost until it is closed.
+func (c *AMQPConnection) supervise(closeChan chan *amqp.Error) {
+	for {
+		// the channel is closed without an error when the connection is closed by Close
+		if amqpErr := <-closeChan; amqpErr == nil {
+			return
+		}
+
+		var err error
+		if closeChan, err = c.reconnect(); err != nil {
+			c.shutdown(err)
+			return
+		}
+	}
+}
+
+func (c *AMQPConnection) reconnect() (chan *amqp.Error, error) {
+	var lastErr error
+	for attempt := 1; c.Reconnect.Retries == 0 || attempt <= c.Reconnect.Retries; attempt++ {
+		select {
+		case <-c.done:
+			return nil, ErrConnectionClosed
+		case <-time.After(c.Reconnect.Delay(attempt)):
+		}
+
+		conn, closeChan, err := c.dial()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := c.recover(conn); err != nil {
+			_ = conn.Close()
+			lastErr = err
+			continue
+		}
+
+		return closeChan, nil
+	}
+
+	return nil, fmt.Errorf("connection reconnect: %w", lastErr)
+}
+
+// recover sets up the clients on the new connection.
+func (c *AMQPConnection) recover(conn *amqp.Connection) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	default:
 	}
 
-	return ch, nil
+	for _, cl := range c.clients {
+		if err := cl.setup(conn); err != nil {
+			return fmt.Errorf("connection recover: %w", err)
+		}
+	}
+	c.conn = conn
+
+	return nil
+}
+
+func (c *AMQPConnection) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+	default:
+		c.err = err
+		close(c.done)
+	}
+}
+
+// register sets up the client on the current connection and keeps it to set up again after a reconnection.
+func (c *AMQPConnection) register(cl client) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isClosed() {
+		return ErrConnectionClosed
+	}
+
+	if err := cl.setup(c.conn); err != nil {
+		return err
+	}
+	c.clients = append(c.clients, cl)
+
+	return nil
 }
 
 func (c *AMQPConnection) declareExchange(ch *amqp.Channel, exchange string) error {
@@ -50,14 +168,14 @@ func (c *AMQPConnection) declareExchange(ch *amqp.Channel, exchange string) erro
 	return nil
 }
 
-func (c *AMQPConnection) declareQueue(ch *amqp.Channel, queue string) error {
+func (c *AMQPConnection) declareQueue(ch *amqp.Channel, queue string, args amqp.Table) error {
 	if _, err := ch.QueueDeclare(
 		queue,
 		true,
 		false,
 		false,
 		false,
-		nil,
+		args,
 	); err != nil {
 		return fmt.Errorf("connection queue `%s` declaration: %w", queue, err)
 	}
@@ -65,50 +183,129 @@ func (c *AMQPConnection) declareQueue(ch *amqp.Channel, queue string) error {
 	return nil
 }
 
+// declareRetryQueues declares a queue per retry, the messages wait in it for the delay of the retry
+// and then are dead-lettered back to the queue through the default exchange.
+func (c *AMQPConnection) declareRetryQueues(ch *amqp.Channel, queue string, retry RetryPolicy) error {
+	for attempt := 1; attempt <= retry.Retries; attempt++ {
+		if err := c.declareQueue(ch, retryQueueName(queue, attempt), amqp.Table{
+			"x-message-ttl":             retry.Delay(attempt).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// declareDeadLetter declares the dead letter exchange of the exchange and the dead letter queue of the queue,
+// dead letters are routed by the name of the queue they come from.
+func (c *AMQPConnection) declareDeadLetter(ch *amqp.Channel, exchange, queue string) error {
+	dlx := deadLetterExchangeName(exchange)
+	if err := c.declareExchange(ch, dlx); err != nil {
+		return err
+	}
+
+	dlq := deadLetterQueueName(queue)
+	if err := c.declareQueue(ch, dlq, nil); err != nil {
+		return err
+	}
+
+	if err := ch.QueueBind(dlq, queue, dlx, false, nil); err != nil {
+		return fmt.Errorf("connection dead letter queue `%s` binding: %w", dlq, err)
+	}
+
+	return nil
+}
+
 func (c *AMQPConnection) CreateProducer(exchange string) (Producer, error) {
-	ch, err := c.channel()
-	if err != nil {
-		return nil, fmt.Errorf("connection create pruducer: %w", err)
+	p := &AMQPProducer{
+		exchange: exchange,
+		conn:     c,
+		pending:  make(map[uint64]chan bool),
 	}
-	if err := c.declareExchange(ch, exchange); err != nil {
+
+	if err := c.register(p); err != nil {
 		return nil, fmt.Errorf("connection create pruducer: %w", err)
 	}
 
-	return &AMQPProducer{
+	return p, nil
+}
+
+func (c *AMQPConnection) CreateConsumer(exchange, queue string, opts ConsumerOptions, keys ...string) (Consumer, error) {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	retry := &AMQPProducer{
 		exchange: exchange,
-		ch:       ch,
 		conn:     c,
-	}, nil
-}
+		pending:  make(map[uint64]chan bool),
+	}
+	consumer := &AMQPConsumer{
+		exchange: exchange,
+		queue:    queue,
+		keys:     keys,
+		opts:     opts,
+		conn:     c,
+		retry:    retry,
+	}
 
-func (c *AMQPConnection) CreateConsumer(exchange, queue, key string) (Consumer, error) {
-	ch, err := c.channel()
-	if err != nil {
+	c.mu.Lock()
+	consumer.tag = c.createConsumerTag()
+	c.mu.Unlock()
+
+	if err := c.register(retry); err != nil {
 		return nil, fmt.Errorf("connection create consumer: %w", err)
 	}
 
-	if err := c.declareExchange(ch, exchange); err != nil {
+	if err := c.register(consumer); err != nil {
 		return nil, fmt.Errorf("connection create consumer: %w", err)
 	}
 
-	if err := c.declareQueue(ch, queue); err != nil {
-		return nil, fmt.Errorf("connection create consumer: %w", err)
+	return consumer, nil
+}
+
+func (c *AMQPConnection) CreateDeadLetterQueue(exchange, queue string) (DeadLetterQueue, error) {
+	replay := &AMQPProducer{
+		exchange: exchange,
+		conn:     c,
+		pending:  make(map[uint64]chan bool),
+	}
+	q := &AMQPDeadLetterQueue{
+		exchange: exchange,
+		queue:    queue,
+		conn:     c,
+		replay:   replay,
 	}
 
-	if err := ch.QueueBind(queue, key, exchange, true, nil); err != nil {
-		return nil, fmt.Errorf("connection create consumer: queue binding: %w", err)
+	if err := c.register(replay); err != nil {
+		return nil, fmt.Errorf("connection create dead letter queue: %w", err)
 	}
 
-	closeChan := make(chan *amqp.Error)
-	c.conn.NotifyClose(closeChan)
+	if err := c.register(q); err != nil {
+		return nil, fmt.Errorf("connection create dead letter queue: %w", err)
+	}
 
-	return &AMQPConsumer{
-		c.createConsumerTag(), queue, c, ch, closeChan,
-	}, nil
+	return q, nil
 }
 
 func (c *AMQPConnection) Close() error {
-	if c.conn != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done == nil {
+		return nil
+	}
+
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+
+	if c.conn != nil && !c.conn.IsClosed() {
 		if err := c.conn.Close(); err != nil {
 			return fmt.Errorf("amqp connection close: %w", err)
 		}
@@ -117,12 +314,45 @@ func (c *AMQPConnection) Close() error {
 	return nil
 }
 
+// Ping reports the lost connection while it is reconnecting, it is not reported after the reconnection succeeds.
+func (c *AMQPConnection) Ping(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isClosed() {
+		return ErrConnectionClosed
+	}
+
+	return nil
+}
+
+// lostErr returns the error of the reconnection that gave up, it is nil when the connection is closed by Close.
+func (c *AMQPConnection) lostErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
 func (c *AMQPConnection) createConsumerTag() string {
 	c.consumerCounter++
 
 	return "consumer" + strconv.Itoa(c.consumerCounter)
 }
 
+// isClosed reports whether the current connection is lost or closed, the caller must hold the lock.
 func (c *AMQPConnection) isClosed() bool {
-	return c.conn.IsClosed()
+	return c.conn == nil || c.conn.IsClosed()
+}
+
+func retryQueueName(queue string, attempt int) string {
+	return queue + ".retry." + strconv.Itoa(attempt)
+}
+
+func deadLetterExchangeName(exchange string) string {
+	return exchange + ".dlx"
+}
+
+func deadLetterQueueName(queue string) string {
+	return queue + ".dead"
 }
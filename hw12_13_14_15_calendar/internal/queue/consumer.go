@@ -1,49 +1,265 @@
 package queue
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// Headers of the retried and dead-lettered messages, they are republished through other exchanges
+// so the original routing key is kept in the header.
+const (
+	reservedPrefix = "x-"
+
+	headerKey     = "x-calendar-key"
+	headerAttempt = "x-calendar-attempt"
+	headerError   = "x-calendar-error"
+	headerDiedAt  = "x-calendar-died-at"
+)
+
+// retryConfirmTimeout limits the waiting for the confirmation of the message moved to the retry queue,
+// to the dead letter queue or back from it.
+const retryConfirmTimeout = 30 * time.Second
+
 var _ Consumer = (*AMQPConsumer)(nil)
 
 type AMQPConsumer struct {
-	tag       string
-	queue     string
-	conn      *AMQPConnection
-	ch        *amqp.Channel
-	closeChan <-chan *amqp.Error
-}
-
-func (c *AMQPConsumer) Consume(h MessageHandler) error {
-	deliveries, err := c.ch.Consume(
-		c.queue,
-		c.tag,
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+	tag      string
+	exchange string
+	queue    string
+	keys     []string
+	opts     ConsumerOptions
+	conn     *AMQPConnection
+	// retry moves the failed messages in the confirm mode, the message is acked only after it is moved
+	retry *AMQPProducer
+
+	mu sync.Mutex
+	ch *amqp.Channel
+	// renewed is closed when ch is replaced by the channel of the new connection
+	renewed chan struct{}
+}
+
+func (c *AMQPConsumer) setup(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
 	if err != nil {
-		return fmt.Errorf("amqp consumer consume: %w", err)
+		return fmt.Errorf("amqp consumer channel: %w", err)
+	}
+
+	if err := ch.Qos(c.opts.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("amqp consumer qos: %w", err)
+	}
+
+	if err := c.conn.declareExchange(ch, c.exchange); err != nil {
+		return err
+	}
+
+	if err := c.conn.declareQueue(ch, c.queue, nil); err != nil {
+		return err
+	}
+
+	if err := c.conn.declareRetryQueues(ch, c.queue, c.opts.Retry); err != nil {
+		return err
+	}
+
+	if err := c.conn.declareDeadLetter(ch, c.exchange, c.queue); err != nil {
+		return err
+	}
+
+	for _, key := range c.keys {
+		if err := ch.QueueBind(c.queue, key, c.exchange, true, nil); err != nil {
+			return fmt.Errorf("amqp consumer queue binding `%s`: %w", key, err)
+		}
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.renewed != nil {
+		close(c.renewed)
+	}
+	c.ch = ch
+	c.renewed = make(chan struct{})
+
+	return nil
+}
+
+func (c *AMQPConsumer) channel() (*amqp.Channel, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ch, c.renewed
+}
+
+// Consume handles the messages by the pool of the workers until the ctx is done,
+// the consuming is resumed on the new channel after a reconnection.
+// The error is returned when the reconnection gives up.
+func (c *AMQPConsumer) Consume(ctx context.Context, h MessageHandler) error {
 	for {
+		ch, renewed := c.channel()
+
+		deliveries, err := ch.Consume(
+			c.queue,
+			c.tag,
+			false,
+			false,
+			false,
+			false,
+			nil,
+		)
+		switch {
+		case err == nil:
+			c.work(ctx, h, deliveries)
+		case !errors.Is(err, amqp.ErrClosed):
+			return fmt.Errorf("amqp consumer consume: %w", err)
+		}
+
+		if ctx.Err() != nil {
+			// the prefetched messages that are not handled are requeued when the channel is closed
+			_ = ch.Cancel(c.tag, false)
+			_ = ch.Close()
+
+			return nil
+		}
+
 		select {
-		case <-c.closeChan:
+		case <-renewed:
+		case <-ctx.Done():
 			return nil
-		default:
-			select {
-			case d := <-deliveries:
-				h(&Message{
-					Key:     d.RoutingKey,
-					Payload: d.Body,
-				})
-				_ = d.Ack(false)
-			default:
+		case <-c.conn.done:
+			if err := c.conn.lostErr(); err != nil {
+				return fmt.Errorf("amqp consumer consume: %w", err)
 			}
+
+			return nil
 		}
 	}
 }
+
+// work handles the deliveries until they are closed with the channel or the ctx is done,
+// it returns when the workers finish the messages in handling.
+func (c *AMQPConsumer) work(ctx context.Context, h MessageHandler, deliveries <-chan amqp.Delivery) {
+	wg := sync.WaitGroup{}
+	wg.Add(c.opts.Workers)
+
+	for i := 0; i < c.opts.Workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case d, ok := <-deliveries:
+					if !ok {
+						return
+					}
+
+					c.handle(h, d)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// handle acks the handled delivery, the failed one is acked after the broker confirms it is moved to a retry queue
+// or to the dead letter queue. The delivery is requeued when it cannot be moved.
+func (c *AMQPConsumer) handle(h MessageHandler, d amqp.Delivery) {
+	m := messageFromDelivery(d)
+
+	handleErr := h(context.Background(), m)
+	if handleErr == nil {
+		_ = d.Ack(false)
+		return
+	}
+
+	exchange, key := c.opts.Retry.route(c.exchange, c.queue, m.Attempt, handleErr)
+	headers := headersTable(m.Headers)
+	headers[headerKey] = m.Key
+	headers[headerAttempt] = int64(m.Attempt + 1)
+	headers[headerError] = handleErr.Error()
+	if exchange != "" {
+		headers[headerDiedAt] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), retryConfirmTimeout)
+	defer cancel()
+
+	if err := c.retry.publishTo(ctx, exchange, key, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+	}); err != nil {
+		_ = d.Nack(false, true)
+		return
+	}
+
+	_ = d.Ack(false)
+}
+
+// route returns the exchange and the routing key for the message failed attempt times before the err.
+// The message goes to the retry queue of the attempt through the default exchange,
+// it is dead-lettered when the retries are exhausted or the err is ErrPermanent.
+func (p RetryPolicy) route(exchange, queue string, attempt int, err error) (string, string) {
+	if p.Exhausted(attempt, err) {
+		return deadLetterExchangeName(exchange), queue
+	}
+
+	return "", retryQueueName(queue, attempt+1)
+}
+
+func messageFromDelivery(d amqp.Delivery) *Message {
+	m := &Message{
+		Key:     d.RoutingKey,
+		Payload: d.Body,
+	}
+
+	for k, v := range d.Headers {
+		if s, ok := v.(string); ok && !strings.HasPrefix(k, reservedPrefix) {
+			if m.Headers == nil {
+				m.Headers = make(map[string]string)
+			}
+			m.Headers[k] = s
+		}
+	}
+
+	if key, ok := d.Headers[headerKey].(string); ok && key != "" {
+		m.Key = key
+	}
+
+	switch attempt := d.Headers[headerAttempt].(type) {
+	case int64:
+		m.Attempt = int(attempt)
+	case int32:
+		m.Attempt = int(attempt)
+	case int:
+		m.Attempt = attempt
+	}
+
+	return m
+}
+
+// headersTable returns the headers of the message for the publishing, the reserved ones are skipped.
+func headersTable(headers map[string]string) amqp.Table {
+	t := amqp.Table{}
+	for k, v := range headers {
+		if !strings.HasPrefix(k, reservedPrefix) {
+			t[k] = v
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Retries: 5, MinDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	require.Equal(t, time.Second, p.Delay(1))
+	require.Equal(t, 2*time.Second, p.Delay(2))
+	require.Equal(t, 8*time.Second, p.Delay(4))
+	require.Equal(t, 10*time.Second, p.Delay(5))
+	require.Equal(t, 10*time.Second, p.Delay(100))
+}
+
+func TestRetryPolicy_Route(t *testing.T) {
+	p := RetryPolicy{Retries: 2, MinDelay: time.Second, MaxDelay: time.Minute}
+	errTest := errors.New("test error")
+
+	exchange, key := p.route("calendar", "events", 0, errTest)
+	require.Equal(t, "", exchange)
+	require.Equal(t, "events.retry.1", key)
+
+	exchange, key = p.route("calendar", "events", 1, errTest)
+	require.Equal(t, "", exchange)
+	require.Equal(t, "events.retry.2", key)
+
+	exchange, key = p.route("calendar", "events", 2, errTest)
+	require.Equal(t, "calendar.dlx", exchange)
+	require.Equal(t, "events", key)
+
+	exchange, key = p.route("calendar", "events", 0, fmt.Errorf("bad payload: %w", ErrPermanent))
+	require.Equal(t, "calendar.dlx", exchange)
+	require.Equal(t, "events", key)
+}
+
+func TestMessageFromDelivery(t *testing.T) {
+	m := messageFromDelivery(amqp.Delivery{RoutingKey: "event_notification", Body: []byte("{}")})
+	require.Equal(t, &Message{Key: "event_notification", Payload: []byte("{}")}, m)
+
+	// retried messages come through the default exchange
+	m = messageFromDelivery(amqp.Delivery{
+		RoutingKey: "events",
+		Headers:    amqp.Table{headerKey: "event_notification", headerAttempt: int32(2)},
+	})
+	require.Equal(t, "event_notification", m.Key)
+	require.Equal(t, 2, m.Attempt)
+
+	// the headers of the queue and of the broker are not the headers of the message
+	m = messageFromDelivery(amqp.Delivery{
+		RoutingKey: "events",
+		Headers: amqp.Table{
+			headerKey:                "event_notification",
+			"x-first-death-queue":    "events.retry.1",
+			"traceparent":            "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			"x-death":                []interface{}{},
+			"unsupported-value-type": int64(1),
+		},
+	})
+	require.Equal(t, map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}, m.Headers)
+	require.Equal(t, amqp.Table{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}, headersTable(map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		headerAttempt: "5",
+	}))
+
+	dl := deadLetterFromDelivery(amqp.Delivery{
+		RoutingKey: "events",
+		Headers: amqp.Table{
+			headerKey:     "event_notification",
+			headerAttempt: int64(3),
+			headerError:   "test error",
+			headerDiedAt:  "2022-05-18T10:00:00Z",
+		},
+	})
+	require.Equal(t, "event_notification", dl.Key)
+	require.Equal(t, 3, dl.Attempt)
+	require.Equal(t, "test error", dl.Error)
+	require.True(t, time.Date(2022, 5, 18, 10, 0, 0, 0, time.UTC).Equal(dl.DiedAt))
+}
+
+type acknowledgerStub struct {
+	mu       sync.Mutex
+	acked    []uint64
+	requeued []uint64
+}
+
+func (a *acknowledgerStub) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.acked = append(a.acked, tag)
+
+	return nil
+}
+
+func (a *acknowledgerStub) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	}
+
+	return nil
+}
+
+func (a *acknowledgerStub) Reject(tag uint64, requeue bool) error {
+	return nil
+}
+
+func TestAMQPConsumer_Work(t *testing.T) {
+	t.Run("messages are handled concurrently", func(t *testing.T) {
+		c := &AMQPConsumer{opts: ConsumerOptions{Workers: 3}}
+		ack := &acknowledgerStub{}
+
+		deliveries := make(chan amqp.Delivery, 3)
+		for i := 1; i <= 3; i++ {
+			deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: uint64(i)}
+		}
+		close(deliveries)
+
+		// every handler waits for the others, so the test hangs unless the workers run concurrently
+		started := sync.WaitGroup{}
+		started.Add(3)
+		c.work(context.Background(), func(_ context.Context, m *Message) error {
+			started.Done()
+			started.Wait()
+
+			return nil
+		}, deliveries)
+
+		require.ElementsMatch(t, []uint64{1, 2, 3}, ack.acked)
+	})
+
+	t.Run("message in handling is finished when ctx is done", func(t *testing.T) {
+		c := &AMQPConsumer{opts: ConsumerOptions{Workers: 1}}
+		ack := &acknowledgerStub{}
+
+		deliveries := make(chan amqp.Delivery, 2)
+		deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 1}
+		deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 2}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		c.work(ctx, func(_ context.Context, m *Message) error {
+			cancel()
+
+			return nil
+		}, deliveries)
+
+		require.Equal(t, []uint64{1}, ack.acked)
+		require.Len(t, deliveries, 1)
+	})
+}
+
+func TestAMQPConsumer_Handle(t *testing.T) {
+	errTest := errors.New("test error")
+	failed := func(_ context.Context, m *Message) error {
+		return errTest
+	}
+
+	t.Run("failed message is acked after it is moved", func(t *testing.T) {
+		ch := newConfirmChannelStub()
+		c := &AMQPConsumer{
+			queue: "events",
+			opts:  ConsumerOptions{Retry: RetryPolicy{Retries: 1, MinDelay: time.Second, MaxDelay: time.Second}},
+			retry: newConfirmedProducer(t, ch),
+		}
+		ack := &acknowledgerStub{}
+
+		c.handle(failed, amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, RoutingKey: "events"})
+
+		require.Equal(t, []string{"events.retry.1"}, ch.keys)
+		require.Equal(t, []uint64{1}, ack.acked)
+		require.Empty(t, ack.requeued)
+	})
+
+	t.Run("failed message is requeued when it is not confirmed", func(t *testing.T) {
+		ch := newConfirmChannelStub()
+		ch.nack = true
+		c := &AMQPConsumer{queue: "events", retry: newConfirmedProducer(t, ch)}
+		ack := &acknowledgerStub{}
+
+		c.handle(failed, amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, RoutingKey: "events"})
+
+		require.Len(t, ch.keys, 1)
+		require.Empty(t, ack.acked)
+		require.Equal(t, []uint64{1}, ack.requeued)
+	})
+}
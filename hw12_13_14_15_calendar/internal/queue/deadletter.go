@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var _ DeadLetterQueue = (*AMQPDeadLetterQueue)(nil)
+
+type AMQPDeadLetterQueue struct {
+	exchange string
+	queue    string
+	conn     *AMQPConnection
+	// replay moves the letters back in the confirm mode, the letter is acked only after it is moved
+	replay *AMQPProducer
+
+	mu sync.Mutex
+	ch *amqp.Channel
+}
+
+func (q *AMQPDeadLetterQueue) setup(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("amqp dead letter queue channel: %w", err)
+	}
+
+	if err := q.conn.declareDeadLetter(ch, q.exchange, q.queue); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.ch = ch
+
+	return nil
+}
+
+func (q *AMQPDeadLetterQueue) List(limit int) ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := make([]*DeadLetter, 0)
+
+	var last uint64
+	for len(result) < limit {
+		d, ok, err := q.ch.Get(deadLetterQueueName(q.queue), false)
+		if err != nil {
+			return nil, fmt.Errorf("amqp dead letter queue list: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		last = d.DeliveryTag
+		result = append(result, deadLetterFromDelivery(d))
+	}
+
+	// the letters are kept unacked while they are read, so every letter is returned once
+	if last != 0 {
+		if err := q.ch.Nack(last, true, true); err != nil {
+			return nil, fmt.Errorf("amqp dead letter queue list: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+func (q *AMQPDeadLetterQueue) Replay(limit int) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	replayed := 0
+	for replayed < limit {
+		d, ok, err := q.ch.Get(deadLetterQueueName(q.queue), false)
+		if err != nil {
+			return replayed, fmt.Errorf("amqp dead letter queue replay: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		m := messageFromDelivery(d)
+		headers := headersTable(m.Headers)
+		headers[headerKey] = m.Key
+		ctx, cancel := context.WithTimeout(context.Background(), retryConfirmTimeout)
+		err = q.replay.publishTo(ctx, "", q.queue, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  d.ContentType,
+			Body:         d.Body,
+			DeliveryMode: amqp.Persistent,
+		})
+		cancel()
+		if err != nil {
+			_ = d.Nack(false, true)
+			return replayed, fmt.Errorf("amqp dead letter queue replay: %w", err)
+		}
+
+		if err := d.Ack(false); err != nil {
+			return replayed, fmt.Errorf("amqp dead letter queue replay: %w", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func deadLetterFromDelivery(d amqp.Delivery) *DeadLetter {
+	dl := &DeadLetter{Message: *messageFromDelivery(d)}
+
+	if e, ok := d.Headers[headerError].(string); ok {
+		dl.Error = e
+	}
+
+	if diedAt, ok := d.Headers[headerDiedAt].(string); ok {
+		dl.DiedAt, _ = time.Parse(time.RFC3339, diedAt)
+	}
+
+	return dl
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeyStore remembers the keys of the handled messages out of the process.
+type KeyStore interface {
+	Has(ctx context.Context, key string) (bool, error)
+	// Add remembers the key of the handled message, its failure is not an error of the message,
+	// so the store reports it itself.
+	Add(ctx context.Context, key string) error
+}
+
+// Deduplicate wraps the handler to skip the messages with already handled keys.
+// The latest size keys are remembered in the process in front of the store, the store may be nil.
+// A key is remembered only when its message is handled without an error,
+// so the retries of the failed message are not skipped. Messages with an empty key are always handled.
+// The key is claimed by the worker before the handling, the duplicates handled by the other workers
+// at the same time are skipped, the claim is released when the handling fails.
+func Deduplicate(h MessageHandler, key func(m *Message) string, size int, store KeyStore) MessageHandler {
+	d := &dedup{
+		seen:     make(map[string]struct{}, size),
+		keys:     make([]string, size),
+		inFlight: make(map[string]struct{}),
+	}
+
+	return func(ctx context.Context, m *Message) error {
+		k := key(m)
+		if k == "" {
+			return h(ctx, m)
+		}
+
+		if !d.claim(k) {
+			return nil
+		}
+
+		if store != nil {
+			handled, err := store.Has(ctx, k)
+			if err != nil {
+				d.release(k)
+				return fmt.Errorf("deduplicate: %w", err)
+			}
+
+			if handled {
+				d.add(k)
+				return nil
+			}
+		}
+
+		if err := h(ctx, m); err != nil {
+			d.release(k)
+			return err
+		}
+		d.add(k)
+
+		if store != nil {
+			_ = store.Add(ctx, k)
+		}
+
+		return nil
+	}
+}
+
+type dedup struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+	// keys is a ring of the remembered keys, the oldest one is replaced first
+	keys []string
+	next int
+	// inFlight are the claimed keys of the messages in handling
+	inFlight map[string]struct{}
+}
+
+// claim reports whether the key is neither handled nor in handling and marks it in handling.
+func (d *dedup) claim(k string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[k]; ok {
+		return false
+	}
+
+	if _, ok := d.inFlight[k]; ok {
+		return false
+	}
+	d.inFlight[k] = struct{}{}
+
+	return true
+}
+
+// release forgets the claimed key of the failed message.
+func (d *dedup) release(k string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, k)
+}
+
+// add remembers the key of the handled message and releases its claim.
+func (d *dedup) add(k string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, k)
+	if _, ok := d.seen[k]; ok || len(d.keys) == 0 {
+		return
+	}
+
+	if old := d.keys[d.next]; old != "" {
+		delete(d.seen, old)
+	}
+	d.keys[d.next] = k
+	d.seen[k] = struct{}{}
+	d.next = (d.next + 1) % len(d.keys)
+}
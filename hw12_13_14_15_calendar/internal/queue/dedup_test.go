@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicate(t *testing.T) {
+	errTest := errors.New("test error")
+	ctx := context.Background()
+
+	handled := make([]string, 0)
+	h := Deduplicate(func(_ context.Context, m *Message) error {
+		handled = append(handled, string(m.Payload))
+		if string(m.Payload) == "fail" {
+			return errTest
+		}
+
+		return nil
+	}, func(m *Message) string {
+		return m.Key
+	}, 2, nil)
+
+	for _, m := range []*Message{
+		{Key: "a", Payload: []byte("1")},
+		{Key: "a", Payload: []byte("2")},
+		{Key: "b", Payload: []byte("3")},
+		{Key: "", Payload: []byte("4")},
+		{Key: "", Payload: []byte("5")},
+		{Key: "b", Payload: []byte("6")},
+		// "a" is forgotten after "c"
+		{Key: "c", Payload: []byte("7")},
+		{Key: "a", Payload: []byte("8")},
+	} {
+		require.NoError(t, h(ctx, m))
+	}
+
+	require.Equal(t, []string{"1", "3", "4", "5", "7", "8"}, handled)
+
+	t.Run("failed message is not remembered", func(t *testing.T) {
+		handled = handled[:0]
+
+		require.ErrorIs(t, h(ctx, &Message{Key: "d", Payload: []byte("fail")}), errTest)
+		require.NoError(t, h(ctx, &Message{Key: "d", Payload: []byte("9")}))
+		require.NoError(t, h(ctx, &Message{Key: "d", Payload: []byte("10")}))
+
+		require.Equal(t, []string{"fail", "9"}, handled)
+	})
+}
+
+func TestDeduplicateConcurrent(t *testing.T) {
+	errTest := errors.New("test error")
+	ctx := context.Background()
+
+	var calls int32
+	started := make(chan struct{}, 1)
+	unblock := make(chan error)
+	h := Deduplicate(func(_ context.Context, _ *Message) error {
+		atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+
+		return <-unblock
+	}, func(m *Message) string {
+		return m.Key
+	}, 10, nil)
+
+	handle := func(fail error) {
+		result := make(chan error, 1)
+		go func() {
+			result <- h(ctx, &Message{Key: "a"})
+		}()
+		<-started
+
+		// the duplicates delivered to the other workers during the handling are skipped
+		errs := make(chan error, 10)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- h(ctx, &Message{Key: "a"})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		unblock <- fail
+		require.ErrorIs(t, <-result, fail)
+	}
+
+	// the key of the failed message is released and its retry is handled
+	handle(errTest)
+	handle(nil)
+	require.NoError(t, h(ctx, &Message{Key: "a"}))
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+type keyStoreStub struct {
+	keys map[string]struct{}
+	err  error
+}
+
+func (s *keyStoreStub) Has(_ context.Context, key string) (bool, error) {
+	_, ok := s.keys[key]
+
+	return ok, s.err
+}
+
+func (s *keyStoreStub) Add(_ context.Context, key string) error {
+	s.keys[key] = struct{}{}
+
+	return nil
+}
+
+func TestDeduplicateStore(t *testing.T) {
+	ctx := context.Background()
+	store := &keyStoreStub{keys: map[string]struct{}{"a": {}}}
+
+	handled := make([]string, 0)
+	handler := func(_ context.Context, m *Message) error {
+		handled = append(handled, string(m.Payload))
+		return nil
+	}
+	key := func(m *Message) string {
+		return m.Key
+	}
+
+	h := Deduplicate(handler, key, 1, store)
+	require.NoError(t, h(ctx, &Message{Key: "a", Payload: []byte("1")}))
+	require.NoError(t, h(ctx, &Message{Key: "b", Payload: []byte("2")}))
+	require.Contains(t, store.keys, "b")
+
+	// the keys handled before the restart are skipped
+	h = Deduplicate(handler, key, 1, store)
+	require.NoError(t, h(ctx, &Message{Key: "b", Payload: []byte("3")}))
+	require.Equal(t, []string{"2"}, handled)
+
+	t.Run("store error", func(t *testing.T) {
+		errTest := errors.New("test error")
+		store.err = errTest
+
+		require.ErrorIs(t, h(ctx, &Message{Key: "c", Payload: []byte("4")}), errTest)
+		require.Equal(t, []string{"2"}, handled)
+
+		store.err = nil
+		require.NoError(t, h(ctx, &Message{Key: "c", Payload: []byte("5")}))
+		require.Equal(t, []string{"2", "5"}, handled)
+	})
+}
@@ -0,0 +1,170 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue/memory"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var _ memory.Journal = (*Journal)(nil)
+
+// Journal is the append-only log of the broker changes, a record per line.
+// The log is compacted on loading, the file must be used by a single process.
+type Journal struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// New creates the queue kept in the log at the path, the messages survive the restarts of the process.
+func New(path string) queue.Queue {
+	return memory.NewWithJournal(&Journal{path: path})
+}
+
+func (j *Journal) Load() ([]*memory.Record, error) {
+	records, err := j.read()
+	if err != nil {
+		return nil, fmt.Errorf("file journal load: %w", err)
+	}
+
+	records = memory.Compact(records)
+	if err := j.write(records); err != nil {
+		return nil, fmt.Errorf("file journal load: %w", err)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("file journal load: %w", err)
+	}
+
+	j.mu.Lock()
+	j.f = f
+	j.mu.Unlock()
+
+	return records, nil
+}
+
+func (j *Journal) Append(r *memory.Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("file journal append: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return queue.ErrConnectionClosed
+	}
+
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("file journal append: %w", err)
+	}
+
+	return nil
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+
+	err := j.f.Close()
+	j.f = nil
+	if err != nil {
+		return fmt.Errorf("file journal close: %w", err)
+	}
+
+	return nil
+}
+
+// read returns the records of the log, the last line is skipped when it is broken by the crash
+// in the middle of writing.
+func (j *Journal) read() ([]*memory.Record, error) {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := make([]*memory.Record, 0)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		record := &memory.Record{}
+		if err := json.Unmarshal(line, record); err != nil {
+			return nil, fmt.Errorf("broken record %q: %w", line, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// write replaces the log by the records, the new log is written aside and renamed.
+func (j *Journal) write(records []*memory.Record) error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		_, _ = w.Write(append(line, '\n'))
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,90 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+var opts = queue.ConsumerOptions{
+	Retry: queue.RetryPolicy{Retries: 1, MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+}
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue", "calendar.log")
+
+	q := New(path)
+	require.NoError(t, q.Connect())
+
+	c, err := q.CreateConsumer("calendar", "events", opts, "a")
+	require.NoError(t, err)
+	p, err := q.CreateProducer("calendar")
+	require.NoError(t, err)
+
+	for _, payload := range []string{"1", "2", "3"} {
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte(payload)}))
+	}
+
+	// "1" is handled, "2" is dead-lettered, "3" is left in the queue
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, c.Consume(ctx, func(_ context.Context, m *queue.Message) error {
+		switch string(m.Payload) {
+		case "2":
+			return fmt.Errorf("bad: %w", queue.ErrPermanent)
+		case "3":
+			cancel()
+			return fmt.Errorf("interrupted")
+		}
+
+		return nil
+	}))
+	require.NoError(t, q.Close())
+
+	// the crash in the middle of writing leaves the broken line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"put","queue":"ev`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q = New(path)
+	require.NoError(t, q.Connect())
+	defer q.Close()
+
+	// the binding is restored before the consumer is created
+	p, err = q.CreateProducer("calendar")
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte("4")}))
+
+	dlq, err := q.CreateDeadLetterQueue("calendar", "events")
+	require.NoError(t, err)
+	letters, err := dlq.List(10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, "2", string(letters[0].Payload))
+	require.Contains(t, letters[0].Error, "bad")
+
+	c, err = q.CreateConsumer("calendar", "events", opts, "a")
+	require.NoError(t, err)
+
+	// "3" waits for its retry delay, so "4" may come first
+	handled := make(map[string]int)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Consume(ctx, func(_ context.Context, m *queue.Message) error {
+		handled[string(m.Payload)] = m.Attempt
+		if len(handled) == 2 {
+			cancel()
+		}
+
+		return nil
+	}))
+
+	require.Equal(t, map[string]int{"3": 1, "4": 0}, handled)
+}
@@ -0,0 +1,481 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+)
+
+var _ queue.Queue = (*Broker)(nil)
+
+// Broker is the in-process queue, the messages of an exchange are routed to the queues
+// bound to their routing key like in the AMQP direct exchange.
+// The messages are lost with the process unless the broker has a journal.
+type Broker struct {
+	mu      sync.Mutex
+	journal Journal
+
+	// bindings are the queues by the routing keys by the exchanges
+	bindings map[string]map[string]map[string]struct{}
+	queues   map[string]*messageQueue
+	id       uint64
+	timers   map[uint64]*time.Timer
+
+	done   chan struct{}
+	closed bool
+}
+
+type messageQueue struct {
+	// entries are the messages that are not handled yet, ready ones are delivered in the order of ready
+	entries map[uint64]*Entry
+	ready   []*Entry
+	dead    []*Entry
+	// signal is closed when a message becomes ready
+	signal chan struct{}
+}
+
+func New() *Broker {
+	return &Broker{
+		bindings: make(map[string]map[string]map[string]struct{}),
+		queues:   make(map[string]*messageQueue),
+		timers:   make(map[uint64]*time.Timer),
+		done:     make(chan struct{}),
+	}
+}
+
+// NewWithJournal creates the broker that restores its state from the journal on Connect
+// and appends every change to it.
+func NewWithJournal(j Journal) *Broker {
+	b := New()
+	b.journal = j
+
+	return b
+}
+
+func (b *Broker) Connect() error {
+	if b.journal == nil {
+		return nil
+	}
+
+	records, err := b.journal.Load()
+	if err != nil {
+		return fmt.Errorf("memory broker connect: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, r := range records {
+		b.apply(r)
+	}
+
+	return nil
+}
+
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	b.stopTimers()
+
+	if b.journal != nil {
+		if err := b.journal.Close(); err != nil {
+			return fmt.Errorf("memory broker close: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (b *Broker) Ping(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return queue.ErrConnectionClosed
+	}
+
+	return nil
+}
+
+func (b *Broker) CreateProducer(exchange string) (queue.Producer, error) {
+	return &Producer{
+		exchange: exchange,
+		broker:   b,
+	}, nil
+}
+
+// CreateConsumer binds the queue to the keys of the exchange, the prefetch of the options is not used
+// since the workers take the messages one by one.
+func (b *Broker) CreateConsumer(exchange, q string, opts queue.ConsumerOptions, keys ...string) (queue.Consumer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queue(q)
+	for _, key := range keys {
+		if _, ok := b.bindings[exchange][key][q]; ok {
+			continue
+		}
+
+		if err := b.commit(&Record{Op: OpBind, Exchange: exchange, Key: key, Queue: q}); err != nil {
+			return nil, fmt.Errorf("memory broker create consumer: %w", err)
+		}
+	}
+
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	return &Consumer{
+		queue:  q,
+		opts:   opts,
+		broker: b,
+	}, nil
+}
+
+func (b *Broker) CreateDeadLetterQueue(_, q string) (queue.DeadLetterQueue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queue(q)
+
+	return &DeadLetterQueue{
+		queue:  q,
+		broker: b,
+	}, nil
+}
+
+func (b *Broker) publish(exchange string, m *queue.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return queue.ErrConnectionClosed
+	}
+
+	queues := make([]string, 0, len(b.bindings[exchange][m.Key]))
+	for q := range b.bindings[exchange][m.Key] {
+		queues = append(queues, q)
+	}
+	sort.Strings(queues)
+
+	for _, q := range queues {
+		b.id++
+		e := &Entry{
+			ID: b.id,
+			Message: queue.Message{
+				Key:     m.Key,
+				Payload: append([]byte(nil), m.Payload...),
+				Headers: copyHeaders(m.Headers),
+			},
+		}
+
+		if err := b.commit(&Record{Op: OpPut, Queue: q, Entry: e}); err != nil {
+			return fmt.Errorf("memory broker publish: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// take waits for the ready message of the queue, it returns nil when the ctx is done or the broker is closed.
+func (b *Broker) take(ctx context.Context, q string) *Entry {
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil
+		}
+
+		mq := b.queue(q)
+		for len(mq.ready) > 0 {
+			e := mq.ready[0]
+			mq.ready = mq.ready[1:]
+
+			// the entry is replaced when it is retried or replayed
+			if mq.entries[e.ID] == e {
+				b.mu.Unlock()
+				return e
+			}
+		}
+		signal := mq.signal
+		b.mu.Unlock()
+
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return nil
+		case <-b.done:
+			return nil
+		}
+	}
+}
+
+// settle removes the handled message, the failed one is retried or moved to the dead letters.
+// The message is left in handling when the change cannot be written to the journal,
+// so it is delivered again after the journal is loaded.
+func (b *Broker) settle(q string, e *Entry, retry queue.RetryPolicy, handleErr error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	if handleErr == nil {
+		_ = b.commit(&Record{Op: OpDone, Queue: q, Entry: &Entry{ID: e.ID}})
+		return
+	}
+
+	next := *e
+	next.Message.Attempt++
+	next.Error = handleErr.Error()
+
+	if retry.Exhausted(e.Message.Attempt, handleErr) {
+		next.DiedAt = time.Now().UTC()
+		_ = b.commit(&Record{Op: OpDead, Queue: q, Entry: &next})
+
+		return
+	}
+
+	next.ReadyAt = time.Now().Add(retry.Delay(next.Message.Attempt))
+	_ = b.commit(&Record{Op: OpPut, Queue: q, Entry: &next})
+}
+
+func (b *Broker) deadLetters(q string, limit int) []*queue.DeadLetter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dead := b.queue(q).dead
+	if len(dead) > limit {
+		dead = dead[:limit]
+	}
+
+	result := make([]*queue.DeadLetter, 0, len(dead))
+	for _, e := range dead {
+		result = append(result, &queue.DeadLetter{
+			Message: queue.Message{
+				Key:     e.Message.Key,
+				Payload: append([]byte(nil), e.Message.Payload...),
+				Headers: copyHeaders(e.Message.Headers),
+				Attempt: e.Message.Attempt,
+			},
+			Error:  e.Error,
+			DiedAt: e.DiedAt,
+		})
+	}
+
+	return result
+}
+
+func (b *Broker) replay(q string, limit int) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return 0, queue.ErrConnectionClosed
+	}
+
+	replayed := 0
+	for replayed < limit && len(b.queue(q).dead) > 0 {
+		e := b.queue(q).dead[0]
+		if err := b.commit(&Record{Op: OpPut, Queue: q, Entry: &Entry{
+			ID: e.ID,
+			Message: queue.Message{
+				Key:     e.Message.Key,
+				Payload: e.Message.Payload,
+				Headers: e.Message.Headers,
+			},
+		}}); err != nil {
+			return replayed, fmt.Errorf("memory dead letter queue replay: %w", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// commit writes the record to the journal and applies it, the caller must hold the lock.
+func (b *Broker) commit(r *Record) error {
+	if b.journal != nil {
+		if err := b.journal.Append(r); err != nil {
+			return err
+		}
+	}
+	b.apply(r)
+
+	return nil
+}
+
+// apply changes the state by the record, the caller must hold the lock.
+func (b *Broker) apply(r *Record) {
+	mq := b.queue(r.Queue)
+
+	switch r.Op {
+	case OpBind:
+		if b.bindings[r.Exchange] == nil {
+			b.bindings[r.Exchange] = make(map[string]map[string]struct{})
+		}
+		if b.bindings[r.Exchange][r.Key] == nil {
+			b.bindings[r.Exchange][r.Key] = make(map[string]struct{})
+		}
+		b.bindings[r.Exchange][r.Key][r.Queue] = struct{}{}
+	case OpPut:
+		mq.removeDead(r.Entry.ID)
+		mq.entries[r.Entry.ID] = r.Entry
+		b.schedule(mq, r.Entry)
+	case OpDead:
+		delete(mq.entries, r.Entry.ID)
+		mq.removeDead(r.Entry.ID)
+		mq.dead = append(mq.dead, r.Entry)
+	case OpDone:
+		delete(mq.entries, r.Entry.ID)
+	}
+
+	if r.Entry != nil && r.Entry.ID > b.id {
+		b.id = r.Entry.ID
+	}
+}
+
+// schedule makes the entry ready at its ReadyAt, the caller must hold the lock.
+func (b *Broker) schedule(mq *messageQueue, e *Entry) {
+	if t, ok := b.timers[e.ID]; ok {
+		t.Stop()
+		delete(b.timers, e.ID)
+	}
+
+	delay := time.Until(e.ReadyAt)
+	if delay <= 0 {
+		mq.push(e)
+		return
+	}
+
+	b.timers[e.ID] = time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if b.closed || mq.entries[e.ID] != e {
+			return
+		}
+		delete(b.timers, e.ID)
+		mq.push(e)
+	})
+}
+
+// snapshot returns the records of the current state, the caller must hold the lock.
+func (b *Broker) snapshot() []*Record {
+	records := make([]*Record, 0)
+
+	exchanges := make([]string, 0, len(b.bindings))
+	for exchange := range b.bindings {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	for _, exchange := range exchanges {
+		keys := make([]string, 0, len(b.bindings[exchange]))
+		for key := range b.bindings[exchange] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			queues := make([]string, 0, len(b.bindings[exchange][key]))
+			for q := range b.bindings[exchange][key] {
+				queues = append(queues, q)
+			}
+			sort.Strings(queues)
+
+			for _, q := range queues {
+				records = append(records, &Record{Op: OpBind, Exchange: exchange, Key: key, Queue: q})
+			}
+		}
+	}
+
+	queues := make([]string, 0, len(b.queues))
+	for q := range b.queues {
+		queues = append(queues, q)
+	}
+	sort.Strings(queues)
+
+	for _, q := range queues {
+		mq := b.queues[q]
+
+		entries := make([]*Entry, 0, len(mq.entries))
+		for _, e := range mq.entries {
+			entries = append(entries, e)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].ID < entries[j].ID
+		})
+
+		for _, e := range entries {
+			records = append(records, &Record{Op: OpPut, Queue: q, Entry: e})
+		}
+
+		for _, e := range mq.dead {
+			records = append(records, &Record{Op: OpDead, Queue: q, Entry: e})
+		}
+	}
+
+	return records
+}
+
+func (b *Broker) stopTimers() {
+	for id, t := range b.timers {
+		t.Stop()
+		delete(b.timers, id)
+	}
+}
+
+// queue returns the queue by the name and declares it when it does not exist, the caller must hold the lock.
+func (b *Broker) queue(name string) *messageQueue {
+	mq, ok := b.queues[name]
+	if !ok {
+		mq = &messageQueue{
+			entries: make(map[uint64]*Entry),
+			signal:  make(chan struct{}),
+		}
+		b.queues[name] = mq
+	}
+
+	return mq
+}
+
+func (mq *messageQueue) push(e *Entry) {
+	mq.ready = append(mq.ready, e)
+
+	close(mq.signal)
+	mq.signal = make(chan struct{})
+}
+
+func (mq *messageQueue) removeDead(id uint64) {
+	for i, e := range mq.dead {
+		if e.ID == id {
+			mq.dead = append(mq.dead[:i], mq.dead[i+1:]...)
+			return
+		}
+	}
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		result[k] = v
+	}
+
+	return result
+}
@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+var retry = queue.RetryPolicy{Retries: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+// collect consumes the queue until n messages are handled and returns their payloads.
+func collect(t *testing.T, c queue.Consumer, n int, h queue.MessageHandler) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	mu := sync.Mutex{}
+	handled := make([]string, 0, n)
+	require.NoError(t, c.Consume(ctx, func(ctx context.Context, m *queue.Message) error {
+		err := h(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled = append(handled, string(m.Payload))
+		if len(handled) == n {
+			cancel()
+		}
+
+		return nil
+	}))
+
+	require.Len(t, handled, n)
+
+	return handled
+}
+
+func ok(context.Context, *queue.Message) error {
+	return nil
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("messages are routed by key", func(t *testing.T) {
+		b := New()
+		require.NoError(t, b.Connect())
+		defer b.Close()
+
+		events, err := b.CreateConsumer("calendar", "events", queue.ConsumerOptions{Retry: retry}, "a", "b")
+		require.NoError(t, err)
+		audit, err := b.CreateConsumer("calendar", "audit", queue.ConsumerOptions{Retry: retry}, "a")
+		require.NoError(t, err)
+
+		p, err := b.CreateProducer("calendar")
+		require.NoError(t, err)
+		other, err := b.CreateProducer("other")
+		require.NoError(t, err)
+
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte("1")}))
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "b", Payload: []byte("2")}))
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "c", Payload: []byte("3")}))
+		require.NoError(t, other.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte("4")}))
+
+		require.Equal(t, []string{"1", "2"}, collect(t, events, 2, ok))
+		require.Equal(t, []string{"1"}, collect(t, audit, 1, ok))
+	})
+
+	t.Run("failed message is retried and dead-lettered", func(t *testing.T) {
+		b := New()
+		require.NoError(t, b.Connect())
+		defer b.Close()
+
+		c, err := b.CreateConsumer("calendar", "events", queue.ConsumerOptions{Retry: retry, Workers: 2}, "a")
+		require.NoError(t, err)
+		p, err := b.CreateProducer("calendar")
+		require.NoError(t, err)
+
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte("flaky")}))
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte("broken")}))
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{
+			Key:     "a",
+			Payload: []byte("invalid"),
+			Headers: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		}))
+		require.NoError(t, p.Publish(context.Background(), &queue.Message{Key: "a", Payload: []byte("done")}))
+
+		dlq, err := b.CreateDeadLetterQueue("calendar", "events")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		consumed := make(chan error)
+
+		mu := sync.Mutex{}
+		attempts := make(map[string][]int)
+		go func() {
+			consumed <- c.Consume(ctx, func(_ context.Context, m *queue.Message) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				payload := string(m.Payload)
+				attempts[payload] = append(attempts[payload], m.Attempt)
+
+				switch {
+				case payload == "flaky" && m.Attempt < 2:
+					return errors.New("flaky")
+				case payload == "broken":
+					return errors.New("broken")
+				case payload == "invalid":
+					return fmt.Errorf("invalid: %w", queue.ErrPermanent)
+				}
+
+				return nil
+			})
+		}()
+
+		var letters []*queue.DeadLetter
+		require.Eventually(t, func() bool {
+			letters, err = dlq.List(10)
+			require.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(letters) == 2 && len(attempts["flaky"]) == 3 && len(attempts["done"]) == 1
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-consumed)
+
+		require.Equal(t, []int{0, 1, 2}, attempts["flaky"])
+		require.Equal(t, []int{0, 1, 2}, attempts["broken"])
+		require.Equal(t, []int{0}, attempts["invalid"])
+
+		require.Equal(t, "invalid", string(letters[0].Payload))
+		require.Equal(t, 1, letters[0].Attempt)
+		require.Contains(t, letters[0].Error, "permanent failure")
+		require.False(t, letters[0].DiedAt.IsZero())
+		require.Equal(t, "broken", string(letters[1].Payload))
+		require.Equal(t, 3, letters[1].Attempt)
+
+		replayed, err := dlq.Replay(1)
+		require.NoError(t, err)
+		require.Equal(t, 1, replayed)
+
+		require.Equal(t, []string{"invalid"}, collect(t, c, 1, func(_ context.Context, m *queue.Message) error {
+			require.Equal(t, 0, m.Attempt)
+			require.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", m.Headers["traceparent"])
+
+			return nil
+		}))
+
+		letters, err = dlq.List(10)
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+	})
+
+	t.Run("consume returns when broker is closed", func(t *testing.T) {
+		b := New()
+		require.NoError(t, b.Connect())
+
+		c, err := b.CreateConsumer("calendar", "events", queue.ConsumerOptions{Retry: retry}, "a")
+		require.NoError(t, err)
+		p, err := b.CreateProducer("calendar")
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = b.Close()
+		}()
+
+		require.NoError(t, c.Consume(context.Background(), ok))
+		require.ErrorIs(t, p.Publish(context.Background(), &queue.Message{Key: "a"}), queue.ErrConnectionClosed)
+	})
+}
+
+func TestCompact(t *testing.T) {
+	entry := func(id uint64, payload string) *Entry {
+		return &Entry{ID: id, Message: queue.Message{Key: "a", Payload: []byte(payload)}}
+	}
+
+	records := Compact([]*Record{
+		{Op: OpBind, Exchange: "calendar", Key: "a", Queue: "events"},
+		{Op: OpPut, Queue: "events", Entry: entry(1, "1")},
+		{Op: OpPut, Queue: "events", Entry: entry(2, "2")},
+		{Op: OpPut, Queue: "events", Entry: entry(3, "3")},
+		{Op: OpDone, Queue: "events", Entry: &Entry{ID: 1}},
+		{Op: OpDead, Queue: "events", Entry: entry(2, "2")},
+		{Op: OpBind, Exchange: "calendar", Key: "a", Queue: "events"},
+	})
+
+	require.Equal(t, []*Record{
+		{Op: OpBind, Exchange: "calendar", Key: "a", Queue: "events"},
+		{Op: OpPut, Queue: "events", Entry: entry(3, "3")},
+		{Op: OpDead, Queue: "events", Entry: entry(2, "2")},
+	}, records)
+}
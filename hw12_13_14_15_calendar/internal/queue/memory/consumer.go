@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+)
+
+var _ queue.Consumer = (*Consumer)(nil)
+
+type Consumer struct {
+	queue  string
+	opts   queue.ConsumerOptions
+	broker *Broker
+}
+
+// Consume handles the messages by the pool of the workers until the ctx is done or the broker is closed.
+func (c *Consumer) Consume(ctx context.Context, h queue.MessageHandler) error {
+	wg := sync.WaitGroup{}
+	wg.Add(c.opts.Workers)
+
+	for i := 0; i < c.opts.Workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				e := c.broker.take(ctx, c.queue)
+				if e == nil {
+					return
+				}
+
+				m := e.Message
+				c.broker.settle(c.queue, e, c.opts.Retry, h(context.Background(), &m))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package memory
+
+import "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+
+var _ queue.DeadLetterQueue = (*DeadLetterQueue)(nil)
+
+type DeadLetterQueue struct {
+	queue  string
+	broker *Broker
+}
+
+func (q *DeadLetterQueue) List(limit int) ([]*queue.DeadLetter, error) {
+	return q.broker.deadLetters(q.queue, limit), nil
+}
+
+func (q *DeadLetterQueue) Replay(limit int) (int, error) {
+	return q.broker.replay(q.queue, limit)
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+)
+
+type Op string
+
+const (
+	// OpBind binds the queue to the routing key of the exchange.
+	OpBind Op = "bind"
+	// OpPut puts the message to the queue or replaces the message with the same id.
+	OpPut Op = "put"
+	// OpDead moves the message to the dead letters of the queue.
+	OpDead Op = "dead"
+	// OpDone removes the handled message.
+	OpDone Op = "done"
+)
+
+// Entry is the message of the queue, it is delivered not before ReadyAt.
+type Entry struct {
+	ID      uint64        `json:"id"`
+	Message queue.Message `json:"message"`
+	ReadyAt time.Time     `json:"readyAt"`
+	Error   string        `json:"error,omitempty"`
+	DiedAt  time.Time     `json:"diedAt"`
+}
+
+// Record is the change of the broker state.
+type Record struct {
+	Op       Op     `json:"op"`
+	Exchange string `json:"exchange,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Queue    string `json:"queue"`
+	Entry    *Entry `json:"entry,omitempty"`
+}
+
+// Journal keeps the changes of the broker state to restore it on the next connection.
+type Journal interface {
+	// Load returns the records the broker state is restored from, the changes are appended after them.
+	Load() ([]*Record, error)
+	Append(r *Record) error
+	Close() error
+}
+
+// Compact returns the records of the state the records lead to,
+// the messages are put in the order of their ids.
+func Compact(records []*Record) []*Record {
+	b := New()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, r := range records {
+		b.apply(r)
+	}
+	defer b.stopTimers()
+
+	return b.snapshot()
+}
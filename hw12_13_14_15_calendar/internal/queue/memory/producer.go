@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+)
+
+var _ queue.Producer = (*Producer)(nil)
+
+type Producer struct {
+	exchange string
+	broker   *Broker
+}
+
+// Publish returns nil once the message is put to the bound queues and written to the journal.
+func (p *Producer) Publish(_ context.Context, m *queue.Message) error {
+	return p.broker.publish(p.exchange, m)
+}
@@ -3,6 +3,8 @@
 package mockqueue
 
 import (
+	context "context"
+
 	queue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,13 +14,13 @@ type Consumer struct {
 	mock.Mock
 }
 
-// Consume provides a mock function with given fields: h
-func (_m *Consumer) Consume(h queue.MessageHandler) error {
-	ret := _m.Called(h)
+// Consume provides a mock function with given fields: ctx, h
+func (_m *Consumer) Consume(ctx context.Context, h queue.MessageHandler) error {
+	ret := _m.Called(ctx, h)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(queue.MessageHandler) error); ok {
-		r0 = rf(h)
+	if rf, ok := ret.Get(0).(func(context.Context, queue.MessageHandler) error); ok {
+		r0 = rf(ctx, h)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -3,6 +3,8 @@
 package mockqueue
 
 import (
+	context "context"
+
 	queue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,7 +14,16 @@ type MessageHandler struct {
 	mock.Mock
 }
 
-// Execute provides a mock function with given fields: m
-func (_m *MessageHandler) Execute(m *queue.Message) {
-	_m.Called(m)
+// Execute provides a mock function with given fields: ctx, m
+func (_m *MessageHandler) Execute(ctx context.Context, m *queue.Message) error {
+	ret := _m.Called(ctx, m)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *queue.Message) error); ok {
+		r0 = rf(ctx, m)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
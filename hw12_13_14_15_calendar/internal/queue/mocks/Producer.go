@@ -3,6 +3,8 @@
 package mockqueue
 
 import (
+	context "context"
+
 	queue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,13 +14,13 @@ type Producer struct {
 	mock.Mock
 }
 
-// Publish provides a mock function with given fields: m
-func (_m *Producer) Publish(m *queue.Message) error {
-	ret := _m.Called(m)
+// Publish provides a mock function with given fields: ctx, m
+func (_m *Producer) Publish(ctx context.Context, m *queue.Message) error {
+	ret := _m.Called(ctx, m)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*queue.Message) error); ok {
-		r0 = rf(m)
+	if rf, ok := ret.Get(0).(func(context.Context, *queue.Message) error); ok {
+		r0 = rf(ctx, m)
 	} else {
 		r0 = ret.Error(0)
 	}
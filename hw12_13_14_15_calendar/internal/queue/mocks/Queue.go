@@ -3,6 +3,8 @@
 package mockqueue
 
 import (
+	context "context"
+
 	queue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -40,13 +42,20 @@ func (_m *Queue) Connect() error {
 	return r0
 }
 
-// CreateConsumer provides a mock function with given fields: exchange, _a1, key
-func (_m *Queue) CreateConsumer(exchange string, _a1 string, key string) (queue.Consumer, error) {
-	ret := _m.Called(exchange, _a1, key)
+// CreateConsumer provides a mock function with given fields: exchange, _a1, opts, keys
+func (_m *Queue) CreateConsumer(exchange string, _a1 string, opts queue.ConsumerOptions, keys ...string) (queue.Consumer, error) {
+	_va := make([]interface{}, len(keys))
+	for _i := range keys {
+		_va[_i] = keys[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, exchange, _a1, opts)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 queue.Consumer
-	if rf, ok := ret.Get(0).(func(string, string, string) queue.Consumer); ok {
-		r0 = rf(exchange, _a1, key)
+	if rf, ok := ret.Get(0).(func(string, string, queue.ConsumerOptions, ...string) queue.Consumer); ok {
+		r0 = rf(exchange, _a1, opts, keys...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(queue.Consumer)
@@ -54,8 +63,31 @@ func (_m *Queue) CreateConsumer(exchange string, _a1 string, key string) (queue.
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
-		r1 = rf(exchange, _a1, key)
+	if rf, ok := ret.Get(1).(func(string, string, queue.ConsumerOptions, ...string) error); ok {
+		r1 = rf(exchange, _a1, opts, keys...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateDeadLetterQueue provides a mock function with given fields: exchange, _a1
+func (_m *Queue) CreateDeadLetterQueue(exchange string, _a1 string) (queue.DeadLetterQueue, error) {
+	ret := _m.Called(exchange, _a1)
+
+	var r0 queue.DeadLetterQueue
+	if rf, ok := ret.Get(0).(func(string, string) queue.DeadLetterQueue); ok {
+		r0 = rf(exchange, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(queue.DeadLetterQueue)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(exchange, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -85,3 +117,17 @@ func (_m *Queue) CreateProducer(exchange string) (queue.Producer, error) {
 
 	return r0, r1
 }
+
+// Ping provides a mock function with given fields: ctx
+func (_m *Queue) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
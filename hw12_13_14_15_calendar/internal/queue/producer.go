@@ -1,38 +1,181 @@
 package queue
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/streadway/amqp"
 )
 
 var _ Producer = (*AMQPProducer)(nil)
 
+// confirmsBuffer is the size of the buffer of the confirmations, the reader of the connection blocks
+// the channel while the confirmation is not taken.
+const confirmsBuffer = 128
+
+// publishChannel is the part of amqp.Channel used for the publishing.
+type publishChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// AMQPProducer publishes in the confirm mode. The delivery tags of the channel are numbered in the order
+// of the publishing, so the confirmations are passed to the waiting publishers by the tags.
+//
+// The confirmations are dispatched under the own lock of the pending publishers: amqp.Channel holds
+// its lock of the confirmations both in Publish and while the reader of the connection sends them,
+// so the dispatching must not wait for the publishing.
 type AMQPProducer struct {
 	exchange string
 	conn     *AMQPConnection
-	ch       *amqp.Channel
+
+	// mu keeps the delivery tags in the order of the publishing
+	mu sync.Mutex
+	ch publishChannel
+	// tag is the delivery tag of the last message published to the channel
+	tag uint64
+
+	pendingMu sync.Mutex
+	// pending are the publishers waiting for the confirmations by the delivery tags
+	pending map[uint64]chan bool
 }
 
-func (p *AMQPProducer) Publish(m *Message) error {
-	if p.conn.isClosed() {
-		return ErrConnectionClosed
+func (p *AMQPProducer) setup(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("amqp producer channel: %w", err)
 	}
 
-	if err := p.ch.Publish(
-		p.exchange,
-		m.Key,
-		false,
-		false,
-		amqp.Publishing{
-			Headers:         amqp.Table{},
-			ContentType:     "application/json",
-			ContentEncoding: "",
-			Body:            m.Payload,
-			DeliveryMode:    amqp.Transient,
-		}); err != nil {
-		return fmt.Errorf("amqp producer publish: %w", err)
+	if err := p.conn.declareExchange(ch, p.exchange); err != nil {
+		return err
 	}
 
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("amqp producer confirm mode: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, confirmsBuffer))
+
+	p.mu.Lock()
+	// the messages published to the previous channel are not confirmed by the new one
+	p.pendingMu.Lock()
+	p.failPending()
+	p.pendingMu.Unlock()
+	p.ch = ch
+	p.tag = 0
+	p.mu.Unlock()
+
+	go p.dispatch(ch, confirms)
+
 	return nil
 }
+
+// dispatch passes the confirmations to the publishers until the channel is closed, the confirmations
+// must be read even when the publisher does not wait for them anymore.
+func (p *AMQPProducer) dispatch(ch publishChannel, confirms <-chan amqp.Confirmation) {
+	for c := range confirms {
+		if confirmed := p.takePending(c.DeliveryTag); confirmed != nil {
+			confirmed <- c.Ack
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the publishers of the next channel are not failed
+	if p.ch == ch {
+		p.pendingMu.Lock()
+		p.failPending()
+		p.pendingMu.Unlock()
+	}
+}
+
+// takePending removes the publisher waiting for the confirmation of the tag and returns it,
+// nil is returned when nobody waits for it.
+func (p *AMQPProducer) takePending(tag uint64) chan bool {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	confirmed, ok := p.pending[tag]
+	if !ok {
+		return nil
+	}
+	delete(p.pending, tag)
+
+	return confirmed
+}
+
+// failPending fails the publishers waiting for the confirmations, the caller must hold the pending lock.
+func (p *AMQPProducer) failPending() {
+	for tag, confirmed := range p.pending {
+		close(confirmed)
+		delete(p.pending, tag)
+	}
+}
+
+// Publish returns nil once the broker has accepted the message, it stops waiting when the ctx is done.
+func (p *AMQPProducer) Publish(ctx context.Context, m *Message) error {
+	return p.publishTo(ctx, p.exchange, m.Key, amqp.Publishing{
+		Headers:         headersTable(m.Headers),
+		ContentType:     "application/json",
+		ContentEncoding: "",
+		Body:            m.Payload,
+		DeliveryMode:    amqp.Persistent,
+	})
+}
+
+// publishTo publishes to the exchange and waits for the confirmation like Publish does.
+func (p *AMQPProducer) publishTo(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	confirmed, err := p.publish(exchange, key, msg)
+	if err != nil {
+		return err
+	}
+
+	return waitConfirm(ctx, confirmed)
+}
+
+func waitConfirm(ctx context.Context, confirmed <-chan bool) error {
+	select {
+	case ack, ok := <-confirmed:
+		// the waiting publishers are failed when the channel is closed, the message may be lost then
+		if !ok {
+			return ErrConnectionClosed
+		}
+
+		if !ack {
+			return ErrNotConfirmed
+		}
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("amqp producer confirm: %w", ctx.Err())
+	}
+}
+
+// publish publishes the message and returns the channel of its confirmation,
+// the publisher is registered before the publishing since the confirmation may come before Publish returns.
+func (p *AMQPProducer) publish(exchange, key string, msg amqp.Publishing) (<-chan bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tag := p.tag + 1
+	confirmed := make(chan bool, 1)
+	p.pendingMu.Lock()
+	p.pending[tag] = confirmed
+	p.pendingMu.Unlock()
+
+	if err := p.ch.Publish(exchange, key, false, false, msg); err != nil {
+		// the delivery tag is not taken by the failed publishing
+		p.takePending(tag)
+
+		if errors.Is(err, amqp.ErrClosed) {
+			return nil, ErrConnectionClosed
+		}
+
+		return nil, fmt.Errorf("amqp producer publish: %w", err)
+	}
+
+	p.tag = tag
+
+	return confirmed, nil
+}
@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAMQPProducer_Dispatch(t *testing.T) {
+	ch := &amqp.Channel{}
+	p := &AMQPProducer{ch: ch, pending: make(map[uint64]chan bool)}
+	waiter := func(tag uint64) chan bool {
+		confirmed := make(chan bool, 1)
+		p.pending[tag] = confirmed
+
+		return confirmed
+	}
+
+	first, second, third := waiter(1), waiter(2), waiter(3)
+	confirms := make(chan amqp.Confirmation, 2)
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+
+	done := make(chan struct{})
+	go func() {
+		p.dispatch(ch, confirms)
+		close(done)
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, waitConfirm(ctx, first))
+	require.ErrorIs(t, waitConfirm(ctx, second), ErrNotConfirmed)
+
+	// the publisher stops waiting when the ctx is done
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, waitConfirm(timeoutCtx, third), context.DeadlineExceeded)
+
+	// the publishers are failed when the channel is closed
+	close(confirms)
+	<-done
+	require.ErrorIs(t, waitConfirm(ctx, third), ErrConnectionClosed)
+	require.Empty(t, p.pending)
+
+	t.Run("publishers of the next channel are not failed", func(t *testing.T) {
+		confirms := make(chan amqp.Confirmation)
+		p.ch = &amqp.Channel{}
+		next := waiter(1)
+
+		close(confirms)
+		p.dispatch(ch, confirms)
+
+		require.Len(t, p.pending, 1)
+		select {
+		case <-next:
+			require.Fail(t, "the publisher is failed")
+		default:
+		}
+	})
+}
+
+// confirmChannelStub confirms the messages like amqp.Channel in the confirm mode: the reader of the connection
+// holds the lock of the publishing while it waits for the confirmation to be taken.
+type confirmChannelStub struct {
+	mu       sync.Mutex
+	tag      uint64
+	acks     chan uint64
+	confirms chan amqp.Confirmation
+	// nack makes the broker refuse the messages
+	nack bool
+	// keys are the routing keys of the published messages
+	keys []string
+}
+
+func newConfirmChannelStub() *confirmChannelStub {
+	c := &confirmChannelStub{acks: make(chan uint64, 1000), confirms: make(chan amqp.Confirmation)}
+	go func() {
+		for tag := range c.acks {
+			c.mu.Lock()
+			c.confirms <- amqp.Confirmation{DeliveryTag: tag, Ack: !c.nack}
+			c.mu.Unlock()
+		}
+		close(c.confirms)
+	}()
+
+	return c
+}
+
+func (c *confirmChannelStub) Publish(_, key string, _, _ bool, _ amqp.Publishing) error {
+	// the frame is written before the delivery tag is taken
+	time.Sleep(100 * time.Microsecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keys = append(c.keys, key)
+	c.tag++
+	c.acks <- c.tag
+
+	return nil
+}
+
+// newConfirmedProducer returns the producer publishing to the channel in the confirm mode,
+// the channel is closed by the cleanup.
+func newConfirmedProducer(t *testing.T, ch *confirmChannelStub) *AMQPProducer {
+	t.Helper()
+
+	p := &AMQPProducer{ch: ch, pending: make(map[uint64]chan bool)}
+	done := make(chan struct{})
+	go func() {
+		p.dispatch(ch, ch.confirms)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		close(ch.acks)
+		<-done
+	})
+
+	return p
+}
+
+func TestAMQPProducer_Publish(t *testing.T) {
+	ch := newConfirmChannelStub()
+	p := newConfirmedProducer(t, ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const publishers, messages = 10, 20
+	errs := make(chan error, publishers*messages)
+	wg := sync.WaitGroup{}
+	wg.Add(publishers)
+	for i := 0; i < publishers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < messages; j++ {
+				errs <- p.Publish(ctx, &Message{Key: "test"})
+			}
+		}()
+	}
+
+	// the publishing blocked by the dispatching does not stop on the ctx
+	published := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the publishers are deadlocked")
+	}
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint64(publishers*messages), p.tag)
+	require.Empty(t, p.pending)
+}
@@ -1,35 +1,107 @@
 package queue
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
-var ErrConnectionClosed = errors.New("connection is closed")
+var (
+	ErrConnectionClosed = errors.New("connection is closed")
+	// ErrNotConfirmed is returned when the broker refused to accept the published message.
+	ErrNotConfirmed = errors.New("message is not confirmed")
+	// ErrPermanent marks handler errors that retries cannot fix, such messages are dead-lettered at once.
+	ErrPermanent = errors.New("permanent failure")
+)
 
 type Message struct {
 	Key     string
 	Payload []byte
+	// Headers are delivered together with the message, like the trace context of the publisher.
+	// The headers prefixed with x- are reserved for the queue.
+	Headers map[string]string
+	// Attempt is the number of the failed handling attempts of the message.
+	Attempt int
 }
 
-type MessageHandler func(m *Message)
+// MessageHandler handles the message, the failed message is retried according to the RetryPolicy of the consumer.
+// The ctx is not canceled when the consuming stops, so the message in handling is finished.
+type MessageHandler func(ctx context.Context, m *Message) error
 
 type Queue interface {
 	Connect() error
 	Close() error
+	// Ping returns ErrConnectionClosed while the connection is lost or after it is closed.
+	Ping(ctx context.Context) error
 	CreateProducer(exchange string) (Producer, error)
-	CreateConsumer(exchange, queue, key string) (Consumer, error)
+	CreateConsumer(exchange, queue string, opts ConsumerOptions, keys ...string) (Consumer, error)
+	CreateDeadLetterQueue(exchange, queue string) (DeadLetterQueue, error)
 }
 
 type Producer interface {
-	Publish(m *Message) error
+	Publish(ctx context.Context, m *Message) error
 }
 
 type Consumer interface {
-	Consume(h MessageHandler) error
+	// Consume handles the messages until the ctx is done, the messages in handling are finished before it returns.
+	Consume(ctx context.Context, h MessageHandler) error
+}
+
+type ConsumerOptions struct {
+	Retry RetryPolicy
+	// Prefetch is the number of the unacknowledged messages the broker sends to the consumer,
+	// zero means no limit.
+	Prefetch int
+	// Workers is the number of the messages handled concurrently.
+	Workers int
+}
+
+type DeadLetter struct {
+	Message
+	Error  string
+	DiedAt time.Time
+}
+
+// DeadLetterQueue keeps the messages of the queue that exhausted their retries.
+type DeadLetterQueue interface {
+	// List returns up to limit dead letters without removing them.
+	List(limit int) ([]*DeadLetter, error)
+	// Replay moves up to limit dead letters back to the queue with reset attempts and returns their number.
+	Replay(limit int) (int, error)
+}
+
+// RetryPolicy retries a failed message Retries times, the delay starts at MinDelay
+// and is doubled after every attempt up to MaxDelay.
+type RetryPolicy struct {
+	Retries  int
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// Exhausted reports whether the message failed attempt times before the err is not retried anymore,
+// it is when the retries are exhausted or the err is ErrPermanent.
+func (p RetryPolicy) Exhausted(attempt int, err error) bool {
+	return errors.Is(err, ErrPermanent) || attempt >= p.Retries
+}
+
+// Delay returns the delay before the retry of the message failed attempt times.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.MinDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+
+	return d
 }
 
-func New(uri string) Queue {
+// New creates the queue that reconnects according to the reconnect policy when the connection is lost.
+func New(uri string, reconnect RetryPolicy) Queue {
 	return &AMQPConnection{
-		URI: uri,
+		URI:       uri,
+		Reconnect: reconnect,
 	}
 }
@@ -2,21 +2,28 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-co-op/gocron"
 )
 
+var ErrNotRunning = errors.New("scheduler is not running")
+
 type Scheduler struct {
 	s             *gocron.Scheduler
 	ctx           context.Context
 	cancelContext context.CancelFunc
+	started       int32
 }
 
 func New(parent context.Context) *Scheduler {
 	s := gocron.NewScheduler(time.UTC)
 	s.TagsUnique()
+	// the run of the task waiting for the storage or the queue is not overlapped by the next one
+	s.SingletonModeAll()
 
 	ctx, cancel := context.WithCancel(parent)
 
@@ -55,11 +62,28 @@ func (s *Scheduler) addTaskWithCron(cronExpression string, task Task) error {
 	return nil
 }
 
+// Start runs the tasks until the scheduler is stopped or its context is done.
 func (s *Scheduler) Start() {
-	s.s.StartBlocking()
+	s.s.StartAsync()
+	atomic.StoreInt32(&s.started, 1)
+	<-s.ctx.Done()
+	s.s.Stop()
 }
 
 func (s *Scheduler) Stop() {
 	s.cancelContext()
 	s.s.Stop()
 }
+
+// Check returns ErrNotRunning when the started scheduler stopped running the tasks before its context is done.
+func (s *Scheduler) Check(_ context.Context) error {
+	if atomic.LoadInt32(&s.started) == 0 || s.ctx.Err() != nil {
+		return nil
+	}
+
+	if !s.s.IsRunning() {
+		return ErrNotRunning
+	}
+
+	return nil
+}
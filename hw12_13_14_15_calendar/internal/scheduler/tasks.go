@@ -3,26 +3,77 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 )
 
-const EventNotificationKey = "event_notification"
+const (
+	EventNotificationKey   = "event_notification"
+	AttendeeInvitationKey  = "attendee_invitation"
+	AttendeeRSVPChangedKey = "attendee_rsvp_changed"
+)
+
+const (
+	relayBatchSize = 100
+	minRetryDelay  = 5 * time.Second
+	maxRetryDelay  = 10 * time.Minute
+)
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
+// EventNotification is sent for every due reminder of the event through the channel of the reminder.
 type EventNotification struct {
-	EventID   int64     `json:"eventId"`
-	UserID    int64     `json:"userId"`
-	Title     string    `json:"title"`
-	TimeStart time.Time `json:"timeStart"`
+	IdempotencyKey string `json:"idempotencyKey"`
+
+	EventID    int64     `json:"eventId"`
+	ReminderID int64     `json:"reminderId"`
+	UserID     int64     `json:"userId"`
+	Channel    string    `json:"channel"`
+	Title      string    `json:"title"`
+	TimeStart  time.Time `json:"timeStart"`
+}
+
+// AttendeeInvitation is sent to the attendee, either to UserID or to Email.
+type AttendeeInvitation struct {
+	IdempotencyKey string `json:"idempotencyKey"`
+
+	EventID     int64     `json:"eventId"`
+	OrganizerID int64     `json:"organizerId"`
+	AttendeeID  int64     `json:"attendeeId"`
+	UserID      int64     `json:"userId"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	Title       string    `json:"title"`
+	TimeStart   time.Time `json:"timeStart"`
+}
+
+// AttendeeRSVPChanged is sent to the organizer of the event.
+type AttendeeRSVPChanged struct {
+	IdempotencyKey string `json:"idempotencyKey"`
+
+	EventID     int64     `json:"eventId"`
+	OrganizerID int64     `json:"organizerId"`
+	AttendeeID  int64     `json:"attendeeId"`
+	UserID      int64     `json:"userId"`
+	Email       string    `json:"email"`
+	Status      string    `json:"status"`
+	Title       string    `json:"title"`
+	TimeStart   time.Time `json:"timeStart"`
 }
 
 type Task func(ctx context.Context) error
 
+// RetentionPolicy is how long the ended events are kept, Users overrides Default by the user id.
+type RetentionPolicy struct {
+	Default time.Duration
+	Users   map[int64]time.Duration
+}
+
 type TaskFactory struct {
 	storage  storage.EventStorage
 	producer queue.Producer
@@ -33,55 +84,192 @@ func (f *TaskFactory) CreateSendNotificationTask(timeout time.Duration) Task {
 		ctx, cancel := context.WithTimeout(parent, timeout)
 		defer cancel()
 
-		events, err := f.storage.FindUnNotified(ctx, time.Now())
+		noww := time.Now()
+		reminders, err := f.storage.FindUnNotified(ctx, noww)
 		if err != nil {
 			return fmt.Errorf("notification task: %w", err)
 		}
 
-		if len(events) == 0 {
+		if len(reminders) == 0 {
 			return nil
 		}
 
-		ids := make([]int64, 0, len(events))
-		for _, e := range events {
-			n := &EventNotification{
-				EventID:   e.ID,
-				UserID:    e.UserID,
-				Title:     e.Title,
-				TimeStart: e.TimeStart,
+		ids := make([]int64, 0, len(reminders))
+		outbox := make([]*storage.OutboxMessage, 0, len(reminders))
+		rearmed := make([]*storage.Reminder, 0)
+		rearmedOutbox := make([]*storage.OutboxMessage, 0)
+		for _, r := range reminders {
+			timeStart, notifyAt := r.Event.TimeStart, r.Reminder.NotifyAt
+			// the reminder of the series is moved to the next occurrence instead of being marked
+			var rearmAt time.Time
+			if r.Event.IsRecurring() {
+				if timeStart, rearmAt, err = dueOccurrence(r, noww); err != nil {
+					return fmt.Errorf("notification task: event %d: %w", r.Event.ID, err)
+				}
+				notifyAt = timeStart.Add(-r.Reminder.Before)
+			}
+
+			messages := make([]*storage.OutboxMessage, 0, 1)
+			if !timeStart.IsZero() {
+				// a moved reminder is sent once more, so the key includes the notification time
+				// the notification continues the trace of the request that saved the reminder
+				m, err := newOutboxMessage(EventNotificationKey, r.Reminder.TraceContext, &EventNotification{
+					IdempotencyKey: fmt.Sprintf("reminder:%d:%d", r.Reminder.ID, notifyAt.Unix()),
+					EventID:        r.Event.ID,
+					ReminderID:     r.Reminder.ID,
+					UserID:         r.Event.UserID,
+					Channel:        string(r.Reminder.Channel),
+					Title:          r.Event.Title,
+					TimeStart:      timeStart,
+				})
+				if err != nil {
+					return fmt.Errorf("notification task: %w", err)
+				}
+				messages = append(messages, m)
 			}
 
-			payload, err := json.Marshal(n)
-			if err != nil {
+			if rearmAt.IsZero() {
+				ids = append(ids, r.Reminder.ID)
+				outbox = append(outbox, messages...)
+				continue
+			}
+
+			rearmed = append(rearmed, &storage.Reminder{ID: r.Reminder.ID, NotifyAt: rearmAt})
+			rearmedOutbox = append(rearmedOutbox, messages...)
+		}
+
+		if len(ids) > 0 {
+			if err := f.storage.MarkNotified(ctx, ids, outbox); err != nil {
 				return fmt.Errorf("notification task: %w", err)
 			}
+		}
 
-			if err := f.producer.Publish(&queue.Message{
-				Key:     EventNotificationKey,
-				Payload: payload,
-			}); err != nil {
+		if len(rearmed) > 0 {
+			if err := f.storage.RearmReminders(ctx, rearmed, rearmedOutbox); err != nil {
 				return fmt.Errorf("notification task: %w", err)
 			}
+		}
 
-			ids = append(ids, e.ID)
+		return nil
+	}
+}
+
+// dueOccurrence returns the start of the occurrence of the series the reminder is due for at t
+// and NotifyAt of the reminder for the following occurrence. The reminder is armed for an occurrence,
+// when that one is excluded or passed since, the reminder is moved to the next one. The start is zero
+// when no reminder is due yet, NotifyAt is zero when the series has no more occurrences.
+func dueOccurrence(r *storage.DueReminder, t time.Time) (time.Time, time.Time, error) {
+	from, inc := r.Reminder.NotifyAt.Add(r.Reminder.Before), true
+	if !from.After(t) {
+		from, inc = t, false
+	}
+
+	start, ok, err := app.NextOccurrence(r.Event, from, inc)
+	if err != nil || !ok {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if notifyAt := start.Add(-r.Reminder.Before); notifyAt.After(t) {
+		return time.Time{}, notifyAt, nil
+	}
+
+	next, ok, err := app.NextOccurrence(r.Event, start, false)
+	if err != nil || !ok {
+		return start, time.Time{}, err
+	}
+
+	return start, next.Add(-r.Reminder.Before), nil
+}
+
+func (f *TaskFactory) CreateSendInvitationsTask(timeout time.Duration) Task {
+	return func(parent context.Context) error {
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		invitations, err := f.storage.FindUnNotifiedAttendees(ctx)
+		if err != nil {
+			return fmt.Errorf("invitations task: %w", err)
 		}
 
-		if err := f.storage.MarkNotified(ctx, ids); err != nil {
-			return fmt.Errorf("notification task: %w", err)
+		if len(invitations) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, 0, len(invitations))
+		outbox := make([]*storage.OutboxMessage, 0, len(invitations))
+		for _, i := range invitations {
+			if !i.Attendee.InvitationSent {
+				m, err := newOutboxMessage(AttendeeInvitationKey, nil, &AttendeeInvitation{
+					IdempotencyKey: fmt.Sprintf("invitation:%d", i.Attendee.ID),
+					EventID:        i.Event.ID,
+					OrganizerID:    i.Event.UserID,
+					AttendeeID:     i.Attendee.ID,
+					UserID:         i.Attendee.UserID.Int64,
+					Email:          i.Attendee.Email,
+					Role:           string(i.Attendee.Role),
+					Title:          i.Event.Title,
+					TimeStart:      i.Event.TimeStart,
+				})
+				if err != nil {
+					return fmt.Errorf("invitations task: %w", err)
+				}
+				outbox = append(outbox, m)
+			}
+
+			if !i.Attendee.ResponseSent {
+				// the attendee may respond several times, every response is reported
+				m, err := newOutboxMessage(AttendeeRSVPChangedKey, nil, &AttendeeRSVPChanged{
+					IdempotencyKey: fmt.Sprintf("rsvp:%d:%d", i.Attendee.ID, i.Attendee.UpdatedAt.UnixNano()),
+					EventID:        i.Event.ID,
+					OrganizerID:    i.Event.UserID,
+					AttendeeID:     i.Attendee.ID,
+					UserID:         i.Attendee.UserID.Int64,
+					Email:          i.Attendee.Email,
+					Status:         string(i.Attendee.Status),
+					Title:          i.Event.Title,
+					TimeStart:      i.Event.TimeStart,
+				})
+				if err != nil {
+					return fmt.Errorf("invitations task: %w", err)
+				}
+				outbox = append(outbox, m)
+			}
+
+			ids = append(ids, i.Attendee.ID)
+		}
+
+		if err := f.storage.MarkAttendeesNotified(ctx, ids, outbox); err != nil {
+			return fmt.Errorf("invitations task: %w", err)
 		}
 
 		return nil
 	}
 }
 
-func (f *TaskFactory) CreateDeleteOldEventsTask(timeout time.Duration) Task {
+// CreateDeleteOldEventsTask permanently deletes the events ended longer than the retention ago,
+// the users of the policy are deleted by their own retention.
+func (f *TaskFactory) CreateDeleteOldEventsTask(timeout time.Duration, retention RetentionPolicy) Task {
 	return func(parent context.Context) error {
 		ctx, cancel := context.WithTimeout(parent, timeout)
 		defer cancel()
 
-		lastYear := time.Now().AddDate(-1, 0, 0)
-		err := f.storage.DeleteOlderThan(ctx, lastYear)
-		if err != nil {
+		noww := time.Now()
+		users := make([]int64, 0, len(retention.Users))
+		for userID, d := range retention.Users {
+			if err := f.storage.DeleteOlderThan(ctx, storage.RetentionFilter{
+				Before:  noww.Add(-d),
+				UserIDs: []int64{userID},
+			}); err != nil {
+				return fmt.Errorf("delete old events task: user %d: %w", userID, err)
+			}
+
+			users = append(users, userID)
+		}
+
+		if err := f.storage.DeleteOlderThan(ctx, storage.RetentionFilter{
+			Before:        noww.Add(-retention.Default),
+			ExceptUserIDs: users,
+		}); err != nil {
 			return fmt.Errorf("delete old events task: %w", err)
 		}
 
@@ -89,6 +277,119 @@ func (f *TaskFactory) CreateDeleteOldEventsTask(timeout time.Duration) Task {
 	}
 }
 
+// CreatePurgeTrashTask permanently deletes the events which are in the trash longer than the retention.
+func (f *TaskFactory) CreatePurgeTrashTask(timeout, retention time.Duration) Task {
+	return func(parent context.Context) error {
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		if err := f.storage.PurgeDeleted(ctx, time.Now().Add(-retention)); err != nil {
+			return fmt.Errorf("purge trash task: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// CreateRelayOutboxTask publishes the saved messages, a failed message is retried with an exponential delay.
+// A message is deleted only after it is published, so it may be published more than once,
+// consumers skip the duplicates by the idempotency key of the payload.
+// The run is skipped while the previous one is publishing, otherwise both runs would publish the same messages.
+func (f *TaskFactory) CreateRelayOutboxTask(timeout time.Duration) Task {
+	var running int32
+
+	return func(parent context.Context) error {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			return nil
+		}
+		defer atomic.StoreInt32(&running, 0)
+
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		messages, err := f.storage.FindOutbox(ctx, time.Now(), relayBatchSize)
+		if err != nil {
+			return fmt.Errorf("relay outbox task: %w", err)
+		}
+
+		for _, m := range messages {
+			if err := f.producer.Publish(ctx, &queue.Message{
+				Key:     m.Key,
+				Payload: m.Payload,
+				Headers: m.Headers,
+			}); err != nil {
+				// the rest of the batch is left for the next run, the queue is probably unavailable
+				if err := f.storage.RetryOutbox(ctx, m.ID, time.Now().Add(retryDelay(m.Attempts))); err != nil {
+					return fmt.Errorf("relay outbox task: %w", err)
+				}
+
+				return fmt.Errorf("relay outbox task: %w", err)
+			}
+
+			if err := f.storage.DeleteOutbox(ctx, m.ID); err != nil {
+				return fmt.Errorf("relay outbox task: %w", err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// IdempotencyKey returns the idempotency key of the message payload, it is empty for unknown payloads.
+func IdempotencyKey(payload []byte) string {
+	var v struct {
+		IdempotencyKey string `json:"idempotencyKey"`
+	}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return ""
+	}
+
+	return v.IdempotencyKey
+}
+
+func newOutboxMessage(
+	key string,
+	headers map[string]string,
+	v interface{ idempotencyKey() string },
+) (*storage.OutboxMessage, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.OutboxMessage{
+		Key:            key,
+		IdempotencyKey: v.idempotencyKey(),
+		Payload:        payload,
+		Headers:        headers,
+	}, nil
+}
+
+func retryDelay(attempts int) time.Duration {
+	d := minRetryDelay
+	for i := 0; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+
+	if d > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return d
+}
+
+func (n *EventNotification) idempotencyKey() string {
+	return n.IdempotencyKey
+}
+
+func (n *AttendeeInvitation) idempotencyKey() string {
+	return n.IdempotencyKey
+}
+
+func (n *AttendeeRSVPChanged) idempotencyKey() string {
+	return n.IdempotencyKey
+}
+
 func NewTaskFactory(s storage.EventStorage, p queue.Producer) *TaskFactory {
 	return &TaskFactory{
 		s, p,
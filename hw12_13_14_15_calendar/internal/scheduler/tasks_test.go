@@ -3,7 +3,7 @@ package scheduler
 import (
 	"context"
 	"errors"
-	"strings"
+	"fmt"
 	"testing"
 	"time"
 
@@ -23,10 +23,11 @@ var (
 	now = mock.MatchedBy(func(t time.Time) bool {
 		return time.Until(time.Now()) < time.Minute
 	})
-	lastYear = mock.MatchedBy(func(t time.Time) bool {
+	lastYear = mock.MatchedBy(func(f storage.RetentionFilter) bool {
 		ly := time.Now().AddDate(-1, 0, 0)
-		return t.Sub(ly) < time.Minute
+		return f.Before.Sub(ly) < time.Minute && len(f.UserIDs) == 0 && len(f.ExceptUserIDs) == 0
 	})
+	yearRetention = RetentionPolicy{Default: 365 * 24 * time.Hour}
 )
 
 func event(id, userID int64, title string, time time.Time) *storage.Event {
@@ -38,70 +39,222 @@ func event(id, userID int64, title string, time time.Time) *storage.Event {
 	}
 }
 
+func reminder(id int64, e *storage.Event, channel storage.ReminderChannel) *storage.DueReminder {
+	return &storage.DueReminder{
+		Event: e,
+		Reminder: &storage.Reminder{
+			ID:       id,
+			EventID:  e.ID,
+			Channel:  channel,
+			NotifyAt: e.TimeStart,
+		},
+	}
+}
+
+// captureOutbox matches any outbox and keeps the last matched one.
+func captureOutbox(outbox *[]*storage.OutboxMessage) interface{} {
+	return mock.MatchedBy(func(o []*storage.OutboxMessage) bool {
+		*outbox = o
+		return true
+	})
+}
+
 func TestSendNotificationTaskSuccess(t *testing.T) {
-	t.Run("no events", func(t *testing.T) {
+	t.Run("no reminders", func(t *testing.T) {
 		p := &mockqueue.Producer{}
 		s := &mockstorage.EventStorage{}
 
-		s.On("FindUnNotified", notDefaultContext, now).Once().Return([]*storage.Event{}, nil)
+		s.On("FindUnNotified", notDefaultContext, now).Once().Return([]*storage.DueReminder{}, nil)
 
 		f := NewTaskFactory(s, p)
 		task := f.CreateSendNotificationTask(time.Second)
 		require.NoError(t, task(ctx))
+		s.AssertNotCalled(t, "MarkNotified", mock.Anything, mock.Anything, mock.Anything)
 	})
 
-	t.Run("several events", func(t *testing.T) {
+	t.Run("several reminders", func(t *testing.T) {
 		p := &mockqueue.Producer{}
 		s := &mockstorage.EventStorage{}
 
-		events := make([]*storage.Event, 0, 3)
-		events = append(events, event(1, 1, "test event name 1", time.Now()))
-		events = append(events, event(2, 2, "test event name 2", time.Now()))
-		events = append(events, event(3, 3, "test event name 2", time.Now()))
-		s.On("FindUnNotified", notDefaultContext, now).Once().Return(events, nil)
+		reminders := make([]*storage.DueReminder, 0, 3)
+		reminders = append(reminders, reminder(10, event(1, 1, "test event name 1", time.Now()), storage.ChannelLog))
+		reminders = append(reminders, reminder(20, event(2, 2, "test event name 2", time.Now()), storage.ChannelLog))
+		reminders = append(reminders, reminder(30, event(3, 3, "test event name 2", time.Now()), storage.ChannelLog))
+		s.On("FindUnNotified", notDefaultContext, now).Once().Return(reminders, nil)
 
-		s.On("MarkNotified", notDefaultContext, []int64{1, 2, 3}).Once().Return(nil)
+		var outbox []*storage.OutboxMessage
+		s.On("MarkNotified", notDefaultContext, []int64{10, 20, 30}, captureOutbox(&outbox)).Once().Return(nil)
 
-		p.On("Publish", mock.MatchedBy(func(m *queue.Message) bool {
-			return m.Key == EventNotificationKey &&
-				strings.Contains(string(m.Payload), "test event name 1") ||
-				strings.Contains(string(m.Payload), "test event name 2") ||
-				strings.Contains(string(m.Payload), "test event name 3")
-		})).Times(3).Return(nil)
+		f := NewTaskFactory(s, p)
+		task := f.CreateSendNotificationTask(time.Second)
+		require.NoError(t, task(ctx))
+
+		require.Len(t, outbox, 3)
+		for _, m := range outbox {
+			require.Equal(t, EventNotificationKey, m.Key)
+			require.Contains(t, string(m.Payload), "test event name")
+		}
+		p.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+	})
+
+	t.Run("several reminders of the event", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		e := event(1, 1, "test event name", time.Now())
+		reminders := []*storage.DueReminder{
+			reminder(10, e, storage.ChannelEmail),
+			reminder(11, e, storage.ChannelWebhook),
+		}
+		reminders[0].Reminder.TraceContext = map[string]string{"traceparent": "test"}
+		s.On("FindUnNotified", notDefaultContext, now).Once().Return(reminders, nil)
+
+		var outbox []*storage.OutboxMessage
+		s.On("MarkNotified", notDefaultContext, []int64{10, 11}, captureOutbox(&outbox)).Once().Return(nil)
 
 		f := NewTaskFactory(s, p)
 		task := f.CreateSendNotificationTask(time.Second)
 		require.NoError(t, task(ctx))
+
+		require.Len(t, outbox, 2)
+		published := make([]*EventNotification, 0, 2)
+		for _, m := range outbox {
+			n := &EventNotification{}
+			require.NoError(t, json.Unmarshal(m.Payload, n))
+			require.Equal(t, m.IdempotencyKey, n.IdempotencyKey)
+			require.Equal(t, n.IdempotencyKey, IdempotencyKey(m.Payload))
+			published = append(published, n)
+		}
+
+		require.Equal(t, int64(10), published[0].ReminderID)
+		require.Equal(t, "email", published[0].Channel)
+		require.Equal(t, int64(11), published[1].ReminderID)
+		require.Equal(t, "webhook", published[1].Channel)
+		require.Equal(t, int64(1), published[1].EventID)
+		require.NotEqual(t, published[0].IdempotencyKey, published[1].IdempotencyKey)
+
+		// the notification continues the trace of the saved reminder
+		require.Equal(t, map[string]string{"traceparent": "test"}, outbox[0].Headers)
+		require.Nil(t, outbox[1].Headers)
 	})
 }
 
-func TestSendNotificationTaskError(t *testing.T) {
-	t.Run("FindUnNotified", func(t *testing.T) {
+func TestSendNotificationTaskSeries(t *testing.T) {
+	base := time.Now().Truncate(time.Second)
+	series := func(timeStart time.Time, rrule string) *storage.DueReminder {
+		e := event(1, 1, "series", timeStart.AddDate(0, 0, -10))
+		e.RRule = rrule
+		e.TimeZone = "UTC"
+		r := reminder(10, e, storage.ChannelLog)
+		r.Reminder.Before = time.Hour
+		// the reminder is armed for the first occurrence
+		r.Reminder.NotifyAt = e.TimeStart.Add(-time.Hour)
+
+		return r
+	}
+	rearmedAt := func(notifyAt *time.Time) interface{} {
+		return mock.MatchedBy(func(r []*storage.Reminder) bool {
+			*notifyAt = r[0].NotifyAt
+			return len(r) == 1 && r[0].ID == 10
+		})
+	}
+
+	t.Run("due occurrence is sent", func(t *testing.T) {
 		p := &mockqueue.Producer{}
 		s := &mockstorage.EventStorage{}
 
-		testErr := errors.New("test error")
-		s.On("FindUnNotified", notDefaultContext, now).Once().Return(nil, testErr)
+		occurrence := base.Add(30 * time.Minute)
+		s.On("FindUnNotified", notDefaultContext, now).
+			Once().
+			Return([]*storage.DueReminder{series(occurrence, "FREQ=DAILY")}, nil)
+
+		var notifyAt time.Time
+		var outbox []*storage.OutboxMessage
+		s.On("RearmReminders", notDefaultContext, rearmedAt(&notifyAt), captureOutbox(&outbox)).Once().Return(nil)
+		defer s.AssertExpectations(t)
 
 		f := NewTaskFactory(s, p)
-		task := f.CreateSendNotificationTask(time.Second)
-		err := task(ctx)
-		require.ErrorIs(t, err, testErr)
+		require.NoError(t, f.CreateSendNotificationTask(time.Second)(ctx))
+
+		require.True(t, notifyAt.Equal(occurrence.AddDate(0, 0, 1).Add(-time.Hour)))
+		require.Len(t, outbox, 1)
+		n := &EventNotification{}
+		require.NoError(t, json.Unmarshal(outbox[0].Payload, n))
+		require.True(t, n.TimeStart.Equal(occurrence))
+		require.Equal(t, fmt.Sprintf("reminder:10:%d", occurrence.Add(-time.Hour).Unix()), n.IdempotencyKey)
+		s.AssertNotCalled(t, "MarkNotified", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("reminder is moved to the next occurrence", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		occurrence := base.Add(3 * time.Hour)
+		s.On("FindUnNotified", notDefaultContext, now).
+			Once().
+			Return([]*storage.DueReminder{series(occurrence, "FREQ=DAILY")}, nil)
+
+		var notifyAt time.Time
+		var outbox []*storage.OutboxMessage
+		s.On("RearmReminders", notDefaultContext, rearmedAt(&notifyAt), captureOutbox(&outbox)).Once().Return(nil)
+		defer s.AssertExpectations(t)
+
+		f := NewTaskFactory(s, p)
+		require.NoError(t, f.CreateSendNotificationTask(time.Second)(ctx))
+
+		require.True(t, notifyAt.Equal(occurrence.Add(-time.Hour)))
+		require.Empty(t, outbox)
 	})
 
-	t.Run("Publish", func(t *testing.T) {
+	t.Run("excluded occurrence is not sent", func(t *testing.T) {
 		p := &mockqueue.Producer{}
 		s := &mockstorage.EventStorage{}
 
-		events := make([]*storage.Event, 0, 1)
-		events = append(events, event(1, 1, "test event name 1", time.Now()))
-		s.On("FindUnNotified", notDefaultContext, now).Once().Return(events, nil)
+		occurrence := base.Add(30 * time.Minute)
+		r := series(occurrence, "FREQ=DAILY")
+		r.Event.ExDates = []time.Time{occurrence}
+		r.Reminder.NotifyAt = occurrence.Add(-time.Hour)
+		s.On("FindUnNotified", notDefaultContext, now).Once().Return([]*storage.DueReminder{r}, nil)
+
+		var notifyAt time.Time
+		var outbox []*storage.OutboxMessage
+		s.On("RearmReminders", notDefaultContext, rearmedAt(&notifyAt), captureOutbox(&outbox)).Once().Return(nil)
+		defer s.AssertExpectations(t)
+
+		f := NewTaskFactory(s, p)
+		require.NoError(t, f.CreateSendNotificationTask(time.Second)(ctx))
+
+		require.True(t, notifyAt.Equal(occurrence.AddDate(0, 0, 1).Add(-time.Hour)))
+		require.Empty(t, outbox)
+	})
+
+	t.Run("ended series is marked", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		s.On("FindUnNotified", notDefaultContext, now).
+			Once().
+			Return([]*storage.DueReminder{series(base.Add(30*time.Minute), "FREQ=DAILY;COUNT=3")}, nil)
+
+		var outbox []*storage.OutboxMessage
+		s.On("MarkNotified", notDefaultContext, []int64{10}, captureOutbox(&outbox)).Once().Return(nil)
+		defer s.AssertExpectations(t)
+
+		f := NewTaskFactory(s, p)
+		require.NoError(t, f.CreateSendNotificationTask(time.Second)(ctx))
+
+		require.Empty(t, outbox)
+		s.AssertNotCalled(t, "RearmReminders", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSendNotificationTaskError(t *testing.T) {
+	t.Run("FindUnNotified", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
 
 		testErr := errors.New("test error")
-		p.On("Publish", mock.MatchedBy(func(m *queue.Message) bool {
-			return m.Key == EventNotificationKey &&
-				strings.Contains(string(m.Payload), "test event name 1")
-		})).Once().Return(testErr)
+		s.On("FindUnNotified", notDefaultContext, now).Once().Return(nil, testErr)
 
 		f := NewTaskFactory(s, p)
 		task := f.CreateSendNotificationTask(time.Second)
@@ -113,17 +266,12 @@ func TestSendNotificationTaskError(t *testing.T) {
 		p := &mockqueue.Producer{}
 		s := &mockstorage.EventStorage{}
 
-		events := make([]*storage.Event, 0, 1)
-		events = append(events, event(1, 1, "test event name 1", time.Now()))
-		s.On("FindUnNotified", notDefaultContext, now).Once().Return(events, nil)
+		reminders := make([]*storage.DueReminder, 0, 1)
+		reminders = append(reminders, reminder(1, event(1, 1, "test event name 1", time.Now()), storage.ChannelLog))
+		s.On("FindUnNotified", notDefaultContext, now).Once().Return(reminders, nil)
 
 		testErr := errors.New("test error")
-		s.On("MarkNotified", notDefaultContext, []int64{1}).Once().Return(testErr)
-
-		p.On("Publish", mock.MatchedBy(func(m *queue.Message) bool {
-			return m.Key == EventNotificationKey &&
-				strings.Contains(string(m.Payload), "test event name 1")
-		})).Once().Return(testErr)
+		s.On("MarkNotified", notDefaultContext, []int64{1}, mock.Anything).Once().Return(testErr)
 
 		f := NewTaskFactory(s, p)
 		task := f.CreateSendNotificationTask(time.Second)
@@ -139,7 +287,7 @@ func TestDeleteOldEventsTaskSuccess(t *testing.T) {
 	s.On("DeleteOlderThan", notDefaultContext, lastYear).Once().Return(nil)
 
 	f := NewTaskFactory(s, p)
-	task := f.CreateDeleteOldEventsTask(time.Second)
+	task := f.CreateDeleteOldEventsTask(time.Second, yearRetention)
 	require.NoError(t, task(ctx))
 }
 
@@ -151,7 +299,201 @@ func TestDeleteOldEventsTaskError(t *testing.T) {
 	s.On("DeleteOlderThan", notDefaultContext, lastYear).Once().Return(testErr)
 
 	f := NewTaskFactory(s, p)
-	task := f.CreateDeleteOldEventsTask(time.Second)
+	task := f.CreateDeleteOldEventsTask(time.Second, yearRetention)
 	err := task(ctx)
 	require.ErrorIs(t, err, testErr)
 }
+
+func TestDeleteOldEventsTaskRetentionOfUsers(t *testing.T) {
+	p := &mockqueue.Producer{}
+	s := &mockstorage.EventStorage{}
+
+	retention := RetentionPolicy{
+		Default: 24 * time.Hour,
+		Users:   map[int64]time.Duration{7: 48 * time.Hour},
+	}
+
+	s.On("DeleteOlderThan", notDefaultContext, mock.MatchedBy(func(f storage.RetentionFilter) bool {
+		return f.Before.Sub(time.Now().Add(-48*time.Hour)) < time.Minute && len(f.UserIDs) == 1 && f.UserIDs[0] == 7
+	})).Once().Return(nil)
+	s.On("DeleteOlderThan", notDefaultContext, mock.MatchedBy(func(f storage.RetentionFilter) bool {
+		return f.Before.Sub(time.Now().Add(-24*time.Hour)) < time.Minute &&
+			len(f.ExceptUserIDs) == 1 && f.ExceptUserIDs[0] == 7
+	})).Once().Return(nil)
+	defer s.AssertExpectations(t)
+
+	f := NewTaskFactory(s, p)
+	task := f.CreateDeleteOldEventsTask(time.Second, retention)
+	require.NoError(t, task(ctx))
+}
+
+func TestPurgeTrashTask(t *testing.T) {
+	p := &mockqueue.Producer{}
+	s := &mockstorage.EventStorage{}
+
+	lastMonth := mock.MatchedBy(func(t time.Time) bool {
+		return t.Sub(time.Now().Add(-30*24*time.Hour)) < time.Minute
+	})
+	testErr := errors.New("test error")
+	s.On("PurgeDeleted", notDefaultContext, lastMonth).Once().Return(nil)
+	s.On("PurgeDeleted", notDefaultContext, lastMonth).Once().Return(testErr)
+
+	f := NewTaskFactory(s, p)
+	task := f.CreatePurgeTrashTask(time.Second, 30*24*time.Hour)
+	require.NoError(t, task(ctx))
+	require.ErrorIs(t, task(ctx), testErr)
+}
+
+func TestSendInvitationsTask(t *testing.T) {
+	t.Run("no invitations", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		s.On("FindUnNotifiedAttendees", notDefaultContext).Once().Return([]*storage.Invitation{}, nil)
+
+		f := NewTaskFactory(s, p)
+		task := f.CreateSendInvitationsTask(time.Second)
+		require.NoError(t, task(ctx))
+	})
+
+	t.Run("invitation and response", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		e := event(1, 1, "test event name", time.Now())
+		invitations := []*storage.Invitation{
+			{Event: e, Attendee: &storage.Attendee{ID: 1, EventID: 1, Email: "a@b.c", ResponseSent: true}},
+			{Event: e, Attendee: &storage.Attendee{ID: 2, EventID: 1, InvitationSent: true, Status: storage.RSVPAccepted}},
+		}
+		s.On("FindUnNotifiedAttendees", notDefaultContext).Once().Return(invitations, nil)
+
+		var outbox []*storage.OutboxMessage
+		s.On("MarkAttendeesNotified", notDefaultContext, []int64{1, 2}, captureOutbox(&outbox)).Once().Return(nil)
+
+		f := NewTaskFactory(s, p)
+		task := f.CreateSendInvitationsTask(time.Second)
+		require.NoError(t, task(ctx))
+
+		require.Len(t, outbox, 2)
+		require.Equal(t, AttendeeInvitationKey, outbox[0].Key)
+		require.Contains(t, string(outbox[0].Payload), "a@b.c")
+		require.Equal(t, "invitation:1", outbox[0].IdempotencyKey)
+		require.Equal(t, AttendeeRSVPChangedKey, outbox[1].Key)
+		require.Contains(t, string(outbox[1].Payload), "accepted")
+	})
+
+	t.Run("mark error", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		invitations := []*storage.Invitation{
+			{Event: event(1, 1, "test event name", time.Now()), Attendee: &storage.Attendee{ID: 1, EventID: 1}},
+		}
+		s.On("FindUnNotifiedAttendees", notDefaultContext).Once().Return(invitations, nil)
+
+		testErr := errors.New("test error")
+		s.On("MarkAttendeesNotified", notDefaultContext, []int64{1}, mock.Anything).Once().Return(testErr)
+
+		f := NewTaskFactory(s, p)
+		task := f.CreateSendInvitationsTask(time.Second)
+		require.ErrorIs(t, task(ctx), testErr)
+	})
+}
+
+func TestRelayOutboxTask(t *testing.T) {
+	messages := func() []*storage.OutboxMessage {
+		return []*storage.OutboxMessage{
+			{ID: 1, Key: EventNotificationKey, Payload: []byte("first"), Headers: map[string]string{"traceparent": "test"}},
+			{ID: 2, Key: AttendeeInvitationKey, Payload: []byte("second"), Attempts: 2},
+			{ID: 3, Key: EventNotificationKey, Payload: []byte("third")},
+		}
+	}
+	payload := func(p string) interface{} {
+		return mock.MatchedBy(func(m *queue.Message) bool {
+			return string(m.Payload) == p
+		})
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		s.On("FindOutbox", notDefaultContext, now, relayBatchSize).Once().Return(messages(), nil)
+		p.On("Publish", notDefaultContext, mock.MatchedBy(func(m *queue.Message) bool {
+			return string(m.Payload) != "first" || m.Headers["traceparent"] == "test"
+		})).Times(3).Return(nil)
+		s.On("DeleteOutbox", notDefaultContext, int64(1)).Once().Return(nil)
+		s.On("DeleteOutbox", notDefaultContext, int64(2)).Once().Return(nil)
+		s.On("DeleteOutbox", notDefaultContext, int64(3)).Once().Return(nil)
+
+		f := NewTaskFactory(s, p)
+		require.NoError(t, f.CreateRelayOutboxTask(time.Second)(ctx))
+		s.AssertExpectations(t)
+		p.AssertExpectations(t)
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		testErr := errors.New("test error")
+		s.On("FindOutbox", notDefaultContext, now, relayBatchSize).Once().Return(messages(), nil)
+		p.On("Publish", notDefaultContext, payload("first")).Once().Return(nil)
+		p.On("Publish", notDefaultContext, payload("second")).Once().Return(testErr)
+		s.On("DeleteOutbox", notDefaultContext, int64(1)).Once().Return(nil)
+		s.On("RetryOutbox", notDefaultContext, int64(2), mock.MatchedBy(func(t time.Time) bool {
+			d := time.Until(t)
+			return d > 15*time.Second && d <= 20*time.Second
+		})).Once().Return(nil)
+
+		f := NewTaskFactory(s, p)
+		require.ErrorIs(t, f.CreateRelayOutboxTask(time.Second)(ctx), testErr)
+		s.AssertExpectations(t)
+		p.AssertNotCalled(t, "Publish", mock.Anything, payload("third"))
+	})
+
+	t.Run("find error", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		testErr := errors.New("test error")
+		s.On("FindOutbox", notDefaultContext, now, relayBatchSize).Once().Return(nil, testErr)
+
+		f := NewTaskFactory(s, p)
+		require.ErrorIs(t, f.CreateRelayOutboxTask(time.Second)(ctx), testErr)
+	})
+
+	t.Run("overlapping run is skipped", func(t *testing.T) {
+		p := &mockqueue.Producer{}
+		s := &mockstorage.EventStorage{}
+
+		task := NewTaskFactory(s, p).CreateRelayOutboxTask(time.Second)
+		// the second run starts while the first one is publishing
+		s.On("FindOutbox", notDefaultContext, now, relayBatchSize).
+			Once().
+			Return([]*storage.OutboxMessage{{ID: 1, Key: EventNotificationKey, Payload: []byte("first")}}, nil)
+		p.On("Publish", notDefaultContext, payload("first")).
+			Once().
+			Run(func(args mock.Arguments) {
+				require.NoError(t, task(ctx))
+			}).
+			Return(nil)
+		s.On("DeleteOutbox", notDefaultContext, int64(1)).Once().Return(nil)
+
+		require.NoError(t, task(ctx))
+		s.AssertExpectations(t)
+		p.AssertExpectations(t)
+
+		// the next run is not skipped after the previous one is finished
+		s.On("FindOutbox", notDefaultContext, now, relayBatchSize).Once().Return([]*storage.OutboxMessage{}, nil)
+		require.NoError(t, task(ctx))
+		s.AssertExpectations(t)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, minRetryDelay, retryDelay(0))
+	require.Equal(t, 2*minRetryDelay, retryDelay(1))
+	require.Equal(t, 8*minRetryDelay, retryDelay(3))
+	require.Equal(t, maxRetryDelay, retryDelay(100))
+}
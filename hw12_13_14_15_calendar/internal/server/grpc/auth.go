@@ -0,0 +1,57 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type authService struct {
+	users app.UsersUseCase
+	pb.UnimplementedAuthServer
+}
+
+func newAuthService(users app.UsersUseCase) *authService {
+	return &authService{users: users}
+}
+
+func (s *authService) Register(ctx context.Context, req *pb.Credentials) (*pb.AuthResponse, error) {
+	result, err := s.users.Register(ctx, app.CredentialsDTO{Login: req.Login, Password: req.Password})
+	if err != nil {
+		if errors.Is(err, app.ErrLoginIsTaken) {
+			return nil, status.Errorf(codes.AlreadyExists, "grpc register: %v", err.Error())
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc register validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc register: %v", err.Error())
+	}
+
+	return &pb.AuthResponse{
+		UserId: result.UserID,
+		Token:  result.Token,
+	}, nil
+}
+
+func (s *authService) Login(ctx context.Context, req *pb.Credentials) (*pb.AuthResponse, error) {
+	result, err := s.users.Login(ctx, app.CredentialsDTO{Login: req.Login, Password: req.Password})
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid login or password")
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc login: %v", err.Error())
+	}
+
+	return &pb.AuthResponse{
+		UserId: result.UserID,
+		Token:  result.Token,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// watchInterval is the period the watched status is checked with.
+const watchInterval = time.Second
+
+// healthService implements the grpc health checking protocol over the readiness of the checker,
+// the empty service name is the status of the whole server.
+type healthService struct {
+	checker  *health.Checker
+	services map[string]struct{}
+	healthpb.UnimplementedHealthServer
+}
+
+func newHealthService(checker *health.Checker, services ...string) *healthService {
+	s := &healthService{
+		checker:  checker,
+		services: map[string]struct{}{"": {}},
+	}
+	for _, name := range services {
+		s.services[name] = struct{}{}
+	}
+
+	return s
+}
+
+func (s *healthService) Check(
+	ctx context.Context,
+	req *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	if _, ok := s.services[req.Service]; !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+
+	return &healthpb.HealthCheckResponse{Status: s.status(ctx)}, nil
+}
+
+// Watch sends the status on the start and every its change until the client cancels the call.
+func (s *healthService) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+
+	if _, ok := s.services[req.Service]; !ok {
+		// the protocol requires the unknown service to be watched until it is known
+		if err := stream.Send(&healthpb.HealthCheckResponse{
+			Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN,
+		}); err != nil {
+			return status.Errorf(codes.Canceled, "grpc health watch: %v", err)
+		}
+		<-ctx.Done()
+
+		return status.Error(codes.Canceled, "grpc health watch: stream has ended")
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		if current := s.status(ctx); current != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+				return status.Errorf(codes.Canceled, "grpc health watch: %v", err)
+			}
+			last = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "grpc health watch: stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *healthService) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	if s.checker.Ready(ctx).Up() {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
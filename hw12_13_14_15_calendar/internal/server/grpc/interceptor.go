@@ -2,15 +2,28 @@ package grpcserver
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"strings"
 	"time"
 
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 const timeLayout = "[02/Jan/2006:15:04:05 -0700]"
 
+type contextKey int
+
+const userIDKey contextKey = iota
+
 func unaryLoggingInterceptor(log logger.Logger) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
@@ -41,3 +54,184 @@ func unaryLoggingInterceptor(log logger.Logger) grpc.UnaryServerInterceptor {
 		return resp, err
 	}
 }
+
+func streamLoggingInterceptor(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+
+		err := handler(srv, ss)
+
+		msg := strings.Join([]string{
+			start.Format(timeLayout),
+			info.FullMethod,
+			time.Since(start).String(),
+		}, " ")
+		log.Info(msg,
+			"type", "access",
+			"context", "grpc",
+		)
+
+		if err != nil {
+			log.Error(err.Error(),
+				"context", "grpc",
+			)
+		}
+
+		return err
+	}
+}
+
+// unaryMetricsInterceptor observes the requests by the method and the status code.
+func unaryMetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+		metrics.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+
+		return resp, err
+	}
+}
+
+// streamMetricsInterceptor observes the streams by the method and the status code.
+func streamMetricsInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+
+		err := handler(srv, ss)
+		metrics.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+
+		return err
+	}
+}
+
+// unaryAuthInterceptor authenticates the caller with the `authorization` metadata,
+// either a bearer API token or basic login and password. Methods of the Auth service are public.
+func unaryAuthInterceptor(users app.UsersUseCase) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp interface{}, err error) {
+		ctx, err = authorize(ctx, info.FullMethod, users)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// streamAuthInterceptor authenticates the caller of the streaming method the same way as unaryAuthInterceptor.
+func streamAuthInterceptor(users app.UsersUseCase) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authorize(ss.Context(), info.FullMethod, users)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticatedStream passes the context with the authenticated user to the handler.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize returns the context with the authenticated user, methods of the public services are not authenticated.
+func authorize(ctx context.Context, method string, users app.UsersUseCase) (context.Context, error) {
+	public := []string{
+		"/" + pb.Auth_ServiceDesc.ServiceName + "/",
+		"/" + healthpb.Health_ServiceDesc.ServiceName + "/",
+	}
+	for _, prefix := range public {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+
+	userID, err := authenticate(ctx, users)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc authenticate: %v", err.Error())
+	}
+
+	return context.WithValue(ctx, userIDKey, userID), nil
+}
+
+func authenticate(ctx context.Context, users app.UsersUseCase) (int64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return 0, app.ErrInvalidCredentials
+	}
+
+	scheme, credentials, ok := cut(values[0], " ")
+	if !ok {
+		return 0, app.ErrInvalidCredentials
+	}
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return users.AuthenticateToken(ctx, strings.TrimSpace(credentials))
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+		if err != nil {
+			return 0, app.ErrInvalidCredentials
+		}
+
+		login, password, ok := cut(string(decoded), ":")
+		if !ok {
+			return 0, app.ErrInvalidCredentials
+		}
+
+		return users.AuthenticatePassword(ctx, app.CredentialsDTO{Login: login, Password: password})
+	default:
+		return 0, app.ErrInvalidCredentials
+	}
+}
+
+// userIDFromContext returns the user authenticated by the auth interceptors.
+func userIDFromContext(ctx context.Context) int64 {
+	userID, _ := ctx.Value(userIDKey).(int64)
+
+	return userID
+}
+
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
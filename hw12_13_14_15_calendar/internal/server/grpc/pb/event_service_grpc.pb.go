@@ -18,17 +18,158 @@ import (
 // Requires gRPC-Go v1.32.0 or later.
 const _ = grpc.SupportPackageIsVersion7
 
+// AuthClient is the client API for Auth service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthClient interface {
+	Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error)
+	Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error)
+}
+
+type authClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthClient(cc grpc.ClientConnInterface) AuthClient {
+	return &authClient{cc}
+}
+
+func (c *authClient) Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error) {
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, "/event.Auth/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error) {
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, "/event.Auth/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthServer is the server API for Auth service.
+// All implementations must embed UnimplementedAuthServer
+// for forward compatibility
+type AuthServer interface {
+	Register(context.Context, *Credentials) (*AuthResponse, error)
+	Login(context.Context, *Credentials) (*AuthResponse, error)
+	mustEmbedUnimplementedAuthServer()
+}
+
+// UnimplementedAuthServer must be embedded to have forward compatible implementations.
+type UnimplementedAuthServer struct {
+}
+
+func (UnimplementedAuthServer) Register(context.Context, *Credentials) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedAuthServer) Login(context.Context, *Credentials) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedAuthServer) mustEmbedUnimplementedAuthServer() {}
+
+// UnsafeAuthServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthServer will
+// result in compilation errors.
+type UnsafeAuthServer interface {
+	mustEmbedUnimplementedAuthServer()
+}
+
+func RegisterAuthServer(s grpc.ServiceRegistrar, srv AuthServer) {
+	s.RegisterService(&Auth_ServiceDesc, srv)
+}
+
+func _Auth_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Auth/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Register(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Auth/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Login(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Auth_ServiceDesc is the grpc.ServiceDesc for Auth service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Auth_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "event.Auth",
+	HandlerType: (*AuthServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Auth_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Auth_Login_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "event_service.proto",
+}
+
 // CalendarClient is the client API for Calendar service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CalendarClient interface {
 	GetEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*Event, error)
 	CreateEvent(ctx context.Context, in *CreateEventRequest, opts ...grpc.CallOption) (*EventResponse, error)
-	UpdateEvent(ctx context.Context, in *UpdateEventRequest, opts ...grpc.CallOption) (*EmptyResponse, error)
+	UpdateEvent(ctx context.Context, in *UpdateEventRequest, opts ...grpc.CallOption) (*UpdateEventResponse, error)
 	DeleteEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EmptyResponse, error)
+	UpdateOccurrence(ctx context.Context, in *UpdateOccurrenceRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	DeleteOccurrence(ctx context.Context, in *OccurrenceRequest, opts ...grpc.CallOption) (*EmptyResponse, error)
 	FindForDay(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*EventCollection, error)
 	FindForWeek(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*EventCollection, error)
 	FindForMonth(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*EventCollection, error)
+	SearchEvents(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*EventCollection, error)
+	ExportEvents(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*ICalendar, error)
+	ImportEvents(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportResponse, error)
+	// BatchEvents creates, updates and deletes many events, the atomic batch is applied only when every operation is valid.
+	BatchEvents(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	InviteAttendee(ctx context.Context, in *InviteRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	RespondInvitation(ctx context.Context, in *RespondRequest, opts ...grpc.CallOption) (*EmptyResponse, error)
+	FindInvitations(ctx context.Context, in *InvitationsRequest, opts ...grpc.CallOption) (*InvitationCollection, error)
+	FindFreeSlots(ctx context.Context, in *FreeSlotsRequest, opts ...grpc.CallOption) (*FreeSlotsResponse, error)
+	GetSettings(ctx context.Context, in *SettingsRequest, opts ...grpc.CallOption) (*Settings, error)
+	UpdateSettings(ctx context.Context, in *Settings, opts ...grpc.CallOption) (*Settings, error)
+	FindDeleted(ctx context.Context, in *TrashRequest, opts ...grpc.CallOption) (*EventCollection, error)
+	RestoreEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EmptyResponse, error)
+	PurgeEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EmptyResponse, error)
+	GetEventHistory(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EventHistory, error)
+	// WatchEvents streams the changes of the events of the user as they happen.
+	WatchEvents(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Calendar_WatchEventsClient, error)
 }
 
 type calendarClient struct {
@@ -57,8 +198,8 @@ func (c *calendarClient) CreateEvent(ctx context.Context, in *CreateEventRequest
 	return out, nil
 }
 
-func (c *calendarClient) UpdateEvent(ctx context.Context, in *UpdateEventRequest, opts ...grpc.CallOption) (*EmptyResponse, error) {
-	out := new(EmptyResponse)
+func (c *calendarClient) UpdateEvent(ctx context.Context, in *UpdateEventRequest, opts ...grpc.CallOption) (*UpdateEventResponse, error) {
+	out := new(UpdateEventResponse)
 	err := c.cc.Invoke(ctx, "/event.Calendar/UpdateEvent", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -75,6 +216,24 @@ func (c *calendarClient) DeleteEvent(ctx context.Context, in *EventRequest, opts
 	return out, nil
 }
 
+func (c *calendarClient) UpdateOccurrence(ctx context.Context, in *UpdateOccurrenceRequest, opts ...grpc.CallOption) (*EventResponse, error) {
+	out := new(EventResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/UpdateOccurrence", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) DeleteOccurrence(ctx context.Context, in *OccurrenceRequest, opts ...grpc.CallOption) (*EmptyResponse, error) {
+	out := new(EmptyResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/DeleteOccurrence", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *calendarClient) FindForDay(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*EventCollection, error) {
 	out := new(EventCollection)
 	err := c.cc.Invoke(ctx, "/event.Calendar/FindForDay", in, out, opts...)
@@ -102,17 +261,194 @@ func (c *calendarClient) FindForMonth(ctx context.Context, in *PeriodRequest, op
 	return out, nil
 }
 
+func (c *calendarClient) SearchEvents(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*EventCollection, error) {
+	out := new(EventCollection)
+	err := c.cc.Invoke(ctx, "/event.Calendar/SearchEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ExportEvents(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*ICalendar, error) {
+	out := new(ICalendar)
+	err := c.cc.Invoke(ctx, "/event.Calendar/ExportEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ImportEvents(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportResponse, error) {
+	out := new(ImportResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/ImportEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) BatchEvents(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/BatchEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) InviteAttendee(ctx context.Context, in *InviteRequest, opts ...grpc.CallOption) (*EventResponse, error) {
+	out := new(EventResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/InviteAttendee", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) RespondInvitation(ctx context.Context, in *RespondRequest, opts ...grpc.CallOption) (*EmptyResponse, error) {
+	out := new(EmptyResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/RespondInvitation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) FindInvitations(ctx context.Context, in *InvitationsRequest, opts ...grpc.CallOption) (*InvitationCollection, error) {
+	out := new(InvitationCollection)
+	err := c.cc.Invoke(ctx, "/event.Calendar/FindInvitations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) FindFreeSlots(ctx context.Context, in *FreeSlotsRequest, opts ...grpc.CallOption) (*FreeSlotsResponse, error) {
+	out := new(FreeSlotsResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/FindFreeSlots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) GetSettings(ctx context.Context, in *SettingsRequest, opts ...grpc.CallOption) (*Settings, error) {
+	out := new(Settings)
+	err := c.cc.Invoke(ctx, "/event.Calendar/GetSettings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) UpdateSettings(ctx context.Context, in *Settings, opts ...grpc.CallOption) (*Settings, error) {
+	out := new(Settings)
+	err := c.cc.Invoke(ctx, "/event.Calendar/UpdateSettings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) FindDeleted(ctx context.Context, in *TrashRequest, opts ...grpc.CallOption) (*EventCollection, error) {
+	out := new(EventCollection)
+	err := c.cc.Invoke(ctx, "/event.Calendar/FindDeleted", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) RestoreEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EmptyResponse, error) {
+	out := new(EmptyResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/RestoreEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) PurgeEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EmptyResponse, error) {
+	out := new(EmptyResponse)
+	err := c.cc.Invoke(ctx, "/event.Calendar/PurgeEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) GetEventHistory(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EventHistory, error) {
+	out := new(EventHistory)
+	err := c.cc.Invoke(ctx, "/event.Calendar/GetEventHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) WatchEvents(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Calendar_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Calendar_ServiceDesc.Streams[0], "/event.Calendar/WatchEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calendarWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Calendar_WatchEventsClient interface {
+	Recv() (*HistoryEntry, error)
+	grpc.ClientStream
+}
+
+type calendarWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *calendarWatchEventsClient) Recv() (*HistoryEntry, error) {
+	m := new(HistoryEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // CalendarServer is the server API for Calendar service.
 // All implementations must embed UnimplementedCalendarServer
 // for forward compatibility
 type CalendarServer interface {
 	GetEvent(context.Context, *EventRequest) (*Event, error)
 	CreateEvent(context.Context, *CreateEventRequest) (*EventResponse, error)
-	UpdateEvent(context.Context, *UpdateEventRequest) (*EmptyResponse, error)
+	UpdateEvent(context.Context, *UpdateEventRequest) (*UpdateEventResponse, error)
 	DeleteEvent(context.Context, *EventRequest) (*EmptyResponse, error)
+	UpdateOccurrence(context.Context, *UpdateOccurrenceRequest) (*EventResponse, error)
+	DeleteOccurrence(context.Context, *OccurrenceRequest) (*EmptyResponse, error)
 	FindForDay(context.Context, *PeriodRequest) (*EventCollection, error)
 	FindForWeek(context.Context, *PeriodRequest) (*EventCollection, error)
 	FindForMonth(context.Context, *PeriodRequest) (*EventCollection, error)
+	SearchEvents(context.Context, *SearchRequest) (*EventCollection, error)
+	ExportEvents(context.Context, *ExportRequest) (*ICalendar, error)
+	ImportEvents(context.Context, *ImportRequest) (*ImportResponse, error)
+	// BatchEvents creates, updates and deletes many events, the atomic batch is applied only when every operation is valid.
+	BatchEvents(context.Context, *BatchRequest) (*BatchResponse, error)
+	InviteAttendee(context.Context, *InviteRequest) (*EventResponse, error)
+	RespondInvitation(context.Context, *RespondRequest) (*EmptyResponse, error)
+	FindInvitations(context.Context, *InvitationsRequest) (*InvitationCollection, error)
+	FindFreeSlots(context.Context, *FreeSlotsRequest) (*FreeSlotsResponse, error)
+	GetSettings(context.Context, *SettingsRequest) (*Settings, error)
+	UpdateSettings(context.Context, *Settings) (*Settings, error)
+	FindDeleted(context.Context, *TrashRequest) (*EventCollection, error)
+	RestoreEvent(context.Context, *EventRequest) (*EmptyResponse, error)
+	PurgeEvent(context.Context, *EventRequest) (*EmptyResponse, error)
+	GetEventHistory(context.Context, *EventRequest) (*EventHistory, error)
+	// WatchEvents streams the changes of the events of the user as they happen.
+	WatchEvents(*WatchRequest, Calendar_WatchEventsServer) error
 	mustEmbedUnimplementedCalendarServer()
 }
 
@@ -126,12 +462,18 @@ func (UnimplementedCalendarServer) GetEvent(context.Context, *EventRequest) (*Ev
 func (UnimplementedCalendarServer) CreateEvent(context.Context, *CreateEventRequest) (*EventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateEvent not implemented")
 }
-func (UnimplementedCalendarServer) UpdateEvent(context.Context, *UpdateEventRequest) (*EmptyResponse, error) {
+func (UnimplementedCalendarServer) UpdateEvent(context.Context, *UpdateEventRequest) (*UpdateEventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateEvent not implemented")
 }
 func (UnimplementedCalendarServer) DeleteEvent(context.Context, *EventRequest) (*EmptyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteEvent not implemented")
 }
+func (UnimplementedCalendarServer) UpdateOccurrence(context.Context, *UpdateOccurrenceRequest) (*EventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateOccurrence not implemented")
+}
+func (UnimplementedCalendarServer) DeleteOccurrence(context.Context, *OccurrenceRequest) (*EmptyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteOccurrence not implemented")
+}
 func (UnimplementedCalendarServer) FindForDay(context.Context, *PeriodRequest) (*EventCollection, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindForDay not implemented")
 }
@@ -141,6 +483,51 @@ func (UnimplementedCalendarServer) FindForWeek(context.Context, *PeriodRequest)
 func (UnimplementedCalendarServer) FindForMonth(context.Context, *PeriodRequest) (*EventCollection, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindForMonth not implemented")
 }
+func (UnimplementedCalendarServer) SearchEvents(context.Context, *SearchRequest) (*EventCollection, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchEvents not implemented")
+}
+func (UnimplementedCalendarServer) ExportEvents(context.Context, *ExportRequest) (*ICalendar, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportEvents not implemented")
+}
+func (UnimplementedCalendarServer) ImportEvents(context.Context, *ImportRequest) (*ImportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportEvents not implemented")
+}
+func (UnimplementedCalendarServer) BatchEvents(context.Context, *BatchRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchEvents not implemented")
+}
+func (UnimplementedCalendarServer) InviteAttendee(context.Context, *InviteRequest) (*EventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InviteAttendee not implemented")
+}
+func (UnimplementedCalendarServer) RespondInvitation(context.Context, *RespondRequest) (*EmptyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RespondInvitation not implemented")
+}
+func (UnimplementedCalendarServer) FindInvitations(context.Context, *InvitationsRequest) (*InvitationCollection, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindInvitations not implemented")
+}
+func (UnimplementedCalendarServer) FindFreeSlots(context.Context, *FreeSlotsRequest) (*FreeSlotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindFreeSlots not implemented")
+}
+func (UnimplementedCalendarServer) GetSettings(context.Context, *SettingsRequest) (*Settings, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSettings not implemented")
+}
+func (UnimplementedCalendarServer) UpdateSettings(context.Context, *Settings) (*Settings, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSettings not implemented")
+}
+func (UnimplementedCalendarServer) FindDeleted(context.Context, *TrashRequest) (*EventCollection, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindDeleted not implemented")
+}
+func (UnimplementedCalendarServer) RestoreEvent(context.Context, *EventRequest) (*EmptyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreEvent not implemented")
+}
+func (UnimplementedCalendarServer) PurgeEvent(context.Context, *EventRequest) (*EmptyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeEvent not implemented")
+}
+func (UnimplementedCalendarServer) GetEventHistory(context.Context, *EventRequest) (*EventHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEventHistory not implemented")
+}
+func (UnimplementedCalendarServer) WatchEvents(*WatchRequest, Calendar_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
 func (UnimplementedCalendarServer) mustEmbedUnimplementedCalendarServer() {}
 
 // UnsafeCalendarServer may be embedded to opt out of forward compatibility for this service.
@@ -226,6 +613,42 @@ func _Calendar_DeleteEvent_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Calendar_UpdateOccurrence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateOccurrenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).UpdateOccurrence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/UpdateOccurrence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).UpdateOccurrence(ctx, req.(*UpdateOccurrenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_DeleteOccurrence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OccurrenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).DeleteOccurrence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/DeleteOccurrence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).DeleteOccurrence(ctx, req.(*OccurrenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Calendar_FindForDay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PeriodRequest)
 	if err := dec(in); err != nil {
@@ -280,6 +703,279 @@ func _Calendar_FindForMonth_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Calendar_SearchEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).SearchEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/SearchEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).SearchEvents(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ExportEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ExportEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/ExportEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ExportEvents(ctx, req.(*ExportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ImportEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ImportEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/ImportEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ImportEvents(ctx, req.(*ImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_BatchEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).BatchEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/BatchEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).BatchEvents(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_InviteAttendee_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).InviteAttendee(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/InviteAttendee",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).InviteAttendee(ctx, req.(*InviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_RespondInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RespondRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).RespondInvitation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/RespondInvitation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).RespondInvitation(ctx, req.(*RespondRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_FindInvitations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvitationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).FindInvitations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/FindInvitations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).FindInvitations(ctx, req.(*InvitationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_FindFreeSlots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FreeSlotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).FindFreeSlots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/FindFreeSlots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).FindFreeSlots(ctx, req.(*FreeSlotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_GetSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).GetSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/GetSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).GetSettings(ctx, req.(*SettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_UpdateSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Settings)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).UpdateSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/UpdateSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).UpdateSettings(ctx, req.(*Settings))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_FindDeleted_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).FindDeleted(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/FindDeleted",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).FindDeleted(ctx, req.(*TrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_RestoreEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).RestoreEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/RestoreEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).RestoreEvent(ctx, req.(*EventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_PurgeEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).PurgeEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/PurgeEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).PurgeEvent(ctx, req.(*EventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_GetEventHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).GetEventHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.Calendar/GetEventHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).GetEventHistory(ctx, req.(*EventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalendarServer).WatchEvents(m, &calendarWatchEventsServer{stream})
+}
+
+type Calendar_WatchEventsServer interface {
+	Send(*HistoryEntry) error
+	grpc.ServerStream
+}
+
+type calendarWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *calendarWatchEventsServer) Send(m *HistoryEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Calendar_ServiceDesc is the grpc.ServiceDesc for Calendar service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -303,6 +999,14 @@ var Calendar_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteEvent",
 			Handler:    _Calendar_DeleteEvent_Handler,
 		},
+		{
+			MethodName: "UpdateOccurrence",
+			Handler:    _Calendar_UpdateOccurrence_Handler,
+		},
+		{
+			MethodName: "DeleteOccurrence",
+			Handler:    _Calendar_DeleteOccurrence_Handler,
+		},
 		{
 			MethodName: "FindForDay",
 			Handler:    _Calendar_FindForDay_Handler,
@@ -315,7 +1019,69 @@ var Calendar_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "FindForMonth",
 			Handler:    _Calendar_FindForMonth_Handler,
 		},
+		{
+			MethodName: "SearchEvents",
+			Handler:    _Calendar_SearchEvents_Handler,
+		},
+		{
+			MethodName: "ExportEvents",
+			Handler:    _Calendar_ExportEvents_Handler,
+		},
+		{
+			MethodName: "ImportEvents",
+			Handler:    _Calendar_ImportEvents_Handler,
+		},
+		{
+			MethodName: "BatchEvents",
+			Handler:    _Calendar_BatchEvents_Handler,
+		},
+		{
+			MethodName: "InviteAttendee",
+			Handler:    _Calendar_InviteAttendee_Handler,
+		},
+		{
+			MethodName: "RespondInvitation",
+			Handler:    _Calendar_RespondInvitation_Handler,
+		},
+		{
+			MethodName: "FindInvitations",
+			Handler:    _Calendar_FindInvitations_Handler,
+		},
+		{
+			MethodName: "FindFreeSlots",
+			Handler:    _Calendar_FindFreeSlots_Handler,
+		},
+		{
+			MethodName: "GetSettings",
+			Handler:    _Calendar_GetSettings_Handler,
+		},
+		{
+			MethodName: "UpdateSettings",
+			Handler:    _Calendar_UpdateSettings_Handler,
+		},
+		{
+			MethodName: "FindDeleted",
+			Handler:    _Calendar_FindDeleted_Handler,
+		},
+		{
+			MethodName: "RestoreEvent",
+			Handler:    _Calendar_RestoreEvent_Handler,
+		},
+		{
+			MethodName: "PurgeEvent",
+			Handler:    _Calendar_PurgeEvent_Handler,
+		},
+		{
+			MethodName: "GetEventHistory",
+			Handler:    _Calendar_GetEventHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Calendar_WatchEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "event_service.proto",
 }
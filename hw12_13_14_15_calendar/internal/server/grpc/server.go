@@ -1,28 +1,68 @@
 package grpcserver
 
 import (
+	"context"
 	"fmt"
 	"net"
 
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Server struct {
-	addr   string
-	logger logger.Logger
-	events app.EventsUseCase
-	server *grpc.Server
+	addr    string
+	logger  logger.Logger
+	events  app.EventsUseCase
+	ical    app.ICalendarUseCase
+	users   app.UsersUseCase
+	checker *health.Checker
+	server  *grpc.Server
 }
 
-func New(logger logger.Logger, events app.EventsUseCase, addr string) *Server {
-	return &Server{
-		addr:   addr,
-		logger: logger,
-		events: events,
+func New(
+	logger logger.Logger,
+	events app.EventsUseCase,
+	ical app.ICalendarUseCase,
+	users app.UsersUseCase,
+	checker *health.Checker,
+	addr string,
+) *Server {
+	s := &Server{
+		addr:    addr,
+		logger:  logger,
+		events:  events,
+		ical:    ical,
+		users:   users,
+		checker: checker,
 	}
+
+	s.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			unaryMetricsInterceptor(),
+			unaryLoggingInterceptor(s.logger),
+			unaryAuthInterceptor(s.users),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			streamMetricsInterceptor(),
+			streamLoggingInterceptor(s.logger),
+			streamAuthInterceptor(s.users),
+		),
+	)
+	pb.RegisterAuthServer(s.server, newAuthService(s.users))
+	pb.RegisterCalendarServer(s.server, newCalendarService(s.events, s.ical, s.users))
+	healthpb.RegisterHealthServer(s.server, newHealthService(s.checker,
+		pb.Auth_ServiceDesc.ServiceName,
+		pb.Calendar_ServiceDesc.ServiceName,
+	))
+
+	return s
 }
 
 func (s *Server) Start() error {
@@ -32,13 +72,6 @@ func (s *Server) Start() error {
 		return fmt.Errorf("listening grpc on %s: %w", s.addr, err)
 	}
 
-	s.server = grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			unaryLoggingInterceptor(s.logger),
-		),
-	)
-	pb.RegisterCalendarServer(s.server, newCalendarService(s.events))
-
 	s.logger.Info("starting grpc server")
 	if err := s.server.Serve(lsn); err != nil {
 		return fmt.Errorf("starting grpc server: %w", err)
@@ -47,6 +80,23 @@ func (s *Server) Start() error {
 	return nil
 }
 
-func (s *Server) Stop() {
-	s.server.Stop()
+// Stop waits for the active calls to finish until the context is done, then closes the remaining ones.
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger.Info("stopping grpc server")
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		<-stopped
+
+		return fmt.Errorf("grpc server graceful stop: %w", ctx.Err())
+	}
 }
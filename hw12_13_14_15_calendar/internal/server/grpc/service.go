@@ -1,28 +1,33 @@
 package grpcserver
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"time"
 
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type calendarService struct {
 	events app.EventsUseCase
+	ical   app.ICalendarUseCase
+	users  app.UsersUseCase
 	pb.UnimplementedCalendarServer
 }
 
-func newCalendarService(events app.EventsUseCase) *calendarService {
-	return &calendarService{events: events}
+func newCalendarService(events app.EventsUseCase, ical app.ICalendarUseCase, users app.UsersUseCase) *calendarService {
+	return &calendarService{events: events, ical: ical, users: users}
 }
 
 func (s *calendarService) GetEvent(ctx context.Context, req *pb.EventRequest) (*pb.Event, error) {
-	e, err := s.events.GetByID(ctx, req.Id)
+	e, err := s.events.GetByID(ctx, userIDFromContext(ctx), req.Id)
 	if err != nil {
 		if errors.Is(err, app.ErrEventIsNotExists) {
 			return nil, status.Errorf(codes.NotFound, "event %d is not found", req.Id)
@@ -36,12 +41,16 @@ func (s *calendarService) GetEvent(ctx context.Context, req *pb.EventRequest) (*
 
 func (s *calendarService) CreateEvent(ctx context.Context, req *pb.CreateEventRequest) (*pb.EventResponse, error) {
 	dto := app.CreateDTO{
-		UserID:      req.UserId,
+		UserID:      userIDFromContext(ctx),
 		Title:       req.Title,
 		Description: req.Description,
 		TimeStart:   req.TimeStart.AsTime(),
 		TimeEnd:     req.TimeEnd.AsTime(),
-		Notify:      req.Notify.AsDuration(),
+		Reminders:   grpcRemindersToDTO(req.Reminders),
+		RRule:       req.Rrule,
+		ExDates:     grpcTimesToTimes(req.ExDates),
+		TimeZone:    req.TimeZone,
+		NonBlocking: req.NonBlocking,
 	}
 
 	id, err := s.events.Create(ctx, dto)
@@ -59,21 +68,34 @@ func (s *calendarService) CreateEvent(ctx context.Context, req *pb.CreateEventRe
 	}, nil
 }
 
-func (s *calendarService) UpdateEvent(ctx context.Context, req *pb.UpdateEventRequest) (*pb.EmptyResponse, error) {
+func (s *calendarService) UpdateEvent(
+	ctx context.Context,
+	req *pb.UpdateEventRequest,
+) (*pb.UpdateEventResponse, error) {
 	dto := app.UpdateDTO{
+		UserID:      userIDFromContext(ctx),
 		Title:       req.Title,
 		Description: req.Description,
 		TimeStart:   req.TimeStart.AsTime(),
 		TimeEnd:     req.TimeEnd.AsTime(),
-		Notify:      req.Notify.AsDuration(),
+		Reminders:   grpcRemindersToDTO(req.Reminders),
+		RRule:       req.Rrule,
+		ExDates:     grpcTimesToTimes(req.ExDates),
+		TimeZone:    req.TimeZone,
+		Version:     req.Version,
+		NonBlocking: req.NonBlocking,
 	}
 
-	err := s.events.Update(ctx, req.Id, dto)
+	version, err := s.events.Update(ctx, req.Id, dto)
 	if err != nil {
 		if errors.Is(err, app.ErrEventIsNotExists) {
 			return nil, status.Errorf(codes.NotFound, "grpc create event: event %d is not exists", req.Id)
 		}
 
+		if errors.Is(err, app.ErrEventIsModified) {
+			return nil, status.Errorf(codes.Aborted, "grpc update event: event %d is modified", req.Id)
+		}
+
 		var v *app.ValidationErrors
 		if errors.As(err, &v) {
 			return nil, status.Errorf(codes.InvalidArgument, "grpc update event validation error: %v", v.Error())
@@ -82,79 +104,643 @@ func (s *calendarService) UpdateEvent(ctx context.Context, req *pb.UpdateEventRe
 		return nil, status.Errorf(codes.Internal, "grpc update event: %v", err.Error())
 	}
 
-	return &pb.EmptyResponse{}, nil
+	return &pb.UpdateEventResponse{
+		Version: version,
+	}, nil
 }
 
 func (s *calendarService) DeleteEvent(ctx context.Context, req *pb.EventRequest) (*pb.EmptyResponse, error) {
-	if err := s.events.Delete(ctx, req.Id); err != nil {
+	if err := s.events.Delete(ctx, userIDFromContext(ctx), req.Id); err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			return nil, status.Errorf(codes.NotFound, "grpc delete event: event %d is not exists", req.Id)
+		}
+
 		return nil, status.Errorf(codes.Internal, "grpc delete event: %v", err.Error())
 	}
 
 	return &pb.EmptyResponse{}, nil
 }
 
+func (s *calendarService) UpdateOccurrence(
+	ctx context.Context,
+	req *pb.UpdateOccurrenceRequest,
+) (*pb.EventResponse, error) {
+	dto := app.UpdateDTO{
+		UserID:      userIDFromContext(ctx),
+		Title:       req.Title,
+		Description: req.Description,
+		TimeStart:   req.TimeStart.AsTime(),
+		TimeEnd:     req.TimeEnd.AsTime(),
+		Reminders:   grpcRemindersToDTO(req.Reminders),
+		TimeZone:    req.TimeZone,
+		NonBlocking: req.NonBlocking,
+	}
+
+	id, err := s.events.UpdateOccurrence(ctx, req.Id, req.Occurrence.AsTime(), dto)
+	if err != nil {
+		if isOccurrenceNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "grpc update occurrence: %v", err.Error())
+		}
+
+		if errors.Is(err, app.ErrEventIsModified) {
+			return nil, status.Errorf(codes.Aborted, "grpc update occurrence: %v", err.Error())
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc update occurrence validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc update occurrence: %v", err.Error())
+	}
+
+	return &pb.EventResponse{
+		Id: id,
+	}, nil
+}
+
+func (s *calendarService) DeleteOccurrence(ctx context.Context, req *pb.OccurrenceRequest) (*pb.EmptyResponse, error) {
+	if err := s.events.DeleteOccurrence(ctx, userIDFromContext(ctx), req.Id, req.Occurrence.AsTime()); err != nil {
+		if isOccurrenceNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "grpc delete occurrence: %v", err.Error())
+		}
+
+		if errors.Is(err, app.ErrEventIsModified) {
+			return nil, status.Errorf(codes.Aborted, "grpc delete occurrence: %v", err.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc delete occurrence: %v", err.Error())
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+func isOccurrenceNotFound(err error) bool {
+	return errors.Is(err, app.ErrEventIsNotExists) ||
+		errors.Is(err, app.ErrEventIsNotRecurring) ||
+		errors.Is(err, app.ErrOccurrenceIsNotExists)
+}
+
 func (s *calendarService) FindForDay(ctx context.Context, req *pb.PeriodRequest) (*pb.EventCollection, error) {
-	events, err := s.events.FindForDay(ctx, grpcPeriodToDto(req))
+	page, err := s.events.FindForDay(ctx, grpcPeriodToDto(ctx, req))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "grpc event get for day: %v", err.Error())
+		return nil, collectionError("grpc event get for day", err)
 	}
 
-	return eventsToGrpcCollection(events), nil
+	return pageToGrpcCollection(page), nil
 }
 
 func (s *calendarService) FindForWeek(ctx context.Context, req *pb.PeriodRequest) (*pb.EventCollection, error) {
-	events, err := s.events.FindForWeek(ctx, grpcPeriodToDto(req))
+	page, err := s.events.FindForWeek(ctx, grpcPeriodToDto(ctx, req))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "grpc event get for week: %v", err.Error())
+		return nil, collectionError("grpc event get for week", err)
 	}
 
-	return eventsToGrpcCollection(events), nil
+	return pageToGrpcCollection(page), nil
 }
 
 func (s *calendarService) FindForMonth(ctx context.Context, req *pb.PeriodRequest) (*pb.EventCollection, error) {
-	events, err := s.events.FindForMonth(ctx, grpcPeriodToDto(req))
+	page, err := s.events.FindForMonth(ctx, grpcPeriodToDto(ctx, req))
+	if err != nil {
+		return nil, collectionError("grpc event get for month", err)
+	}
+
+	return pageToGrpcCollection(page), nil
+}
+
+func (s *calendarService) SearchEvents(ctx context.Context, req *pb.SearchRequest) (*pb.EventCollection, error) {
+	dto := app.SearchDTO{
+		UserID:       userIDFromContext(ctx),
+		From:         req.From.AsTime(),
+		To:           req.To.AsTime(),
+		Query:        req.Query,
+		Notification: storage.NotificationState(req.Notification),
+		Sort:         storage.SearchSort(req.Sort),
+		Limit:        int(req.Limit),
+		PageToken:    req.PageToken,
+	}
+	if req.CreatedSince != nil {
+		dto.CreatedSince = req.CreatedSince.AsTime()
+	}
+	if req.UpdatedSince != nil {
+		dto.UpdatedSince = req.UpdatedSince.AsTime()
+	}
+
+	page, err := s.events.Search(ctx, dto)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "grpc event get for month: %v", err.Error())
+		return nil, collectionError("grpc search events", err)
+	}
+
+	return pageToGrpcCollection(page), nil
+}
+
+func (s *calendarService) ExportEvents(ctx context.Context, req *pb.ExportRequest) (*pb.ICalendar, error) {
+	dto := app.ExportDTO{
+		UserID: userIDFromContext(ctx),
+		From:   req.From.AsTime(),
+		To:     req.To.AsTime(),
+	}
+
+	data := &bytes.Buffer{}
+	if err := s.ical.Export(ctx, dto, data); err != nil {
+		return nil, status.Errorf(codes.Internal, "grpc export events: %v", err.Error())
 	}
 
-	return eventsToGrpcCollection(events), nil
+	return &pb.ICalendar{
+		Data: data.Bytes(),
+	}, nil
 }
 
-func grpcPeriodToDto(req *pb.PeriodRequest) app.FindByDateDTO {
+func (s *calendarService) ImportEvents(ctx context.Context, req *pb.ImportRequest) (*pb.ImportResponse, error) {
+	result, err := s.ical.Import(ctx, userIDFromContext(ctx), bytes.NewReader(req.Data))
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidCalendar) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc import events: %v", err.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc import events: %v", err.Error())
+	}
+
+	failed := make([]*pb.ImportFailure, 0, len(result.Failed))
+	for _, f := range result.Failed {
+		errs := make([]string, 0, len(f.Err.Errors()))
+		for _, e := range f.Err.Errors() {
+			errs = append(errs, e.Error())
+		}
+
+		failed = append(failed, &pb.ImportFailure{
+			Index:  int32(f.Index),
+			Uid:    f.UID,
+			Errors: errs,
+		})
+	}
+
+	return &pb.ImportResponse{
+		Created: result.Created,
+		Failed:  failed,
+	}, nil
+}
+
+func (s *calendarService) BatchEvents(ctx context.Context, req *pb.BatchRequest) (*pb.BatchResponse, error) {
+	dto := app.BatchDTO{
+		UserID:     userIDFromContext(ctx),
+		Atomic:     req.Atomic,
+		Operations: make([]app.BatchOperationDTO, 0, len(req.Operations)),
+	}
+	for _, op := range req.Operations {
+		e := op.Event
+		if e == nil {
+			e = &pb.UpdateEventRequest{}
+		}
+
+		dto.Operations = append(dto.Operations, app.BatchOperationDTO{
+			Operation: app.BatchOperation(op.Operation),
+			ID:        e.Id,
+			Event: app.UpdateDTO{
+				Title:       e.Title,
+				Description: e.Description,
+				TimeStart:   e.TimeStart.AsTime(),
+				TimeEnd:     e.TimeEnd.AsTime(),
+				Reminders:   grpcRemindersToDTO(e.Reminders),
+				RRule:       e.Rrule,
+				ExDates:     grpcTimesToTimes(e.ExDates),
+				TimeZone:    e.TimeZone,
+				Version:     e.Version,
+				NonBlocking: e.NonBlocking,
+			},
+		})
+	}
+
+	result, err := s.events.Batch(ctx, dto)
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsModified) {
+			return nil, status.Errorf(codes.Aborted, "grpc batch events: %v", err.Error())
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc batch events validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc batch events: %v", err.Error())
+	}
+
+	results := make([]*pb.BatchResult, 0, len(result.Items))
+	for i, item := range result.Items {
+		errs := make([]string, 0)
+		if item.Err != nil {
+			for _, e := range item.Err.Errors() {
+				errs = append(errs, e.Error())
+			}
+		}
+
+		results = append(results, &pb.BatchResult{
+			Index:   int32(i),
+			Id:      item.ID,
+			Version: item.Version,
+			Errors:  errs,
+		})
+	}
+
+	return &pb.BatchResponse{
+		Applied: result.Applied,
+		Results: results,
+	}, nil
+}
+
+func (s *calendarService) InviteAttendee(ctx context.Context, req *pb.InviteRequest) (*pb.EventResponse, error) {
+	dto := app.InviteDTO{
+		UserID:         userIDFromContext(ctx),
+		EventID:        req.EventId,
+		AttendeeUserID: req.UserId,
+		Email:          req.Email,
+		Role:           storage.AttendeeRole(req.Role),
+	}
+
+	id, err := s.events.Invite(ctx, dto)
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			return nil, status.Errorf(codes.NotFound, "grpc invite attendee: event %d is not exists", req.EventId)
+		}
+
+		if errors.Is(err, app.ErrAttendeeAlreadyInvited) {
+			return nil, status.Errorf(codes.AlreadyExists, "grpc invite attendee: %v", err.Error())
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc invite attendee validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc invite attendee: %v", err.Error())
+	}
+
+	return &pb.EventResponse{
+		Id: id,
+	}, nil
+}
+
+func (s *calendarService) RespondInvitation(ctx context.Context, req *pb.RespondRequest) (*pb.EmptyResponse, error) {
+	dto := app.RespondDTO{
+		UserID:  userIDFromContext(ctx),
+		EventID: req.EventId,
+		Status:  storage.RSVPStatus(req.Status),
+	}
+
+	if err := s.events.Respond(ctx, dto); err != nil {
+		if errors.Is(err, app.ErrInvitationIsNotExists) {
+			return nil, status.Errorf(codes.NotFound, "grpc respond invitation: %v", err.Error())
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc respond invitation validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc respond invitation: %v", err.Error())
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+func (s *calendarService) FindInvitations(
+	ctx context.Context,
+	_ *pb.InvitationsRequest,
+) (*pb.InvitationCollection, error) {
+	invitations, err := s.events.FindInvitations(ctx, userIDFromContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "grpc find invitations: %v", err.Error())
+	}
+
+	result := make([]*pb.Invitation, 0, len(invitations))
+	for _, i := range invitations {
+		result = append(result, &pb.Invitation{
+			Event:    eventToGrpc(i.Event),
+			Attendee: attendeeToGrpc(i.Attendee),
+		})
+	}
+
+	return &pb.InvitationCollection{
+		Invitations: result,
+	}, nil
+}
+
+func (s *calendarService) FindFreeSlots(ctx context.Context, req *pb.FreeSlotsRequest) (*pb.FreeSlotsResponse, error) {
+	dto := app.FreeSlotsDTO{
+		UserIDs:      req.UserIds,
+		From:         req.From.AsTime(),
+		To:           req.To.AsTime(),
+		Duration:     req.Duration.AsDuration(),
+		WorkDayStart: req.WorkDayStart.AsDuration(),
+		WorkDayEnd:   req.WorkDayEnd.AsDuration(),
+		TimeZone:     req.TimeZone,
+	}
+	if len(dto.UserIDs) == 0 {
+		dto.UserIDs = []int64{userIDFromContext(ctx)}
+	}
+	// working hours are in the zone of the requester by default
+	if dto.TimeZone == "" {
+		settings, err := s.users.GetSettings(ctx, userIDFromContext(ctx))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "grpc find free slots: %v", err.Error())
+		}
+		dto.TimeZone = settings.TimeZone
+	}
+
+	slots, err := s.events.FindFreeSlots(ctx, dto)
+	if err != nil {
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc find free slots validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc find free slots: %v", err.Error())
+	}
+
+	result := make([]*pb.TimeSlot, 0, len(slots))
+	for _, slot := range slots {
+		result = append(result, &pb.TimeSlot{
+			Start: timestamppb.New(slot.Start),
+			End:   timestamppb.New(slot.End),
+		})
+	}
+
+	return &pb.FreeSlotsResponse{
+		Slots: result,
+	}, nil
+}
+
+func (s *calendarService) GetSettings(ctx context.Context, _ *pb.SettingsRequest) (*pb.Settings, error) {
+	settings, err := s.users.GetSettings(ctx, userIDFromContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "grpc get settings: %v", err.Error())
+	}
+
+	return settingsToGrpc(settings), nil
+}
+
+func (s *calendarService) UpdateSettings(ctx context.Context, req *pb.Settings) (*pb.Settings, error) {
+	settings, err := s.users.UpdateSettings(ctx, app.SettingsDTO{
+		UserID:    userIDFromContext(ctx),
+		TimeZone:  req.TimeZone,
+		WeekStart: time.Weekday(req.WeekStart),
+	})
+	if err != nil {
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc update settings validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc update settings: %v", err.Error())
+	}
+
+	return settingsToGrpc(settings), nil
+}
+
+func (s *calendarService) FindDeleted(ctx context.Context, req *pb.TrashRequest) (*pb.EventCollection, error) {
+	page, err := s.events.FindDeleted(ctx, app.TrashDTO{
+		UserID:    userIDFromContext(ctx),
+		Limit:     int(req.Limit),
+		PageToken: req.PageToken,
+	})
+	if err != nil {
+		return nil, collectionError("grpc find deleted", err)
+	}
+
+	return pageToGrpcCollection(page), nil
+}
+
+func (s *calendarService) RestoreEvent(ctx context.Context, req *pb.EventRequest) (*pb.EmptyResponse, error) {
+	if err := s.events.Restore(ctx, userIDFromContext(ctx), req.Id); err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			return nil, status.Errorf(codes.NotFound, "grpc restore event: event %d is not in the trash", req.Id)
+		}
+
+		if errors.Is(err, app.ErrSeriesIsDeleted) {
+			return nil, status.Errorf(codes.FailedPrecondition, "grpc restore event: %v", err.Error())
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			return nil, status.Errorf(codes.InvalidArgument, "grpc restore event validation error: %v", v.Error())
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc restore event: %v", err.Error())
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+func (s *calendarService) PurgeEvent(ctx context.Context, req *pb.EventRequest) (*pb.EmptyResponse, error) {
+	if err := s.events.Purge(ctx, userIDFromContext(ctx), req.Id); err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			return nil, status.Errorf(codes.NotFound, "grpc purge event: event %d is not in the trash", req.Id)
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc purge event: %v", err.Error())
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+func (s *calendarService) GetEventHistory(ctx context.Context, req *pb.EventRequest) (*pb.EventHistory, error) {
+	history, err := s.events.FindHistory(ctx, userIDFromContext(ctx), req.Id)
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			return nil, status.Errorf(codes.NotFound, "grpc get event history: event %d is not exists", req.Id)
+		}
+
+		return nil, status.Errorf(codes.Internal, "grpc get event history: %v", err.Error())
+	}
+
+	entries := make([]*pb.HistoryEntry, 0, len(history))
+	for _, h := range history {
+		entries = append(entries, historyEntryToGrpc(h))
+	}
+
+	return &pb.EventHistory{
+		Entries: entries,
+	}, nil
+}
+
+func (s *calendarService) WatchEvents(req *pb.WatchRequest, stream pb.Calendar_WatchEventsServer) error {
+	ctx := stream.Context()
+
+	c, err := s.events.Watch(ctx, userIDFromContext(ctx), req.AfterId)
+	if err != nil {
+		if errors.Is(err, app.ErrTooManyChanges) {
+			return status.Errorf(codes.OutOfRange, "grpc watch events: %v", err.Error())
+		}
+
+		return status.Errorf(codes.Internal, "grpc watch events: %v", err.Error())
+	}
+
+	for h := range c {
+		if err := stream.Send(historyEntryToGrpc(h)); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	// the watcher fell behind, the client resumes from the last received change
+	return status.Error(codes.Unavailable, "grpc watch events: the watcher fell behind the changes")
+}
+
+func grpcPeriodToDto(ctx context.Context, req *pb.PeriodRequest) app.FindByDateDTO {
 	return app.FindByDateDTO{
-		UserID: req.UserId,
-		Date:   req.Date.AsTime(),
-		Limit:  uint8(req.Limit),
-		Offset: uint8(req.Offset),
+		UserID:    userIDFromContext(ctx),
+		Date:      req.Date.AsTime(),
+		Limit:     int(req.Limit),
+		PageToken: req.PageToken,
+	}
+}
+
+func collectionError(prefix string, err error) error {
+	var v *app.ValidationErrors
+	if errors.As(err, &v) {
+		return status.Errorf(codes.InvalidArgument, "%s validation error: %v", prefix, v.Error())
 	}
+
+	return status.Errorf(codes.Internal, "%s: %v", prefix, err.Error())
 }
 
 func eventToGrpc(e *storage.Event) *pb.Event {
+	var until, recurrenceID, deletedAt *timestamppb.Timestamp
+	if e.RecurrenceUntil.Valid {
+		until = timestamppb.New(e.RecurrenceUntil.Time)
+	}
+	if e.RecurrenceID.Valid {
+		recurrenceID = timestamppb.New(e.RecurrenceID.Time)
+	}
+	if e.DeletedAt.Valid {
+		deletedAt = timestamppb.New(e.DeletedAt.Time)
+	}
+
+	reminders := make([]*pb.Reminder, 0, len(e.Reminders))
+	for _, r := range e.Reminders {
+		reminders = append(reminders, &pb.Reminder{
+			Id:       r.ID,
+			Channel:  string(r.Channel),
+			Before:   durationpb.New(r.Before),
+			NotifyAt: timestamppb.New(r.NotifyAt),
+			Sent:     r.Sent,
+		})
+	}
+
+	attendees := make([]*pb.Attendee, 0, len(e.Attendees))
+	for _, a := range e.Attendees {
+		attendees = append(attendees, attendeeToGrpc(a))
+	}
+
 	return &pb.Event{
-		Id:          e.ID,
-		UserId:      e.UserID,
-		Title:       e.Title,
-		Description: e.Description,
-		TimeStart:   timestamppb.New(e.TimeStart),
-		TimeEnd:     timestamppb.New(e.TimeEnd),
-		NotifyAt: &pb.NullableNotificationTime{
-			Valid: e.NotifyAt.Valid,
-			Time:  timestamppb.New(e.NotifyAt.Time),
-		},
-		CreatedAt:        timestamppb.New(e.CreatedAt),
-		UpdatedAt:        timestamppb.New(e.UpdatedAt),
-		NotificationSent: e.NotificationSent,
-	}
-}
-
-func eventsToGrpcCollection(events []*storage.Event) *pb.EventCollection {
-	ev := make([]*pb.Event, 0, len(events))
-
-	for _, e := range events {
+		Id:              e.ID,
+		UserId:          e.UserID,
+		Title:           e.Title,
+		Description:     e.Description,
+		TimeStart:       timestamppb.New(e.TimeStart),
+		TimeEnd:         timestamppb.New(e.TimeEnd),
+		CreatedAt:       timestamppb.New(e.CreatedAt),
+		UpdatedAt:       timestamppb.New(e.UpdatedAt),
+		Rrule:           e.RRule,
+		ExDates:         timesToGrpc(e.ExDates),
+		RecurrenceUntil: until,
+		SeriesId:        e.SeriesID.Int64,
+		RecurrenceId:    recurrenceID,
+		Attendees:       attendees,
+		TimeZone:        e.TimeZone,
+		Reminders:       reminders,
+		Version:         e.Version,
+		NonBlocking:     e.NonBlocking,
+		DeletedAt:       deletedAt,
+	}
+}
+
+func grpcRemindersToDTO(reminders []*pb.ReminderRequest) []app.ReminderDTO {
+	result := make([]app.ReminderDTO, 0, len(reminders))
+	for _, r := range reminders {
+		result = append(result, app.ReminderDTO{
+			Channel: storage.ReminderChannel(r.Channel),
+			Before:  r.Before.AsDuration(),
+		})
+	}
+
+	return result
+}
+
+func settingsToGrpc(settings *app.SettingsDTO) *pb.Settings {
+	return &pb.Settings{
+		TimeZone:  settings.TimeZone,
+		WeekStart: int32(settings.WeekStart),
+	}
+}
+
+func attendeeToGrpc(a *storage.Attendee) *pb.Attendee {
+	return &pb.Attendee{
+		Id:        a.ID,
+		EventId:   a.EventID,
+		UserId:    a.UserID.Int64,
+		Email:     a.Email,
+		Role:      string(a.Role),
+		Status:    string(a.Status),
+		CreatedAt: timestamppb.New(a.CreatedAt),
+		UpdatedAt: timestamppb.New(a.UpdatedAt),
+	}
+}
+
+func historyEntryToGrpc(h *storage.HistoryEntry) *pb.HistoryEntry {
+	changes := make([]*pb.FieldChange, 0, len(h.Changes))
+	for _, c := range h.Changes {
+		changes = append(changes, &pb.FieldChange{
+			Field: c.Field,
+			Old:   c.Old,
+			New:   c.New,
+		})
+	}
+
+	return &pb.HistoryEntry{
+		Id:        h.ID,
+		EventId:   h.EventID,
+		UserId:    h.UserID,
+		Action:    string(h.Action),
+		Changes:   changes,
+		CreatedAt: timestamppb.New(h.CreatedAt),
+	}
+}
+
+func timesToGrpc(times []time.Time) []*timestamppb.Timestamp {
+	result := make([]*timestamppb.Timestamp, 0, len(times))
+	for _, t := range times {
+		result = append(result, timestamppb.New(t))
+	}
+
+	return result
+}
+
+func grpcTimesToTimes(times []*timestamppb.Timestamp) []time.Time {
+	if len(times) == 0 {
+		return nil
+	}
+
+	result := make([]time.Time, 0, len(times))
+	for _, t := range times {
+		result = append(result, t.AsTime())
+	}
+
+	return result
+}
+
+func pageToGrpcCollection(page *app.EventPage) *pb.EventCollection {
+	ev := make([]*pb.Event, 0, len(page.Events))
+
+	for _, e := range page.Events {
 		ev = append(ev, eventToGrpc(e))
 	}
 
 	return &pb.EventCollection{
-		Events: ev,
+		Events:        ev,
+		NextPageToken: page.NextPageToken,
 	}
 }
@@ -1,16 +1,27 @@
 package httpserver
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const timeLayout = "[02/Jan/2006:15:04:05 -0700]"
 
+type contextKey int
+
+const userIDKey contextKey = iota
+
 type responseWriterDecorator struct {
 	http.ResponseWriter
 	statusCode int
@@ -21,6 +32,13 @@ func (w *responseWriterDecorator) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 }
 
+// Flush lets the streaming handlers flush the response through the decorator.
+func (w *responseWriterDecorator) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func wrapResponseWriter(w http.ResponseWriter) *responseWriterDecorator {
 	return &responseWriterDecorator{
 		ResponseWriter: w,
@@ -52,3 +70,95 @@ func loggingMiddleware(next http.Handler, log logger.Logger) http.Handler {
 		)
 	})
 }
+
+// metricsMiddleware observes the requests by the path template of the matched route.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		decoratedWriter := wrapResponseWriter(w)
+		next.ServeHTTP(decoratedWriter, r)
+
+		metrics.ObserveHTTPRequest(routeTemplate(r), r.Method, decoratedWriter.statusCode, time.Since(start))
+	})
+}
+
+// tracingMiddleware names the span of the request started by otelhttp after the path template of the matched route.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRouteKey.String(route))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+
+	return "unmatched"
+}
+
+// authMiddleware authenticates the caller either with a bearer API token or with basic login and password.
+func authMiddleware(next http.Handler, users app.UsersUseCase, log logger.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := authenticate(r, users)
+		if err != nil {
+			if errors.Is(err, app.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer, Basic realm="calendar"`)
+				writeMiddlewareError(w, "unauthorized", http.StatusUnauthorized, log)
+				return
+			}
+
+			log.Error("http authenticate: "+err.Error(),
+				"context", "http",
+			)
+			writeMiddlewareError(w, internalError, http.StatusInternalServerError, log)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func authenticate(r *http.Request, users app.UsersUseCase) (int64, error) {
+	if login, password, ok := r.BasicAuth(); ok {
+		return users.AuthenticatePassword(r.Context(), app.CredentialsDTO{Login: login, Password: password})
+	}
+
+	header := r.Header.Get("Authorization")
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return users.AuthenticateToken(r.Context(), strings.TrimSpace(header[len("Bearer "):]))
+	}
+
+	return 0, app.ErrInvalidCredentials
+}
+
+// userIDFromContext returns the user authenticated by authMiddleware.
+func userIDFromContext(ctx context.Context) int64 {
+	userID, _ := ctx.Value(userIDKey).(int64)
+
+	return userID
+}
+
+func writeMiddlewareError(w http.ResponseWriter, msg string, statusCode int, log logger.Logger) {
+	body, err := json.Marshal(&response{Error: &msg})
+	if err != nil {
+		log.Error("marshal response: "+err.Error(),
+			"context", "http",
+		)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
@@ -9,7 +9,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/health"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type Server struct {
@@ -18,14 +20,25 @@ type Server struct {
 	events app.EventsUseCase
 }
 
-func New(logger logger.Logger, events app.EventsUseCase, addr string) *Server {
-	s := newCalendarService(events, logger, time.Second*3, time.RFC3339)
+func New(
+	logger logger.Logger,
+	events app.EventsUseCase,
+	ical app.ICalendarUseCase,
+	users app.UsersUseCase,
+	checker *health.Checker,
+	addr string,
+) *Server {
+	s := newCalendarService(events, ical, users, logger, time.Second*3, time.RFC3339)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: otelhttp.NewHandler(loggingMiddleware(createHandler(s, checker, logger), logger), "http"),
+	}
+	// Shutdown waits for the active requests, so the event streams are closed first
+	server.RegisterOnShutdown(s.stopStreaming)
 
 	return &Server{
-		server: &http.Server{
-			Addr:    addr,
-			Handler: loggingMiddleware(createHandler(s), logger),
-		},
+		server: server,
 		logger: logger,
 		events: events,
 	}
@@ -53,15 +66,42 @@ func (s *Server) Stop(ctx context.Context) error {
 	return nil
 }
 
-func createHandler(s *calendarAPI) http.Handler {
+func createHandler(s *calendarAPI, checker *health.Checker, log logger.Logger) http.Handler {
 	router := mux.NewRouter()
+	router.Use(tracingMiddleware, metricsMiddleware)
 
 	router.HandleFunc("/", helloWorldHandler).Methods("GET")
-	router.HandleFunc("/event", s.CreateHandler).Methods("POST")
-	router.HandleFunc("/event/{id:[0-9]+}", s.GetByIDHandler).Methods("GET")
-	router.HandleFunc("/event/{id:[0-9]+}", s.DeleteEventHandler).Methods("DELETE")
-	router.HandleFunc("/event/{id:[0-9]+}", s.UpdateHandler).Methods("PUT")
-	router.HandleFunc("/events/{period:day|week|month}", s.FindForPeriodHandler).Methods("GET")
+	router.Handle("/healthz", checker.LivenessHandler()).Methods("GET")
+	router.Handle("/readyz", checker.ReadinessHandler()).Methods("GET")
+	router.HandleFunc("/auth/register", s.RegisterHandler).Methods("POST")
+	router.HandleFunc("/auth/login", s.LoginHandler).Methods("POST")
+
+	api := router.PathPrefix("/").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return authMiddleware(next, s.users, log)
+	})
+	api.HandleFunc("/settings", s.GetSettingsHandler).Methods("GET")
+	api.HandleFunc("/settings", s.UpdateSettingsHandler).Methods("PUT")
+	api.HandleFunc("/event", s.CreateHandler).Methods("POST")
+	api.HandleFunc("/event/{id:[0-9]+}", s.GetByIDHandler).Methods("GET")
+	api.HandleFunc("/event/{id:[0-9]+}", s.DeleteEventHandler).Methods("DELETE")
+	api.HandleFunc("/event/{id:[0-9]+}", s.UpdateHandler).Methods("PUT")
+	api.HandleFunc("/event/{id:[0-9]+}/occurrence", s.UpdateOccurrenceHandler).Methods("PUT")
+	api.HandleFunc("/event/{id:[0-9]+}/occurrence", s.DeleteOccurrenceHandler).Methods("DELETE")
+	api.HandleFunc("/event/{id:[0-9]+}/attendees", s.InviteHandler).Methods("POST")
+	api.HandleFunc("/event/{id:[0-9]+}/rsvp", s.RespondHandler).Methods("PUT")
+	api.HandleFunc("/event/{id:[0-9]+}/history", s.HistoryHandler).Methods("GET")
+	api.HandleFunc("/invitations", s.FindInvitationsHandler).Methods("GET")
+	api.HandleFunc("/free-slots", s.FindFreeSlotsHandler).Methods("GET")
+	api.HandleFunc("/events/search", s.SearchHandler).Methods("GET")
+	api.HandleFunc("/events/{period:day|week|month}", s.FindForPeriodHandler).Methods("GET")
+	api.HandleFunc("/events/export", s.ExportHandler).Methods("GET")
+	api.HandleFunc("/events/watch", s.WatchHandler).Methods("GET")
+	api.HandleFunc("/events/import", s.ImportHandler).Methods("POST")
+	api.HandleFunc("/events/batch", s.BatchHandler).Methods("POST")
+	api.HandleFunc("/trash", s.FindDeletedHandler).Methods("GET")
+	api.HandleFunc("/trash/{id:[0-9]+}/restore", s.RestoreHandler).Methods("POST")
+	api.HandleFunc("/trash/{id:[0-9]+}", s.PurgeHandler).Methods("DELETE")
 
 	return router
 }
@@ -1,11 +1,16 @@
 package httpserver
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -15,28 +20,79 @@ import (
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 )
 
-const internalError = "internal server error"
+const (
+	internalError   = "internal server error"
+	timeOfDayLayout = "15:04"
+)
 
 type response struct {
 	Error *string     `json:"error"`
 	Data  interface{} `json:"data"`
+	// NextPageToken is set by paginated endpoints until the last page.
+	NextPageToken string `json:"nextPageToken,omitempty"`
+}
+
+type credentialsRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type authResponse struct {
+	UserID int64  `json:"userId"`
+	Token  string `json:"token"`
 }
 
 type createEventRequest struct {
-	UserID      int64  `json:"userId"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	TimeStart   string `json:"timeStart"`
-	TimeEnd     string `json:"timeEnd"`
-	Notify      string `json:"notify"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	TimeStart   string   `json:"timeStart"`
+	TimeEnd     string   `json:"timeEnd"`
+	RRule       string   `json:"rrule"`
+	ExDates     []string `json:"exDates"`
+	TimeZone    string   `json:"timeZone"`
+	NonBlocking bool     `json:"nonBlocking"`
+
+	Reminders []*reminderRequest `json:"reminders"`
 }
 
 type updateEventRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	TimeStart   string `json:"timeStart"`
-	TimeEnd     string `json:"timeEnd"`
-	Notify      string `json:"notify"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	TimeStart   string   `json:"timeStart"`
+	TimeEnd     string   `json:"timeEnd"`
+	RRule       string   `json:"rrule"`
+	ExDates     []string `json:"exDates"`
+	TimeZone    string   `json:"timeZone"`
+	NonBlocking bool     `json:"nonBlocking"`
+
+	Reminders []*reminderRequest `json:"reminders"`
+}
+
+type updateOccurrenceRequest struct {
+	updateEventRequest
+	Occurrence string `json:"occurrence"`
+}
+
+// reminderRequest is a reminder of the event, before is a duration like 15m.
+type reminderRequest struct {
+	Channel string `json:"channel"`
+	Before  string `json:"before"`
+}
+
+type inviteRequest struct {
+	UserID int64  `json:"userId"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+type respondRequest struct {
+	Status string `json:"status"`
+}
+
+// settingsRequest is also the response of the settings endpoints, weekStart 0 is Sunday.
+type settingsRequest struct {
+	TimeZone  string `json:"timeZone"`
+	WeekStart int    `json:"weekStart"`
 }
 
 type createEventResponse struct {
@@ -44,41 +100,151 @@ type createEventResponse struct {
 }
 
 type eventResponse struct {
-	ID               int64   `json:"id"`
-	UserID           int64   `json:"userId"`
-	Title            string  `json:"title"`
-	Description      string  `json:"description"`
-	TimeStart        string  `json:"timeStart"`
-	TimeEnd          string  `json:"timeEnd"`
-	NotifyAt         *string `json:"notifyAt"`
-	CreatedAt        string  `json:"createdAt"`
-	UpdatedAt        string  `json:"updatedAt"`
-	NotificationSent bool    `json:"notificationSent"`
+	ID              int64    `json:"id"`
+	UserID          int64    `json:"userId"`
+	Title           string   `json:"title"`
+	Description     string   `json:"description"`
+	TimeStart       string   `json:"timeStart"`
+	TimeEnd         string   `json:"timeEnd"`
+	CreatedAt       string   `json:"createdAt"`
+	UpdatedAt       string   `json:"updatedAt"`
+	RRule           string   `json:"rrule"`
+	ExDates         []string `json:"exDates"`
+	RecurrenceUntil *string  `json:"recurrenceUntil"`
+	SeriesID        *int64   `json:"seriesId"`
+	RecurrenceID    *string  `json:"recurrenceId"`
+	TimeZone        string   `json:"timeZone"`
+	Version         int64    `json:"version"`
+	NonBlocking     bool     `json:"nonBlocking"`
+	DeletedAt       *string  `json:"deletedAt"`
+
+	Reminders []*reminderResponse `json:"reminders"`
+	Attendees []*attendeeResponse `json:"attendees,omitempty"`
+}
+
+type reminderResponse struct {
+	ID       int64  `json:"id"`
+	Channel  string `json:"channel"`
+	Before   string `json:"before"`
+	NotifyAt string `json:"notifyAt"`
+	Sent     bool   `json:"sent"`
+}
+
+type historyEntryResponse struct {
+	ID        int64                  `json:"id"`
+	EventID   int64                  `json:"eventId"`
+	UserID    int64                  `json:"userId"`
+	Action    string                 `json:"action"`
+	Changes   []*fieldChangeResponse `json:"changes"`
+	CreatedAt string                 `json:"createdAt"`
+}
+
+type fieldChangeResponse struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type attendeeResponse struct {
+	ID        int64  `json:"id"`
+	EventID   int64  `json:"eventId"`
+	UserID    *int64 `json:"userId"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type timeSlotResponse struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type invitationResponse struct {
+	Event    *eventResponse    `json:"event"`
+	Attendee *attendeeResponse `json:"attendee"`
+}
+
+type importResponse struct {
+	Created []int64                  `json:"created"`
+	Failed  []*importFailureResponse `json:"failed"`
+}
+
+type importFailureResponse struct {
+	Index  int      `json:"index"`
+	UID    string   `json:"uid"`
+	Errors []string `json:"errors"`
+}
+
+// batchRequest changes many events at once, atomic batches are applied only when every operation is valid.
+type batchRequest struct {
+	Atomic     bool                     `json:"atomic"`
+	Operations []*batchOperationRequest `json:"operations"`
+}
+
+// batchOperationRequest is a create, update or delete operation, event is not required for the delete.
+type batchOperationRequest struct {
+	Operation string              `json:"operation"`
+	ID        int64               `json:"id"`
+	Version   int64               `json:"version"`
+	Event     *updateEventRequest `json:"event"`
+}
+
+type batchResponse struct {
+	Applied bool                   `json:"applied"`
+	Results []*batchResultResponse `json:"results"`
+}
+
+type batchResultResponse struct {
+	Index   int      `json:"index"`
+	ID      int64    `json:"id"`
+	Version int64    `json:"version"`
+	Errors  []string `json:"errors"`
 }
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
+// watchKeepAlive is the interval of the comments sent to the idle event stream, so proxies do not close it.
+const watchKeepAlive = 15 * time.Second
+
 type calendarAPI struct {
 	timeLayout string
 	events     app.EventsUseCase
+	ical       app.ICalendarUseCase
+	users      app.UsersUseCase
 	log        logger.Logger
 	timeout    time.Duration
+	// shutdown is closed when the server shuts down, so the streaming handlers return
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 func newCalendarService(
 	events app.EventsUseCase,
+	ical app.ICalendarUseCase,
+	users app.UsersUseCase,
 	log logger.Logger,
 	timeout time.Duration,
 	timeLayout string,
 ) *calendarAPI {
 	return &calendarAPI{
 		events:     events,
+		ical:       ical,
+		users:      users,
 		log:        log,
 		timeout:    timeout,
 		timeLayout: timeLayout,
+		shutdown:   make(chan struct{}),
 	}
 }
 
+func (s *calendarAPI) stopStreaming() {
+	s.shutdownOnce.Do(func() {
+		close(s.shutdown)
+	})
+}
+
 func (s *calendarAPI) GetByIDHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
 	defer cancel()
@@ -91,7 +257,7 @@ func (s *calendarAPI) GetByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	e, err := s.events.GetByID(ctx, int64(id))
+	e, err := s.events.GetByID(ctx, userIDFromContext(ctx), int64(id))
 	if err != nil {
 		if errors.Is(err, app.ErrEventIsNotExists) {
 			s.writeErrorResponse(w, "not found", http.StatusNotFound)
@@ -103,10 +269,8 @@ func (s *calendarAPI) GetByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeResponse(w, &response{
-		nil,
-		s.storageEventToResponse(e),
-	}, http.StatusOK)
+	w.Header().Set("ETag", eventETag(e.Version))
+	s.writeResponse(w, &response{Data: s.storageEventToResponse(e)}, http.StatusOK)
 }
 
 func (s *calendarAPI) CreateHandler(w http.ResponseWriter, r *http.Request) {
@@ -114,7 +278,7 @@ func (s *calendarAPI) CreateHandler(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	rq := &createEventRequest{}
-	if err := json.NewDecoder(r.Body).Decode(rq); err != nil {
+	if err := decodeRequest(r.Body, rq); err != nil {
 		s.logErrorf("http event create: decode request: %s", err.Error())
 		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
 		return
@@ -126,6 +290,7 @@ func (s *calendarAPI) CreateHandler(w http.ResponseWriter, r *http.Request) {
 		s.writeErrorResponse(w, "invalid request", http.StatusBadRequest)
 		return
 	}
+	dto.UserID = userIDFromContext(ctx)
 
 	id, err := s.events.Create(ctx, *dto)
 	if err != nil {
@@ -140,7 +305,7 @@ func (s *calendarAPI) CreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeResponse(w, &response{nil, createEventResponse{id}}, http.StatusCreated)
+	s.writeResponse(w, &response{Data: createEventResponse{id}}, http.StatusCreated)
 }
 
 func (s *calendarAPI) UpdateHandler(w http.ResponseWriter, r *http.Request) {
@@ -156,7 +321,7 @@ func (s *calendarAPI) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rq := &updateEventRequest{}
-	if err := json.NewDecoder(r.Body).Decode(rq); err != nil {
+	if err := decodeRequest(r.Body, rq); err != nil {
 		s.logErrorf("http event update: decode request: %s", err.Error())
 		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
 		return
@@ -168,8 +333,35 @@ func (s *calendarAPI) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		s.writeErrorResponse(w, "invalid request", http.StatusBadRequest)
 		return
 	}
+	dto.UserID = userIDFromContext(ctx)
+
+	// the update without the precondition is still rejected when the event is changed while it is saved
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" {
+		version, ok := parseIfMatch(ifMatch)
+		if !ok {
+			s.writeErrorResponse(w, "event is modified", http.StatusPreconditionFailed)
+			return
+		}
+		dto.Version = version
+	}
+
+	version, err := s.events.Update(ctx, int64(id), *dto)
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			s.writeErrorResponse(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, app.ErrEventIsModified) {
+			code := http.StatusConflict
+			if ifMatch != "" {
+				code = http.StatusPreconditionFailed
+			}
+			s.writeErrorResponse(w, "event is modified", code)
+			return
+		}
 
-	if err := s.events.Update(ctx, int64(id), *dto); err != nil {
 		var v *app.ValidationErrors
 		if errors.As(err, &v) {
 			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
@@ -181,6 +373,7 @@ func (s *calendarAPI) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", eventETag(version))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -196,7 +389,12 @@ func (s *calendarAPI) DeleteEventHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := s.events.Delete(ctx, int64(id)); err != nil {
+	if err := s.events.Delete(ctx, userIDFromContext(ctx), int64(id)); err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			s.writeErrorResponse(w, "not found", http.StatusNotFound)
+			return
+		}
+
 		s.logErrorf("http event delete: events use case: %s", err.Error())
 		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
 		return
@@ -205,184 +403,1238 @@ func (s *calendarAPI) DeleteEventHandler(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *calendarAPI) FindForPeriodHandler(w http.ResponseWriter, r *http.Request) {
+func (s *calendarAPI) FindDeletedHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
 	defer cancel()
 	var err error
 
-	dto := app.FindByDateDTO{}
+	dto := app.TrashDTO{UserID: userIDFromContext(ctx)}
 	q := r.URL.Query()
 
-	u, ok := q["userId"]
-	if !ok {
-		s.writeErrorResponse(w, "`userId` is required", http.StatusBadRequest)
-		return
+	if l, ok := q["limit"]; ok {
+		dto.Limit, err = strconv.Atoi(l[0])
+		if err != nil {
+			s.writeErrorResponse(w, "`limit` must be numeric", http.StatusBadRequest)
+			return
+		}
 	}
-	userID, err := strconv.Atoi(u[0])
+	dto.PageToken = q.Get("pageToken")
+
+	page, err := s.events.FindDeleted(ctx, dto)
 	if err != nil {
-		s.writeErrorResponse(w, "`userId` must be numeric", http.StatusBadRequest)
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http find deleted: event use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
 		return
 	}
-	dto.UserID = int64(userID)
 
-	d, ok := q["date"]
-	if !ok {
-		s.writeErrorResponse(w, "`date` is required", http.StatusBadRequest)
-		return
+	rspEvents := make([]*eventResponse, 0, len(page.Events))
+	for _, e := range page.Events {
+		rspEvents = append(rspEvents, s.storageEventToResponse(e))
 	}
-	dto.Date, err = time.Parse(s.timeLayout, d[0])
+	s.writeResponse(w, &response{Data: rspEvents, NextPageToken: page.NextPageToken}, http.StatusOK)
+}
+
+func (s *calendarAPI) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		s.writeErrorResponse(w, "`date` must has layout "+s.timeLayout, http.StatusBadRequest)
+		s.logErrorf("http event restore: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	l, ok := q["limit"]
-	if !ok {
-		dto.Limit = 50
-	} else {
-		limit, err := strconv.Atoi(l[0])
-		if err != nil {
-			s.writeErrorResponse(w, "`limit` must be numeric", http.StatusBadRequest)
+	if err := s.events.Restore(ctx, userIDFromContext(ctx), int64(id)); err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			s.writeErrorResponse(w, "not found", http.StatusNotFound)
 			return
 		}
-		dto.Limit = uint8(limit)
-	}
 
-	o, ok := q["offset"]
-	if !ok {
-		dto.Offset = 0
-	} else {
-		offset, err := strconv.Atoi(o[0])
-		if err != nil {
-			s.writeErrorResponse(w, "`offset` must be numeric", http.StatusBadRequest)
+		if errors.Is(err, app.ErrSeriesIsDeleted) {
+			s.writeErrorResponse(w, app.ErrSeriesIsDeleted.Error(), http.StatusConflict)
 			return
 		}
-		dto.Offset = uint8(offset)
-	}
 
-	vars := mux.Vars(r)
-	period := vars["period"]
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 
-	var events []*storage.Event
-	switch period {
-	case "day":
-		events, err = s.events.FindForDay(ctx, dto)
-	case "week":
-		events, err = s.events.FindForWeek(ctx, dto)
-	case "month":
-		events, err = s.events.FindForMonth(ctx, dto)
-	default:
-		s.writeErrorResponse(w, "unknown period", http.StatusNotFound)
+		s.logErrorf("http event restore: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *calendarAPI) PurgeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		s.logErrorf("http find for %s: event use case: %v", period, err.Error())
-		s.writeErrorResponse(w, internalError, http.StatusBadRequest)
+		s.logErrorf("http event purge: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	rspEvents := make([]*eventResponse, 0, len(events))
-	for _, e := range events {
-		rspEvents = append(rspEvents, s.storageEventToResponse(e))
+	if err := s.events.Purge(ctx, userIDFromContext(ctx), int64(id)); err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			s.writeErrorResponse(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		s.logErrorf("http event purge: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
 	}
-	s.writeResponse(w, &response{nil, rspEvents}, http.StatusOK)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *calendarAPI) writeResponse(w http.ResponseWriter, rsp *response, statusCode int) {
-	w.WriteHeader(statusCode)
-	w.Header().Add("Content-Type", "application/json")
-	body, err := json.Marshal(rsp)
+func (s *calendarAPI) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		s.logErrorf("marshal response: %s", err.Error())
+		s.logErrorf("http event history: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	if _, err := w.Write(body); err != nil {
-		s.logErrorf("writer response: %s", err.Error())
+	history, err := s.events.FindHistory(ctx, userIDFromContext(ctx), int64(id))
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			s.writeErrorResponse(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		s.logErrorf("http event history: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
 		return
 	}
-}
 
-func (s *calendarAPI) writeErrorResponse(w http.ResponseWriter, msg string, statusCode int) {
-	rsp := response{&msg, nil}
-	s.writeResponse(w, &rsp, statusCode)
+	rsp := make([]*historyEntryResponse, 0, len(history))
+	for _, h := range history {
+		rsp = append(rsp, s.historyEntryToResponse(h))
+	}
+	s.writeResponse(w, &response{Data: rsp}, http.StatusOK)
 }
 
-func (s *calendarAPI) logErrorf(format string, a ...interface{}) {
-	s.log.Error(fmt.Sprintf(format, a...),
-		"context", "http",
-	)
-}
+// WatchHandler streams the changes of the events as server-sent events. The id of the sent event
+// is the id of the change, so the reconnecting client resumes with the Last-Event-ID header
+// or with the `after` query parameter.
+func (s *calendarAPI) WatchHandler(w http.ResponseWriter, r *http.Request) {
+	// the stream is not limited with the request timeout
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 
-func (s *calendarAPI) storageEventToResponse(e *storage.Event) *eventResponse {
-	var notify *string
-	if e.NotifyAt.Valid {
-		t := e.NotifyAt.Time.Format(s.timeLayout)
-		notify = &t
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.logErrorf("http watch events: response writer is not a flusher")
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
 	}
 
-	return &eventResponse{
-		ID:               e.ID,
-		UserID:           e.UserID,
-		Title:            e.Title,
-		Description:      e.Description,
-		TimeStart:        e.TimeStart.Format(s.timeLayout),
-		TimeEnd:          e.TimeEnd.Format(s.timeLayout),
-		NotifyAt:         notify,
-		CreatedAt:        e.CreatedAt.Format(s.timeLayout),
-		UpdatedAt:        e.CreatedAt.Format(s.timeLayout),
-		NotificationSent: e.NotificationSent,
+	var afterID int64
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("after")
+	}
+	if lastID != "" {
+		var err error
+		if afterID, err = strconv.ParseInt(lastID, 10, 64); err != nil {
+			s.writeErrorResponse(w, "last event id must be numeric", http.StatusBadRequest)
+			return
+		}
 	}
-}
 
-func (s *calendarAPI) createRequestToDTO(r *createEventRequest) (*app.CreateDTO, error) {
-	ts, err := time.Parse(s.timeLayout, r.TimeStart)
+	c, err := s.events.Watch(ctx, userIDFromContext(ctx), afterID)
 	if err != nil {
-		return nil, fmt.Errorf("parse time start: %w", err)
+		if errors.Is(err, app.ErrTooManyChanges) {
+			s.writeErrorResponse(w, app.ErrTooManyChanges.Error(), http.StatusGone)
+			return
+		}
+
+		s.logErrorf("http watch events: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
 	}
 
-	te, err := time.Parse(s.timeLayout, r.TimeEnd)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case h, ok := <-c:
+			// the watcher fell behind, the client reconnects and resumes from the last received change
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(s.historyEntryToResponse(h))
+			if err != nil {
+				s.logErrorf("http watch events: marshal change: %s", err.Error())
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", h.ID, h.Action, data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-s.shutdown:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (s *calendarAPI) UpdateOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		return nil, fmt.Errorf("parse time end: %w", err)
+		s.logErrorf("http event update occurrence: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	rq := &updateOccurrenceRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http event update occurrence: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
 	}
 
-	notify, err := time.ParseDuration(r.Notify)
+	occurrence, err := time.Parse(s.timeLayout, rq.Occurrence)
 	if err != nil {
-		return nil, fmt.Errorf("parse notify: %w", err)
+		s.writeErrorResponse(w, "`occurrence` must has layout "+s.timeLayout, http.StatusBadRequest)
+		return
 	}
 
-	return &app.CreateDTO{
-		UserID:      r.UserID,
-		Title:       r.Title,
-		Description: r.Description,
-		TimeStart:   ts,
-		TimeEnd:     te,
-		Notify:      notify,
-	}, nil
-}
+	dto, err := s.updateRequestToDTO(&rq.updateEventRequest)
+	if err != nil {
+		s.logErrorf("http event update occurrence: %s", err.Error())
+		s.writeErrorResponse(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	dto.UserID = userIDFromContext(ctx)
 
-func (s *calendarAPI) updateRequestToDTO(r *updateEventRequest) (*app.UpdateDTO, error) {
-	ts, err := time.Parse(s.timeLayout, r.TimeStart)
+	exceptionID, err := s.events.UpdateOccurrence(ctx, int64(id), occurrence, *dto)
 	if err != nil {
-		return nil, fmt.Errorf("parse time start: %w", err)
+		if isOccurrenceNotFound(err) {
+			s.writeErrorResponse(w, "occurrence not found", http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, app.ErrEventIsModified) {
+			s.writeErrorResponse(w, "event is modified", http.StatusConflict)
+			return
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http event update occurrence: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
 	}
 
-	te, err := time.Parse(s.timeLayout, r.TimeEnd)
+	s.writeResponse(w, &response{Data: createEventResponse{exceptionID}}, http.StatusCreated)
+}
+
+func (s *calendarAPI) DeleteOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		return nil, fmt.Errorf("parse time end: %w", err)
+		s.logErrorf("http event delete occurrence: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
+		return
 	}
 
-	notify, err := time.ParseDuration(r.Notify)
+	o, ok := r.URL.Query()["occurrence"]
+	if !ok {
+		s.writeErrorResponse(w, "`occurrence` is required", http.StatusBadRequest)
+		return
+	}
+	occurrence, err := time.Parse(s.timeLayout, o[0])
 	if err != nil {
-		return nil, fmt.Errorf("parse notify: %w", err)
+		s.writeErrorResponse(w, "`occurrence` must has layout "+s.timeLayout, http.StatusBadRequest)
+		return
 	}
 
-	return &app.UpdateDTO{
-		Title:       r.Title,
-		Description: r.Description,
-		TimeStart:   ts,
-		TimeEnd:     te,
-		Notify:      notify,
-	}, nil
+	if err := s.events.DeleteOccurrence(ctx, userIDFromContext(ctx), int64(id), occurrence); err != nil {
+		if isOccurrenceNotFound(err) {
+			s.writeErrorResponse(w, "occurrence not found", http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, app.ErrEventIsModified) {
+			s.writeErrorResponse(w, "event is modified", http.StatusConflict)
+			return
+		}
+
+		s.logErrorf("http event delete occurrence: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isOccurrenceNotFound(err error) bool {
+	return errors.Is(err, app.ErrEventIsNotExists) ||
+		errors.Is(err, app.ErrEventIsNotRecurring) ||
+		errors.Is(err, app.ErrOccurrenceIsNotExists)
+}
+
+// eventETag is the strong entity tag of the version of the event.
+func eventETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the version of the If-Match header, the version of "*" is zero and matches any event.
+// The weak tags and the lists are not supported, they never match.
+func parseIfMatch(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, true
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+func (s *calendarAPI) InviteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		s.logErrorf("http event invite: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	rq := &inviteRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http event invite: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
+	}
+
+	attendeeID, err := s.events.Invite(ctx, app.InviteDTO{
+		UserID:         userIDFromContext(ctx),
+		EventID:        int64(id),
+		AttendeeUserID: rq.UserID,
+		Email:          rq.Email,
+		Role:           storage.AttendeeRole(rq.Role),
+	})
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsNotExists) {
+			s.writeErrorResponse(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, app.ErrAttendeeAlreadyInvited) {
+			s.writeErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http event invite: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	s.writeResponse(w, &response{Data: createEventResponse{attendeeID}}, http.StatusCreated)
+}
+
+func (s *calendarAPI) RespondHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		s.logErrorf("http event respond: id is not int: %s", err.Error())
+		s.writeErrorResponse(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	rq := &respondRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http event respond: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.events.Respond(ctx, app.RespondDTO{
+		UserID:  userIDFromContext(ctx),
+		EventID: int64(id),
+		Status:  storage.RSVPStatus(rq.Status),
+	}); err != nil {
+		if errors.Is(err, app.ErrInvitationIsNotExists) {
+			s.writeErrorResponse(w, "invitation not found", http.StatusNotFound)
+			return
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http event respond: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *calendarAPI) FindInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	invitations, err := s.events.FindInvitations(ctx, userIDFromContext(ctx))
+	if err != nil {
+		s.logErrorf("http find invitations: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	rsp := make([]*invitationResponse, 0, len(invitations))
+	for _, i := range invitations {
+		rsp = append(rsp, &invitationResponse{
+			Event:    s.storageEventToResponse(i.Event),
+			Attendee: s.storageAttendeeToResponse(i.Attendee),
+		})
+	}
+	s.writeResponse(w, &response{Data: rsp}, http.StatusOK)
+}
+
+func (s *calendarAPI) FindForPeriodHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	var err error
+
+	dto := app.FindByDateDTO{UserID: userIDFromContext(ctx)}
+	q := r.URL.Query()
+
+	d, ok := q["date"]
+	if !ok {
+		s.writeErrorResponse(w, "`date` is required", http.StatusBadRequest)
+		return
+	}
+	dto.Date, err = time.Parse(s.timeLayout, d[0])
+	if err != nil {
+		s.writeErrorResponse(w, "`date` must has layout "+s.timeLayout, http.StatusBadRequest)
+		return
+	}
+
+	if l, ok := q["limit"]; ok {
+		dto.Limit, err = strconv.Atoi(l[0])
+		if err != nil {
+			s.writeErrorResponse(w, "`limit` must be numeric", http.StatusBadRequest)
+			return
+		}
+	}
+	dto.PageToken = q.Get("pageToken")
+
+	vars := mux.Vars(r)
+	period := vars["period"]
+
+	var page *app.EventPage
+	switch period {
+	case "day":
+		page, err = s.events.FindForDay(ctx, dto)
+	case "week":
+		page, err = s.events.FindForWeek(ctx, dto)
+	case "month":
+		page, err = s.events.FindForMonth(ctx, dto)
+	default:
+		s.writeErrorResponse(w, "unknown period", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http find for %s: event use case: %v", period, err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusBadRequest)
+		return
+	}
+
+	rspEvents := make([]*eventResponse, 0, len(page.Events))
+	for _, e := range page.Events {
+		rspEvents = append(rspEvents, s.storageEventToResponse(e))
+	}
+	s.writeResponse(w, &response{Data: rspEvents, NextPageToken: page.NextPageToken}, http.StatusOK)
+}
+
+func (s *calendarAPI) FindFreeSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	dto, err := s.queryToFreeSlotsDTO(r.URL.Query())
+	if err != nil {
+		s.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(dto.UserIDs) == 0 {
+		dto.UserIDs = []int64{userIDFromContext(ctx)}
+	}
+	// working hours are in the zone of the requester by default
+	if dto.TimeZone == "" {
+		settings, err := s.users.GetSettings(ctx, userIDFromContext(ctx))
+		if err != nil {
+			s.logErrorf("http find free slots: users use case: %s", err.Error())
+			s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		dto.TimeZone = settings.TimeZone
+	}
+
+	slots, err := s.events.FindFreeSlots(ctx, *dto)
+	if err != nil {
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http find free slots: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	rsp := make([]*timeSlotResponse, 0, len(slots))
+	for _, slot := range slots {
+		rsp = append(rsp, &timeSlotResponse{
+			Start: slot.Start.Format(s.timeLayout),
+			End:   slot.End.Format(s.timeLayout),
+		})
+	}
+	s.writeResponse(w, &response{Data: rsp}, http.StatusOK)
+}
+
+func (s *calendarAPI) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	dto, err := s.queryToSearchDTO(r.URL.Query())
+	if err != nil {
+		s.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dto.UserID = userIDFromContext(ctx)
+
+	page, err := s.events.Search(ctx, *dto)
+	if err != nil {
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http events search: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	rspEvents := make([]*eventResponse, 0, len(page.Events))
+	for _, e := range page.Events {
+		rspEvents = append(rspEvents, s.storageEventToResponse(e))
+	}
+	s.writeResponse(w, &response{Data: rspEvents, NextPageToken: page.NextPageToken}, http.StatusOK)
+}
+
+func (s *calendarAPI) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	var err error
+
+	dto := app.ExportDTO{UserID: userIDFromContext(ctx)}
+	q := r.URL.Query()
+
+	f, ok := q["from"]
+	if !ok {
+		s.writeErrorResponse(w, "`from` is required", http.StatusBadRequest)
+		return
+	}
+	dto.From, err = time.Parse(s.timeLayout, f[0])
+	if err != nil {
+		s.writeErrorResponse(w, "`from` must has layout "+s.timeLayout, http.StatusBadRequest)
+		return
+	}
+
+	t, ok := q["to"]
+	if !ok {
+		s.writeErrorResponse(w, "`to` is required", http.StatusBadRequest)
+		return
+	}
+	dto.To, err = time.Parse(s.timeLayout, t[0])
+	if err != nil {
+		s.writeErrorResponse(w, "`to` must has layout "+s.timeLayout, http.StatusBadRequest)
+		return
+	}
+
+	body := &bytes.Buffer{}
+	if err := s.ical.Export(ctx, dto, body); err != nil {
+		s.logErrorf("http events export: icalendar use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		s.logErrorf("http events export: write response: %s", err.Error())
+	}
+}
+
+// ImportHandler accepts a .ics file either as the request body or as the `file` field of a multipart form.
+func (s *calendarAPI) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			s.writeErrorResponse(w, "`file` is required", http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	result, err := s.ical.Import(ctx, userIDFromContext(ctx), body)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidCalendar) {
+			s.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.logErrorf("http events import: icalendar use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	rsp := &importResponse{
+		Created: result.Created,
+		Failed:  make([]*importFailureResponse, 0, len(result.Failed)),
+	}
+	for _, f := range result.Failed {
+		errs := make([]string, 0, len(f.Err.Errors()))
+		for _, e := range f.Err.Errors() {
+			errs = append(errs, e.Error())
+		}
+
+		rsp.Failed = append(rsp.Failed, &importFailureResponse{
+			Index:  f.Index,
+			UID:    f.UID,
+			Errors: errs,
+		})
+	}
+
+	s.writeResponse(w, &response{Data: rsp}, http.StatusOK)
+}
+
+// BatchHandler responds with 422 when the atomic batch is rejected, the results report the failed operations.
+func (s *calendarAPI) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	rq := &batchRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http events batch: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
+	}
+
+	dto := app.BatchDTO{
+		UserID:     userIDFromContext(ctx),
+		Atomic:     rq.Atomic,
+		Operations: make([]app.BatchOperationDTO, 0, len(rq.Operations)),
+	}
+	for i, op := range rq.Operations {
+		opDTO, err := s.batchOperationRequestToDTO(op)
+		if err != nil {
+			s.logErrorf("http events batch: operation %d: %s", i, err.Error())
+			s.writeErrorResponse(w, fmt.Sprintf("invalid operation %d", i), http.StatusBadRequest)
+			return
+		}
+		dto.Operations = append(dto.Operations, *opDTO)
+	}
+
+	result, err := s.events.Batch(ctx, dto)
+	if err != nil {
+		if errors.Is(err, app.ErrEventIsModified) {
+			s.writeErrorResponse(w, "event is modified", http.StatusConflict)
+			return
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http events batch: events use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	rsp := &batchResponse{
+		Applied: result.Applied,
+		Results: make([]*batchResultResponse, 0, len(result.Items)),
+	}
+	for i, item := range result.Items {
+		errs := make([]string, 0)
+		if item.Err != nil {
+			for _, e := range item.Err.Errors() {
+				errs = append(errs, e.Error())
+			}
+		}
+
+		rsp.Results = append(rsp.Results, &batchResultResponse{
+			Index:   i,
+			ID:      item.ID,
+			Version: item.Version,
+			Errors:  errs,
+		})
+	}
+
+	if !result.Applied {
+		msg := "batch is not applied"
+		s.writeResponse(w, &response{Error: &msg, Data: rsp}, http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.writeResponse(w, &response{Data: rsp}, http.StatusOK)
+}
+
+func (s *calendarAPI) batchOperationRequestToDTO(r *batchOperationRequest) (*app.BatchOperationDTO, error) {
+	if r == nil {
+		return nil, errors.New("operation is null")
+	}
+
+	dto := &app.BatchOperationDTO{
+		Operation: app.BatchOperation(r.Operation),
+		ID:        r.ID,
+	}
+	if r.Event == nil {
+		if dto.Operation != app.BatchDelete {
+			return nil, errors.New("event is required")
+		}
+
+		return dto, nil
+	}
+
+	event, err := s.updateRequestToDTO(r.Event)
+	if err != nil {
+		return nil, err
+	}
+	dto.Event = *event
+	dto.Event.Version = r.Version
+
+	return dto, nil
+}
+
+func (s *calendarAPI) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	rq := &credentialsRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http register: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.users.Register(ctx, app.CredentialsDTO{Login: rq.Login, Password: rq.Password})
+	if err != nil {
+		if errors.Is(err, app.ErrLoginIsTaken) {
+			s.writeErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http register: users use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	s.writeResponse(w, &response{Data: authResponse{result.UserID, result.Token}}, http.StatusCreated)
+}
+
+func (s *calendarAPI) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	rq := &credentialsRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http login: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.users.Login(ctx, app.CredentialsDTO{Login: rq.Login, Password: rq.Password})
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidCredentials) {
+			s.writeErrorResponse(w, "invalid login or password", http.StatusUnauthorized)
+			return
+		}
+
+		s.logErrorf("http login: users use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	s.writeResponse(w, &response{Data: authResponse{result.UserID, result.Token}}, http.StatusOK)
+}
+
+func (s *calendarAPI) GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	settings, err := s.users.GetSettings(ctx, userIDFromContext(ctx))
+	if err != nil {
+		s.logErrorf("http get settings: users use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	s.writeResponse(w, &response{Data: settingsToResponse(settings)}, http.StatusOK)
+}
+
+func (s *calendarAPI) UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	rq := &settingsRequest{}
+	if err := decodeRequest(r.Body, rq); err != nil {
+		s.logErrorf("http update settings: decode request: %s", err.Error())
+		s.writeErrorResponse(w, "malformed json", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := s.users.UpdateSettings(ctx, app.SettingsDTO{
+		UserID:    userIDFromContext(ctx),
+		TimeZone:  rq.TimeZone,
+		WeekStart: time.Weekday(rq.WeekStart),
+	})
+	if err != nil {
+		var v *app.ValidationErrors
+		if errors.As(err, &v) {
+			s.writeErrorResponse(w, v.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.logErrorf("http update settings: users use case: %s", err.Error())
+		s.writeErrorResponse(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	s.writeResponse(w, &response{Data: settingsToResponse(settings)}, http.StatusOK)
+}
+
+// decodeRequest decodes the json body of the request, the data left after the json value is malformed too.
+func decodeRequest(body io.Reader, rq interface{}) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+
+	return json.Unmarshal(data, rq)
+}
+
+func (s *calendarAPI) writeResponse(w http.ResponseWriter, rsp *response, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	body, err := json.Marshal(rsp)
+	if err != nil {
+		s.logErrorf("marshal response: %s", err.Error())
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		s.logErrorf("writer response: %s", err.Error())
+		return
+	}
+}
+
+func (s *calendarAPI) writeErrorResponse(w http.ResponseWriter, msg string, statusCode int) {
+	rsp := response{Error: &msg}
+	s.writeResponse(w, &rsp, statusCode)
+}
+
+func (s *calendarAPI) logErrorf(format string, a ...interface{}) {
+	s.log.Error(fmt.Sprintf(format, a...),
+		"context", "http",
+	)
+}
+
+func (s *calendarAPI) historyEntryToResponse(h *storage.HistoryEntry) *historyEntryResponse {
+	changes := make([]*fieldChangeResponse, 0, len(h.Changes))
+	for _, c := range h.Changes {
+		changes = append(changes, &fieldChangeResponse{Field: c.Field, Old: c.Old, New: c.New})
+	}
+
+	return &historyEntryResponse{
+		ID:        h.ID,
+		EventID:   h.EventID,
+		UserID:    h.UserID,
+		Action:    string(h.Action),
+		Changes:   changes,
+		CreatedAt: h.CreatedAt.Format(s.timeLayout),
+	}
+}
+
+func (s *calendarAPI) storageEventToResponse(e *storage.Event) *eventResponse {
+	exDates := make([]string, 0, len(e.ExDates))
+	for _, t := range e.ExDates {
+		exDates = append(exDates, t.Format(s.timeLayout))
+	}
+
+	var until *string
+	if e.RecurrenceUntil.Valid {
+		t := e.RecurrenceUntil.Time.Format(s.timeLayout)
+		until = &t
+	}
+
+	var seriesID *int64
+	if e.SeriesID.Valid {
+		seriesID = &e.SeriesID.Int64
+	}
+
+	var recurrenceID *string
+	if e.RecurrenceID.Valid {
+		t := e.RecurrenceID.Time.Format(s.timeLayout)
+		recurrenceID = &t
+	}
+
+	var deletedAt *string
+	if e.DeletedAt.Valid {
+		t := e.DeletedAt.Time.Format(s.timeLayout)
+		deletedAt = &t
+	}
+
+	reminders := make([]*reminderResponse, 0, len(e.Reminders))
+	for _, r := range e.Reminders {
+		reminders = append(reminders, &reminderResponse{
+			ID:       r.ID,
+			Channel:  string(r.Channel),
+			Before:   r.Before.String(),
+			NotifyAt: r.NotifyAt.Format(s.timeLayout),
+			Sent:     r.Sent,
+		})
+	}
+
+	var attendees []*attendeeResponse
+	for _, a := range e.Attendees {
+		attendees = append(attendees, s.storageAttendeeToResponse(a))
+	}
+
+	return &eventResponse{
+		ID:              e.ID,
+		UserID:          e.UserID,
+		Title:           e.Title,
+		Description:     e.Description,
+		TimeStart:       e.TimeStart.Format(s.timeLayout),
+		TimeEnd:         e.TimeEnd.Format(s.timeLayout),
+		CreatedAt:       e.CreatedAt.Format(s.timeLayout),
+		UpdatedAt:       e.CreatedAt.Format(s.timeLayout),
+		RRule:           e.RRule,
+		ExDates:         exDates,
+		RecurrenceUntil: until,
+		SeriesID:        seriesID,
+		RecurrenceID:    recurrenceID,
+		TimeZone:        e.TimeZone,
+		Version:         e.Version,
+		NonBlocking:     e.NonBlocking,
+		DeletedAt:       deletedAt,
+		Reminders:       reminders,
+		Attendees:       attendees,
+	}
+}
+
+func (s *calendarAPI) storageAttendeeToResponse(a *storage.Attendee) *attendeeResponse {
+	var userID *int64
+	if a.UserID.Valid {
+		userID = &a.UserID.Int64
+	}
+
+	return &attendeeResponse{
+		ID:        a.ID,
+		EventID:   a.EventID,
+		UserID:    userID,
+		Email:     a.Email,
+		Role:      string(a.Role),
+		Status:    string(a.Status),
+		CreatedAt: a.CreatedAt.Format(s.timeLayout),
+		UpdatedAt: a.UpdatedAt.Format(s.timeLayout),
+	}
+}
+
+func settingsToResponse(settings *app.SettingsDTO) *settingsRequest {
+	return &settingsRequest{TimeZone: settings.TimeZone, WeekStart: int(settings.WeekStart)}
+}
+
+func (s *calendarAPI) createRequestToDTO(r *createEventRequest) (*app.CreateDTO, error) {
+	ts, err := time.Parse(s.timeLayout, r.TimeStart)
+	if err != nil {
+		return nil, fmt.Errorf("parse time start: %w", err)
+	}
+
+	te, err := time.Parse(s.timeLayout, r.TimeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("parse time end: %w", err)
+	}
+
+	reminders, err := parseReminders(r.Reminders)
+	if err != nil {
+		return nil, fmt.Errorf("parse reminders: %w", err)
+	}
+
+	exDates, err := s.parseTimes(r.ExDates)
+	if err != nil {
+		return nil, fmt.Errorf("parse ex dates: %w", err)
+	}
+
+	return &app.CreateDTO{
+		Title:       r.Title,
+		Description: r.Description,
+		TimeStart:   ts,
+		TimeEnd:     te,
+		Reminders:   reminders,
+		RRule:       r.RRule,
+		ExDates:     exDates,
+		TimeZone:    r.TimeZone,
+		NonBlocking: r.NonBlocking,
+	}, nil
+}
+
+func (s *calendarAPI) updateRequestToDTO(r *updateEventRequest) (*app.UpdateDTO, error) {
+	ts, err := time.Parse(s.timeLayout, r.TimeStart)
+	if err != nil {
+		return nil, fmt.Errorf("parse time start: %w", err)
+	}
+
+	te, err := time.Parse(s.timeLayout, r.TimeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("parse time end: %w", err)
+	}
+
+	reminders, err := parseReminders(r.Reminders)
+	if err != nil {
+		return nil, fmt.Errorf("parse reminders: %w", err)
+	}
+
+	exDates, err := s.parseTimes(r.ExDates)
+	if err != nil {
+		return nil, fmt.Errorf("parse ex dates: %w", err)
+	}
+
+	return &app.UpdateDTO{
+		Title:       r.Title,
+		Description: r.Description,
+		TimeStart:   ts,
+		TimeEnd:     te,
+		Reminders:   reminders,
+		RRule:       r.RRule,
+		ExDates:     exDates,
+		TimeZone:    r.TimeZone,
+		NonBlocking: r.NonBlocking,
+	}, nil
+}
+
+func parseReminders(r []*reminderRequest) ([]app.ReminderDTO, error) {
+	reminders := make([]app.ReminderDTO, 0, len(r))
+	for _, rr := range r {
+		if rr == nil {
+			return nil, errors.New("reminder is null")
+		}
+
+		before, err := time.ParseDuration(rr.Before)
+		if err != nil {
+			return nil, err
+		}
+
+		reminders = append(reminders, app.ReminderDTO{Channel: storage.ReminderChannel(rr.Channel), Before: before})
+	}
+
+	return reminders, nil
+}
+
+// queryToFreeSlotsDTO parses users=1,2&from=&to=&duration=30m&workDayStart=09:00&workDayEnd=18:00&timeZone=,
+// the error is ready to be sent to the client.
+func (s *calendarAPI) queryToFreeSlotsDTO(q url.Values) (*app.FreeSlotsDTO, error) {
+	var err error
+	dto := &app.FreeSlotsDTO{}
+
+	for _, users := range q["users"] {
+		for _, u := range strings.Split(users, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(u), 10, 64)
+			if err != nil {
+				return nil, errors.New("`users` must be a list of ids")
+			}
+
+			dto.UserIDs = append(dto.UserIDs, id)
+		}
+	}
+
+	if dto.From, err = time.Parse(s.timeLayout, q.Get("from")); err != nil {
+		return nil, errors.New("`from` must has layout " + s.timeLayout)
+	}
+
+	if dto.To, err = time.Parse(s.timeLayout, q.Get("to")); err != nil {
+		return nil, errors.New("`to` must has layout " + s.timeLayout)
+	}
+
+	if dto.Duration, err = time.ParseDuration(q.Get("duration")); err != nil {
+		return nil, errors.New("`duration` must be a duration like 30m")
+	}
+
+	if v := q.Get("workDayStart"); v != "" {
+		if dto.WorkDayStart, err = parseTimeOfDay(v); err != nil {
+			return nil, errors.New("`workDayStart` must has layout " + timeOfDayLayout)
+		}
+	}
+
+	if v := q.Get("workDayEnd"); v != "" {
+		if dto.WorkDayEnd, err = parseTimeOfDay(v); err != nil {
+			return nil, errors.New("`workDayEnd` must has layout " + timeOfDayLayout)
+		}
+	}
+	dto.TimeZone = q.Get("timeZone")
+
+	return dto, nil
+}
+
+// queryToSearchDTO parses from=&to=&q=&notification=&createdSince=&updatedSince=&sort=&limit=&pageToken=,
+// the error is ready to be sent to the client.
+func (s *calendarAPI) queryToSearchDTO(q url.Values) (*app.SearchDTO, error) {
+	var err error
+	dto := &app.SearchDTO{
+		Query:        q.Get("q"),
+		Notification: storage.NotificationState(q.Get("notification")),
+		Sort:         storage.SearchSort(q.Get("sort")),
+		PageToken:    q.Get("pageToken"),
+	}
+
+	if dto.From, err = time.Parse(s.timeLayout, q.Get("from")); err != nil {
+		return nil, errors.New("`from` must has layout " + s.timeLayout)
+	}
+
+	if dto.To, err = time.Parse(s.timeLayout, q.Get("to")); err != nil {
+		return nil, errors.New("`to` must has layout " + s.timeLayout)
+	}
+
+	if v := q.Get("createdSince"); v != "" {
+		if dto.CreatedSince, err = time.Parse(s.timeLayout, v); err != nil {
+			return nil, errors.New("`createdSince` must has layout " + s.timeLayout)
+		}
+	}
+
+	if v := q.Get("updatedSince"); v != "" {
+		if dto.UpdatedSince, err = time.Parse(s.timeLayout, v); err != nil {
+			return nil, errors.New("`updatedSince` must has layout " + s.timeLayout)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if dto.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, errors.New("`limit` must be numeric")
+		}
+	}
+
+	return dto, nil
+}
+
+// parseTimeOfDay returns the offset from the midnight, "24:00" is allowed as the end of the day.
+func parseTimeOfDay(v string) (time.Duration, error) {
+	if v == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	t, err := time.Parse(timeOfDayLayout, v)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (s *calendarAPI) parseTimes(values []string) ([]time.Time, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	result := make([]time.Time, 0, len(values))
+	for _, v := range values {
+		t, err := time.Parse(s.timeLayout, v)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, t)
+	}
+
+	return result, nil
 }
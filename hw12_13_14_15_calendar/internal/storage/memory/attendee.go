@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) AddAttendee(_ context.Context, attendee *storage.Attendee) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[attendee.EventID]; !ok {
+		return 0, storage.ErrNotFound
+	}
+
+	for _, a := range s.attendees {
+		if a.EventID != attendee.EventID {
+			continue
+		}
+
+		if (attendee.UserID.Valid && a.UserID == attendee.UserID) ||
+			(attendee.Email != "" && a.Email == attendee.Email) {
+			return 0, storage.ErrAlreadyExists
+		}
+	}
+
+	noww := time.Now()
+	s.attendeeID++
+	attendee.ID = s.attendeeID
+	attendee.CreatedAt = noww
+	attendee.UpdatedAt = noww
+
+	val := *attendee
+	s.attendees[s.attendeeID] = &val
+
+	return s.attendeeID, nil
+}
+
+func (s *EventStorage) UpdateAttendee(_ context.Context, attendee *storage.Attendee) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attendees[attendee.ID]; !ok {
+		return storage.ErrNotFound
+	}
+
+	val := *attendee
+	val.UpdatedAt = time.Now()
+	s.attendees[attendee.ID] = &val
+
+	return nil
+}
+
+func (s *EventStorage) FindAttendees(_ context.Context, eventID int64) ([]*storage.Attendee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*storage.Attendee, 0)
+	for _, a := range s.attendees {
+		if a.EventID == eventID {
+			val := *a
+			result = append(result, &val)
+		}
+	}
+	sortAttendees(result)
+
+	return result, nil
+}
+
+func (s *EventStorage) FindInvitations(_ context.Context, userID int64) ([]*storage.Invitation, error) {
+	return s.findInvitations(func(a *storage.Attendee) bool {
+		return a.UserID.Valid && a.UserID.Int64 == userID
+	}), nil
+}
+
+func (s *EventStorage) FindUnNotifiedAttendees(_ context.Context) ([]*storage.Invitation, error) {
+	return s.findInvitations(func(a *storage.Attendee) bool {
+		return !a.InvitationSent || !a.ResponseSent
+	}), nil
+}
+
+func (s *EventStorage) MarkAttendeesNotified(_ context.Context, ids []int64, outbox []*storage.OutboxMessage) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	noww := time.Now()
+	s.enqueue(outbox, noww)
+	for _, id := range ids {
+		a, ok := s.attendees[id]
+		if !ok {
+			continue
+		}
+
+		a.InvitationSent = true
+		a.ResponseSent = true
+		a.UpdatedAt = noww
+	}
+
+	return nil
+}
+
+func (s *EventStorage) findInvitations(match func(a *storage.Attendee) bool) []*storage.Invitation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attendees := make([]*storage.Attendee, 0)
+	for _, a := range s.attendees {
+		if match(a) {
+			val := *a
+			attendees = append(attendees, &val)
+		}
+	}
+	sortAttendees(attendees)
+
+	result := make([]*storage.Invitation, 0, len(attendees))
+	for _, a := range attendees {
+		e, ok := s.events[a.EventID]
+		if !ok {
+			continue
+		}
+
+		result = append(result, &storage.Invitation{
+			Event:    clone(e),
+			Attendee: a,
+		})
+	}
+
+	return result
+}
+
+func sortAttendees(attendees []*storage.Attendee) {
+	sort.Slice(attendees, func(i, j int) bool {
+		return attendees[i].ID < attendees[j].ID
+	})
+}
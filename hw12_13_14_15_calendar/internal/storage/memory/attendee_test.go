@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStorage_Attendees(t *testing.T) {
+	unit := New()
+
+	eventID, err := unit.Create(ctx, gen(1, "t", "d", testZeroTime), nil)
+	require.NoError(t, err)
+
+	_, err = unit.AddAttendee(ctx, &storage.Attendee{EventID: 42, Email: "a@b.c"})
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	byUser := &storage.Attendee{
+		EventID: eventID,
+		UserID:  storage.AttendeeUserID{Int64: 2, Valid: true},
+		Role:    storage.RoleRequired,
+		Status:  storage.RSVPNeedsAction,
+	}
+	id, err := unit.AddAttendee(ctx, byUser)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), id)
+
+	byEmail := &storage.Attendee{EventID: eventID, Email: "a@b.c", Role: storage.RoleOptional}
+	_, err = unit.AddAttendee(ctx, byEmail)
+	require.NoError(t, err)
+
+	_, err = unit.AddAttendee(ctx, &storage.Attendee{EventID: eventID, UserID: byUser.UserID})
+	require.ErrorIs(t, err, storage.ErrAlreadyExists)
+	_, err = unit.AddAttendee(ctx, &storage.Attendee{EventID: eventID, Email: "a@b.c"})
+	require.ErrorIs(t, err, storage.ErrAlreadyExists)
+
+	attendees, err := unit.FindAttendees(ctx, eventID)
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Attendee{byUser, byEmail}, attendees)
+
+	invitations, err := unit.FindInvitations(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	require.Equal(t, eventID, invitations[0].Event.ID)
+	require.Equal(t, byUser.ID, invitations[0].Attendee.ID)
+
+	unNotified, err := unit.FindUnNotifiedAttendees(ctx)
+	require.NoError(t, err)
+	require.Len(t, unNotified, 2)
+
+	require.NoError(t, unit.MarkAttendeesNotified(ctx, []int64{byUser.ID, byEmail.ID}, nil))
+	unNotified, err = unit.FindUnNotifiedAttendees(ctx)
+	require.NoError(t, err)
+	require.Empty(t, unNotified)
+
+	byUser.Status = storage.RSVPAccepted
+	byUser.InvitationSent = true
+	require.NoError(t, unit.UpdateAttendee(ctx, byUser))
+	unNotified, err = unit.FindUnNotifiedAttendees(ctx)
+	require.NoError(t, err)
+	require.Len(t, unNotified, 1)
+	require.Equal(t, storage.RSVPAccepted, unNotified[0].Attendee.Status)
+
+	require.ErrorIs(t, unit.UpdateAttendee(ctx, &storage.Attendee{ID: 42}), storage.ErrNotFound)
+
+	// the invitations of the deleted event are not sent, the attendees are kept until it is purged
+	require.NoError(t, unit.Delete(ctx, eventID, nil))
+	unNotified, err = unit.FindUnNotifiedAttendees(ctx)
+	require.NoError(t, err)
+	require.Empty(t, unNotified)
+
+	require.NoError(t, unit.Purge(ctx, eventID))
+	attendees, err = unit.FindAttendees(ctx, eventID)
+	require.NoError(t, err)
+	require.Empty(t, attendees)
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ApplyBatch checks the changes against a copy of the events first, so nothing is applied when one of them fails.
+func (s *EventStorage) ApplyBatch(_ context.Context, batch *storage.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make(map[int64]*storage.Event, len(s.events)+len(batch.Create))
+	for id, e := range s.events {
+		events[id] = e
+	}
+
+	for _, id := range batch.Delete {
+		for eventID, e := range events {
+			if eventID == id || (e.SeriesID.Valid && e.SeriesID.Int64 == id) {
+				delete(events, eventID)
+			}
+		}
+	}
+
+	for _, e := range batch.Update {
+		stored, ok := events[e.ID]
+		if !ok || stored.Version != e.Version {
+			return storage.ErrConflict
+		}
+
+		if overlaps(events, e) {
+			return storage.ErrOverlap
+		}
+		events[e.ID] = e
+	}
+
+	// the created events have no ids yet, so they are keyed by negative numbers
+	for i, e := range batch.Create {
+		if overlaps(events, e) {
+			return storage.ErrOverlap
+		}
+		events[-int64(i)-1] = e
+	}
+
+	noww := time.Now()
+	deletedAt := storage.DeletedTime{Time: noww, Valid: true}
+	for i, id := range batch.Delete {
+		if _, ok := s.events[id]; ok && i < len(batch.DeleteHistory) {
+			s.addHistory(batch.DeleteHistory[i], noww)
+		}
+		s.trashEvent(id, deletedAt)
+	}
+
+	for i, e := range batch.Update {
+		val := clone(e)
+		val.UpdatedAt = noww
+		val.Version = e.Version + 1
+		s.events[e.ID] = val
+		s.saveReminders(e, noww)
+		if i < len(batch.UpdateHistory) {
+			s.addHistory(batch.UpdateHistory[i], noww)
+		}
+
+		e.UpdatedAt = noww
+		e.Version = val.Version
+	}
+
+	for i, e := range batch.Create {
+		s.id++
+		e.ID = s.id
+		e.CreatedAt = noww
+		e.UpdatedAt = noww
+		e.Version = 1
+
+		s.events[s.id] = clone(e)
+		s.saveReminders(e, noww)
+		if i < len(batch.CreateHistory) {
+			batch.CreateHistory[i].EventID = e.ID
+			s.addHistory(batch.CreateHistory[i], noww)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStorage_ApplyBatch(t *testing.T) {
+	unit := New()
+
+	deleted := gen(1, "deleted", "", testZeroTime)
+	_, err := unit.Create(ctx, deleted, nil)
+	require.NoError(t, err)
+
+	updated := gen(1, "updated", "", testZeroTime.Add(2*time.Hour))
+	_, err = unit.Create(ctx, updated, nil)
+	require.NoError(t, err)
+
+	t.Run("overlapping events are not applied", func(t *testing.T) {
+		err := unit.ApplyBatch(ctx, &storage.Batch{
+			Create: []*storage.Event{
+				gen(1, "first", "", testZeroTime.Add(4*time.Hour)),
+				gen(1, "second", "", testZeroTime.Add(4*time.Hour+30*time.Minute)),
+			},
+			Delete:        []int64{deleted.ID},
+			DeleteHistory: []*storage.HistoryEntry{{EventID: deleted.ID, UserID: 1, Action: storage.HistoryDeleted}},
+		})
+		require.ErrorIs(t, err, storage.ErrOverlap)
+
+		_, err = unit.GetByID(ctx, deleted.ID)
+		require.NoError(t, err)
+		events, err := unit.FindOverlapping(ctx, 1, testZeroTime.Add(4*time.Hour), testZeroTime.Add(6*time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		// the history is not saved without the changes
+		history, err := unit.FindUserHistory(ctx, 1, 0, 10)
+		require.NoError(t, err)
+		require.Empty(t, history)
+	})
+
+	t.Run("stale update is not applied", func(t *testing.T) {
+		stale := *updated
+		stale.Version = 0
+
+		err := unit.ApplyBatch(ctx, &storage.Batch{
+			Update: []*storage.Event{&stale},
+			Delete: []int64{deleted.ID},
+		})
+		require.ErrorIs(t, err, storage.ErrConflict)
+
+		_, err = unit.GetByID(ctx, deleted.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("success case", func(t *testing.T) {
+		// the updated event takes the time of the deleted one and the created event takes its time
+		moved := *updated
+		moved.TimeStart, moved.TimeEnd = deleted.TimeStart, deleted.TimeEnd
+		created := gen(1, "created", "", updated.TimeStart)
+		created.Reminders = []*storage.Reminder{reminder(created, storage.ChannelLog, time.Minute)}
+
+		history := []*storage.HistoryEntry{
+			{UserID: 1, Action: storage.HistoryCreated},
+			{EventID: updated.ID, UserID: 1, Action: storage.HistoryUpdated},
+			{EventID: deleted.ID, UserID: 1, Action: storage.HistoryDeleted},
+		}
+
+		err := unit.ApplyBatch(ctx, &storage.Batch{
+			Create:        []*storage.Event{created},
+			Update:        []*storage.Event{&moved},
+			Delete:        []int64{deleted.ID},
+			CreateHistory: history[:1],
+			UpdateHistory: history[1:2],
+			DeleteHistory: history[2:],
+		})
+		require.NoError(t, err)
+		require.Equal(t, created.ID, history[0].EventID)
+		require.Equal(t, updated.Version+1, moved.Version)
+		require.NotZero(t, created.ID)
+		require.Equal(t, int64(1), created.Version)
+
+		_, err = unit.GetByID(ctx, deleted.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = unit.GetDeleted(ctx, deleted.ID)
+		require.NoError(t, err)
+
+		found, err := unit.GetByID(ctx, updated.ID)
+		require.NoError(t, err)
+		require.Equal(t, deleted.TimeStart, found.TimeStart)
+
+		found, err = unit.GetByID(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, "created", found.Title)
+
+		reminders, err := unit.FindReminders(ctx, []int64{created.ID})
+		require.NoError(t, err)
+		require.Len(t, reminders, 1)
+
+		saved, err := unit.FindUserHistory(ctx, 1, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, saved, 3)
+		for _, h := range history {
+			require.NotZero(t, h.ID)
+		}
+	})
+}
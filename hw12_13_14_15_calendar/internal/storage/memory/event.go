@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,57 +16,148 @@ type EventStorage struct {
 
 	id     int64
 	events map[int64]*storage.Event
+	// trash keeps the deleted events, so the queries of the events skip them
+	trash map[int64]*storage.Event
+
+	attendeeID int64
+	attendees  map[int64]*storage.Attendee
+
+	reminderID int64
+	reminders  map[int64]*storage.Reminder
+
+	outboxID int64
+	outbox   map[int64]*storage.OutboxMessage
+
+	historyID int64
+	history   map[int64]*storage.HistoryEntry
 }
 
 func New() *EventStorage {
 	return &EventStorage{
-		events: make(map[int64]*storage.Event),
+		events:    make(map[int64]*storage.Event),
+		trash:     make(map[int64]*storage.Event),
+		attendees: make(map[int64]*storage.Attendee),
+		reminders: make(map[int64]*storage.Reminder),
+		outbox:    make(map[int64]*storage.OutboxMessage),
+		history:   make(map[int64]*storage.HistoryEntry),
 	}
 }
 
-func (s *EventStorage) Create(_ context.Context, event *storage.Event) (int64, error) {
+func (s *EventStorage) Create(_ context.Context, event *storage.Event, history *storage.HistoryEntry) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.overlaps(event) {
+		return 0, storage.ErrOverlap
+	}
+
 	noww := time.Now()
 	s.id++
 	event.ID = s.id
 	event.CreatedAt = noww
 	event.UpdatedAt = noww
+	event.Version = 1
+
+	s.events[s.id] = clone(event)
+	s.saveReminders(event, noww)
 
-	val := *event
-	cpy := val
-	s.events[s.id] = &cpy
+	if history != nil {
+		history.EventID = event.ID
+	}
+	s.addHistory(history, noww)
 
 	return s.id, nil
 }
 
-func (s *EventStorage) Update(ctx context.Context, event *storage.Event) error {
+func (s *EventStorage) Update(_ context.Context, event *storage.Event, history *storage.HistoryEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	e, err := s.getByID(ctx, event.ID)
-	if err != nil {
-		// nolint:nilerr
-		return nil
+	// the event is changed or deleted since it was read, the trashed events are not kept in events
+	e, ok := s.events[event.ID]
+	if !ok || e.Version != event.Version {
+		return storage.ErrConflict
+	}
+
+	if s.overlaps(event) {
+		return storage.ErrOverlap
 	}
 
-	val := *event
+	noww := time.Now()
+	val := clone(event)
 	val.ID = e.ID
-	val.UpdatedAt = time.Now()
-	s.events[event.ID] = &val
+	val.UpdatedAt = noww
+	val.Version = e.Version + 1
+	s.events[event.ID] = val
+	s.saveReminders(event, noww)
+	s.addHistory(history, noww)
+
+	event.Version = val.Version
 
 	return nil
 }
 
-func (s *EventStorage) Delete(_ context.Context, id int64) error {
+// overlaps reports whether the one-off blocking event overlaps another one of the user,
+// the recurring series are expanded and checked by the application.
+func (s *EventStorage) overlaps(event *storage.Event) bool {
+	return overlaps(s.events, event)
+}
+
+// overlaps reports whether the event overlaps another one of events, the events are keyed by id.
+func overlaps(events map[int64]*storage.Event, event *storage.Event) bool {
+	if event.NonBlocking || event.RRule != "" || !event.TimeStart.Before(event.TimeEnd) {
+		return false
+	}
+
+	for id, e := range events {
+		if id == event.ID || e.UserID != event.UserID || e.NonBlocking || e.RRule != "" {
+			continue
+		}
+
+		if e.TimeStart.Before(event.TimeEnd) && e.TimeEnd.After(event.TimeStart) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delete records the history only when the event is moved to the trash by this call.
+func (s *EventStorage) Delete(_ context.Context, id int64, history *storage.HistoryEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.events, id)
+	noww := time.Now()
+	if _, ok := s.events[id]; ok {
+		s.trashEvent(id, storage.DeletedTime{Time: noww, Valid: true})
+		s.addHistory(history, noww)
+	}
+
 	return nil
 }
 
+// trashEvent moves the event with its exceptions to the trash, the caller must hold the lock.
+func (s *EventStorage) trashEvent(id int64, deletedAt storage.DeletedTime) {
+	e, ok := s.events[id]
+	if !ok {
+		return
+	}
+
+	for exceptionID, exception := range s.events {
+		if exception.SeriesID.Valid && exception.SeriesID.Int64 == id {
+			s.moveToTrash(exceptionID, exception, deletedAt)
+		}
+	}
+	s.moveToTrash(id, e, deletedAt)
+}
+
+// moveToTrash marks the event deleted and moves it to the trash, the caller must hold the lock.
+func (s *EventStorage) moveToTrash(id int64, e *storage.Event, deletedAt storage.DeletedTime) {
+	e.DeletedAt = deletedAt
+	s.trash[id] = e
+	delete(s.events, id)
+}
+
 func (s *EventStorage) GetByID(ctx context.Context, id int64) (*storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -79,100 +171,159 @@ func (s *EventStorage) getByID(_ context.Context, id int64) (*storage.Event, err
 		return nil, storage.ErrNotFound
 	}
 
-	value := *event
-	cpy := value
-	return &cpy, nil
+	return clone(event), nil
 }
 
 func (s *EventStorage) FindForInterval(
 	_ context.Context,
 	userID int64,
 	from, to time.Time,
-	limit, offset uint8) ([]*storage.Event, error) {
+	after *storage.Cursor,
+	limit int) ([]*storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	result := make([]*storage.Event, 0, limit)
+	result := make([]*storage.Event, 0)
 
 	for _, e := range s.events {
 		if !(e.UserID == userID &&
+			!e.IsRecurring() &&
 			(e.TimeStart.Equal(from) || e.TimeStart.After(from)) &&
 			(e.TimeStart.Equal(to) || e.TimeStart.Before(to))) {
 			continue
 		}
 
-		if offset != 0 {
-			offset--
+		if after != nil && !isAfter(e, after) {
 			continue
 		}
 
-		val := *e
-		cpy := val
-		result = append(result, &cpy)
-		if len(result) == int(limit) {
-			break
-		}
+		result = append(result, clone(e))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return isAfter(result[j], &storage.Cursor{TimeStart: result[i].TimeStart, ID: result[i].ID})
+	})
+	if len(result) > limit {
+		result = result[:limit]
 	}
 
 	return result, nil
 }
 
-func (s *EventStorage) FindUnNotified(_ context.Context, t time.Time) ([]*storage.Event, error) {
+// isAfter reports whether the event follows the cursor in (time_start, id) order.
+func isAfter(e *storage.Event, c *storage.Cursor) bool {
+	return e.TimeStart.After(c.TimeStart) || (e.TimeStart.Equal(c.TimeStart) && e.ID > c.ID)
+}
+
+func (s *EventStorage) FindRecurring(_ context.Context, userID int64, from, to time.Time) ([]*storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	result := make([]*storage.Event, 0)
 
 	for _, e := range s.events {
-		if e.NotifyAt.Valid &&
-			(e.NotifyAt.Time.Equal(t) || e.NotifyAt.Time.Before(t)) &&
-			!e.NotificationSent &&
-			e.TimeStart.After(t) {
-			val := *e
-			cpy := val
-			result = append(result, &cpy)
+		if e.UserID == userID &&
+			e.IsRecurring() &&
+			(e.TimeStart.Equal(to) || e.TimeStart.Before(to)) &&
+			(!e.RecurrenceUntil.Valid || e.RecurrenceUntil.Time.Equal(from) || e.RecurrenceUntil.Time.After(from)) {
+			result = append(result, clone(e))
 		}
 	}
 
 	return result, nil
 }
 
-func (s *EventStorage) MarkNotified(ctx context.Context, ids []int64) error {
-	if len(ids) == 0 {
-		return nil
-	}
+func (s *EventStorage) FindOverlapping(_ context.Context, userID int64, from, to time.Time) ([]*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	result := make([]*storage.Event, 0)
 
-	noww := time.Now()
-	for _, id := range ids {
-		e, ok := s.events[id]
-		if !ok {
-			continue
+	for _, e := range s.events {
+		if e.UserID == userID &&
+			!e.IsRecurring() &&
+			e.TimeStart.Before(to) &&
+			e.TimeEnd.After(from) {
+			result = append(result, clone(e))
 		}
-
-		e.NotificationSent = true
-		e.UpdatedAt = noww
 	}
 
-	return nil
+	return result, nil
 }
 
-func (s *EventStorage) DeleteOlderThan(_ context.Context, t time.Time) error {
+func (s *EventStorage) DeleteOlderThan(_ context.Context, filter storage.RetentionFilter) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	toDelete := make([]int64, 0)
-	for id, e := range s.events {
-		if e.TimeEnd.Equal(t) || e.TimeEnd.Before(t) {
-			toDelete = append(toDelete, id)
+	for _, events := range []map[int64]*storage.Event{s.events, s.trash} {
+		for id, e := range events {
+			if isExpired(e, filter) {
+				toDelete = append(toDelete, id)
+			}
 		}
 	}
 
 	for _, id := range toDelete {
-		delete(s.events, id)
+		s.delete(id)
 	}
 
 	return nil
 }
+
+// isExpired reports whether the retention filter selects the event.
+func isExpired(e *storage.Event, filter storage.RetentionFilter) bool {
+	if len(filter.UserIDs) > 0 && !containsID(filter.UserIDs, e.UserID) {
+		return false
+	}
+
+	if containsID(filter.ExceptUserIDs, e.UserID) {
+		return false
+	}
+
+	if e.IsRecurring() && (!e.RecurrenceUntil.Valid || e.RecurrenceUntil.Time.After(filter.Before)) {
+		return false
+	}
+
+	return !e.TimeEnd.After(filter.Before)
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// delete removes the event with its attendees and reminders permanently, the history of the event is kept,
+// the caller must hold the lock.
+func (s *EventStorage) delete(id int64) {
+	delete(s.events, id)
+	delete(s.trash, id)
+	for attendeeID, a := range s.attendees {
+		if a.EventID == id {
+			delete(s.attendees, attendeeID)
+		}
+	}
+	for reminderID, r := range s.reminders {
+		if r.EventID == id {
+			delete(s.reminders, reminderID)
+		}
+	}
+}
+
+func clone(e *storage.Event) *storage.Event {
+	cpy := *e
+	if e.ExDates != nil {
+		cpy.ExDates = make([]time.Time, len(e.ExDates))
+		copy(cpy.ExDates, e.ExDates)
+	}
+	// attendees and reminders are not a part of the event record
+	cpy.Attendees = nil
+	cpy.Reminders = nil
+
+	return &cpy
+}
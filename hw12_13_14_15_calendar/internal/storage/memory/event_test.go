@@ -43,13 +43,13 @@ func TestStorage_SimpleCRUD(t *testing.T) {
 	event := gen(1, "title", "descr", testZeroTime)
 	event2 := gen(2, "title2", "descr2", testZeroTime.Add(24*time.Hour))
 
-	id, err := unit.Create(ctx, event)
+	id, err := unit.Create(ctx, event, nil)
 	require.NoError(t, err)
 	require.Equal(t, int64(1), id)
 	require.Equal(t, id, event.ID)
 	require.Equal(t, event.CreatedAt, event.UpdatedAt)
 
-	id2, err := unit.Create(ctx, event2)
+	id2, err := unit.Create(ctx, event2, nil)
 	require.NoError(t, err)
 	require.Equal(t, int64(2), id2)
 	require.Equal(t, id2, event2.ID)
@@ -72,7 +72,7 @@ func TestStorage_SimpleCRUD(t *testing.T) {
 	event.TimeStart = testZeroTime.Add(time.Minute)
 	event.TimeEnd = testZeroTime.Add(time.Minute)
 
-	err = unit.Update(ctx, event)
+	err = unit.Update(ctx, event, nil)
 	require.NoError(t, err)
 
 	foundUpdated, err := unit.GetByID(ctx, event.ID)
@@ -80,8 +80,8 @@ func TestStorage_SimpleCRUD(t *testing.T) {
 	compare(t, event, foundUpdated)
 	require.NotEqual(t, event.UpdatedAt, foundUpdated.UpdatedAt)
 
-	require.NoError(t, unit.Delete(ctx, event.ID))
-	require.NoError(t, unit.Delete(ctx, event2.ID))
+	require.NoError(t, unit.Delete(ctx, event.ID, nil))
+	require.NoError(t, unit.Delete(ctx, event2.ID, nil))
 
 	_, err = unit.GetByID(ctx, event.ID)
 	require.ErrorIs(t, err, storage.ErrNotFound)
@@ -89,6 +89,116 @@ func TestStorage_SimpleCRUD(t *testing.T) {
 	require.ErrorIs(t, err, storage.ErrNotFound)
 }
 
+func TestEventStorage_UpdateConflict(t *testing.T) {
+	unit := New()
+
+	event := gen(1, "title", "descr", testZeroTime)
+	_, err := unit.Create(ctx, event, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), event.Version)
+
+	// two clients read the same version of the event
+	first, err := unit.GetByID(ctx, event.ID)
+	require.NoError(t, err)
+	second, err := unit.GetByID(ctx, event.ID)
+	require.NoError(t, err)
+
+	first.Title = "first"
+	require.NoError(t, unit.Update(ctx, first, nil))
+	require.Equal(t, int64(2), first.Version)
+
+	second.Title = "second"
+	require.ErrorIs(t, unit.Update(ctx, second, nil), storage.ErrConflict)
+	require.Equal(t, int64(1), second.Version)
+
+	found, err := unit.GetByID(ctx, event.ID)
+	require.NoError(t, err)
+	require.Equal(t, "first", found.Title)
+	require.Equal(t, int64(2), found.Version)
+
+	t.Run("missing event", func(t *testing.T) {
+		missing := gen(1, "missing", "descr", testZeroTime)
+		missing.ID = 100
+		missing.Version = 1
+
+		require.ErrorIs(t, unit.Update(ctx, missing, nil), storage.ErrConflict)
+		_, err := unit.GetByID(ctx, missing.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("trashed event", func(t *testing.T) {
+		require.NoError(t, unit.Delete(ctx, found.ID, nil))
+
+		found.Title = "trashed"
+		require.ErrorIs(t, unit.Update(ctx, found, nil), storage.ErrConflict)
+		_, err := unit.GetByID(ctx, found.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestEventStorage_Overlap(t *testing.T) {
+	unit := New()
+
+	event := gen(1, "", "", testZeroTime)
+	_, err := unit.Create(ctx, event, nil)
+	require.NoError(t, err)
+
+	t.Run("overlapping event", func(t *testing.T) {
+		_, err := unit.Create(ctx, gen(1, "", "", testZeroTime.Add(30*time.Minute)), nil)
+		require.ErrorIs(t, err, storage.ErrOverlap)
+	})
+
+	t.Run("adjacent event", func(t *testing.T) {
+		_, err := unit.Create(ctx, gen(1, "", "", testZeroTime.Add(time.Hour)), nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("event of another user", func(t *testing.T) {
+		_, err := unit.Create(ctx, gen(2, "", "", testZeroTime), nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("non-blocking event", func(t *testing.T) {
+		e := gen(1, "", "", testZeroTime)
+		e.NonBlocking = true
+		_, err := unit.Create(ctx, e, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("recurring event", func(t *testing.T) {
+		e := gen(1, "", "", testZeroTime)
+		e.RRule = "FREQ=DAILY"
+		_, err := unit.Create(ctx, e, nil)
+		require.NoError(t, err)
+	})
+
+	// the occurrences are checked by the application, the storage does not guard them against concurrent changes
+	t.Run("event over occurrence", func(t *testing.T) {
+		series := gen(3, "", "", testZeroTime)
+		series.RRule = "FREQ=DAILY"
+		_, err := unit.Create(ctx, series, nil)
+		require.NoError(t, err)
+
+		_, err = unit.Create(ctx, gen(3, "", "", testZeroTime.AddDate(0, 0, 1)), nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("update to overlapping time", func(t *testing.T) {
+		e := gen(1, "", "", testZeroTime.Add(3*time.Hour))
+		_, err := unit.Create(ctx, e, nil)
+		require.NoError(t, err)
+
+		e.TimeStart = testZeroTime.Add(-30 * time.Minute)
+		e.TimeEnd = testZeroTime.Add(30 * time.Minute)
+		require.ErrorIs(t, unit.Update(ctx, e, nil), storage.ErrOverlap)
+
+		// the event does not overlap itself
+		e.TimeStart = testZeroTime.Add(3*time.Hour + 30*time.Minute)
+		e.TimeEnd = testZeroTime.Add(4 * time.Hour)
+		require.NoError(t, unit.Update(ctx, e, nil))
+	})
+}
+
 func TestEventStorage_FindForInterval(t *testing.T) {
 	unit := New()
 	for i := 1; i <= 4; i++ {
@@ -96,33 +206,129 @@ func TestEventStorage_FindForInterval(t *testing.T) {
 
 		for u := int64(1); u <= 2; u++ {
 			e := gen(u, "", "", timeStart)
-			_, err := unit.Create(ctx, e)
+			_, err := unit.Create(ctx, e, nil)
 			require.NoError(t, err)
 		}
 	}
 
 	t.Run("simple case", func(t *testing.T) {
-		events, err := unit.FindForInterval(ctx, 1, testZeroTime.Add(time.Minute), testZeroTime.Add(3*time.Hour+1), 99, 0)
+		events, err := unit.FindForInterval(ctx, 1, testZeroTime.Add(time.Minute), testZeroTime.Add(3*time.Hour+1), nil, 99)
 		require.NoError(t, err)
 		require.Len(t, events, 3)
 	})
 
 	t.Run("works like BETWEEN from SQL", func(t *testing.T) {
-		events, err := unit.FindForInterval(ctx, 1, testZeroTime.Add(time.Hour), testZeroTime.Add(4*time.Hour), 99, 0)
+		events, err := unit.FindForInterval(ctx, 1, testZeroTime.Add(time.Hour), testZeroTime.Add(4*time.Hour), nil, 99)
 		require.NoError(t, err)
 		require.Len(t, events, 4)
 	})
 
 	t.Run("limit", func(t *testing.T) {
-		events, err := unit.FindForInterval(ctx, 1, testZeroTime.Add(time.Hour), testZeroTime.Add(3*time.Hour), 2, 0)
+		events, err := unit.FindForInterval(ctx, 1, testZeroTime.Add(time.Hour), testZeroTime.Add(3*time.Hour), nil, 2)
 		require.NoError(t, err)
 		require.Len(t, events, 2)
 	})
 
-	t.Run("offset", func(t *testing.T) {
-		events, err := unit.FindForInterval(ctx, 2, testZeroTime.Add(time.Hour), testZeroTime.Add(3*time.Hour), 99, 1)
+	t.Run("cursor", func(t *testing.T) {
+		from, to := testZeroTime.Add(time.Hour), testZeroTime.Add(3*time.Hour)
+
+		first, err := unit.FindForInterval(ctx, 2, from, to, nil, 2)
+		require.NoError(t, err)
+		require.Len(t, first, 2)
+		require.True(t, first[0].TimeStart.Before(first[1].TimeStart))
+
+		last := first[len(first)-1]
+		next, err := unit.FindForInterval(ctx, 2, from, to, &storage.Cursor{TimeStart: last.TimeStart, ID: last.ID}, 2)
+		require.NoError(t, err)
+		require.Len(t, next, 1)
+		require.Equal(t, to, next[0].TimeStart)
+	})
+
+	t.Run("cursor orders by id within the same time start", func(t *testing.T) {
+		unit := New()
+		for i := 0; i < 3; i++ {
+			e := gen(1, "", "", testZeroTime)
+			e.NonBlocking = true
+			_, err := unit.Create(ctx, e, nil)
+			require.NoError(t, err)
+		}
+
+		after := &storage.Cursor{TimeStart: testZeroTime, ID: 1}
+		events, err := unit.FindForInterval(ctx, 1, testZeroTime, testZeroTime, after, 99)
 		require.NoError(t, err)
 		require.Len(t, events, 2)
+		require.Equal(t, int64(2), events[0].ID)
+		require.Equal(t, int64(3), events[1].ID)
+	})
+}
+
+func TestEventStorage_FindRecurring(t *testing.T) {
+	unit := New()
+
+	oneOff := gen(1, "one-off", "", testZeroTime)
+	_, err := unit.Create(ctx, oneOff, nil)
+	require.NoError(t, err)
+
+	endless := gen(1, "endless", "", testZeroTime)
+	endless.RRule = "FREQ=DAILY"
+	_, err = unit.Create(ctx, endless, nil)
+	require.NoError(t, err)
+
+	finished := gen(1, "finished", "", testZeroTime)
+	finished.RRule = "FREQ=DAILY;COUNT=2"
+	finished.RecurrenceUntil = storage.RecurrenceTime{Time: testZeroTime.AddDate(0, 0, 1).Add(time.Hour), Valid: true}
+	_, err = unit.Create(ctx, finished, nil)
+	require.NoError(t, err)
+
+	future := gen(1, "future", "", testZeroTime.AddDate(0, 1, 0))
+	future.RRule = "FREQ=WEEKLY"
+	_, err = unit.Create(ctx, future, nil)
+	require.NoError(t, err)
+
+	anotherUser := gen(2, "another user", "", testZeroTime)
+	anotherUser.RRule = "FREQ=DAILY"
+	_, err = unit.Create(ctx, anotherUser, nil)
+	require.NoError(t, err)
+
+	t.Run("series are not returned as one-off events", func(t *testing.T) {
+		events, err := unit.FindForInterval(ctx, 1, testZeroTime, testZeroTime, nil, 99)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, oneOff.ID, events[0].ID)
+	})
+
+	t.Run("series which may have occurrences in interval", func(t *testing.T) {
+		events, err := unit.FindRecurring(ctx, 1, testZeroTime.AddDate(0, 0, 3), testZeroTime.AddDate(0, 0, 4))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, endless.ID, events[0].ID)
+
+		events, err = unit.FindRecurring(ctx, 1, testZeroTime, testZeroTime.AddDate(0, 2, 0))
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+	})
+
+	t.Run("series survive delete older than while they are not finished", func(t *testing.T) {
+		require.NoError(t, unit.DeleteOlderThan(ctx, storage.RetentionFilter{Before: testZeroTime.AddDate(0, 0, 3)}))
+
+		_, err := unit.GetByID(ctx, oneOff.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = unit.GetByID(ctx, finished.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = unit.GetByID(ctx, endless.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("exceptions are deleted with the series", func(t *testing.T) {
+		exception := gen(1, "exception", "", testZeroTime.AddDate(0, 0, 5))
+		exception.SeriesID = storage.SeriesID{Int64: endless.ID, Valid: true}
+		exception.RecurrenceID = storage.RecurrenceTime{Time: testZeroTime.AddDate(0, 0, 4), Valid: true}
+		_, err := unit.Create(ctx, exception, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, unit.Delete(ctx, endless.ID, nil))
+		_, err = unit.GetByID(ctx, exception.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
 	})
 }
 
@@ -131,7 +337,7 @@ func TestEventStorage_StorageContainsCopy(t *testing.T) {
 		t.Run("storage contains copy on create", func(t *testing.T) {
 			unit := New()
 			original := gen(1, "title", "descr", testZeroTime)
-			_, err := unit.Create(ctx, original)
+			_, err := unit.Create(ctx, original, nil)
 			require.NoError(t, err)
 
 			fromStorage, err := unit.GetByID(ctx, original.ID)
@@ -145,11 +351,11 @@ func TestEventStorage_StorageContainsCopy(t *testing.T) {
 		t.Run("storage contains copy on update", func(t *testing.T) {
 			unit := New()
 			original := gen(1, "title", "descr", testZeroTime)
-			_, err := unit.Create(ctx, original)
+			_, err := unit.Create(ctx, original, nil)
 			require.NoError(t, err)
 
 			original.UserID++
-			err = unit.Update(ctx, original)
+			err = unit.Update(ctx, original, nil)
 			require.NoError(t, err)
 
 			fromStorage, err := unit.GetByID(ctx, original.ID)
@@ -160,13 +366,27 @@ func TestEventStorage_StorageContainsCopy(t *testing.T) {
 			require.NotEqual(t, original, fromStorage)
 		})
 
+		t.Run("storage contains copy of ex dates", func(t *testing.T) {
+			unit := New()
+			original := gen(1, "", "", testZeroTime)
+			original.RRule = "FREQ=DAILY"
+			original.ExDates = []time.Time{testZeroTime.AddDate(0, 0, 1)}
+			_, err := unit.Create(ctx, original, nil)
+			require.NoError(t, err)
+
+			original.ExDates[0] = testZeroTime.AddDate(0, 0, 2)
+			fromStorage, err := unit.GetByID(ctx, original.ID)
+			require.NoError(t, err)
+			require.Equal(t, testZeroTime.AddDate(0, 0, 1), fromStorage.ExDates[0])
+		})
+
 		t.Run("storage contains copy of search", func(t *testing.T) {
 			unit := New()
 			original := gen(1, "", "", testZeroTime)
-			_, err := unit.Create(ctx, original)
+			_, err := unit.Create(ctx, original, nil)
 			require.NoError(t, err)
 
-			chunk, err := unit.FindForInterval(ctx, 1, testZeroTime, testZeroTime, 1, 0)
+			chunk, err := unit.FindForInterval(ctx, 1, testZeroTime, testZeroTime, nil, 1)
 			require.NoError(t, err)
 			require.Len(t, chunk, 1)
 
@@ -176,93 +396,19 @@ func TestEventStorage_StorageContainsCopy(t *testing.T) {
 	})
 }
 
-func TestEventStorage_FindUnNotified(t *testing.T) {
-	unit := New()
-
-	e := gen(1, "not foud", "notify_at == nil", testZeroTime)
-	_, err := unit.Create(ctx, e)
-	require.NoError(t, err)
-
-	e = gen(1, "not found", "notify_at in past", testZeroTime)
-	e.NotifyAt = storage.CreateNotificationTime(e.TimeStart, 10*time.Minute)
-	_, err = unit.Create(ctx, e)
-	require.NoError(t, err)
-
-	e = gen(1, "not found", "notify_at in future", testZeroTime.AddDate(0, 0, 2))
-	e.NotifyAt = storage.CreateNotificationTime(e.TimeStart, 10*time.Minute)
-	_, err = unit.Create(ctx, e)
-	require.NoError(t, err)
-
-	e = gen(1, "not found", "notification sent", testZeroTime.AddDate(0, 0, 1))
-	e.NotifyAt = storage.CreateNotificationTime(e.TimeStart, 10*time.Minute)
-	e.NotificationSent = true
-	_, err = unit.Create(ctx, e)
-	require.NoError(t, err)
-
-	e = gen(1, "found", "", testZeroTime.AddDate(0, 0, 1))
-	e.NotifyAt = storage.CreateNotificationTime(e.TimeStart, 10*time.Minute)
-	_, err = unit.Create(ctx, e)
-	require.NoError(t, err)
-
-	e = gen(2, "found", "", testZeroTime.AddDate(0, 0, 1))
-	e.NotifyAt = storage.CreateNotificationTime(e.TimeStart, 30*time.Minute)
-	_, err = unit.Create(ctx, e)
-	require.NoError(t, err)
-
-	testNow := testZeroTime.AddDate(0, 0, 1).Add(-5 * time.Minute)
-	events, err := unit.FindUnNotified(ctx, testNow)
-	require.NoError(t, err)
-	require.Len(t, events, 2)
-	for _, e := range events {
-		require.Equal(t, "found", e.Title)
-	}
-}
-
-func TestEventStorage_MarkNotified(t *testing.T) {
-	unit := New()
-	idsToNotify := make([]int64, 0, 10)
-	ids := make([]int64, 0, 20)
-	for i := 0; i < 20; i++ {
-		id, err := unit.Create(ctx, gen(1, "", "", testZeroTime))
-		require.NoError(t, err)
-		if id%2 == 0 {
-			idsToNotify = append(idsToNotify, id)
-		}
-		ids = append(ids, id)
-	}
-
-	// Add some not existed ids
-	idsToNotify = append(idsToNotify, int64(10000))
-	idsToNotify = append(idsToNotify, int64(10001))
-	idsToNotify = append(idsToNotify, int64(10002))
-
-	require.NoError(t, unit.MarkNotified(ctx, idsToNotify))
-	for _, id := range ids {
-		e, err := unit.GetByID(ctx, id)
-		require.NoError(t, err)
-		if e.ID%2 == 0 {
-			require.NotEqual(t, e.CreatedAt, e.UpdatedAt)
-			require.True(t, e.NotificationSent)
-		} else {
-			require.Equal(t, e.CreatedAt, e.UpdatedAt)
-			require.False(t, e.NotificationSent)
-		}
-	}
-}
-
 func TestEventStorage_DeleteOlderThan(t *testing.T) {
 	unit := New()
 
 	events := make(map[int64]*storage.Event)
 	for i := 0; i < 10; i++ {
 		e := gen(1, "", "", testZeroTime.AddDate(0, 0, i))
-		id, err := unit.Create(ctx, e)
+		id, err := unit.Create(ctx, e, nil)
 		require.NoError(t, err)
 		events[id] = e
 	}
 
 	tt := testZeroTime.AddDate(0, 0, 5)
-	require.NoError(t, unit.DeleteOlderThan(ctx, tt))
+	require.NoError(t, unit.DeleteOlderThan(ctx, storage.RetentionFilter{Before: tt}))
 	for id, e := range events {
 		_, err := unit.GetByID(ctx, id)
 		if e.TimeEnd.Equal(tt) || e.TimeEnd.Before(tt) {
@@ -271,6 +417,38 @@ func TestEventStorage_DeleteOlderThan(t *testing.T) {
 			require.NoError(t, err)
 		}
 	}
+
+	t.Run("retention of the users", func(t *testing.T) {
+		unit := New()
+
+		ids := make(map[int64]int64)
+		for userID := int64(1); userID <= 3; userID++ {
+			id, err := unit.Create(ctx, gen(userID, "", "", testZeroTime), nil)
+			require.NoError(t, err)
+			ids[userID] = id
+		}
+		require.NoError(t, unit.Delete(ctx, ids[3], nil))
+
+		// the first user keeps the events, the others do not, the events in the trash are deleted too
+		require.NoError(t, unit.DeleteOlderThan(ctx, storage.RetentionFilter{
+			Before:        testZeroTime.AddDate(0, 0, 1),
+			ExceptUserIDs: []int64{1},
+		}))
+
+		_, err := unit.GetByID(ctx, ids[1])
+		require.NoError(t, err)
+		_, err = unit.GetByID(ctx, ids[2])
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = unit.GetDeleted(ctx, ids[3])
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		require.NoError(t, unit.DeleteOlderThan(ctx, storage.RetentionFilter{
+			Before:  testZeroTime.AddDate(0, 0, 1),
+			UserIDs: []int64{1},
+		}))
+		_, err = unit.GetByID(ctx, ids[1])
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
 }
 
 func TestStorage_Concurrency(t *testing.T) {
@@ -282,7 +460,9 @@ func TestStorage_Concurrency(t *testing.T) {
 		doSomeWork := func(n int) {
 			ids := make([]int64, 0, n)
 			for i := 0; i < n; i++ {
-				id, err := unit.Create(ctx, gen(1, "", "", testZeroTime))
+				e := gen(1, "", "", testZeroTime)
+				e.NonBlocking = true
+				id, err := unit.Create(ctx, e, nil)
 				require.NoError(t, err)
 
 				ids = append(ids, id)
@@ -292,10 +472,10 @@ func TestStorage_Concurrency(t *testing.T) {
 				_, err := unit.GetByID(ctx, id)
 				require.NoError(t, err)
 
-				_, err = unit.FindForInterval(ctx, 1, testZeroTime, testZeroTime, 1, 0)
+				_, err = unit.FindForInterval(ctx, 1, testZeroTime, testZeroTime, nil, 1)
 				require.NoError(t, err)
 
-				require.NoError(t, unit.Delete(ctx, id))
+				require.NoError(t, unit.Delete(ctx, id, nil))
 			}
 		}
 
@@ -310,7 +490,36 @@ func TestStorage_Concurrency(t *testing.T) {
 		wg.Wait()
 
 		e := gen(1, "", "", testZeroTime)
-		_, _ = unit.Create(ctx, e)
+		_, _ = unit.Create(ctx, e, nil)
 		require.Equal(t, int64(eventsPerIteration*iterationsCount+1), e.ID)
 	})
 }
+
+func TestEventStorage_FindOverlapping(t *testing.T) {
+	unit := New()
+
+	for _, e := range []*storage.Event{
+		gen(1, "before", "d", testZeroTime.Add(-2*time.Hour)),
+		gen(1, "overlaps start", "d", testZeroTime.Add(-30*time.Minute)),
+		gen(1, "inside", "d", testZeroTime.Add(time.Hour)),
+		gen(1, "after", "d", testZeroTime.Add(3*time.Hour)),
+		gen(2, "other user", "d", testZeroTime.Add(time.Hour)),
+	} {
+		_, err := unit.Create(ctx, e, nil)
+		require.NoError(t, err)
+	}
+
+	series := gen(1, "series", "d", testZeroTime)
+	series.RRule = "FREQ=DAILY"
+	_, err := unit.Create(ctx, series, nil)
+	require.NoError(t, err)
+
+	events, err := unit.FindOverlapping(ctx, 1, testZeroTime, testZeroTime.Add(3*time.Hour))
+	require.NoError(t, err)
+
+	titles := make([]string, 0, len(events))
+	for _, e := range events {
+		titles = append(titles, e.Title)
+	}
+	require.ElementsMatch(t, []string{"overlaps start", "inside"}, titles)
+}
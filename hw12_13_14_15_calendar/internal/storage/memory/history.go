@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// addHistory saves the entry of the change together with the change, nothing is saved for nil entry,
+// the caller must hold the lock.
+func (s *EventStorage) addHistory(entry *storage.HistoryEntry, now time.Time) {
+	if entry == nil {
+		return
+	}
+
+	s.historyID++
+	entry.ID = s.historyID
+	entry.CreatedAt = now
+
+	s.history[entry.ID] = cloneHistory(entry)
+}
+
+func (s *EventStorage) FindHistory(_ context.Context, eventID int64) ([]*storage.HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*storage.HistoryEntry, 0)
+	for _, h := range s.history {
+		if h.EventID == eventID {
+			result = append(result, cloneHistory(h))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+func (s *EventStorage) FindUserHistory(
+	_ context.Context,
+	userID, afterID int64,
+	limit int) ([]*storage.HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*storage.HistoryEntry, 0)
+	for _, h := range s.history {
+		if h.ID > afterID && h.UserID == userID {
+			result = append(result, cloneHistory(h))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+func cloneHistory(h *storage.HistoryEntry) *storage.HistoryEntry {
+	cpy := *h
+	cpy.Changes = make([]*storage.FieldChange, 0, len(h.Changes))
+	for _, c := range h.Changes {
+		change := *c
+		cpy.Changes = append(cpy.Changes, &change)
+	}
+
+	return &cpy
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStorage_History(t *testing.T) {
+	unit := New()
+
+	e := gen(1, "a", "", testZeroTime)
+	created := &storage.HistoryEntry{
+		UserID:  1,
+		Action:  storage.HistoryCreated,
+		Changes: []*storage.FieldChange{{Field: "title", New: "a"}},
+	}
+	id, err := unit.Create(ctx, e, created)
+	require.NoError(t, err)
+	require.Equal(t, id, created.EventID)
+	require.NotZero(t, created.ID)
+
+	stale := *e
+	e.Title = "b"
+	updated := &storage.HistoryEntry{
+		EventID: id,
+		UserID:  1,
+		Action:  storage.HistoryUpdated,
+		Changes: []*storage.FieldChange{{Field: "title", Old: "a", New: "b"}},
+	}
+	require.NoError(t, unit.Update(ctx, e, updated))
+
+	// the saved entry is not changed with the argument
+	updated.Changes[0].New = "c"
+
+	// the history is not saved when the change fails
+	require.ErrorIs(t, unit.Update(ctx, &stale, &storage.HistoryEntry{EventID: id, UserID: 1}), storage.ErrConflict)
+	_, err = unit.Create(ctx, gen(1, "", "", testZeroTime), &storage.HistoryEntry{UserID: 1})
+	require.ErrorIs(t, err, storage.ErrOverlap)
+
+	t.Run("history is ordered", func(t *testing.T) {
+		history, err := unit.FindHistory(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Equal(t, created.ID, history[0].ID)
+		require.Equal(t, storage.HistoryUpdated, history[1].Action)
+		require.Equal(t, "b", history[1].Changes[0].New)
+		require.False(t, history[1].CreatedAt.IsZero())
+	})
+
+	t.Run("history of the user", func(t *testing.T) {
+		other := gen(2, "", "", testZeroTime)
+		_, err := unit.Create(ctx, other, &storage.HistoryEntry{UserID: 2, Action: storage.HistoryCreated})
+		require.NoError(t, err)
+
+		history, err := unit.FindUserHistory(ctx, 1, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+
+		history, err = unit.FindUserHistory(ctx, 1, created.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Equal(t, updated.ID, history[0].ID)
+
+		history, err = unit.FindUserHistory(ctx, 1, 0, 1)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Equal(t, created.ID, history[0].ID)
+	})
+
+	t.Run("history is kept in the trash", func(t *testing.T) {
+		deleted := &storage.HistoryEntry{EventID: id, UserID: 1, Action: storage.HistoryDeleted}
+		require.NoError(t, unit.Delete(ctx, id, deleted))
+		// the event deleted before is not recorded again
+		require.NoError(t, unit.Delete(ctx, id, &storage.HistoryEntry{EventID: id, UserID: 1}))
+
+		history, err := unit.FindHistory(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		require.Equal(t, deleted.ID, history[2].ID)
+
+		restored := &storage.HistoryEntry{EventID: id, UserID: 1, Action: storage.HistoryRestored}
+		require.NoError(t, unit.Restore(ctx, id, restored))
+		require.NoError(t, unit.Delete(ctx, id, &storage.HistoryEntry{EventID: id, UserID: 1}))
+
+		history, err = unit.FindHistory(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, history, 5)
+		require.Equal(t, restored.ID, history[3].ID)
+	})
+
+	t.Run("history is kept after the event is purged", func(t *testing.T) {
+		require.NoError(t, unit.Purge(ctx, id))
+
+		history, err := unit.FindHistory(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, history, 5)
+
+		history, err = unit.FindUserHistory(ctx, 1, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 5)
+	})
+}
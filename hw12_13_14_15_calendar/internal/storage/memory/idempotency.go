@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+var _ storage.IdempotencyStorage = (*IdempotencyStorage)(nil)
+
+type IdempotencyStorage struct {
+	mu sync.Mutex
+
+	keys map[string]time.Time
+}
+
+func NewIdempotencyStorage() *IdempotencyStorage {
+	return &IdempotencyStorage{
+		keys: make(map[string]time.Time),
+	}
+}
+
+func (s *IdempotencyStorage) HasKey(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.keys[key]
+
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+func (s *IdempotencyStorage) AddKey(_ context.Context, key string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	noww := time.Now()
+	for k, e := range s.keys {
+		if !e.After(noww) {
+			delete(s.keys, k)
+		}
+	}
+
+	s.keys[key] = expiresAt
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStorage(t *testing.T) {
+	unit := NewIdempotencyStorage()
+
+	has, err := unit.HasKey(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, unit.AddKey(ctx, "a", time.Now().Add(time.Hour)))
+	require.NoError(t, unit.AddKey(ctx, "b", time.Now().Add(-time.Second)))
+
+	has, err = unit.HasKey(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, has)
+
+	// the expired key is forgotten
+	has, err = unit.HasKey(ctx, "b")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, unit.AddKey(ctx, "c", time.Now().Add(time.Hour)))
+	require.NotContains(t, unit.keys, "b")
+}
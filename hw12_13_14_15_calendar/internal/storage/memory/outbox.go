@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) FindOutbox(_ context.Context, t time.Time, limit int) ([]*storage.OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*storage.OutboxMessage, 0)
+	for _, m := range s.outbox {
+		if !m.NextAttemptAt.After(t) {
+			result = append(result, cloneOutbox(m))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) DeleteOutbox(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.outbox, id)
+
+	return nil
+}
+
+func (s *EventStorage) RetryOutbox(_ context.Context, id int64, nextAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.outbox[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	m.Attempts++
+	m.NextAttemptAt = nextAttemptAt
+
+	return nil
+}
+
+// enqueue saves the messages skipping the known idempotency keys, the caller must hold the lock.
+func (s *EventStorage) enqueue(messages []*storage.OutboxMessage, now time.Time) {
+	keys := make(map[string]struct{}, len(s.outbox))
+	for _, m := range s.outbox {
+		keys[m.IdempotencyKey] = struct{}{}
+	}
+
+	for _, m := range messages {
+		if _, ok := keys[m.IdempotencyKey]; ok {
+			continue
+		}
+		keys[m.IdempotencyKey] = struct{}{}
+
+		s.outboxID++
+		m.ID = s.outboxID
+		m.Attempts = 0
+		m.NextAttemptAt = now
+		m.CreatedAt = now
+
+		s.outbox[m.ID] = cloneOutbox(m)
+	}
+}
+
+func cloneOutbox(m *storage.OutboxMessage) *storage.OutboxMessage {
+	cpy := *m
+	cpy.Payload = append([]byte(nil), m.Payload...)
+
+	return &cpy
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func message(key string) *storage.OutboxMessage {
+	return &storage.OutboxMessage{Key: "test", IdempotencyKey: key, Payload: []byte(key)}
+}
+
+func TestEventStorage_Outbox(t *testing.T) {
+	unit := New()
+
+	e := gen(1, "", "", testZeroTime)
+	e.Reminders = []*storage.Reminder{reminder(e, storage.ChannelLog, time.Minute)}
+	id, err := unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	reminders, err := unit.FindReminders(ctx, []int64{id})
+	require.NoError(t, err)
+
+	require.NoError(t, unit.MarkNotified(ctx, []int64{reminders[0].ID}, []*storage.OutboxMessage{
+		message("a"), message("b"),
+	}))
+	// the known idempotency keys are skipped
+	require.NoError(t, unit.MarkNotified(ctx, []int64{reminders[0].ID}, []*storage.OutboxMessage{
+		message("b"), message("c"),
+	}))
+
+	now := time.Now()
+	messages, err := unit.FindOutbox(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	require.Equal(t, "a", messages[0].IdempotencyKey)
+	require.Equal(t, "b", messages[1].IdempotencyKey)
+	require.Equal(t, "c", messages[2].IdempotencyKey)
+	require.Equal(t, []byte("c"), messages[2].Payload)
+
+	messages, err = unit.FindOutbox(ctx, now, 2)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	t.Run("retry", func(t *testing.T) {
+		require.NoError(t, unit.RetryOutbox(ctx, messages[0].ID, now.Add(time.Minute)))
+
+		due, err := unit.FindOutbox(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		require.Equal(t, "b", due[0].IdempotencyKey)
+
+		due, err = unit.FindOutbox(ctx, now.Add(time.Minute), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 3)
+		require.Equal(t, 1, due[0].Attempts)
+
+		require.ErrorIs(t, unit.RetryOutbox(ctx, 10000, now), storage.ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, unit.DeleteOutbox(ctx, messages[1].ID))
+
+		due, err := unit.FindOutbox(ctx, now.Add(time.Minute), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		require.Equal(t, "a", due[0].IdempotencyKey)
+		require.Equal(t, "c", due[1].IdempotencyKey)
+	})
+}
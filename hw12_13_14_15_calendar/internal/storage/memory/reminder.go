@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) FindReminders(_ context.Context, eventIDs []int64) ([]*storage.Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make(map[int64]struct{}, len(eventIDs))
+	for _, id := range eventIDs {
+		ids[id] = struct{}{}
+	}
+
+	result := make([]*storage.Reminder, 0)
+	for _, r := range s.reminders {
+		if _, ok := ids[r.EventID]; ok {
+			val := *r
+			result = append(result, &val)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.EventID != b.EventID {
+			return a.EventID < b.EventID
+		}
+		if !a.NotifyAt.Equal(b.NotifyAt) {
+			return a.NotifyAt.Before(b.NotifyAt)
+		}
+
+		return a.ID < b.ID
+	})
+
+	return result, nil
+}
+
+func (s *EventStorage) FindUnNotified(_ context.Context, t time.Time) ([]*storage.DueReminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*storage.DueReminder, 0)
+	for _, r := range s.reminders {
+		e, ok := s.events[r.EventID]
+		if !ok || r.Sent || r.NotifyAt.After(t) || !isActive(e, t) {
+			continue
+		}
+
+		val := *r
+		result = append(result, &storage.DueReminder{Event: clone(e), Reminder: &val})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Reminder, result[j].Reminder
+		if !a.NotifyAt.Equal(b.NotifyAt) {
+			return a.NotifyAt.Before(b.NotifyAt)
+		}
+
+		return a.ID < b.ID
+	})
+
+	return result, nil
+}
+
+func (s *EventStorage) MarkNotified(_ context.Context, reminderIDs []int64, outbox []*storage.OutboxMessage) error {
+	if len(reminderIDs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	noww := time.Now()
+	s.enqueue(outbox, noww)
+	for _, id := range reminderIDs {
+		r, ok := s.reminders[id]
+		if !ok {
+			continue
+		}
+
+		r.Sent = true
+		r.UpdatedAt = noww
+	}
+
+	return nil
+}
+
+func (s *EventStorage) RearmReminders(
+	_ context.Context,
+	reminders []*storage.Reminder,
+	outbox []*storage.OutboxMessage,
+) error {
+	if len(reminders) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	noww := time.Now()
+	s.enqueue(outbox, noww)
+	for _, r := range reminders {
+		stored, ok := s.reminders[r.ID]
+		if !ok {
+			continue
+		}
+
+		stored.NotifyAt = r.NotifyAt
+		stored.Sent = false
+		stored.UpdatedAt = noww
+	}
+
+	return nil
+}
+
+// isActive reports whether the event is not started yet or the series is not ended yet.
+func isActive(e *storage.Event, t time.Time) bool {
+	if e.IsRecurring() {
+		return !e.RecurrenceUntil.Valid || e.RecurrenceUntil.Time.After(t)
+	}
+
+	return e.TimeStart.After(t)
+}
+
+// saveReminders replaces the reminders of the event, reminders with the same channel and duration
+// keep their id and the sent flag unless they are moved. The caller must hold the lock.
+func (s *EventStorage) saveReminders(event *storage.Event, now time.Time) {
+	type key struct {
+		channel storage.ReminderChannel
+		before  time.Duration
+	}
+
+	existed := make(map[key]*storage.Reminder)
+	for id, r := range s.reminders {
+		if r.EventID == event.ID {
+			existed[key{r.Channel, r.Before}] = r
+			delete(s.reminders, id)
+		}
+	}
+
+	for _, r := range event.Reminders {
+		r.EventID = event.ID
+		r.UpdatedAt = now
+
+		if old, ok := existed[key{r.Channel, r.Before}]; ok {
+			r.ID = old.ID
+			r.Sent = old.Sent && old.NotifyAt.Equal(r.NotifyAt)
+			r.CreatedAt = old.CreatedAt
+		} else {
+			s.reminderID++
+			r.ID = s.reminderID
+			r.Sent = false
+			r.CreatedAt = now
+		}
+
+		val := *r
+		s.reminders[r.ID] = &val
+	}
+}
+
+// notificationState returns the state of the event reminders, the caller must hold the lock.
+func (s *EventStorage) notificationState(eventID int64) storage.NotificationState {
+	state := storage.NotificationNone
+	for _, r := range s.reminders {
+		if r.EventID != eventID {
+			continue
+		}
+
+		if !r.Sent {
+			return storage.NotificationPending
+		}
+		state = storage.NotificationSent
+	}
+
+	return state
+}
@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func reminder(e *storage.Event, channel storage.ReminderChannel, before time.Duration) *storage.Reminder {
+	return &storage.Reminder{Channel: channel, Before: before, NotifyAt: e.TimeStart.Add(-before)}
+}
+
+func TestEventStorage_SaveReminders(t *testing.T) {
+	unit := New()
+
+	e := gen(1, "", "", testZeroTime)
+	e.Reminders = []*storage.Reminder{
+		reminder(e, storage.ChannelEmail, 10*time.Minute),
+		reminder(e, storage.ChannelLog, time.Hour),
+	}
+	id, err := unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	reminders, err := unit.FindReminders(ctx, []int64{id})
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+	require.Equal(t, storage.ChannelLog, reminders[0].Channel)
+	require.Equal(t, storage.ChannelEmail, reminders[1].Channel)
+	emailID := reminders[1].ID
+
+	require.NoError(t, unit.MarkNotified(ctx, []int64{reminders[0].ID, emailID}, nil))
+
+	t.Run("sent is kept when the reminder is not moved", func(t *testing.T) {
+		e.Title = "renamed"
+		e.Reminders = []*storage.Reminder{
+			reminder(e, storage.ChannelEmail, 10*time.Minute),
+			reminder(e, storage.ChannelWebhook, time.Minute),
+		}
+		require.NoError(t, unit.Update(ctx, e, nil))
+
+		reminders, err := unit.FindReminders(ctx, []int64{id})
+		require.NoError(t, err)
+		require.Len(t, reminders, 2)
+		require.Equal(t, emailID, reminders[0].ID)
+		require.True(t, reminders[0].Sent)
+		require.Equal(t, storage.ChannelWebhook, reminders[1].Channel)
+		require.False(t, reminders[1].Sent)
+	})
+
+	t.Run("sent is reset when the event is moved", func(t *testing.T) {
+		e.TimeStart = e.TimeStart.Add(time.Hour)
+		e.TimeEnd = e.TimeEnd.Add(time.Hour)
+		e.Reminders = []*storage.Reminder{reminder(e, storage.ChannelEmail, 10*time.Minute)}
+		require.NoError(t, unit.Update(ctx, e, nil))
+
+		reminders, err := unit.FindReminders(ctx, []int64{id})
+		require.NoError(t, err)
+		require.Len(t, reminders, 1)
+		require.Equal(t, emailID, reminders[0].ID)
+		require.False(t, reminders[0].Sent)
+	})
+
+	t.Run("reminders are deleted with the event", func(t *testing.T) {
+		require.NoError(t, unit.Delete(ctx, id, nil))
+		require.NoError(t, unit.Purge(ctx, id))
+
+		reminders, err := unit.FindReminders(ctx, []int64{id})
+		require.NoError(t, err)
+		require.Empty(t, reminders)
+	})
+}
+
+func TestEventStorage_FindUnNotified(t *testing.T) {
+	unit := New()
+
+	_, err := unit.Create(ctx, gen(1, "not found", "without reminders", testZeroTime), nil)
+	require.NoError(t, err)
+
+	e := gen(1, "not found", "already started", testZeroTime)
+	e.NonBlocking = true
+	e.Reminders = []*storage.Reminder{reminder(e, storage.ChannelLog, 10*time.Minute)}
+	_, err = unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	e = gen(1, "not found", "notify_at in future", testZeroTime.AddDate(0, 0, 2))
+	e.Reminders = []*storage.Reminder{reminder(e, storage.ChannelLog, 10*time.Minute)}
+	_, err = unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	e = gen(1, "found", "", testZeroTime.AddDate(0, 0, 1))
+	e.Reminders = []*storage.Reminder{
+		reminder(e, storage.ChannelLog, 10*time.Minute),
+		reminder(e, storage.ChannelEmail, 30*time.Minute),
+		reminder(e, storage.ChannelWebhook, time.Minute),
+	}
+	_, err = unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	e = gen(2, "found", "", testZeroTime.AddDate(0, 0, 1))
+	e.Reminders = []*storage.Reminder{reminder(e, storage.ChannelLog, time.Hour)}
+	_, err = unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	testNow := testZeroTime.AddDate(0, 0, 1).Add(-5 * time.Minute)
+	due, err := unit.FindUnNotified(ctx, testNow)
+	require.NoError(t, err)
+	require.Len(t, due, 3)
+	for _, d := range due {
+		require.Equal(t, "found", d.Event.Title)
+		require.Equal(t, d.Event.ID, d.Reminder.EventID)
+	}
+	require.Equal(t, time.Hour, due[0].Reminder.Before)
+	require.Equal(t, storage.ChannelEmail, due[1].Reminder.Channel)
+	require.Equal(t, storage.ChannelLog, due[2].Reminder.Channel)
+
+	require.NoError(t, unit.MarkNotified(ctx, []int64{due[0].Reminder.ID, due[1].Reminder.ID}, nil))
+
+	due, err = unit.FindUnNotified(ctx, testNow)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, storage.ChannelLog, due[0].Reminder.Channel)
+}
+
+func TestEventStorage_MarkNotified(t *testing.T) {
+	unit := New()
+
+	e := gen(1, "", "", testZeroTime)
+	e.Reminders = []*storage.Reminder{
+		reminder(e, storage.ChannelLog, time.Minute),
+		reminder(e, storage.ChannelLog, time.Hour),
+	}
+	id, err := unit.Create(ctx, e, nil)
+	require.NoError(t, err)
+
+	reminders, err := unit.FindReminders(ctx, []int64{id})
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+
+	// not existed ids are ignored
+	require.NoError(t, unit.MarkNotified(ctx, []int64{reminders[0].ID, 10000}, nil))
+
+	reminders, err = unit.FindReminders(ctx, []int64{id})
+	require.NoError(t, err)
+	require.True(t, reminders[0].Sent)
+	require.NotEqual(t, reminders[0].CreatedAt, reminders[0].UpdatedAt)
+	require.False(t, reminders[1].Sent)
+	require.Equal(t, reminders[1].CreatedAt, reminders[1].UpdatedAt)
+}
+
+func TestEventStorage_RearmReminders(t *testing.T) {
+	unit := New()
+
+	series := gen(1, "series", "", testZeroTime)
+	series.RRule = "FREQ=DAILY"
+	series.Reminders = []*storage.Reminder{reminder(series, storage.ChannelLog, 10*time.Minute)}
+	_, err := unit.Create(ctx, series, nil)
+	require.NoError(t, err)
+
+	ended := gen(1, "ended", "", testZeroTime.Add(2*time.Hour))
+	ended.RRule = "FREQ=DAILY;COUNT=2"
+	ended.RecurrenceUntil = storage.RecurrenceTime{Time: ended.TimeEnd.AddDate(0, 0, 1), Valid: true}
+	ended.Reminders = []*storage.Reminder{reminder(ended, storage.ChannelLog, 10*time.Minute)}
+	_, err = unit.Create(ctx, ended, nil)
+	require.NoError(t, err)
+
+	// the reminders of the series are due after the first occurrence
+	testNow := testZeroTime.AddDate(0, 0, 3)
+	due, err := unit.FindUnNotified(ctx, testNow)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, series.ID, due[0].Event.ID)
+
+	rearmAt := testNow.Add(time.Hour)
+	require.NoError(t, unit.RearmReminders(ctx, []*storage.Reminder{{ID: due[0].Reminder.ID, NotifyAt: rearmAt}},
+		[]*storage.OutboxMessage{{Key: "test", IdempotencyKey: "reminder", Payload: []byte("{}")}}))
+
+	due, err = unit.FindUnNotified(ctx, testNow)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	reminders, err := unit.FindReminders(ctx, []int64{series.ID})
+	require.NoError(t, err)
+	require.Len(t, reminders, 1)
+	require.Equal(t, rearmAt, reminders[0].NotifyAt)
+	require.False(t, reminders[0].Sent)
+
+	messages, err := unit.FindOutbox(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	due, err = unit.FindUnNotified(ctx, rearmAt)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+}
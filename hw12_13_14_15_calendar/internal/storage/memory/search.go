@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) Search(_ context.Context, filter storage.SearchFilter) ([]*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := words(filter.Query)
+	desc := strings.HasPrefix(string(filter.Sort), "-")
+	key := sortKey(filter.Sort)
+
+	result := make([]*storage.Event, 0)
+	for _, e := range s.events {
+		if e.UserID != filter.UserID ||
+			!matchInterval(e, filter.From, filter.To) ||
+			!s.matchNotification(e.ID, filter.Notification) ||
+			!matchWords(e, terms) ||
+			e.CreatedAt.Before(filter.CreatedSince) ||
+			e.UpdatedAt.Before(filter.UpdatedSince) {
+			continue
+		}
+
+		if filter.After != nil && !follows(key(e), e.ID, filter.After, desc) {
+			continue
+		}
+
+		result = append(result, clone(e))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return follows(key(result[j]), result[j].ID, &storage.SearchCursor{Key: key(result[i]), ID: result[i].ID}, desc)
+	})
+	if len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+func matchInterval(e *storage.Event, from, to time.Time) bool {
+	if e.IsRecurring() {
+		return !e.TimeStart.After(to) && (!e.RecurrenceUntil.Valid || !e.RecurrenceUntil.Time.Before(from))
+	}
+
+	return !e.TimeStart.Before(from) && !e.TimeStart.After(to)
+}
+
+func (s *EventStorage) matchNotification(eventID int64, state storage.NotificationState) bool {
+	return state == storage.NotificationAny || s.notificationState(eventID) == state
+}
+
+// matchWords works like plainto_tsquery with the simple configuration: every term is a word of the event.
+func matchWords(e *storage.Event, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+
+	present := make(map[string]struct{})
+	for _, w := range words(e.Title + " " + e.Description) {
+		present[w] = struct{}{}
+	}
+
+	for _, t := range terms {
+		if _, ok := present[t]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func sortKey(sort storage.SearchSort) func(e *storage.Event) time.Time {
+	switch sort {
+	case storage.SortCreatedAt, storage.SortCreatedAtDesc:
+		return func(e *storage.Event) time.Time { return e.CreatedAt }
+	case storage.SortUpdatedAt, storage.SortUpdatedAtDesc:
+		return func(e *storage.Event) time.Time { return e.UpdatedAt }
+	default:
+		return func(e *storage.Event) time.Time { return e.TimeStart }
+	}
+}
+
+// follows reports whether (key, id) goes after the cursor in the sort order.
+func follows(key time.Time, id int64, c *storage.SearchCursor, desc bool) bool {
+	if key.Equal(c.Key) {
+		return id != c.ID && (id > c.ID) != desc
+	}
+
+	return key.After(c.Key) != desc
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStorage_Search(t *testing.T) {
+	unit := New()
+
+	standUp := gen(1, "Daily standup", "Backend team", testZeroTime)
+	standUp.RRule = "FREQ=DAILY"
+	review := gen(1, "Review", "Sprint review with the backend team", testZeroTime.Add(2*time.Hour))
+	review.Reminders = []*storage.Reminder{reminder(review, storage.ChannelLog, time.Hour)}
+	retro := gen(1, "Retro", "Sprint retrospective", testZeroTime.Add(3*time.Hour))
+	retro.Reminders = []*storage.Reminder{
+		reminder(retro, storage.ChannelLog, time.Hour),
+		reminder(retro, storage.ChannelEmail, time.Hour),
+	}
+	old := gen(1, "Backend sync", "", testZeroTime.AddDate(0, 0, -1))
+	other := gen(2, "Backend", "", testZeroTime.Add(time.Hour))
+
+	for _, e := range []*storage.Event{standUp, review, retro, old, other} {
+		_, err := unit.Create(ctx, e, nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, unit.MarkNotified(ctx, []int64{retro.Reminders[0].ID, retro.Reminders[1].ID}, nil))
+
+	search := func(f storage.SearchFilter) []int64 {
+		t.Helper()
+
+		f.UserID = 1
+		f.From, f.To = testZeroTime, testZeroTime.Add(24*time.Hour)
+		if f.Limit == 0 {
+			f.Limit = 99
+		}
+
+		events, err := unit.Search(ctx, f)
+		require.NoError(t, err)
+
+		ids := make([]int64, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+
+		return ids
+	}
+
+	require.Equal(t, []int64{standUp.ID, review.ID, retro.ID}, search(storage.SearchFilter{}))
+	desc := search(storage.SearchFilter{Sort: storage.SortTimeStartDesc})
+	require.Equal(t, []int64{retro.ID, review.ID, standUp.ID}, desc)
+
+	require.Equal(t, []int64{standUp.ID, review.ID}, search(storage.SearchFilter{Query: "backend TEAM"}))
+	require.Equal(t, []int64{review.ID, retro.ID}, search(storage.SearchFilter{Query: "sprint"}))
+	require.Empty(t, search(storage.SearchFilter{Query: "spr"}))
+
+	require.Equal(t, []int64{standUp.ID}, search(storage.SearchFilter{Notification: storage.NotificationNone}))
+	require.Equal(t, []int64{review.ID}, search(storage.SearchFilter{Notification: storage.NotificationPending}))
+	require.Equal(t, []int64{retro.ID}, search(storage.SearchFilter{Notification: storage.NotificationSent}))
+
+	require.Empty(t, search(storage.SearchFilter{CreatedSince: time.Now().Add(time.Hour)}))
+	require.Len(t, search(storage.SearchFilter{UpdatedSince: time.Now().Add(-time.Hour)}), 3)
+
+	first := search(storage.SearchFilter{Sort: storage.SortTimeStartDesc, Limit: 2})
+	require.Equal(t, []int64{retro.ID, review.ID}, first)
+	require.Equal(t, []int64{standUp.ID}, search(storage.SearchFilter{
+		Sort:  storage.SortTimeStartDesc,
+		After: &storage.SearchCursor{Key: review.TimeStart, ID: review.ID},
+	}))
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) GetDeleted(_ context.Context, id int64) (*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.trash[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return clone(e), nil
+}
+
+// FindDeleted returns the deleted events of the user ordered by (deleted_at, id) descending,
+// the exceptions deleted together with the series are not listed.
+func (s *EventStorage) FindDeleted(
+	_ context.Context,
+	userID int64,
+	after *storage.SearchCursor,
+	limit int) ([]*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*storage.Event, 0)
+	for _, e := range s.trash {
+		if e.UserID != userID || s.isDeletedWithSeries(e) {
+			continue
+		}
+
+		if after != nil && !isDeletedBefore(e, after) {
+			continue
+		}
+
+		result = append(result, clone(e))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return isDeletedBefore(result[j], &storage.SearchCursor{Key: result[i].DeletedAt.Time, ID: result[i].ID})
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+// isDeletedBefore reports whether the event follows the cursor in (deleted_at, id) descending order.
+func isDeletedBefore(e *storage.Event, c *storage.SearchCursor) bool {
+	t := e.DeletedAt.Time
+
+	return t.Before(c.Key) || (t.Equal(c.Key) && e.ID < c.ID)
+}
+
+// isDeletedWithSeries reports whether the exception is deleted together with its series,
+// the caller must hold the lock.
+func (s *EventStorage) isDeletedWithSeries(e *storage.Event) bool {
+	if !e.SeriesID.Valid {
+		return false
+	}
+
+	series, ok := s.trash[e.SeriesID.Int64]
+
+	return ok && series.DeletedAt.Time.Equal(e.DeletedAt.Time)
+}
+
+// Restore moves the event back from the trash together with the exceptions deleted with it.
+func (s *EventStorage) Restore(_ context.Context, id int64, history *storage.HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.trash[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	restored := []*storage.Event{e}
+	for _, exception := range s.trash {
+		if exception.SeriesID.Valid && exception.SeriesID.Int64 == id && s.isDeletedWithSeries(exception) {
+			restored = append(restored, exception)
+		}
+	}
+
+	for _, r := range restored {
+		if s.overlaps(r) {
+			return storage.ErrOverlap
+		}
+	}
+
+	noww := time.Now()
+	for _, r := range restored {
+		r.DeletedAt = storage.DeletedTime{}
+		r.UpdatedAt = noww
+		r.Version++
+		s.events[r.ID] = r
+		delete(s.trash, r.ID)
+	}
+	s.addHistory(history, noww)
+
+	return nil
+}
+
+// Purge deletes the event in the trash permanently together with its exceptions.
+func (s *EventStorage) Purge(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.trash[id]; !ok {
+		return storage.ErrNotFound
+	}
+
+	s.purge(id)
+
+	return nil
+}
+
+// PurgeDeleted permanently deletes the events which are in the trash since t or longer.
+func (s *EventStorage) PurgeDeleted(_ context.Context, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toPurge := make([]int64, 0)
+	for id, e := range s.trash {
+		if !e.DeletedAt.Time.After(t) {
+			toPurge = append(toPurge, id)
+		}
+	}
+
+	for _, id := range toPurge {
+		s.purge(id)
+	}
+
+	return nil
+}
+
+// purge deletes the event with its exceptions, the caller must hold the lock.
+func (s *EventStorage) purge(id int64) {
+	for _, events := range []map[int64]*storage.Event{s.events, s.trash} {
+		for exceptionID, e := range events {
+			if e.SeriesID.Valid && e.SeriesID.Int64 == id {
+				s.delete(exceptionID)
+			}
+		}
+	}
+	s.delete(id)
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStorage_Trash(t *testing.T) {
+	unit := New()
+
+	series := gen(1, "series", "", testZeroTime)
+	series.RRule = "FREQ=DAILY"
+	_, err := unit.Create(ctx, series, nil)
+	require.NoError(t, err)
+
+	exception := gen(1, "exception", "", testZeroTime.AddDate(0, 0, 1).Add(2*time.Hour))
+	exception.SeriesID = storage.SeriesID{Int64: series.ID, Valid: true}
+	exception.RecurrenceID = storage.RecurrenceTime{Time: testZeroTime.AddDate(0, 0, 1), Valid: true}
+	exception.Reminders = []*storage.Reminder{reminder(exception, storage.ChannelLog, time.Minute)}
+	_, err = unit.Create(ctx, exception, nil)
+	require.NoError(t, err)
+
+	oneOff := gen(1, "one-off", "", testZeroTime.Add(-2*time.Hour))
+	_, err = unit.Create(ctx, oneOff, nil)
+	require.NoError(t, err)
+
+	t.Run("deleted events are skipped", func(t *testing.T) {
+		require.NoError(t, unit.Delete(ctx, oneOff.ID, nil))
+		require.NoError(t, unit.Delete(ctx, series.ID, nil))
+
+		for _, id := range []int64{series.ID, exception.ID, oneOff.ID} {
+			_, err := unit.GetByID(ctx, id)
+			require.ErrorIs(t, err, storage.ErrNotFound)
+
+			e, err := unit.GetDeleted(ctx, id)
+			require.NoError(t, err)
+			require.True(t, e.DeletedAt.Valid)
+		}
+
+		events, err := unit.FindOverlapping(ctx, 1, testZeroTime.AddDate(0, 0, -1), testZeroTime.AddDate(0, 0, 2))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		due, err := unit.FindUnNotified(ctx, exception.TimeStart.Add(-time.Second))
+		require.NoError(t, err)
+		require.Empty(t, due)
+
+		// the deleted event is not updated
+		deleted, err := unit.GetDeleted(ctx, oneOff.ID)
+		require.NoError(t, err)
+		deleted.Title = "updated"
+		require.ErrorIs(t, unit.Update(ctx, deleted, nil), storage.ErrConflict)
+		_, err = unit.GetDeleted(ctx, oneOff.ID)
+		require.NoError(t, err)
+
+		// the time of the deleted event is free
+		taken := gen(1, "taken", "", oneOff.TimeStart)
+		_, err = unit.Create(ctx, taken, nil)
+		require.NoError(t, err)
+		require.NoError(t, unit.Delete(ctx, taken.ID, nil))
+	})
+
+	t.Run("trash lists the exceptions with the series", func(t *testing.T) {
+		events, err := unit.FindDeleted(ctx, 1, nil, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		ids := []int64{events[0].ID, events[1].ID, events[2].ID}
+		require.NotContains(t, ids, exception.ID)
+
+		page, err := unit.FindDeleted(ctx, 1, nil, 1)
+		require.NoError(t, err)
+		require.Len(t, page, 1)
+		next, err := unit.FindDeleted(ctx, 1, &storage.SearchCursor{Key: page[0].DeletedAt.Time, ID: page[0].ID}, 10)
+		require.NoError(t, err)
+		require.Len(t, next, 2)
+		require.NotEqual(t, page[0].ID, next[0].ID)
+
+		events, err = unit.FindDeleted(ctx, 2, nil, 10)
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.NoError(t, unit.Restore(ctx, series.ID, nil))
+		require.ErrorIs(t, unit.Restore(ctx, series.ID, nil), storage.ErrNotFound)
+
+		restored, err := unit.GetByID(ctx, series.ID)
+		require.NoError(t, err)
+		require.False(t, restored.DeletedAt.Valid)
+		require.Equal(t, series.Version+1, restored.Version)
+
+		_, err = unit.GetByID(ctx, exception.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("restore overlapping event", func(t *testing.T) {
+		_, err := unit.Create(ctx, gen(1, "", "", oneOff.TimeStart), nil)
+		require.NoError(t, err)
+
+		require.ErrorIs(t, unit.Restore(ctx, oneOff.ID, nil), storage.ErrOverlap)
+		_, err = unit.GetDeleted(ctx, oneOff.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		require.ErrorIs(t, unit.Purge(ctx, series.ID), storage.ErrNotFound)
+		require.NoError(t, unit.Purge(ctx, oneOff.ID))
+
+		_, err := unit.GetDeleted(ctx, oneOff.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("purge deleted", func(t *testing.T) {
+		require.NoError(t, unit.Delete(ctx, series.ID, nil))
+
+		require.NoError(t, unit.PurgeDeleted(ctx, time.Now().Add(-time.Hour)))
+		_, err := unit.GetDeleted(ctx, series.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, unit.PurgeDeleted(ctx, time.Now()))
+		events, err := unit.FindDeleted(ctx, 1, nil, 10)
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		reminders, err := unit.FindReminders(ctx, []int64{exception.ID})
+		require.NoError(t, err)
+		require.Empty(t, reminders)
+	})
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+var _ storage.UserStorage = (*UserStorage)(nil)
+
+type UserStorage struct {
+	mu sync.RWMutex
+
+	id    int64
+	users map[int64]*storage.User
+}
+
+func NewUserStorage() *UserStorage {
+	return &UserStorage{
+		users: make(map[int64]*storage.User),
+	}
+}
+
+func (s *UserStorage) Create(_ context.Context, user *storage.User) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Login == user.Login {
+			return 0, storage.ErrAlreadyExists
+		}
+	}
+
+	noww := time.Now()
+	s.id++
+	user.ID = s.id
+	user.CreatedAt = noww
+	user.UpdatedAt = noww
+
+	val := *user
+	s.users[s.id] = &val
+
+	return s.id, nil
+}
+
+func (s *UserStorage) Update(_ context.Context, user *storage.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return storage.ErrNotFound
+	}
+
+	val := *user
+	val.UpdatedAt = time.Now()
+	s.users[user.ID] = &val
+
+	return nil
+}
+
+func (s *UserStorage) GetByID(_ context.Context, id int64) (*storage.User, error) {
+	return s.find(func(u *storage.User) bool {
+		return u.ID == id
+	})
+}
+
+func (s *UserStorage) GetByLogin(_ context.Context, login string) (*storage.User, error) {
+	return s.find(func(u *storage.User) bool {
+		return u.Login == login
+	})
+}
+
+func (s *UserStorage) GetByTokenHash(_ context.Context, hash string) (*storage.User, error) {
+	if hash == "" {
+		return nil, storage.ErrNotFound
+	}
+
+	return s.find(func(u *storage.User) bool {
+		return u.TokenHash == hash
+	})
+}
+
+func (s *UserStorage) find(match func(u *storage.User) bool) (*storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if match(u) {
+			val := *u
+			return &val, nil
+		}
+	}
+
+	return nil, storage.ErrNotFound
+}
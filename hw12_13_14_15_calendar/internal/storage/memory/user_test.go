@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStorage(t *testing.T) {
+	unit := NewUserStorage()
+
+	user := &storage.User{Login: "user", PasswordHash: "hash", TokenHash: "token"}
+	id, err := unit.Create(ctx, user)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), id)
+	require.Equal(t, id, user.ID)
+
+	_, err = unit.Create(ctx, &storage.User{Login: "user"})
+	require.ErrorIs(t, err, storage.ErrAlreadyExists)
+
+	found, err := unit.GetByLogin(ctx, "user")
+	require.NoError(t, err)
+	require.Equal(t, user, found)
+
+	found.TokenHash = "new token"
+	require.NoError(t, unit.Update(ctx, found))
+
+	_, err = unit.GetByTokenHash(ctx, "token")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	found, err = unit.GetByTokenHash(ctx, "new token")
+	require.NoError(t, err)
+	require.Equal(t, id, found.ID)
+
+	_, err = unit.GetByTokenHash(ctx, "")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.ErrorIs(t, unit.Update(ctx, &storage.User{ID: 42}), storage.ErrNotFound)
+}
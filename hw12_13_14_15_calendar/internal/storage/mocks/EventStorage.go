@@ -15,20 +15,20 @@ type EventStorage struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: ctx, event
-func (_m *EventStorage) Create(ctx context.Context, event *storage.Event) (int64, error) {
-	ret := _m.Called(ctx, event)
+// AddAttendee provides a mock function with given fields: ctx, attendee
+func (_m *EventStorage) AddAttendee(ctx context.Context, attendee *storage.Attendee) (int64, error) {
+	ret := _m.Called(ctx, attendee)
 
 	var r0 int64
-	if rf, ok := ret.Get(0).(func(context.Context, *storage.Event) int64); ok {
-		r0 = rf(ctx, event)
+	if rf, ok := ret.Get(0).(func(context.Context, *storage.Attendee) int64); ok {
+		r0 = rf(ctx, attendee)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *storage.Event) error); ok {
-		r1 = rf(ctx, event)
+	if rf, ok := ret.Get(1).(func(context.Context, *storage.Attendee) error); ok {
+		r1 = rf(ctx, attendee)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -36,13 +36,13 @@ func (_m *EventStorage) Create(ctx context.Context, event *storage.Event) (int64
 	return r0, r1
 }
 
-// Delete provides a mock function with given fields: ctx, id
-func (_m *EventStorage) Delete(ctx context.Context, id int64) error {
-	ret := _m.Called(ctx, id)
+// ApplyBatch provides a mock function with given fields: ctx, batch
+func (_m *EventStorage) ApplyBatch(ctx context.Context, batch *storage.Batch) error {
+	ret := _m.Called(ctx, batch)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, *storage.Batch) error); ok {
+		r0 = rf(ctx, batch)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -50,13 +50,34 @@ func (_m *EventStorage) Delete(ctx context.Context, id int64) error {
 	return r0
 }
 
-// DeleteOlderThan provides a mock function with given fields: ctx, t
-func (_m *EventStorage) DeleteOlderThan(ctx context.Context, t time.Time) error {
-	ret := _m.Called(ctx, t)
+// Create provides a mock function with given fields: ctx, event, history
+func (_m *EventStorage) Create(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) (int64, error) {
+	ret := _m.Called(ctx, event, history)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, *storage.Event, *storage.HistoryEntry) int64); ok {
+		r0 = rf(ctx, event, history)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *storage.Event, *storage.HistoryEntry) error); ok {
+		r1 = rf(ctx, event, history)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: ctx, id, history
+func (_m *EventStorage) Delete(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	ret := _m.Called(ctx, id, history)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
-		r0 = rf(ctx, t)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, *storage.HistoryEntry) error); ok {
+		r0 = rf(ctx, id, history)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -64,13 +85,64 @@ func (_m *EventStorage) DeleteOlderThan(ctx context.Context, t time.Time) error
 	return r0
 }
 
-// FindForInterval provides a mock function with given fields: ctx, userID, from, to, limit, offset
-func (_m *EventStorage) FindForInterval(ctx context.Context, userID int64, from time.Time, to time.Time, limit uint8, offset uint8) ([]*storage.Event, error) {
-	ret := _m.Called(ctx, userID, from, to, limit, offset)
+// DeleteOlderThan provides a mock function with given fields: ctx, filter
+func (_m *EventStorage) DeleteOlderThan(ctx context.Context, filter storage.RetentionFilter) error {
+	ret := _m.Called(ctx, filter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.RetentionFilter) error); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOutbox provides a mock function with given fields: ctx, id
+func (_m *EventStorage) DeleteOutbox(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindAttendees provides a mock function with given fields: ctx, eventID
+func (_m *EventStorage) FindAttendees(ctx context.Context, eventID int64) ([]*storage.Attendee, error) {
+	ret := _m.Called(ctx, eventID)
+
+	var r0 []*storage.Attendee
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*storage.Attendee); ok {
+		r0 = rf(ctx, eventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Attendee)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, eventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDeleted provides a mock function with given fields: ctx, userID, after, limit
+func (_m *EventStorage) FindDeleted(ctx context.Context, userID int64, after *storage.SearchCursor, limit int) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, userID, after, limit)
 
 	var r0 []*storage.Event
-	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time, time.Time, uint8, uint8) []*storage.Event); ok {
-		r0 = rf(ctx, userID, from, to, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, *storage.SearchCursor, int) []*storage.Event); ok {
+		r0 = rf(ctx, userID, after, limit)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*storage.Event)
@@ -78,8 +150,169 @@ func (_m *EventStorage) FindForInterval(ctx context.Context, userID int64, from
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time, time.Time, uint8, uint8) error); ok {
-		r1 = rf(ctx, userID, from, to, limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, int64, *storage.SearchCursor, int) error); ok {
+		r1 = rf(ctx, userID, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindForInterval provides a mock function with given fields: ctx, userID, from, to, after, limit
+func (_m *EventStorage) FindForInterval(ctx context.Context, userID int64, from time.Time, to time.Time, after *storage.Cursor, limit int) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, userID, from, to, after, limit)
+
+	var r0 []*storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time, time.Time, *storage.Cursor, int) []*storage.Event); ok {
+		r0 = rf(ctx, userID, from, to, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time, time.Time, *storage.Cursor, int) error); ok {
+		r1 = rf(ctx, userID, from, to, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindHistory provides a mock function with given fields: ctx, eventID
+func (_m *EventStorage) FindHistory(ctx context.Context, eventID int64) ([]*storage.HistoryEntry, error) {
+	ret := _m.Called(ctx, eventID)
+
+	var r0 []*storage.HistoryEntry
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*storage.HistoryEntry); ok {
+		r0 = rf(ctx, eventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.HistoryEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, eventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindInvitations provides a mock function with given fields: ctx, userID
+func (_m *EventStorage) FindInvitations(ctx context.Context, userID int64) ([]*storage.Invitation, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*storage.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*storage.Invitation); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Invitation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindOutbox provides a mock function with given fields: ctx, t, limit
+func (_m *EventStorage) FindOutbox(ctx context.Context, t time.Time, limit int) ([]*storage.OutboxMessage, error) {
+	ret := _m.Called(ctx, t, limit)
+
+	var r0 []*storage.OutboxMessage
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []*storage.OutboxMessage); ok {
+		r0 = rf(ctx, t, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.OutboxMessage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, t, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindOverlapping provides a mock function with given fields: ctx, userID, from, to
+func (_m *EventStorage) FindOverlapping(ctx context.Context, userID int64, from time.Time, to time.Time) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, userID, from, to)
+
+	var r0 []*storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time, time.Time) []*storage.Event); ok {
+		r0 = rf(ctx, userID, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, userID, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRecurring provides a mock function with given fields: ctx, userID, from, to
+func (_m *EventStorage) FindRecurring(ctx context.Context, userID int64, from time.Time, to time.Time) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, userID, from, to)
+
+	var r0 []*storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time, time.Time) []*storage.Event); ok {
+		r0 = rf(ctx, userID, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, userID, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindReminders provides a mock function with given fields: ctx, eventIDs
+func (_m *EventStorage) FindReminders(ctx context.Context, eventIDs []int64) ([]*storage.Reminder, error) {
+	ret := _m.Called(ctx, eventIDs)
+
+	var r0 []*storage.Reminder
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) []*storage.Reminder); ok {
+		r0 = rf(ctx, eventIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Reminder)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, eventIDs)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -88,15 +321,15 @@ func (_m *EventStorage) FindForInterval(ctx context.Context, userID int64, from
 }
 
 // FindUnNotified provides a mock function with given fields: ctx, t
-func (_m *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*storage.Event, error) {
+func (_m *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*storage.DueReminder, error) {
 	ret := _m.Called(ctx, t)
 
-	var r0 []*storage.Event
-	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*storage.Event); ok {
+	var r0 []*storage.DueReminder
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*storage.DueReminder); ok {
 		r0 = rf(ctx, t)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*storage.Event)
+			r0 = ret.Get(0).([]*storage.DueReminder)
 		}
 	}
 
@@ -110,6 +343,52 @@ func (_m *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*sto
 	return r0, r1
 }
 
+// FindUnNotifiedAttendees provides a mock function with given fields: ctx
+func (_m *EventStorage) FindUnNotifiedAttendees(ctx context.Context) ([]*storage.Invitation, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*storage.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context) []*storage.Invitation); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Invitation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindUserHistory provides a mock function with given fields: ctx, userID, afterID, limit
+func (_m *EventStorage) FindUserHistory(ctx context.Context, userID int64, afterID int64, limit int) ([]*storage.HistoryEntry, error) {
+	ret := _m.Called(ctx, userID, afterID, limit)
+
+	var r0 []*storage.HistoryEntry
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64, int) []*storage.HistoryEntry); ok {
+		r0 = rf(ctx, userID, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.HistoryEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64, int) error); ok {
+		r1 = rf(ctx, userID, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByID provides a mock function with given fields: ctx, id
 func (_m *EventStorage) GetByID(ctx context.Context, id int64) (*storage.Event, error) {
 	ret := _m.Called(ctx, id)
@@ -133,13 +412,157 @@ func (_m *EventStorage) GetByID(ctx context.Context, id int64) (*storage.Event,
 	return r0, r1
 }
 
-// MarkNotified provides a mock function with given fields: ctx, ids
-func (_m *EventStorage) MarkNotified(ctx context.Context, ids []int64) error {
-	ret := _m.Called(ctx, ids)
+// GetDeleted provides a mock function with given fields: ctx, id
+func (_m *EventStorage) GetDeleted(ctx context.Context, id int64) (*storage.Event, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *storage.Event); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkAttendeesNotified provides a mock function with given fields: ctx, ids, outbox
+func (_m *EventStorage) MarkAttendeesNotified(ctx context.Context, ids []int64, outbox []*storage.OutboxMessage) error {
+	ret := _m.Called(ctx, ids, outbox)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, []*storage.OutboxMessage) error); ok {
+		r0 = rf(ctx, ids, outbox)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkNotified provides a mock function with given fields: ctx, reminderIDs, outbox
+func (_m *EventStorage) MarkNotified(ctx context.Context, reminderIDs []int64, outbox []*storage.OutboxMessage) error {
+	ret := _m.Called(ctx, reminderIDs, outbox)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, []*storage.OutboxMessage) error); ok {
+		r0 = rf(ctx, reminderIDs, outbox)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Purge provides a mock function with given fields: ctx, id
+func (_m *EventStorage) Purge(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeDeleted provides a mock function with given fields: ctx, t
+func (_m *EventStorage) PurgeDeleted(ctx context.Context, t time.Time) error {
+	ret := _m.Called(ctx, t)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, t)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RearmReminders provides a mock function with given fields: ctx, reminders, outbox
+func (_m *EventStorage) RearmReminders(ctx context.Context, reminders []*storage.Reminder, outbox []*storage.OutboxMessage) error {
+	ret := _m.Called(ctx, reminders, outbox)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*storage.Reminder, []*storage.OutboxMessage) error); ok {
+		r0 = rf(ctx, reminders, outbox)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Restore provides a mock function with given fields: ctx, id, history
+func (_m *EventStorage) Restore(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	ret := _m.Called(ctx, id, history)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, *storage.HistoryEntry) error); ok {
+		r0 = rf(ctx, id, history)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RetryOutbox provides a mock function with given fields: ctx, id, nextAttemptAt
+func (_m *EventStorage) RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time) error {
+	ret := _m.Called(ctx, id, nextAttemptAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time) error); ok {
+		r0 = rf(ctx, id, nextAttemptAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Search provides a mock function with given fields: ctx, filter
+func (_m *EventStorage) Search(ctx context.Context, filter storage.SearchFilter) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []*storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, storage.SearchFilter) []*storage.Event); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.SearchFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, event, history
+func (_m *EventStorage) Update(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) error {
+	ret := _m.Called(ctx, event, history)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, []int64) error); ok {
-		r0 = rf(ctx, ids)
+	if rf, ok := ret.Get(0).(func(context.Context, *storage.Event, *storage.HistoryEntry) error); ok {
+		r0 = rf(ctx, event, history)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -147,13 +570,13 @@ func (_m *EventStorage) MarkNotified(ctx context.Context, ids []int64) error {
 	return r0
 }
 
-// Update provides a mock function with given fields: ctx, event
-func (_m *EventStorage) Update(ctx context.Context, event *storage.Event) error {
-	ret := _m.Called(ctx, event)
+// UpdateAttendee provides a mock function with given fields: ctx, attendee
+func (_m *EventStorage) UpdateAttendee(ctx context.Context, attendee *storage.Attendee) error {
+	ret := _m.Called(ctx, attendee)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *storage.Event) error); ok {
-		r0 = rf(ctx, event)
+	if rf, ok := ret.Get(0).(func(context.Context, *storage.Attendee) error); ok {
+		r0 = rf(ctx, attendee)
 	} else {
 		r0 = ret.Error(0)
 	}
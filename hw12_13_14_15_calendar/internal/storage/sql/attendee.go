@@ -0,0 +1,256 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const attendeeFields = `
+			a.id,
+			a.event_id,
+			a.user_id,
+			a.email,
+			a.role,
+			a.status,
+			a.invitation_sent,
+			a.response_sent,
+			a.created_at,
+			a.updated_at`
+
+func (s *EventStorage) AddAttendee(ctx context.Context, attendee *storage.Attendee) (int64, error) {
+	q := `
+		INSERT INTO
+			attendees (event_id, user_id, email, role, status, invitation_sent, response_sent,
+				created_at, updated_at)
+		VALUES
+			(:event_id, :user_id, :email, :role, :status, :invitation_sent, :response_sent,
+				:created_at, :updated_at)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+		;
+`
+	now := time.Now()
+
+	res, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"event_id":        attendee.EventID,
+		"user_id":         attendee.UserID,
+		"email":           attendee.Email,
+		"role":            attendee.Role,
+		"status":          attendee.Status,
+		"invitation_sent": attendee.InvitationSent,
+		"response_sent":   attendee.ResponseSent,
+		"created_at":      now,
+		"updated_at":      now,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("attendee add: %w", err)
+	}
+	defer func() {
+		_ = res.Close()
+		_ = res.Err()
+	}()
+
+	// nothing is returned when the attendee is already invited
+	if !res.Next() {
+		return 0, storage.ErrAlreadyExists
+	}
+
+	if err := res.Scan(&attendee.ID); err != nil {
+		return 0, fmt.Errorf("attendee retrieve last insert id: %w", err)
+	}
+
+	attendee.CreatedAt, attendee.UpdatedAt = now, now
+
+	return attendee.ID, nil
+}
+
+func (s *EventStorage) UpdateAttendee(ctx context.Context, attendee *storage.Attendee) error {
+	q := `
+		UPDATE
+			attendees
+		SET
+			role=:role,
+			status=:status,
+			invitation_sent=:invitation_sent,
+			response_sent=:response_sent,
+			updated_at=:updated_at
+		WHERE
+			id=:id
+		;
+`
+	now := time.Now()
+
+	res, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+		"role":            attendee.Role,
+		"status":          attendee.Status,
+		"invitation_sent": attendee.InvitationSent,
+		"response_sent":   attendee.ResponseSent,
+		"updated_at":      now,
+		"id":              attendee.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("attendee update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("attendee update: %w", err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	attendee.UpdatedAt = now
+
+	return nil
+}
+
+func (s *EventStorage) FindAttendees(ctx context.Context, eventID int64) ([]*storage.Attendee, error) {
+	q := `
+		SELECT
+			` + attendeeFields + `
+		FROM
+			attendees a
+		WHERE
+			a.event_id=:event_id
+		ORDER BY a.id
+		;
+`
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"event_id": eventID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attendee find: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.Attendee, 0)
+
+	for rows.Next() {
+		a := &storage.Attendee{}
+		if err := rows.Scan(attendeeDest(a)...); err != nil {
+			return nil, fmt.Errorf("attendee find: %w", err)
+		}
+
+		result = append(result, a)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) FindInvitations(ctx context.Context, userID int64) ([]*storage.Invitation, error) {
+	q := `
+		SELECT
+			` + attendeeFields + `,
+			e.*
+		FROM
+			attendees a
+			JOIN (SELECT ` + eventFields + ` FROM events WHERE deleted_at IS NULL) e ON e.id = a.event_id
+		WHERE
+			a.user_id=:user_id
+		ORDER BY a.id
+		;
+`
+	result, err := s.findInvitations(ctx, q, map[string]interface{}{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attendee find invitations: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) FindUnNotifiedAttendees(ctx context.Context) ([]*storage.Invitation, error) {
+	q := `
+		SELECT
+			` + attendeeFields + `,
+			e.*
+		FROM
+			attendees a
+			JOIN (SELECT ` + eventFields + ` FROM events WHERE deleted_at IS NULL) e ON e.id = a.event_id
+		WHERE
+			a.invitation_sent = false
+			OR a.response_sent = false
+		ORDER BY a.id
+		;
+`
+	result, err := s.findInvitations(ctx, q, map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("attendee find unnotified: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) MarkAttendeesNotified(ctx context.Context, ids []int64, outbox []*storage.OutboxMessage) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	q := `
+		UPDATE
+			attendees
+		SET
+			invitation_sent = true, response_sent = true, updated_at = now()
+		WHERE
+			id IN(?)
+		;
+`
+	if err := s.markWithOutbox(ctx, q, ids, outbox); err != nil {
+		return fmt.Errorf("attendee mark notified: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventStorage) findInvitations(
+	ctx context.Context,
+	q string,
+	arg map[string]interface{},
+) ([]*storage.Invitation, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.Invitation, 0)
+
+	for rows.Next() {
+		a := &storage.Attendee{}
+		e := &storage.Event{}
+		if err := s.scanWith(rows, e, attendeeDest(a)...); err != nil {
+			return nil, err
+		}
+
+		result = append(result, &storage.Invitation{Event: e, Attendee: a})
+	}
+
+	return result, nil
+}
+
+func attendeeDest(a *storage.Attendee) []interface{} {
+	return []interface{}{
+		&a.ID,
+		&a.EventID,
+		&a.UserID,
+		&a.Email,
+		&a.Role,
+		&a.Status,
+		&a.InvitationSent,
+		&a.ResponseSent,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	}
+}
@@ -0,0 +1,211 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jmoiron/sqlx"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+// batchInsertSize is the number of events inserted by a single statement,
+// it keeps the statement under the limit of 65535 parameters.
+const batchInsertSize = 500
+
+func (s *EventStorage) ApplyBatch(ctx context.Context, batch *storage.Batch) error {
+	now := time.Now()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("event apply batch: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if len(batch.Delete) > 0 {
+		deleted, err := s.deleteEvents(ctx, tx, batch.Delete)
+		if err != nil {
+			return fmt.Errorf("event apply batch: %w", err)
+		}
+
+		for i, entry := range batch.DeleteHistory {
+			if !containsID(deleted, batch.Delete[i]) {
+				continue
+			}
+
+			if err := s.addHistory(ctx, tx, entry, now); err != nil {
+				return fmt.Errorf("event apply batch: %w", err)
+			}
+		}
+	}
+
+	// the events are not changed until the transaction is committed, so the failed batch can be retried
+	versions := make([]int64, 0, len(batch.Update))
+	for _, e := range batch.Update {
+		version, err := s.update(ctx, tx, e, now)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, version)
+	}
+
+	for _, entry := range batch.UpdateHistory {
+		if err := s.addHistory(ctx, tx, entry, now); err != nil {
+			return fmt.Errorf("event apply batch: %w", err)
+		}
+	}
+
+	ids := make([]int64, 0, len(batch.Create))
+	for i := 0; i < len(batch.Create); i += batchInsertSize {
+		end := i + batchInsertSize
+		if end > len(batch.Create) {
+			end = len(batch.Create)
+		}
+
+		inserted, err := s.insertEvents(ctx, tx, batch.Create[i:end], now)
+		if err != nil {
+			return fmt.Errorf("event apply batch: %w", err)
+		}
+		ids = append(ids, inserted...)
+	}
+
+	for i, e := range batch.Create {
+		// the reminders are saved with a copy, the id of the event is set after the commit
+		created := *e
+		created.ID = ids[i]
+		if err := s.saveReminders(ctx, tx, &created, now); err != nil {
+			return fmt.Errorf("event apply batch: %w", err)
+		}
+	}
+
+	for i, entry := range batch.CreateHistory {
+		entry.EventID = ids[i]
+		if err := s.addHistory(ctx, tx, entry, now); err != nil {
+			return fmt.Errorf("event apply batch: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("event apply batch: %w", err)
+	}
+
+	for i, e := range batch.Update {
+		e.UpdatedAt = now
+		e.Version = versions[i]
+	}
+
+	for i, e := range batch.Create {
+		e.ID = ids[i]
+		e.CreatedAt, e.UpdatedAt = now, now
+		e.Version = 1
+	}
+
+	return nil
+}
+
+// deleteEvents moves the events with their exceptions to the trash like Delete does
+// and returns the ids of the events moved by this call.
+func (s *EventStorage) deleteEvents(ctx context.Context, tx *sqlx.Tx, ids []int64) ([]int64, error) {
+	q := `
+		UPDATE
+			events
+		SET
+			deleted_at=now()
+		WHERE
+			(id = ANY(:ids) OR series_id = ANY(:ids))
+			AND deleted_at IS NULL
+		RETURNING id
+		;
+`
+	a := &pgtype.Int8Array{}
+	if err := a.Set(ids); err != nil {
+		return nil, fmt.Errorf("event ids: %w", err)
+	}
+
+	deleted, err := s.changeIDs(ctx, tx, q, map[string]interface{}{
+		"ids": a,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("delete events: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// insertEvents inserts the events by a single statement and returns their ids.
+func (s *EventStorage) insertEvents(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	events []*storage.Event,
+	now time.Time,
+) ([]int64, error) {
+	values := make([]string, 0, len(events))
+	args := make(map[string]interface{}, len(events)*14+1)
+	args["now"] = now
+
+	for i, e := range events {
+		exDates, err := timestampArray(e.ExDates)
+		if err != nil {
+			return nil, fmt.Errorf("insert events: %w", err)
+		}
+
+		n := strconv.Itoa(i)
+		values = append(values, `(:user_id_`+n+`, :title_`+n+`, :description_`+n+`, :time_start_`+n+
+			`, :time_end_`+n+`, :now, :now, :rrule_`+n+`, :ex_dates_`+n+`, :recurrence_until_`+n+
+			`, :series_id_`+n+`, :recurrence_id_`+n+`, :time_zone_`+n+`, :non_blocking_`+n+`)`)
+
+		args["user_id_"+n] = e.UserID
+		args["title_"+n] = e.Title
+		args["description_"+n] = e.Description
+		args["time_start_"+n] = e.TimeStart
+		args["time_end_"+n] = e.TimeEnd
+		args["rrule_"+n] = e.RRule
+		args["ex_dates_"+n] = exDates
+		args["recurrence_until_"+n] = e.RecurrenceUntil
+		args["series_id_"+n] = e.SeriesID
+		args["recurrence_id_"+n] = e.RecurrenceID
+		args["time_zone_"+n] = e.TimeZone
+		args["non_blocking_"+n] = e.NonBlocking
+	}
+
+	// the ids are returned in the order of the values
+	q := `
+		INSERT INTO
+			events (user_id, title, description, time_start, time_end, created_at, updated_at,
+				rrule, ex_dates, recurrence_until, series_id, recurrence_id, time_zone, non_blocking)
+		VALUES
+			` + strings.Join(values, ",\n\t\t\t") + `
+		RETURNING id
+		;
+`
+	rows, err := sqlx.NamedQueryContext(ctx, tx, q, args)
+	if err != nil {
+		return nil, fmt.Errorf("insert events: %w", overlapError(err))
+	}
+	// the rows must be closed before the next statement of the transaction
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	ids := make([]int64, len(events))
+	for i := range ids {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return nil, fmt.Errorf("insert events: %w", overlapError(err))
+			}
+
+			return nil, fmt.Errorf("insert events: fewer ids than %d events are returned", len(events))
+		}
+
+		if err := rows.Scan(&ids[i]); err != nil {
+			return nil, fmt.Errorf("insert events: %w", err)
+		}
+	}
+
+	return ids, nil
+}
@@ -2,15 +2,40 @@ package sql
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
 	"github.com/jmoiron/sqlx"
 	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 )
 
 var _ storage.EventStorage = (*EventStorage)(nil)
 
+const eventFields = `
+			id, 
+			user_id,
+			title,
+			description,
+			time_start, 
+			time_end,
+			created_at,
+			updated_at,
+			rrule,
+			ex_dates,
+			recurrence_until,
+			series_id,
+			recurrence_id,
+			time_zone,
+			version,
+			non_blocking,
+			deleted_at`
+
+// exclusionViolation is the SQLSTATE of the events_no_overlap constraint.
+const exclusionViolation = "23P01"
+
 type EventStorage struct {
 	db *sqlx.DB
 }
@@ -19,50 +44,122 @@ func New() *EventStorage {
 	return &EventStorage{}
 }
 
-func (s *EventStorage) Create(ctx context.Context, event *storage.Event) (int64, error) {
+func (s *EventStorage) Create(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) (int64, error) {
 	q := `
 		INSERT INTO 
-			events (user_id, title, description, time_start, time_end, notify_at, created_at, updated_at)
+			events (user_id, title, description, time_start, time_end, created_at, updated_at,
+				rrule, ex_dates, recurrence_until, series_id, recurrence_id, time_zone, non_blocking)
 		VALUES 
-			(:user_id, :title, :description, :time_start, :time_end, :notify_at, :created_at, :updated_at)
+			(:user_id, :title, :description, :time_start, :time_end, :created_at, :updated_at,
+				:rrule, :ex_dates, :recurrence_until, :series_id, :recurrence_id, :time_zone, :non_blocking)
 		RETURNING id
 		;
 `
 	now := time.Now()
 
-	res, err := s.db.NamedQueryContext(
+	exDates, err := timestampArray(event.ExDates)
+	if err != nil {
+		return 0, fmt.Errorf("event create: %w", err)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("event create: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := sqlx.NamedQueryContext(
 		ctx,
+		tx,
 		q,
 		map[string]interface{}{
-			"user_id":     event.UserID,
-			"title":       event.Title,
-			"description": event.Description,
-			"time_start":  event.TimeStart,
-			"time_end":    event.TimeEnd,
-			"notify_at":   event.NotifyAt,
-			"created_at":  now,
-			"updated_at":  now,
+			"user_id":          event.UserID,
+			"title":            event.Title,
+			"description":      event.Description,
+			"time_start":       event.TimeStart,
+			"time_end":         event.TimeEnd,
+			"created_at":       now,
+			"updated_at":       now,
+			"rrule":            event.RRule,
+			"ex_dates":         exDates,
+			"recurrence_until": event.RecurrenceUntil,
+			"series_id":        event.SeriesID,
+			"recurrence_id":    event.RecurrenceID,
+			"time_zone":        event.TimeZone,
+			"non_blocking":     event.NonBlocking,
 		},
 	)
 	if err != nil {
-		return 0, fmt.Errorf("event create: %w", err)
+		return 0, fmt.Errorf("event create: %w", overlapError(err))
 	}
-	defer func() {
-		_ = res.Close()
-		_ = res.Err()
-	}()
 
-	res.Next()
-	if err := res.Scan(&event.ID); err != nil {
+	if !res.Next() {
+		err = res.Err()
+		_ = res.Close()
+		return 0, fmt.Errorf("event create: %w", overlapError(err))
+	}
+	err = res.Scan(&event.ID)
+	_ = res.Close()
+	if err != nil {
 		return 0, fmt.Errorf("event retrieve last insert id: %w", err)
 	}
 
+	if err := s.saveReminders(ctx, tx, event, now); err != nil {
+		return 0, fmt.Errorf("event create: %w", err)
+	}
+
+	if history != nil {
+		history.EventID = event.ID
+	}
+	if err := s.addHistory(ctx, tx, history, now); err != nil {
+		return 0, fmt.Errorf("event create: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("event create: %w", err)
+	}
+
 	event.CreatedAt, event.UpdatedAt = now, now
+	event.Version = 1
 
 	return event.ID, nil
 }
 
-func (s *EventStorage) Update(ctx context.Context, event *storage.Event) error {
+func (s *EventStorage) Update(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) error {
+	now := time.Now()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("event update: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	version, err := s.update(ctx, tx, event, now)
+	if err != nil {
+		return err
+	}
+
+	if err := s.addHistory(ctx, tx, history, now); err != nil {
+		return fmt.Errorf("event update: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("event update: %w", err)
+	}
+
+	event.UpdatedAt = now
+	event.Version = version
+
+	return nil
+}
+
+// update saves the event with its reminders in the transaction and returns the incremented version,
+// the event is not changed until the transaction is committed.
+func (s *EventStorage) update(ctx context.Context, tx *sqlx.Tx, event *storage.Event, now time.Time) (int64, error) {
 	q := `
 		UPDATE 
 			events 
@@ -73,72 +170,165 @@ func (s *EventStorage) Update(ctx context.Context, event *storage.Event) error {
 			time_start=:time_start,
 			time_end=:time_end,
 			updated_at=:updated_at,
-			notify_at=:notify_at
+			rrule=:rrule,
+			ex_dates=:ex_dates,
+			recurrence_until=:recurrence_until,
+			time_zone=:time_zone,
+			non_blocking=:non_blocking,
+			version=version + 1
 		WHERE
 			id=:id
+			AND version=:version
+			AND deleted_at IS NULL
+		RETURNING version
 		;
 `
-	now := time.Now()
+	exDates, err := timestampArray(event.ExDates)
+	if err != nil {
+		return 0, fmt.Errorf("event update: %w", err)
+	}
 
-	_, err := s.db.NamedExecContext(
+	res, err := sqlx.NamedQueryContext(
 		ctx,
+		tx,
 		q,
 		map[string]interface{}{
-			"user_id":     event.UserID,
-			"title":       event.Title,
-			"description": event.Description,
-			"time_start":  event.TimeStart,
-			"time_end":    event.TimeEnd,
-			"notify_at":   event.NotifyAt,
-			"updated_at":  now,
-			"id":          event.ID,
+			"user_id":          event.UserID,
+			"title":            event.Title,
+			"description":      event.Description,
+			"time_start":       event.TimeStart,
+			"time_end":         event.TimeEnd,
+			"updated_at":       now,
+			"rrule":            event.RRule,
+			"ex_dates":         exDates,
+			"recurrence_until": event.RecurrenceUntil,
+			"time_zone":        event.TimeZone,
+			"non_blocking":     event.NonBlocking,
+			"id":               event.ID,
+			"version":          event.Version,
 		},
 	)
 	if err != nil {
-		return fmt.Errorf("event update: %w", err)
+		return 0, fmt.Errorf("event update: %w", overlapError(err))
 	}
 
-	event.UpdatedAt = now
+	// the event is changed or deleted since it was read when no row is updated
+	var version int64
+	if !res.Next() {
+		err = res.Err()
+		_ = res.Close()
+		if err != nil {
+			return 0, fmt.Errorf("event update: %w", overlapError(err))
+		}
 
-	return nil
+		return 0, storage.ErrConflict
+	}
+	err = res.Scan(&version)
+	_ = res.Close()
+	if err != nil {
+		return 0, fmt.Errorf("event update: %w", err)
+	}
+
+	if err := s.saveReminders(ctx, tx, event, now); err != nil {
+		return 0, fmt.Errorf("event update: %w", err)
+	}
+
+	return version, nil
 }
 
-func (s *EventStorage) Delete(ctx context.Context, id int64) error {
+// Delete records the history only when the event is moved to the trash by this call.
+func (s *EventStorage) Delete(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	// the exceptions deleted together with the series have the same deleted_at and are restored with it
 	q := `
-		DELETE FROM 
-			events 
-		WHERE 
-			id=:id
+		UPDATE
+			events
+		SET
+			deleted_at=now()
+		WHERE
+			(id=:id OR series_id=:id)
+			AND deleted_at IS NULL
+		RETURNING id
 		;
 `
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("event delete: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	_, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+	deleted, err := s.changeIDs(ctx, tx, q, map[string]interface{}{
 		"id": id,
 	})
 	if err != nil {
 		return fmt.Errorf("event delete: %w", err)
 	}
 
+	if containsID(deleted, id) {
+		if err := s.addHistory(ctx, tx, history, time.Now()); err != nil {
+			return fmt.Errorf("event delete: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("event delete: %w", err)
+	}
+
 	return nil
 }
 
+// changeIDs executes the change query within the transaction and returns the ids of the changed events.
+func (s *EventStorage) changeIDs(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	q string,
+	args map[string]interface{},
+) ([]int64, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, tx, q, args)
+	if err != nil {
+		return nil, err
+	}
+	// the rows must be closed before the next statement of the transaction
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *EventStorage) GetByID(ctx context.Context, id int64) (*storage.Event, error) {
 	q := `
 		SELECT
-			id, 
-			user_id,
-			title,
-			description,
-			time_start, 
-			time_end,
-			notify_at,
-			created_at,
-			updated_at,
-			notification_sent
+			` + eventFields + `
 		FROM 
 			events
 		WHERE
 			id=:id
+			AND deleted_at IS NULL
 		;
 `
 	e := &storage.Event{}
@@ -169,37 +359,83 @@ func (s *EventStorage) FindForInterval(
 	ctx context.Context,
 	userID int64,
 	from, to time.Time,
-	limit, offset uint8) ([]*storage.Event, error) {
+	after *storage.Cursor,
+	limit int) ([]*storage.Event, error) {
 	q := `
 		SELECT
-			id, 
-			user_id,
-			title,
-			description,
-			time_start, 
-			time_end,
-			notify_at,
-			created_at,
-			updated_at,
-			notification_sent
+			` + eventFields + `
 		FROM
 			events
 		WHERE
 			user_id=:user_id
+			AND rrule = ''
+			AND deleted_at IS NULL
 			AND time_start BETWEEN :from AND :to
+			AND (time_start, id) > (:after_time_start, :after_id)
+		ORDER BY time_start, id
+		LIMIT :limit
+		;
+`
+	// ids start from 1, so the first page is everything after (from, 0)
+	if after == nil {
+		after = &storage.Cursor{TimeStart: from}
+	}
+
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"user_id":          userID,
+		"from":             from,
+		"to":               to,
+		"after_time_start": after.TimeStart,
+		"after_id":         after.ID,
+		"limit":            limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("event find for interval: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.Event, 0)
+
+	for rows.Next() {
+		e := &storage.Event{}
+		if err := s.scan(rows, e); err != nil {
+			return nil, fmt.Errorf("storage find for interval: %w", err)
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) FindRecurring(
+	ctx context.Context,
+	userID int64,
+	from, to time.Time) ([]*storage.Event, error) {
+	q := `
+		SELECT
+			` + eventFields + `
+		FROM
+			events
+		WHERE
+			user_id=:user_id
+			AND rrule <> ''
+			AND deleted_at IS NULL
+			AND time_start <= :to
+			AND (recurrence_until IS NULL OR recurrence_until >= :from)
 		ORDER BY time_start
-		LIMIT :limit OFFSET :offset
 		;
 `
 	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
 		"user_id": userID,
 		"from":    from,
 		"to":      to,
-		"limit":   limit,
-		"offset":  offset,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("event find for interval: %w", err)
+		return nil, fmt.Errorf("event find recurring: %w", err)
 	}
 	defer func() {
 		_ = rows.Close()
@@ -211,7 +447,7 @@ func (s *EventStorage) FindForInterval(
 	for rows.Next() {
 		e := &storage.Event{}
 		if err := s.scan(rows, e); err != nil {
-			return nil, fmt.Errorf("storage find for interval: %w", err)
+			return nil, fmt.Errorf("event find recurring: %w", err)
 		}
 
 		result = append(result, e)
@@ -234,34 +470,35 @@ func (s *EventStorage) Close() error {
 	return s.db.Close()
 }
 
-func (s *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*storage.Event, error) {
+func (s *EventStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *EventStorage) FindOverlapping(
+	ctx context.Context,
+	userID int64,
+	from, to time.Time) ([]*storage.Event, error) {
 	q := `
 		SELECT
-			id, 
-			user_id,
-			title,
-			description,
-			time_start, 
-			time_end,
-			notify_at,
-			created_at,
-			updated_at,
-			notification_sent
+			` + eventFields + `
 		FROM
 			events
 		WHERE
-			notify_at IS NOT NULL 
-			AND notify_at <= :time
-			AND notification_sent = false
-			AND time_start > :time
+			user_id=:user_id
+			AND rrule = ''
+			AND deleted_at IS NULL
+			AND time_start < :to
+			AND time_end > :from
+		ORDER BY time_start
 		;
 `
-
 	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
-		"time": t,
+		"user_id": userID,
+		"from":    from,
+		"to":      to,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("event find unnotified: %w", err)
+		return nil, fmt.Errorf("event find overlapping: %w", err)
 	}
 	defer func() {
 		_ = rows.Close()
@@ -273,7 +510,7 @@ func (s *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*stor
 	for rows.Next() {
 		e := &storage.Event{}
 		if err := s.scan(rows, e); err != nil {
-			return nil, fmt.Errorf("event find unnotified: %w", err)
+			return nil, fmt.Errorf("event find overlapping: %w", err)
 		}
 
 		result = append(result, e)
@@ -282,40 +519,33 @@ func (s *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*stor
 	return result, nil
 }
 
-func (s *EventStorage) MarkNotified(ctx context.Context, ids []int64) error {
-	if len(ids) == 0 {
-		return nil
-	}
-
+func (s *EventStorage) DeleteOlderThan(ctx context.Context, filter storage.RetentionFilter) error {
 	q := `
-		UPDATE 
+		DELETE FROM 
 			events 
-		SET 
-			notification_sent = true, updated_at = now()
 		WHERE 
-			id IN(?)
+			time_end <= :time
+			AND (rrule = '' OR recurrence_until <= :time)
+			AND (:all_users OR user_id = ANY(:user_ids))
+			AND NOT (user_id = ANY(:except_user_ids))
 		;
 `
-	q, args, err := sqlx.In(q, ids)
-	if err != nil {
-		return fmt.Errorf("event mark notified build query: %w", err)
-	}
 
-	q = sqlx.Rebind(sqlx.DOLLAR, q)
-	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
-		return fmt.Errorf("event mark notified exec: %w", err)
+	userIDs := &pgtype.Int8Array{}
+	if err := userIDs.Set(filter.UserIDs); err != nil {
+		return fmt.Errorf("event delete older than: %w", err)
 	}
 
-	return nil
-}
-
-func (s *EventStorage) DeleteOlderThan(ctx context.Context, t time.Time) error {
-	q := `
-		DELETE FROM events WHERE time_end <= :time;
-`
+	exceptUserIDs := &pgtype.Int8Array{}
+	if err := exceptUserIDs.Set(filter.ExceptUserIDs); err != nil {
+		return fmt.Errorf("event delete older than: %w", err)
+	}
 
 	if _, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
-		"time": t,
+		"time":            filter.Before,
+		"all_users":       len(filter.UserIDs) == 0,
+		"user_ids":        userIDs,
+		"except_user_ids": exceptUserIDs,
 	}); err != nil {
 		return fmt.Errorf("event delete older than: %w", err)
 	}
@@ -324,20 +554,61 @@ func (s *EventStorage) DeleteOlderThan(ctx context.Context, t time.Time) error {
 }
 
 func (s *EventStorage) scan(rows *sqlx.Rows, e *storage.Event) error {
-	if err := rows.Scan(
+	return s.scanWith(rows, e)
+}
+
+// scanWith scans the columns of a joined table selected before the event fields into prefix.
+func (s *EventStorage) scanWith(rows *sqlx.Rows, e *storage.Event, prefix ...interface{}) error {
+	var exDates pgtype.TimestamptzArray
+
+	if err := rows.Scan(append(prefix,
 		&e.ID,
 		&e.UserID,
 		&e.Title,
 		&e.Description,
 		&e.TimeStart,
 		&e.TimeEnd,
-		&e.NotifyAt,
 		&e.CreatedAt,
 		&e.UpdatedAt,
-		&e.NotificationSent,
-	); err != nil {
+		&e.RRule,
+		&exDates,
+		&e.RecurrenceUntil,
+		&e.SeriesID,
+		&e.RecurrenceID,
+		&e.TimeZone,
+		&e.Version,
+		&e.NonBlocking,
+		&e.DeletedAt,
+	)...); err != nil {
 		return fmt.Errorf("scan: %w", err)
 	}
 
+	if err := exDates.AssignTo(&e.ExDates); err != nil {
+		return fmt.Errorf("scan ex dates: %w", err)
+	}
+
 	return nil
 }
+
+// overlapError returns storage.ErrOverlap when the event overlaps another blocking event of the user.
+func overlapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == exclusionViolation {
+		return storage.ErrOverlap
+	}
+
+	return err
+}
+
+func timestampArray(times []time.Time) (*pgtype.TimestamptzArray, error) {
+	if times == nil {
+		times = []time.Time{}
+	}
+
+	a := &pgtype.TimestamptzArray{}
+	if err := a.Set(times); err != nil {
+		return nil, fmt.Errorf("timestamp array: %w", err)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,186 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jmoiron/sqlx"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const historyFields = `
+			h.id,
+			h.event_id,
+			h.user_id,
+			h.action,
+			h.changes,
+			h.created_at`
+
+// fieldChange is the JSONB representation of storage.FieldChange.
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// addHistory saves the entry of the change within the transaction of the change, nothing is saved for nil entry.
+func (s *EventStorage) addHistory(ctx context.Context, tx *sqlx.Tx, entry *storage.HistoryEntry, now time.Time) error {
+	if entry == nil {
+		return nil
+	}
+
+	q := `
+		INSERT INTO
+			event_history (event_id, user_id, action, changes, created_at)
+		VALUES
+			(:event_id, :user_id, :action, :changes, :created_at)
+		RETURNING id
+		;
+`
+	j, err := historyChanges(entry)
+	if err != nil {
+		return fmt.Errorf("history add: %w", err)
+	}
+
+	rows, err := sqlx.NamedQueryContext(ctx, tx, q, map[string]interface{}{
+		"event_id":   entry.EventID,
+		"user_id":    entry.UserID,
+		"action":     entry.Action,
+		"changes":    j,
+		"created_at": now,
+	})
+	if err != nil {
+		return fmt.Errorf("history add: %w", err)
+	}
+	// the rows must be closed before the next statement of the transaction
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("history add: %w", err)
+		}
+
+		return errors.New("history add: no id is returned")
+	}
+
+	if err := rows.Scan(&entry.ID); err != nil {
+		return fmt.Errorf("history add: %w", err)
+	}
+	entry.CreatedAt = now
+
+	return nil
+}
+
+// historyChanges returns the JSONB representation of the changes of the entry.
+func historyChanges(entry *storage.HistoryEntry) (*pgtype.JSONB, error) {
+	changes := make([]fieldChange, 0, len(entry.Changes))
+	for _, c := range entry.Changes {
+		changes = append(changes, fieldChange{Field: c.Field, Old: c.Old, New: c.New})
+	}
+
+	j := &pgtype.JSONB{}
+	if err := j.Set(changes); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (s *EventStorage) FindHistory(ctx context.Context, eventID int64) ([]*storage.HistoryEntry, error) {
+	q := `
+		SELECT
+			` + historyFields + `
+		FROM
+			event_history h
+		WHERE
+			h.event_id=:event_id
+		ORDER BY h.id
+		;
+`
+	result, err := s.findHistory(ctx, q, map[string]interface{}{
+		"event_id": eventID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("history find: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) FindUserHistory(
+	ctx context.Context,
+	userID, afterID int64,
+	limit int) ([]*storage.HistoryEntry, error) {
+	q := `
+		SELECT
+			` + historyFields + `
+		FROM
+			event_history h
+		WHERE
+			h.user_id=:user_id
+			AND h.id > :after_id
+		ORDER BY h.id
+		LIMIT :limit
+		;
+`
+	result, err := s.findHistory(ctx, q, map[string]interface{}{
+		"user_id":  userID,
+		"after_id": afterID,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("history find of user: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) findHistory(
+	ctx context.Context,
+	q string,
+	args map[string]interface{},
+) ([]*storage.HistoryEntry, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, args)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.HistoryEntry, 0)
+
+	for rows.Next() {
+		h := &storage.HistoryEntry{}
+		j := pgtype.JSONB{}
+		if err := rows.Scan(
+			&h.ID,
+			&h.EventID,
+			&h.UserID,
+			&h.Action,
+			&j,
+			&h.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		changes := make([]fieldChange, 0)
+		if err := j.AssignTo(&changes); err != nil {
+			return nil, err
+		}
+		h.Changes = make([]*storage.FieldChange, 0, len(changes))
+		for _, c := range changes {
+			h.Changes = append(h.Changes, &storage.FieldChange{Field: c.Field, Old: c.Old, New: c.New})
+		}
+
+		result = append(result, h)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+var _ storage.IdempotencyStorage = (*IdempotencyStorage)(nil)
+
+type IdempotencyStorage struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyStorage() *IdempotencyStorage {
+	return &IdempotencyStorage{}
+}
+
+func (s *IdempotencyStorage) HasKey(ctx context.Context, key string) (bool, error) {
+	q := `
+		SELECT
+			EXISTS(SELECT 1 FROM idempotency_keys WHERE key=:key AND expires_at > :now)
+		;
+`
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"key": key,
+		"now": time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("idempotency key has: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	var exists bool
+	if !rows.Next() {
+		return false, fmt.Errorf("idempotency key has: %w", rows.Err())
+	}
+	if err := rows.Scan(&exists); err != nil {
+		return false, fmt.Errorf("idempotency key has: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (s *IdempotencyStorage) AddKey(ctx context.Context, key string, expiresAt time.Time) error {
+	q := `
+		DELETE FROM
+			idempotency_keys
+		WHERE
+			expires_at <= :now
+		;
+`
+	if _, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+		"now": time.Now(),
+	}); err != nil {
+		return fmt.Errorf("idempotency key delete expired: %w", err)
+	}
+
+	q = `
+		INSERT INTO
+			idempotency_keys (key, expires_at)
+		VALUES
+			(:key, :expires_at)
+		ON CONFLICT (key) DO UPDATE SET
+			expires_at = EXCLUDED.expires_at
+		;
+`
+	if _, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+		"key":        key,
+		"expires_at": expiresAt,
+	}); err != nil {
+		return fmt.Errorf("idempotency key add: %w", err)
+	}
+
+	return nil
+}
+
+func (s *IdempotencyStorage) Connect(ctx context.Context, dsn string) error {
+	db, err := sqlx.Open("pgx", dsn)
+	if err != nil {
+		return fmt.Errorf("open db connection with pgx: %w", err)
+	}
+
+	s.db = db
+	return s.db.PingContext(ctx)
+}
+
+func (s *IdempotencyStorage) Close() error {
+	return s.db.Close()
+}
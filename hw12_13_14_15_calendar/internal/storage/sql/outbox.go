@@ -0,0 +1,172 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) FindOutbox(ctx context.Context, t time.Time, limit int) ([]*storage.OutboxMessage, error) {
+	q := `
+		SELECT
+			id,
+			key,
+			idempotency_key,
+			payload,
+			headers,
+			attempts,
+			next_attempt_at,
+			created_at
+		FROM
+			outbox
+		WHERE
+			next_attempt_at <= :time
+		ORDER BY id
+		LIMIT :limit
+		;
+`
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"time":  t,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("outbox find: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.OutboxMessage, 0)
+
+	for rows.Next() {
+		m := &storage.OutboxMessage{}
+		if err := rows.Scan(
+			&m.ID,
+			&m.Key,
+			&m.IdempotencyKey,
+			&m.Payload,
+			stringMap{&m.Headers},
+			&m.Attempts,
+			&m.NextAttemptAt,
+			&m.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("outbox find: %w", err)
+		}
+
+		result = append(result, m)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) DeleteOutbox(ctx context.Context, id int64) error {
+	q := `
+		DELETE FROM
+			outbox
+		WHERE
+			id=$1
+		;
+`
+	if _, err := s.db.ExecContext(ctx, q, id); err != nil {
+		return fmt.Errorf("outbox delete: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventStorage) RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time) error {
+	q := `
+		UPDATE
+			outbox
+		SET
+			attempts = attempts + 1,
+			next_attempt_at = $1
+		WHERE
+			id=$2
+		;
+`
+	res, err := s.db.ExecContext(ctx, q, nextAttemptAt, id)
+	if err != nil {
+		return fmt.Errorf("outbox retry: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("outbox retry: %w", err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// enqueue saves the messages within the transaction, messages with known idempotency keys are skipped.
+func (s *EventStorage) enqueue(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	messages []*storage.OutboxMessage,
+	now time.Time) error {
+	q := `
+		INSERT INTO
+			outbox (key, idempotency_key, payload, headers, attempts, next_attempt_at, created_at)
+		VALUES
+			(:key, :idempotency_key, :payload, :headers, 0, :now, :now)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		;
+`
+	for _, m := range messages {
+		headers, err := jsonbMap(m.Headers)
+		if err != nil {
+			return fmt.Errorf("outbox enqueue: %w", err)
+		}
+
+		if _, err := sqlx.NamedExecContext(ctx, tx, q, map[string]interface{}{
+			"key":             m.Key,
+			"idempotency_key": m.IdempotencyKey,
+			"payload":         m.Payload,
+			"headers":         headers,
+			"now":             now,
+		}); err != nil {
+			return fmt.Errorf("outbox enqueue: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// markWithOutbox executes the state change query built by sqlx.In and saves the messages in one transaction.
+func (s *EventStorage) markWithOutbox(
+	ctx context.Context,
+	q string,
+	ids []int64,
+	messages []*storage.OutboxMessage,
+) error {
+	q, args, err := sqlx.In(q, ids)
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, sqlx.Rebind(sqlx.DOLLAR, q), args...); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+
+	if err := s.enqueue(ctx, tx, messages, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,324 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jmoiron/sqlx"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+const reminderFields = `
+			r.id,
+			r.event_id,
+			r.channel,
+			r.before_seconds,
+			r.notify_at,
+			r.sent,
+			r.trace_context,
+			r.created_at,
+			r.updated_at`
+
+func (s *EventStorage) FindReminders(ctx context.Context, eventIDs []int64) ([]*storage.Reminder, error) {
+	result := make([]*storage.Reminder, 0)
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+
+	q := `
+		SELECT
+			` + reminderFields + `
+		FROM
+			reminders r
+		WHERE
+			r.event_id IN(?)
+		ORDER BY r.event_id, r.notify_at, r.id
+		;
+`
+	q, args, err := sqlx.In(q, eventIDs)
+	if err != nil {
+		return nil, fmt.Errorf("reminder find build query: %w", err)
+	}
+
+	rows, err := s.db.QueryxContext(ctx, sqlx.Rebind(sqlx.DOLLAR, q), args...)
+	if err != nil {
+		return nil, fmt.Errorf("reminder find: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	for rows.Next() {
+		r := &storage.Reminder{}
+		if err := rows.Scan(reminderDest(r)...); err != nil {
+			return nil, fmt.Errorf("reminder find: %w", err)
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*storage.DueReminder, error) {
+	q := `
+		SELECT
+			` + reminderFields + `,
+			e.*
+		FROM
+			reminders r
+			JOIN (SELECT ` + eventFields + ` FROM events WHERE deleted_at IS NULL) e ON e.id = r.event_id
+		WHERE
+			r.sent = false
+			AND r.notify_at <= :time
+			AND (
+				(e.rrule = '' AND e.time_start > :time)
+				OR (e.rrule != '' AND (e.recurrence_until IS NULL OR e.recurrence_until > :time))
+			)
+		ORDER BY r.notify_at, r.id
+		;
+`
+
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"time": t,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reminder find unnotified: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.DueReminder, 0)
+
+	for rows.Next() {
+		r := &storage.Reminder{}
+		e := &storage.Event{}
+		if err := s.scanWith(rows, e, reminderDest(r)...); err != nil {
+			return nil, fmt.Errorf("reminder find unnotified: %w", err)
+		}
+
+		result = append(result, &storage.DueReminder{Event: e, Reminder: r})
+	}
+
+	return result, nil
+}
+
+func (s *EventStorage) MarkNotified(ctx context.Context, reminderIDs []int64, outbox []*storage.OutboxMessage) error {
+	if len(reminderIDs) == 0 {
+		return nil
+	}
+
+	q := `
+		UPDATE
+			reminders
+		SET
+			sent = true, updated_at = now()
+		WHERE
+			id IN(?)
+		;
+`
+	if err := s.markWithOutbox(ctx, q, reminderIDs, outbox); err != nil {
+		return fmt.Errorf("reminder mark notified: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventStorage) RearmReminders(
+	ctx context.Context,
+	reminders []*storage.Reminder,
+	outbox []*storage.OutboxMessage,
+) error {
+	if len(reminders) == 0 {
+		return nil
+	}
+
+	q := `
+		UPDATE
+			reminders
+		SET
+			notify_at = :notify_at, sent = false, updated_at = :now
+		WHERE
+			id = :id
+		;
+`
+	now := time.Now()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("reminder rearm: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, r := range reminders {
+		if _, err := sqlx.NamedExecContext(ctx, tx, q, map[string]interface{}{
+			"id":        r.ID,
+			"notify_at": r.NotifyAt,
+			"now":       now,
+		}); err != nil {
+			return fmt.Errorf("reminder rearm: %w", err)
+		}
+	}
+
+	if err := s.enqueue(ctx, tx, outbox, now); err != nil {
+		return fmt.Errorf("reminder rearm: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("reminder rearm: %w", err)
+	}
+
+	return nil
+}
+
+// saveReminders replaces the reminders of the event within the transaction,
+// reminders with the same channel and duration keep their id and the sent flag unless they are moved.
+func (s *EventStorage) saveReminders(ctx context.Context, tx *sqlx.Tx, event *storage.Event, now time.Time) error {
+	q := `
+		INSERT INTO
+			reminders (event_id, channel, before_seconds, notify_at, sent, trace_context, created_at, updated_at)
+		VALUES
+			(:event_id, :channel, :before_seconds, :notify_at, false, :trace_context, :now, :now)
+		ON CONFLICT (event_id, channel, before_seconds) DO UPDATE SET
+			notify_at = EXCLUDED.notify_at,
+			sent = reminders.sent AND reminders.notify_at = EXCLUDED.notify_at,
+			trace_context = EXCLUDED.trace_context,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, sent, created_at
+		;
+`
+	ids := make([]int64, 0, len(event.Reminders))
+	for _, r := range event.Reminders {
+		r.EventID = event.ID
+		if err := s.saveReminder(ctx, tx, q, r, now); err != nil {
+			return err
+		}
+
+		ids = append(ids, r.ID)
+	}
+
+	kept := &pgtype.Int8Array{}
+	if err := kept.Set(ids); err != nil {
+		return fmt.Errorf("reminder ids: %w", err)
+	}
+
+	q = `
+		DELETE FROM
+			reminders
+		WHERE
+			event_id=:event_id
+			AND NOT (id = ANY(:ids))
+		;
+`
+	if _, err := sqlx.NamedExecContext(ctx, tx, q, map[string]interface{}{
+		"event_id": event.ID,
+		"ids":      kept,
+	}); err != nil {
+		return fmt.Errorf("reminder delete: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventStorage) saveReminder(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	q string,
+	r *storage.Reminder,
+	now time.Time) error {
+	traceContext, err := jsonbMap(r.TraceContext)
+	if err != nil {
+		return fmt.Errorf("reminder save: %w", err)
+	}
+
+	rows, err := sqlx.NamedQueryContext(ctx, tx, q, map[string]interface{}{
+		"event_id":       r.EventID,
+		"channel":        r.Channel,
+		"before_seconds": int64(r.Before / time.Second),
+		"notify_at":      r.NotifyAt,
+		"trace_context":  traceContext,
+		"now":            now,
+	})
+	if err != nil {
+		return fmt.Errorf("reminder save: %w", err)
+	}
+	// the rows must be closed before the next statement of the transaction
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	if !rows.Next() {
+		return fmt.Errorf("reminder save: %w", rows.Err())
+	}
+
+	if err := rows.Scan(&r.ID, &r.Sent, &r.CreatedAt); err != nil {
+		return fmt.Errorf("reminder save: %w", err)
+	}
+	r.UpdatedAt = now
+
+	return nil
+}
+
+func reminderDest(r *storage.Reminder) []interface{} {
+	return []interface{}{
+		&r.ID,
+		&r.EventID,
+		&r.Channel,
+		seconds{&r.Before},
+		&r.NotifyAt,
+		&r.Sent,
+		stringMap{&r.TraceContext},
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	}
+}
+
+// seconds scans BIGINT seconds into the duration.
+type seconds struct {
+	d *time.Duration
+}
+
+func (s seconds) Scan(src interface{}) error {
+	v, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("scan seconds: unexpected type %T", src)
+	}
+
+	*s.d = time.Duration(v) * time.Second
+
+	return nil
+}
+
+// stringMap scans the JSONB object of strings into the map.
+type stringMap struct {
+	m *map[string]string
+}
+
+func (s stringMap) Scan(src interface{}) error {
+	j := pgtype.JSONB{}
+	if err := j.Scan(src); err != nil {
+		return fmt.Errorf("scan string map: %w", err)
+	}
+
+	return j.AssignTo(s.m)
+}
+
+func jsonbMap(m map[string]string) (*pgtype.JSONB, error) {
+	if m == nil {
+		m = map[string]string{}
+	}
+
+	j := &pgtype.JSONB{}
+	if err := j.Set(m); err != nil {
+		return nil, fmt.Errorf("jsonb map: %w", err)
+	}
+
+	return j, nil
+}
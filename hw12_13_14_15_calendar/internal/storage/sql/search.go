@@ -0,0 +1,109 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+var searchSortColumns = map[storage.SearchSort]string{
+	storage.SortTimeStart:     "time_start",
+	storage.SortTimeStartDesc: "time_start",
+	storage.SortCreatedAt:     "created_at",
+	storage.SortCreatedAtDesc: "created_at",
+	storage.SortUpdatedAt:     "updated_at",
+	storage.SortUpdatedAtDesc: "updated_at",
+}
+
+func (s *EventStorage) Search(ctx context.Context, filter storage.SearchFilter) ([]*storage.Event, error) {
+	column, ok := searchSortColumns[filter.Sort]
+	if !ok {
+		column = searchSortColumns[storage.SortTimeStart]
+	}
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(string(filter.Sort), "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions := []string{
+		"user_id=:user_id",
+		"deleted_at IS NULL",
+		`((rrule = '' AND time_start BETWEEN :from AND :to)
+				OR (rrule <> '' AND time_start <= :to AND (recurrence_until IS NULL OR recurrence_until >= :from)))`,
+	}
+	args := map[string]interface{}{
+		"user_id": filter.UserID,
+		"from":    filter.From,
+		"to":      filter.To,
+		"limit":   filter.Limit,
+	}
+
+	if filter.Query != "" {
+		conditions = append(conditions, "search_vector @@ plainto_tsquery('simple', :query)")
+		args["query"] = filter.Query
+	}
+
+	switch filter.Notification {
+	case storage.NotificationNone:
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM reminders r WHERE r.event_id = events.id)")
+	case storage.NotificationPending:
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM reminders r WHERE r.event_id = events.id AND r.sent = FALSE)")
+	case storage.NotificationSent:
+		conditions = append(conditions,
+			`EXISTS (SELECT 1 FROM reminders r WHERE r.event_id = events.id)
+				AND NOT EXISTS (SELECT 1 FROM reminders r WHERE r.event_id = events.id AND r.sent = FALSE)`)
+	case storage.NotificationAny:
+	}
+
+	if !filter.CreatedSince.IsZero() {
+		conditions = append(conditions, "created_at >= :created_since")
+		args["created_since"] = filter.CreatedSince
+	}
+
+	if !filter.UpdatedSince.IsZero() {
+		conditions = append(conditions, "updated_at >= :updated_since")
+		args["updated_since"] = filter.UpdatedSince
+	}
+
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (:after_key, :after_id)", column, comparison))
+		args["after_key"] = filter.After.Key
+		args["after_id"] = filter.After.ID
+	}
+
+	q := `
+		SELECT
+			` + eventFields + `
+		FROM
+			events
+		WHERE
+			` + strings.Join(conditions, "\n\t\t\tAND ") + `
+		ORDER BY ` + column + ` ` + direction + `, id ` + direction + `
+		LIMIT :limit
+		;
+`
+	rows, err := s.db.NamedQueryContext(ctx, q, args)
+	if err != nil {
+		return nil, fmt.Errorf("event search: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.Event, 0)
+
+	for rows.Next() {
+		e := &storage.Event{}
+		if err := s.scan(rows, e); err != nil {
+			return nil, fmt.Errorf("event search: %w", err)
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
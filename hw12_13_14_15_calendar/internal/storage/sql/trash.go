@@ -0,0 +1,200 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *EventStorage) GetDeleted(ctx context.Context, id int64) (*storage.Event, error) {
+	q := `
+		SELECT
+			` + eventFields + `
+		FROM
+			events
+		WHERE
+			id=:id
+			AND deleted_at IS NOT NULL
+		;
+`
+	e := &storage.Event{}
+
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"id": id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("event get deleted: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	if !rows.Next() {
+		return nil, storage.ErrNotFound
+	}
+
+	if err := s.scan(rows, e); err != nil {
+		return nil, fmt.Errorf("event get deleted: %w", err)
+	}
+
+	return e, nil
+}
+
+// FindDeleted returns the deleted events of the user ordered by (deleted_at, id) descending,
+// the exceptions deleted together with the series are not listed.
+func (s *EventStorage) FindDeleted(
+	ctx context.Context,
+	userID int64,
+	after *storage.SearchCursor,
+	limit int) ([]*storage.Event, error) {
+	cursor := ""
+	args := map[string]interface{}{
+		"user_id": userID,
+		"limit":   limit,
+	}
+	if after != nil {
+		cursor = "AND (deleted_at, id) < (:after_deleted_at, :after_id)"
+		args["after_deleted_at"] = after.Key
+		args["after_id"] = after.ID
+	}
+
+	q := `
+		SELECT
+			` + eventFields + `
+		FROM
+			events
+		WHERE
+			user_id=:user_id
+			AND deleted_at IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM events s WHERE s.id = events.series_id AND s.deleted_at = events.deleted_at
+			)
+			` + cursor + `
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT :limit
+		;
+`
+	rows, err := s.db.NamedQueryContext(ctx, q, args)
+	if err != nil {
+		return nil, fmt.Errorf("event find deleted: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	result := make([]*storage.Event, 0)
+
+	for rows.Next() {
+		e := &storage.Event{}
+		if err := s.scan(rows, e); err != nil {
+			return nil, fmt.Errorf("event find deleted: %w", err)
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// Restore moves the event back from the trash together with the exceptions deleted with it,
+// it fails with storage.ErrOverlap when a restored event overlaps another blocking event of the user.
+func (s *EventStorage) Restore(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	q := `
+		UPDATE
+			events
+		SET
+			deleted_at=NULL,
+			updated_at=:updated_at,
+			version=version + 1
+		WHERE
+			deleted_at IS NOT NULL
+			AND (
+				id=:id
+				OR (series_id=:id AND deleted_at = (SELECT deleted_at FROM events WHERE id=:id))
+			)
+		RETURNING id
+		;
+`
+	now := time.Now()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("event restore: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	restored, err := s.changeIDs(ctx, tx, q, map[string]interface{}{
+		"id":         id,
+		"updated_at": now,
+	})
+	if err != nil {
+		return fmt.Errorf("event restore: %w", overlapError(err))
+	}
+
+	if !containsID(restored, id) {
+		return storage.ErrNotFound
+	}
+
+	if err := s.addHistory(ctx, tx, history, now); err != nil {
+		return fmt.Errorf("event restore: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("event restore: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes the event in the trash permanently, its exceptions, attendees and reminders are cascaded.
+func (s *EventStorage) Purge(ctx context.Context, id int64) error {
+	q := `
+		DELETE FROM
+			events
+		WHERE
+			id=:id
+			AND deleted_at IS NOT NULL
+		;
+`
+	res, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+		"id": id,
+	})
+	if err != nil {
+		return fmt.Errorf("event purge: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("event purge: %w", err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently deletes the events which are in the trash since t or longer.
+func (s *EventStorage) PurgeDeleted(ctx context.Context, t time.Time) error {
+	q := `
+		DELETE FROM
+			events
+		WHERE
+			deleted_at <= :time
+		;
+`
+	if _, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+		"time": t,
+	}); err != nil {
+		return fmt.Errorf("event purge deleted: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+)
+
+var _ storage.UserStorage = (*UserStorage)(nil)
+
+const userFields = `
+			id,
+			login,
+			password_hash,
+			token_hash,
+			time_zone,
+			week_start,
+			created_at,
+			updated_at`
+
+type UserStorage struct {
+	db *sqlx.DB
+}
+
+func NewUserStorage() *UserStorage {
+	return &UserStorage{}
+}
+
+func (s *UserStorage) Create(ctx context.Context, user *storage.User) (int64, error) {
+	q := `
+		INSERT INTO
+			users (login, password_hash, token_hash, time_zone, week_start, created_at, updated_at)
+		VALUES
+			(:login, :password_hash, :token_hash, :time_zone, :week_start, :created_at, :updated_at)
+		ON CONFLICT (login) DO NOTHING
+		RETURNING id
+		;
+`
+	now := time.Now()
+
+	res, err := s.db.NamedQueryContext(ctx, q, map[string]interface{}{
+		"login":         user.Login,
+		"password_hash": user.PasswordHash,
+		"token_hash":    user.TokenHash,
+		"time_zone":     user.TimeZone,
+		"week_start":    int16(user.WeekStart),
+		"created_at":    now,
+		"updated_at":    now,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("user create: %w", err)
+	}
+	defer func() {
+		_ = res.Close()
+		_ = res.Err()
+	}()
+
+	// nothing is returned when the login is taken
+	if !res.Next() {
+		return 0, storage.ErrAlreadyExists
+	}
+
+	if err := res.Scan(&user.ID); err != nil {
+		return 0, fmt.Errorf("user retrieve last insert id: %w", err)
+	}
+
+	user.CreatedAt, user.UpdatedAt = now, now
+
+	return user.ID, nil
+}
+
+func (s *UserStorage) Update(ctx context.Context, user *storage.User) error {
+	q := `
+		UPDATE
+			users
+		SET
+			login=:login,
+			password_hash=:password_hash,
+			token_hash=:token_hash,
+			time_zone=:time_zone,
+			week_start=:week_start,
+			updated_at=:updated_at
+		WHERE
+			id=:id
+		;
+`
+	now := time.Now()
+
+	res, err := s.db.NamedExecContext(ctx, q, map[string]interface{}{
+		"login":         user.Login,
+		"password_hash": user.PasswordHash,
+		"token_hash":    user.TokenHash,
+		"time_zone":     user.TimeZone,
+		"week_start":    int16(user.WeekStart),
+		"updated_at":    now,
+		"id":            user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("user update: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("user update: %w", err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	user.UpdatedAt = now
+
+	return nil
+}
+
+func (s *UserStorage) GetByID(ctx context.Context, id int64) (*storage.User, error) {
+	q := `
+		SELECT
+			` + userFields + `
+		FROM
+			users
+		WHERE
+			id=:id
+		;
+`
+
+	return s.get(ctx, q, map[string]interface{}{
+		"id": id,
+	})
+}
+
+func (s *UserStorage) GetByLogin(ctx context.Context, login string) (*storage.User, error) {
+	q := `
+		SELECT
+			` + userFields + `
+		FROM
+			users
+		WHERE
+			login=:login
+		;
+`
+
+	return s.get(ctx, q, map[string]interface{}{
+		"login": login,
+	})
+}
+
+func (s *UserStorage) GetByTokenHash(ctx context.Context, hash string) (*storage.User, error) {
+	if hash == "" {
+		return nil, storage.ErrNotFound
+	}
+
+	q := `
+		SELECT
+			` + userFields + `
+		FROM
+			users
+		WHERE
+			token_hash=:token_hash
+		;
+`
+
+	return s.get(ctx, q, map[string]interface{}{
+		"token_hash": hash,
+	})
+}
+
+func (s *UserStorage) Connect(ctx context.Context, dsn string) error {
+	db, err := sqlx.Open("pgx", dsn)
+	if err != nil {
+		return fmt.Errorf("open db connection with pgx: %w", err)
+	}
+
+	s.db = db
+	return s.db.PingContext(ctx)
+}
+
+func (s *UserStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *UserStorage) get(ctx context.Context, q string, arg map[string]interface{}) (*storage.User, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, arg)
+	if err != nil {
+		return nil, fmt.Errorf("user get: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	if !rows.Next() {
+		return nil, storage.ErrNotFound
+	}
+
+	u := &storage.User{}
+	var weekStart int16
+	if err := rows.Scan(
+		&u.ID, &u.Login, &u.PasswordHash, &u.TokenHash, &u.TimeZone, &weekStart, &u.CreatedAt, &u.UpdatedAt,
+	); err != nil {
+		return nil, fmt.Errorf("user get: %w", err)
+	}
+	u.WeekStart = time.Weekday(weekStart)
+
+	return u, nil
+}
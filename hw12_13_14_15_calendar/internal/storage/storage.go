@@ -8,43 +8,339 @@ import (
 )
 
 type EventStorage interface {
-	Create(ctx context.Context, event *Event) (int64, error)
-	Update(ctx context.Context, event *Event) error
-	Delete(ctx context.Context, id int64) error
+	// Create, Update, Delete and Restore save the history entry of the change in the same transaction,
+	// the entry is nil when the change is not recorded and the entry of the created event gets its id.
+	Create(ctx context.Context, event *Event, history *HistoryEntry) (int64, error)
+	Update(ctx context.Context, event *Event, history *HistoryEntry) error
+	// Delete moves the event with its exceptions to the trash, the other methods skip the deleted events
+	// except the trash ones.
+	Delete(ctx context.Context, id int64, history *HistoryEntry) error
 	GetByID(ctx context.Context, id int64) (*Event, error)
 	FindForInterval(ctx context.Context,
 		userID int64,
 		from, to time.Time,
-		limit, offset uint8) ([]*Event, error)
-	FindUnNotified(ctx context.Context, t time.Time) ([]*Event, error)
-	MarkNotified(ctx context.Context, ids []int64) error
-	DeleteOlderThan(ctx context.Context, t time.Time) error
+		after *Cursor,
+		limit int) ([]*Event, error)
+	FindRecurring(ctx context.Context, userID int64, from, to time.Time) ([]*Event, error)
+	FindOverlapping(ctx context.Context, userID int64, from, to time.Time) ([]*Event, error)
+	Search(ctx context.Context, filter SearchFilter) ([]*Event, error)
+	FindReminders(ctx context.Context, eventIDs []int64) ([]*Reminder, error)
+	// FindUnNotified returns the due reminders of the events not started yet and of the series not ended yet,
+	// the reminder of the series is due for the occurrence it is armed for.
+	FindUnNotified(ctx context.Context, t time.Time) ([]*DueReminder, error)
+	MarkNotified(ctx context.Context, reminderIDs []int64, outbox []*OutboxMessage) error
+	// RearmReminders moves the reminders of the series to NotifyAt of their next occurrence,
+	// they stay unsent and the messages are saved in the same transaction.
+	RearmReminders(ctx context.Context, reminders []*Reminder, outbox []*OutboxMessage) error
+	DeleteOlderThan(ctx context.Context, filter RetentionFilter) error
+
+	// GetDeleted, Restore and Purge fail with ErrNotFound when the event is not in the trash,
+	// Purge and PurgeDeleted delete the events permanently.
+	GetDeleted(ctx context.Context, id int64) (*Event, error)
+	FindDeleted(ctx context.Context, userID int64, after *SearchCursor, limit int) ([]*Event, error)
+	Restore(ctx context.Context, id int64, history *HistoryEntry) error
+	Purge(ctx context.Context, id int64) error
+	PurgeDeleted(ctx context.Context, t time.Time) error
+
+	// FindHistory returns the history of the event ordered by id, the entries are saved by the changes.
+	// The history is append-only, it is kept after the event is purged or deleted by the retention.
+	FindHistory(ctx context.Context, eventID int64) ([]*HistoryEntry, error)
+	// FindUserHistory returns the history of the changes made by the user after the entry afterID ordered by id.
+	FindUserHistory(ctx context.Context, userID, afterID int64, limit int) ([]*HistoryEntry, error)
+
+	// ApplyBatch applies all the changes of the batch or none of them, it fails with ErrConflict
+	// and ErrOverlap like Update and Create do.
+	ApplyBatch(ctx context.Context, batch *Batch) error
+
+	AddAttendee(ctx context.Context, attendee *Attendee) (int64, error)
+	UpdateAttendee(ctx context.Context, attendee *Attendee) error
+	FindAttendees(ctx context.Context, eventID int64) ([]*Attendee, error)
+	FindInvitations(ctx context.Context, userID int64) ([]*Invitation, error)
+	FindUnNotifiedAttendees(ctx context.Context) ([]*Invitation, error)
+	MarkAttendeesNotified(ctx context.Context, ids []int64, outbox []*OutboxMessage) error
+
+	FindOutbox(ctx context.Context, t time.Time, limit int) ([]*OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+	RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time) error
+}
+
+type UserStorage interface {
+	Create(ctx context.Context, user *User) (int64, error)
+	Update(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByLogin(ctx context.Context, login string) (*User, error)
+	GetByTokenHash(ctx context.Context, hash string) (*User, error)
+}
+
+// IdempotencyStorage remembers the idempotency keys of the handled messages until they expire,
+// so the duplicates are skipped after the restart of the sender and by the other senders.
+type IdempotencyStorage interface {
+	HasKey(ctx context.Context, key string) (bool, error)
+	// AddKey remembers the key until expiresAt and forgets the expired keys.
+	AddKey(ctx context.Context, key string, expiresAt time.Time) error
 }
 
-type NotificationTime = sql.NullTime
+// Batch is applied in a single transaction: the events are deleted, then updated, then created.
+// The ids, versions and times of the updated and created events are filled like Create and Update do.
+type Batch struct {
+	Create []*Event
+	Update []*Event
+	Delete []int64
+	// CreateHistory, UpdateHistory and DeleteHistory are the history entries of the changes in their order,
+	// they are saved in the same transaction or are empty when the changes are not recorded.
+	// The entries of the created events get their ids, the entries of the events deleted before are not saved.
+	CreateHistory []*HistoryEntry
+	UpdateHistory []*HistoryEntry
+	DeleteHistory []*HistoryEntry
+}
+
+// Cursor points to the last event of the previous page, events are ordered by (time_start, id).
+type Cursor struct {
+	TimeStart time.Time
+	ID        int64
+}
+
+type SearchSort string
+
+// Sort orders of the search, the minus prefix means the descending order.
+const (
+	SortTimeStart     SearchSort = "timeStart"
+	SortTimeStartDesc SearchSort = "-timeStart"
+	SortCreatedAt     SearchSort = "createdAt"
+	SortCreatedAtDesc SearchSort = "-createdAt"
+	SortUpdatedAt     SearchSort = "updatedAt"
+	SortUpdatedAtDesc SearchSort = "-updatedAt"
+)
+
+// NotificationState filters events by their reminders: none are events without reminders,
+// pending have unsent reminders and sent have all the reminders sent.
+type NotificationState string
+
+const (
+	NotificationAny     NotificationState = ""
+	NotificationNone    NotificationState = "none"
+	NotificationPending NotificationState = "pending"
+	NotificationSent    NotificationState = "sent"
+)
+
+// SearchFilter matches stored events of the user, series are not expanded into occurrences.
+// One-off events and exceptions match when they start within [From, To],
+// series match when any of their occurrences may start within it.
+type SearchFilter struct {
+	UserID int64
+	From   time.Time
+	To     time.Time
+	// Query is matched against words of the title and the description, all of them are required.
+	Query        string
+	Notification NotificationState
+	// CreatedSince and UpdatedSince are ignored when zero.
+	CreatedSince time.Time
+	UpdatedSince time.Time
+	Sort         SearchSort
+	After        *SearchCursor
+	Limit        int
+}
+
+// SearchCursor points to the last event of the previous search page, Key is the value of the sort field.
+type SearchCursor struct {
+	Key time.Time
+	ID  int64
+}
+
+// RetentionFilter selects the events DeleteOlderThan deletes, they are the events ended before Before
+// of the users of UserIDs, or of every user when it is empty, except the users of ExceptUserIDs.
+// Series are deleted after their last occurrence.
+type RetentionFilter struct {
+	Before        time.Time
+	UserIDs       []int64
+	ExceptUserIDs []int64
+}
+
+type RecurrenceTime = sql.NullTime
+
+type DeletedTime = sql.NullTime
+
+type SeriesID = sql.NullInt64
+
+type AttendeeUserID = sql.NullInt64
+
+type AttendeeRole string
+
+const (
+	RoleChair          AttendeeRole = "chair"
+	RoleRequired       AttendeeRole = "required"
+	RoleOptional       AttendeeRole = "optional"
+	RoleNonParticipant AttendeeRole = "non-participant"
+)
+
+type ReminderChannel string
+
+const (
+	ChannelEmail   ReminderChannel = "email"
+	ChannelWebhook ReminderChannel = "webhook"
+	ChannelLog     ReminderChannel = "log"
+)
+
+type RSVPStatus string
 
-var ErrNotFound = errors.New("not found")
+const (
+	RSVPNeedsAction RSVPStatus = "needs-action"
+	RSVPAccepted    RSVPStatus = "accepted"
+	RSVPDeclined    RSVPStatus = "declined"
+	RSVPTentative   RSVPStatus = "tentative"
+)
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrConflict      = errors.New("conflict")
+	ErrOverlap       = errors.New("overlap")
+)
 
 type Event struct {
-	ID               int64
-	UserID           int64
-	Title            string
-	Description      string
-	TimeStart        time.Time
-	TimeEnd          time.Time
-	NotifyAt         NotificationTime
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
-	NotificationSent bool
-}
-
-func CreateNotificationTime(base time.Time, d time.Duration) NotificationTime {
-	var t NotificationTime
-
-	if d > 0 {
-		t.Valid = true
-		t.Time = base.Add(-d)
-	}
-
-	return t
+	ID          int64
+	UserID      int64
+	Title       string
+	Description string
+	TimeStart   time.Time
+	TimeEnd     time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// Version is 1 for the created event and is incremented by every update,
+	// Update fails with ErrConflict when the stored event has another version.
+	Version int64
+
+	// RRule is an RFC 5545 recurrence rule without the DTSTART part, TimeStart is used instead.
+	// Events with an empty rule are one-off events.
+	RRule   string
+	ExDates []time.Time
+	// RecurrenceUntil is the end of the last occurrence of the series, it is not valid for endless series.
+	RecurrenceUntil RecurrenceTime
+
+	// SeriesID and RecurrenceID are set for exceptions: the occurrence of the series
+	// started at RecurrenceID is replaced with this event.
+	SeriesID     SeriesID
+	RecurrenceID RecurrenceTime
+
+	// TimeZone is an IANA time zone name, recurrence rules are expanded in it.
+	TimeZone string
+
+	// NonBlocking events, e.g. tentative or all-day ones, do not make the time busy.
+	// Create and Update fail with ErrOverlap when a one-off blocking event overlaps another one of the user.
+	// The occurrences of the series are checked only by the application before the change, so two concurrent
+	// changes may still save a series overlapping another event of the user.
+	NonBlocking bool
+
+	// DeletedAt is set for the events in the trash, the exceptions deleted together with the series
+	// have the same time and are restored together with it.
+	DeletedAt DeletedTime
+
+	// Reminders are saved together with the event, but they are not filled by the event queries,
+	// FindReminders returns them.
+	Reminders []*Reminder
+
+	// Attendees are stored separately and are filled only when a single event is requested.
+	Attendees []*Attendee
+}
+
+type Reminder struct {
+	ID      int64
+	EventID int64
+	Channel ReminderChannel
+	// Before is the duration between the reminder and the start of the event.
+	Before   time.Duration
+	NotifyAt time.Time
+	// Sent is kept on update until NotifyAt of the reminder changes.
+	Sent bool
+	// TraceContext is the trace context of the request that saved the reminder,
+	// the notification of the reminder continues its trace.
+	TraceContext map[string]string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// DueReminder is an unsent reminder together with its event.
+type DueReminder struct {
+	Event    *Event
+	Reminder *Reminder
+}
+
+// OutboxMessage is a queue message saved in the same transaction as the state change it reports,
+// the relay publishes it and deletes it afterwards. Messages with an already saved IdempotencyKey are skipped.
+type OutboxMessage struct {
+	ID             int64
+	Key            string
+	IdempotencyKey string
+	Payload        []byte
+	// Headers are published together with the message.
+	Headers       map[string]string
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+type Attendee struct {
+	ID      int64
+	EventID int64
+	// UserID is set for users of the calendar, Email is set for external attendees.
+	UserID AttendeeUserID
+	Email  string
+	Role   AttendeeRole
+	Status RSVPStatus
+	// InvitationSent and ResponseSent are reset when the attendee is invited and when they respond,
+	// the scheduler sets them back after the messages are published.
+	InvitationSent bool
+	ResponseSent   bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Invitation is an attendee together with the event they are invited to.
+type Invitation struct {
+	Event    *Event
+	Attendee *Attendee
+}
+
+func (e *Event) IsRecurring() bool {
+	return e.RRule != ""
+}
+
+type HistoryAction string
+
+const (
+	HistoryCreated  HistoryAction = "created"
+	HistoryUpdated  HistoryAction = "updated"
+	HistoryDeleted  HistoryAction = "deleted"
+	HistoryRestored HistoryAction = "restored"
+)
+
+// HistoryEntry is a change of the event made by the user UserID, entries are never updated.
+type HistoryEntry struct {
+	ID      int64
+	EventID int64
+	UserID  int64
+	Action  HistoryAction
+	// Changes are the changed fields of the event, the fields of the created event are changed from empty values.
+	Changes   []*FieldChange
+	CreatedAt time.Time
+}
+
+// FieldChange is a change of a single field of the event, Old and New are formatted values of the field.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+type User struct {
+	ID           int64
+	Login        string
+	PasswordHash string
+	// TokenHash is a SHA-256 hex digest of the API token, the token itself is not stored.
+	TokenHash string
+	// TimeZone is an IANA time zone name, days, weeks and months of the user are computed in it.
+	TimeZone  string
+	WeekStart time.Weekday
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
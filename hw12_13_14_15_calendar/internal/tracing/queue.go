@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ queue.Producer = (*Producer)(nil)
+
+// Producer records the spans of the published messages and injects their trace context into the headers.
+type Producer struct {
+	producer queue.Producer
+}
+
+func NewProducer(p queue.Producer) *Producer {
+	return &Producer{producer: p}
+}
+
+// Publish continues the trace of the message headers when there is one, like the trace of the relayed
+// outbox message, the span of the ctx is linked then. Otherwise the span is the child of the ctx span.
+func (p *Producer) Publish(ctx context.Context, m *queue.Message) error {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingRabbitmqRoutingKeyKey.String(m.Key),
+		),
+	}
+
+	parent := ctx
+	if carried := trace.SpanContextFromContext(Extract(context.Background(), m.Headers)); carried.IsValid() {
+		parent = trace.ContextWithRemoteSpanContext(ctx, carried)
+		opts = append(opts, trace.WithLinks(trace.LinkFromContext(ctx)))
+	}
+
+	ctx, span := tracer().Start(parent, "queue.publish "+m.Key, opts...)
+
+	// the headers of the message may be shared with the caller, so they are copied
+	headers := make(map[string]string, len(m.Headers))
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	for k, v := range Inject(ctx) {
+		headers[k] = v
+	}
+
+	published := *m
+	published.Headers = headers
+
+	err := p.producer.Publish(ctx, &published)
+	end(span, err)
+
+	return err
+}
+
+// MessageHandler records the span of the handling of the message, it continues the trace of the message headers.
+func MessageHandler(h queue.MessageHandler) queue.MessageHandler {
+	return func(ctx context.Context, m *queue.Message) error {
+		ctx, span := tracer().Start(Extract(ctx, m.Headers), "queue.consume "+m.Key,
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingOperationProcess,
+				semconv.MessagingRabbitmqRoutingKeyKey.String(m.Key),
+				attribute.Int("messaging.attempt", m.Attempt),
+			),
+		)
+
+		err := h(ctx, m)
+		end(span, err)
+
+		return err
+	}
+}
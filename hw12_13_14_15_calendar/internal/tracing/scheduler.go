@@ -0,0 +1,19 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+// Task records the span of every run of the named task, the runs start new traces.
+func Task(name string, t scheduler.Task) scheduler.Task {
+	return func(ctx context.Context) error {
+		ctx, span := tracer().Start(ctx, "scheduler."+name)
+
+		err := t(ctx)
+		end(span, err)
+
+		return err
+	}
+}
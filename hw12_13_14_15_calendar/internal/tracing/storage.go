@@ -0,0 +1,308 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ storage.EventStorage = (*EventStorage)(nil)
+
+// EventStorage records the spans of the operations of the wrapped storage.
+// The reminders of the saved events keep the trace context of the operation, so their notifications join the trace.
+type EventStorage struct {
+	storage storage.EventStorage
+}
+
+func NewEventStorage(s storage.EventStorage) *EventStorage {
+	return &EventStorage{storage: s}
+}
+
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBOperationKey.String(method)),
+	)
+}
+
+// done ends the span of the operation, a missing entity, a version conflict and an overlap
+// are not errors of the storage.
+func done(span trace.Span, err error) {
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrConflict) ||
+		errors.Is(err, storage.ErrOverlap) {
+		err = nil
+	}
+	end(span, err)
+}
+
+func withTraceContext(ctx context.Context, event *storage.Event) {
+	traceContext := Inject(ctx)
+	for _, r := range event.Reminders {
+		r.TraceContext = traceContext
+	}
+}
+
+func (s *EventStorage) Create(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) (int64, error) {
+	ctx, span := startSpan(ctx, "Create")
+	withTraceContext(ctx, event)
+	result, err := s.storage.Create(ctx, event, history)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) Update(ctx context.Context, event *storage.Event, history *storage.HistoryEntry) error {
+	ctx, span := startSpan(ctx, "Update")
+	withTraceContext(ctx, event)
+	err := s.storage.Update(ctx, event, history)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) Delete(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	ctx, span := startSpan(ctx, "Delete")
+	err := s.storage.Delete(ctx, id, history)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) GetByID(ctx context.Context, id int64) (*storage.Event, error) {
+	ctx, span := startSpan(ctx, "GetByID")
+	result, err := s.storage.GetByID(ctx, id)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindForInterval(
+	ctx context.Context,
+	userID int64,
+	from, to time.Time,
+	after *storage.Cursor,
+	limit int) ([]*storage.Event, error) {
+	ctx, span := startSpan(ctx, "FindForInterval")
+	result, err := s.storage.FindForInterval(ctx, userID, from, to, after, limit)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindRecurring(ctx context.Context, userID int64, from, to time.Time) ([]*storage.Event, error) {
+	ctx, span := startSpan(ctx, "FindRecurring")
+	result, err := s.storage.FindRecurring(ctx, userID, from, to)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindOverlapping(
+	ctx context.Context,
+	userID int64,
+	from, to time.Time) ([]*storage.Event, error) {
+	ctx, span := startSpan(ctx, "FindOverlapping")
+	result, err := s.storage.FindOverlapping(ctx, userID, from, to)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) Search(ctx context.Context, filter storage.SearchFilter) ([]*storage.Event, error) {
+	ctx, span := startSpan(ctx, "Search")
+	result, err := s.storage.Search(ctx, filter)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindReminders(ctx context.Context, eventIDs []int64) ([]*storage.Reminder, error) {
+	ctx, span := startSpan(ctx, "FindReminders")
+	result, err := s.storage.FindReminders(ctx, eventIDs)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindUnNotified(ctx context.Context, t time.Time) ([]*storage.DueReminder, error) {
+	ctx, span := startSpan(ctx, "FindUnNotified")
+	result, err := s.storage.FindUnNotified(ctx, t)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) MarkNotified(ctx context.Context, reminderIDs []int64, outbox []*storage.OutboxMessage) error {
+	ctx, span := startSpan(ctx, "MarkNotified")
+	err := s.storage.MarkNotified(ctx, reminderIDs, outbox)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) RearmReminders(
+	ctx context.Context,
+	reminders []*storage.Reminder,
+	outbox []*storage.OutboxMessage,
+) error {
+	ctx, span := startSpan(ctx, "RearmReminders")
+	err := s.storage.RearmReminders(ctx, reminders, outbox)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) DeleteOlderThan(ctx context.Context, filter storage.RetentionFilter) error {
+	ctx, span := startSpan(ctx, "DeleteOlderThan")
+	err := s.storage.DeleteOlderThan(ctx, filter)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) GetDeleted(ctx context.Context, id int64) (*storage.Event, error) {
+	ctx, span := startSpan(ctx, "GetDeleted")
+	result, err := s.storage.GetDeleted(ctx, id)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindDeleted(
+	ctx context.Context,
+	userID int64,
+	after *storage.SearchCursor,
+	limit int) ([]*storage.Event, error) {
+	ctx, span := startSpan(ctx, "FindDeleted")
+	result, err := s.storage.FindDeleted(ctx, userID, after, limit)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) Restore(ctx context.Context, id int64, history *storage.HistoryEntry) error {
+	ctx, span := startSpan(ctx, "Restore")
+	err := s.storage.Restore(ctx, id, history)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) Purge(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "Purge")
+	err := s.storage.Purge(ctx, id)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) PurgeDeleted(ctx context.Context, t time.Time) error {
+	ctx, span := startSpan(ctx, "PurgeDeleted")
+	err := s.storage.PurgeDeleted(ctx, t)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) FindHistory(ctx context.Context, eventID int64) ([]*storage.HistoryEntry, error) {
+	ctx, span := startSpan(ctx, "FindHistory")
+	result, err := s.storage.FindHistory(ctx, eventID)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindUserHistory(
+	ctx context.Context,
+	userID, afterID int64,
+	limit int) ([]*storage.HistoryEntry, error) {
+	ctx, span := startSpan(ctx, "FindUserHistory")
+	result, err := s.storage.FindUserHistory(ctx, userID, afterID, limit)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) ApplyBatch(ctx context.Context, batch *storage.Batch) error {
+	ctx, span := startSpan(ctx, "ApplyBatch")
+	err := s.storage.ApplyBatch(ctx, batch)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) AddAttendee(ctx context.Context, attendee *storage.Attendee) (int64, error) {
+	ctx, span := startSpan(ctx, "AddAttendee")
+	result, err := s.storage.AddAttendee(ctx, attendee)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) UpdateAttendee(ctx context.Context, attendee *storage.Attendee) error {
+	ctx, span := startSpan(ctx, "UpdateAttendee")
+	err := s.storage.UpdateAttendee(ctx, attendee)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) FindAttendees(ctx context.Context, eventID int64) ([]*storage.Attendee, error) {
+	ctx, span := startSpan(ctx, "FindAttendees")
+	result, err := s.storage.FindAttendees(ctx, eventID)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindInvitations(ctx context.Context, userID int64) ([]*storage.Invitation, error) {
+	ctx, span := startSpan(ctx, "FindInvitations")
+	result, err := s.storage.FindInvitations(ctx, userID)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) FindUnNotifiedAttendees(ctx context.Context) ([]*storage.Invitation, error) {
+	ctx, span := startSpan(ctx, "FindUnNotifiedAttendees")
+	result, err := s.storage.FindUnNotifiedAttendees(ctx)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) MarkAttendeesNotified(ctx context.Context, ids []int64, outbox []*storage.OutboxMessage) error {
+	ctx, span := startSpan(ctx, "MarkAttendeesNotified")
+	err := s.storage.MarkAttendeesNotified(ctx, ids, outbox)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) FindOutbox(ctx context.Context, t time.Time, limit int) ([]*storage.OutboxMessage, error) {
+	ctx, span := startSpan(ctx, "FindOutbox")
+	result, err := s.storage.FindOutbox(ctx, t, limit)
+	done(span, err)
+
+	return result, err
+}
+
+func (s *EventStorage) DeleteOutbox(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "DeleteOutbox")
+	err := s.storage.DeleteOutbox(ctx, id)
+	done(span, err)
+
+	return err
+}
+
+func (s *EventStorage) RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time) error {
+	ctx, span := startSpan(ctx, "RetryOutbox")
+	err := s.storage.RetryOutbox(ctx, id, nextAttemptAt)
+	done(span, err)
+
+	return err
+}
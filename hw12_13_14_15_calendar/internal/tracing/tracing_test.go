@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue"
+	mockqueue "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/queue/mocks"
+	"github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	mockstorage "github.com/pustato/otus_home_work/hw12_13_14_15_calendar/internal/storage/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errTest = errors.New("test error")
+
+func setup(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	return recorder
+}
+
+func spanByName(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, s := range spans {
+		if s.Name() == name {
+			return s
+		}
+	}
+	require.Failf(t, "span is not recorded", "name %q", name)
+
+	return nil
+}
+
+func TestEventStorage(t *testing.T) {
+	recorder := setup(t)
+
+	storageMock := &mockstorage.EventStorage{}
+	storageMock.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+	storageMock.On("GetByID", mock.Anything, int64(2)).Return(nil, storage.ErrNotFound).Once()
+	storageMock.On("Delete", mock.Anything, int64(3), mock.Anything).Return(errTest).Once()
+	defer storageMock.AssertExpectations(t)
+
+	s := NewEventStorage(storageMock)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	event := &storage.Event{Reminders: []*storage.Reminder{{}, {}}}
+	_, err := s.Create(ctx, event, nil)
+	require.NoError(t, err)
+	span.End()
+
+	_, err = s.GetByID(context.Background(), 2)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	require.ErrorIs(t, s.Delete(context.Background(), 3, nil), errTest)
+
+	spans := recorder.Ended()
+	create := spanByName(t, spans, "storage.Create")
+	require.Equal(t, span.SpanContext().SpanID(), create.Parent().SpanID())
+
+	// the reminders continue the trace of the operation that saved them
+	for _, r := range event.Reminders {
+		require.Contains(t, r.TraceContext["traceparent"], create.SpanContext().TraceID().String())
+		require.Contains(t, r.TraceContext["traceparent"], create.SpanContext().SpanID().String())
+	}
+
+	require.Equal(t, codes.Unset, spanByName(t, spans, "storage.GetByID").Status().Code)
+	require.Equal(t, codes.Error, spanByName(t, spans, "storage.Delete").Status().Code)
+}
+
+func TestProducer(t *testing.T) {
+	t.Run("message continues trace of publisher", func(t *testing.T) {
+		recorder := setup(t)
+
+		var published *queue.Message
+		producerMock := &mockqueue.Producer{}
+		producerMock.On("Publish", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			published = args.Get(1).(*queue.Message)
+		}).Return(nil).Once()
+
+		ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+		m := &queue.Message{Key: "event_notification", Headers: map[string]string{"custom": "value"}}
+		require.NoError(t, NewProducer(producerMock).Publish(ctx, m))
+		span.End()
+
+		// the headers of the caller are not changed
+		require.Equal(t, map[string]string{"custom": "value"}, m.Headers)
+		require.Equal(t, "value", published.Headers["custom"])
+
+		h := MessageHandler(func(ctx context.Context, m *queue.Message) error {
+			return errTest
+		})
+		require.ErrorIs(t, h(context.Background(), published), errTest)
+
+		spans := recorder.Ended()
+		publish := spanByName(t, spans, "queue.publish event_notification")
+		consume := spanByName(t, spans, "queue.consume event_notification")
+
+		require.Equal(t, span.SpanContext().TraceID(), publish.SpanContext().TraceID())
+		require.Equal(t, span.SpanContext().SpanID(), publish.Parent().SpanID())
+		require.Equal(t, publish.SpanContext().TraceID(), consume.SpanContext().TraceID())
+		require.Equal(t, publish.SpanContext().SpanID(), consume.Parent().SpanID())
+		require.Equal(t, codes.Error, consume.Status().Code)
+	})
+
+	t.Run("relayed message continues trace of its headers", func(t *testing.T) {
+		recorder := setup(t)
+
+		producerMock := &mockqueue.Producer{}
+		producerMock.On("Publish", mock.Anything, mock.Anything).Return(nil).Once()
+
+		// the reminder was saved by the request, the relay task publishes it later
+		request, requestSpan := otel.Tracer("test").Start(context.Background(), "request")
+		requestSpan.End()
+		task := Task("relay_outbox", func(ctx context.Context) error {
+			return NewProducer(producerMock).Publish(ctx, &queue.Message{
+				Key:     "event_notification",
+				Headers: Inject(request),
+			})
+		})
+		require.NoError(t, task(context.Background()))
+
+		spans := recorder.Ended()
+		publish := spanByName(t, spans, "queue.publish event_notification")
+		relay := spanByName(t, spans, "scheduler.relay_outbox")
+
+		require.Equal(t, requestSpan.SpanContext().TraceID(), publish.SpanContext().TraceID())
+		require.Equal(t, requestSpan.SpanContext().SpanID(), publish.Parent().SpanID())
+		require.Len(t, publish.Links(), 1)
+		require.Equal(t, relay.SpanContext().SpanID(), publish.Links()[0].SpanContext.SpanID())
+	})
+}
+
+func TestInject(t *testing.T) {
+	setup(t)
+
+	require.Nil(t, Inject(context.Background()))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), Inject(ctx)))
+	require.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+	require.True(t, extracted.IsRemote())
+}
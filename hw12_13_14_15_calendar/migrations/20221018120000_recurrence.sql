@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE events ADD rrule TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD ex_dates TIMESTAMP[] NOT NULL DEFAULT '{}';
+ALTER TABLE events ADD recurrence_until TIMESTAMP NULL DEFAULT NULL;
+ALTER TABLE events ADD series_id BIGINT NULL DEFAULT NULL
+    CONSTRAINT events_series_id_fk REFERENCES events (id) ON DELETE CASCADE;
+ALTER TABLE events ADD recurrence_id TIMESTAMP NULL DEFAULT NULL;
+CREATE INDEX events_recurring_index ON events (user_id, time_start, recurrence_until) WHERE rrule <> '';
+CREATE UNIQUE INDEX events_series_id_recurrence_id_index ON events (series_id, recurrence_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX events_series_id_recurrence_id_index;
+DROP INDEX events_recurring_index;
+ALTER TABLE events DROP COLUMN recurrence_id;
+ALTER TABLE events DROP COLUMN series_id;
+ALTER TABLE events DROP COLUMN recurrence_until;
+ALTER TABLE events DROP COLUMN ex_dates;
+ALTER TABLE events DROP COLUMN rrule;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users
+(
+    id BIGSERIAL CONSTRAINT users_pk PRIMARY KEY,
+    login VARCHAR (100) NOT NULL CONSTRAINT users_login_unique UNIQUE,
+    password_hash TEXT NOT NULL,
+    token_hash VARCHAR (64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE UNIQUE INDEX users_token_hash_index ON users (token_hash) WHERE token_hash <> '';
+-- events created before users were introduced are not validated
+ALTER TABLE events ADD CONSTRAINT events_user_id_fk
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE NOT VALID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE events DROP CONSTRAINT events_user_id_fk;
+DROP TABLE IF EXISTS users;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE attendees
+(
+    id BIGSERIAL CONSTRAINT attendees_pk PRIMARY KEY,
+    event_id BIGINT NOT NULL CONSTRAINT attendees_event_id_fk REFERENCES events (id) ON DELETE CASCADE,
+    user_id BIGINT NULL DEFAULT NULL CONSTRAINT attendees_user_id_fk REFERENCES users (id) ON DELETE CASCADE,
+    email VARCHAR (254) NOT NULL DEFAULT '',
+    role VARCHAR (20) NOT NULL,
+    status VARCHAR (20) NOT NULL,
+    invitation_sent BOOLEAN NOT NULL DEFAULT FALSE,
+    response_sent BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT attendees_user_id_or_email CHECK ((user_id IS NULL) <> (email = ''))
+);
+CREATE UNIQUE INDEX attendees_event_id_user_id_index ON attendees (event_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX attendees_event_id_email_index ON attendees (event_id, email) WHERE email <> '';
+CREATE INDEX attendees_user_id_index ON attendees (user_id);
+CREATE INDEX attendees_unnotified_index ON attendees (id) WHERE invitation_sent = FALSE OR response_sent = FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS attendees;
+-- +goose StatementEnd
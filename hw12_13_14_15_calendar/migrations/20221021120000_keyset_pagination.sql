@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX events_user_id_time_start_id_index ON events (user_id, time_start, id) WHERE rrule = '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX events_user_id_time_start_id_index;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE events ADD search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || description)) STORED;
+CREATE INDEX events_search_vector_index ON events USING GIN (search_vector);
+CREATE INDEX events_user_id_updated_at_index ON events (user_id, updated_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX events_user_id_updated_at_index;
+DROP INDEX events_search_vector_index;
+ALTER TABLE events DROP COLUMN search_vector;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+-- existing timestamps were written in UTC
+SET LOCAL TIME ZONE 'UTC';
+ALTER TABLE events
+    ALTER time_start TYPE TIMESTAMPTZ,
+    ALTER time_end TYPE TIMESTAMPTZ,
+    ALTER notify_at TYPE TIMESTAMPTZ,
+    ALTER created_at TYPE TIMESTAMPTZ,
+    ALTER updated_at TYPE TIMESTAMPTZ,
+    ALTER ex_dates DROP DEFAULT,
+    ALTER ex_dates TYPE TIMESTAMPTZ[],
+    ALTER ex_dates SET DEFAULT '{}',
+    ALTER recurrence_until TYPE TIMESTAMPTZ,
+    ALTER recurrence_id TYPE TIMESTAMPTZ;
+ALTER TABLE events ADD time_zone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE users
+    ALTER created_at TYPE TIMESTAMPTZ,
+    ALTER updated_at TYPE TIMESTAMPTZ;
+ALTER TABLE users ADD time_zone TEXT NOT NULL DEFAULT 'UTC';
+-- 0 is Sunday as in time.Weekday
+ALTER TABLE users ADD week_start SMALLINT NOT NULL DEFAULT 0 CONSTRAINT users_week_start_check
+    CHECK (week_start BETWEEN 0 AND 6);
+ALTER TABLE attendees
+    ALTER created_at TYPE TIMESTAMPTZ,
+    ALTER updated_at TYPE TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SET LOCAL TIME ZONE 'UTC';
+ALTER TABLE attendees
+    ALTER created_at TYPE TIMESTAMP,
+    ALTER updated_at TYPE TIMESTAMP;
+ALTER TABLE users DROP COLUMN week_start;
+ALTER TABLE users DROP COLUMN time_zone;
+ALTER TABLE users
+    ALTER created_at TYPE TIMESTAMP,
+    ALTER updated_at TYPE TIMESTAMP;
+ALTER TABLE events DROP COLUMN time_zone;
+ALTER TABLE events
+    ALTER time_start TYPE TIMESTAMP,
+    ALTER time_end TYPE TIMESTAMP,
+    ALTER notify_at TYPE TIMESTAMP,
+    ALTER created_at TYPE TIMESTAMP,
+    ALTER updated_at TYPE TIMESTAMP,
+    ALTER ex_dates DROP DEFAULT,
+    ALTER ex_dates TYPE TIMESTAMP[],
+    ALTER ex_dates SET DEFAULT '{}',
+    ALTER recurrence_until TYPE TIMESTAMP,
+    ALTER recurrence_id TYPE TIMESTAMP;
+-- +goose StatementEnd
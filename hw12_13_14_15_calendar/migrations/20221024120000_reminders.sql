@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE reminders
+(
+    id BIGSERIAL CONSTRAINT reminders_pk PRIMARY KEY,
+    event_id BIGINT NOT NULL CONSTRAINT reminders_event_id_fk REFERENCES events (id) ON DELETE CASCADE,
+    channel VARCHAR (20) NOT NULL,
+    before_seconds BIGINT NOT NULL,
+    notify_at TIMESTAMPTZ NOT NULL,
+    sent BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT reminders_event_id_channel_before_unique UNIQUE (event_id, channel, before_seconds)
+);
+CREATE INDEX reminders_unsent_notify_at_index ON reminders (notify_at) WHERE sent = FALSE;
+-- the sender only logged notifications before channels were introduced
+INSERT INTO reminders (event_id, channel, before_seconds, notify_at, sent, created_at, updated_at)
+SELECT
+    id, 'log', EXTRACT(EPOCH FROM time_start - notify_at)::BIGINT, notify_at, COALESCE(notification_sent, FALSE),
+    created_at, updated_at
+FROM
+    events
+WHERE
+    notify_at IS NOT NULL;
+DROP INDEX events_notify_at_notification_sent_index;
+ALTER TABLE events DROP COLUMN notify_at;
+ALTER TABLE events DROP COLUMN notification_sent;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE events ADD notify_at TIMESTAMPTZ NULL DEFAULT NULL;
+ALTER TABLE events ADD notification_sent BOOLEAN DEFAULT FALSE;
+-- only the earliest reminder of the event is kept
+UPDATE
+    events
+SET
+    notify_at = r.notify_at,
+    notification_sent = r.sent
+FROM
+    (SELECT DISTINCT ON (event_id) event_id, notify_at, sent FROM reminders ORDER BY event_id, notify_at) r
+WHERE
+    r.event_id = events.id;
+CREATE INDEX events_notify_at_notification_sent_index ON events (notify_at, notification_sent);
+DROP TABLE IF EXISTS reminders;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE outbox
+(
+    id BIGSERIAL CONSTRAINT outbox_pk PRIMARY KEY,
+    key VARCHAR (100) NOT NULL,
+    idempotency_key VARCHAR (255) NOT NULL CONSTRAINT outbox_idempotency_key_unique UNIQUE,
+    payload BYTEA NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX outbox_next_attempt_at_index ON outbox (next_attempt_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
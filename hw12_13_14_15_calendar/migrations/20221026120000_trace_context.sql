@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE reminders ADD trace_context JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE outbox ADD headers JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE outbox DROP COLUMN headers;
+ALTER TABLE reminders DROP COLUMN trace_context;
+-- +goose StatementEnd
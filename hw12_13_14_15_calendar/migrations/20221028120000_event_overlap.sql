@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+ALTER TABLE events ADD non_blocking BOOLEAN NOT NULL DEFAULT FALSE;
+-- the events which overlap an earlier event of the user were saved before the constraint,
+-- the migration fails on them unless it is run with `migrate --resolve-overlaps`,
+-- then they are kept but do not block the time anymore
+DO $$
+DECLARE
+    overlapping BIGINT[];
+BEGIN
+    SELECT array_agg(e.id ORDER BY e.id) INTO overlapping FROM events e
+    WHERE e.rrule = '' AND EXISTS (
+        SELECT 1 FROM events o
+        WHERE o.user_id = e.user_id
+            AND o.rrule = ''
+            AND o.id < e.id
+            AND tstzrange(o.time_start, o.time_end) && tstzrange(e.time_start, e.time_end)
+    );
+    IF overlapping IS NULL THEN
+        RETURN;
+    END IF;
+
+    IF coalesce(current_setting('calendar.resolve_overlaps', true), '') <> 'on' THEN
+        RAISE EXCEPTION '% events overlap earlier events of their users, the first ones are %, '
+            'run `migrate --resolve-overlaps` to mark them non-blocking or resolve them by hand',
+            cardinality(overlapping), overlapping[1:20];
+    END IF;
+
+    UPDATE events SET non_blocking = TRUE WHERE id = ANY(overlapping);
+    RAISE NOTICE '% overlapping events are marked non-blocking: %', cardinality(overlapping), overlapping;
+END
+$$;
+-- the series are expanded by the application, the constraint covers the one-off events and the exceptions
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    user_id WITH =,
+    tstzrange(time_start, time_end) WITH &&
+) WHERE (rrule = '' AND NOT non_blocking);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events DROP COLUMN non_blocking;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE events ADD deleted_at TIMESTAMPTZ NULL DEFAULT NULL;
+CREATE INDEX events_user_id_deleted_at_id_index ON events (user_id, deleted_at, id) WHERE deleted_at IS NOT NULL;
+-- the deleted events do not take the time
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    user_id WITH =,
+    tstzrange(time_start, time_end) WITH &&
+) WHERE (rrule = '' AND NOT non_blocking AND deleted_at IS NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM events WHERE deleted_at IS NOT NULL;
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    user_id WITH =,
+    tstzrange(time_start, time_end) WITH &&
+) WHERE (rrule = '' AND NOT non_blocking);
+DROP INDEX events_user_id_deleted_at_id_index;
+ALTER TABLE events DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the history is append-only, it is kept after the event is purged or deleted by the retention
+CREATE TABLE event_history
+(
+    id BIGSERIAL CONSTRAINT event_history_pk PRIMARY KEY,
+    event_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    action VARCHAR (20) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX event_history_event_id_index ON event_history (event_id, id);
+CREATE INDEX event_history_user_id_index ON event_history (user_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS event_history;
+-- +goose StatementEnd